@@ -1,35 +1,74 @@
 package domino
 
 import (
+	"bufio"
+	"bytes"
+	"container/list"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
 	"math"
+	"math/big"
+	"math/rand"
+	"net/http"
+	"os"
 	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 )
 
-/*DynamoDBIFace is the interface to the underlying aws dynamo db api*/
-type DynamoDBIFace interface {
-	CreateTableWithContext(aws.Context, *dynamodb.CreateTableInput, ...request.Option) (*dynamodb.CreateTableOutput, error)
-	DeleteTableWithContext(aws.Context, *dynamodb.DeleteTableInput, ...request.Option) (*dynamodb.DeleteTableOutput, error)
+/*DynamoReader is the part of the dynamo db api that reads items*/
+type DynamoReader interface {
 	GetItemWithContext(aws.Context, *dynamodb.GetItemInput, ...request.Option) (*dynamodb.GetItemOutput, error)
 	BatchGetItemWithContext(aws.Context, *dynamodb.BatchGetItemInput, ...request.Option) (*dynamodb.BatchGetItemOutput, error)
-	PutItemWithContext(aws.Context, *dynamodb.PutItemInput, ...request.Option) (*dynamodb.PutItemOutput, error)
 	QueryWithContext(aws.Context, *dynamodb.QueryInput, ...request.Option) (*dynamodb.QueryOutput, error)
 	ScanWithContext(aws.Context, *dynamodb.ScanInput, ...request.Option) (*dynamodb.ScanOutput, error)
+	TransactGetItemsWithContext(aws.Context, *dynamodb.TransactGetItemsInput, ...request.Option) (*dynamodb.TransactGetItemsOutput, error)
+}
+
+/*DynamoWriter is the part of the dynamo db api that writes items*/
+type DynamoWriter interface {
+	PutItemWithContext(aws.Context, *dynamodb.PutItemInput, ...request.Option) (*dynamodb.PutItemOutput, error)
 	UpdateItemWithContext(aws.Context, *dynamodb.UpdateItemInput, ...request.Option) (*dynamodb.UpdateItemOutput, error)
 	DeleteItemWithContext(aws.Context, *dynamodb.DeleteItemInput, ...request.Option) (*dynamodb.DeleteItemOutput, error)
 	BatchWriteItemWithContext(aws.Context, *dynamodb.BatchWriteItemInput, ...request.Option) (*dynamodb.BatchWriteItemOutput, error)
-	TransactGetItemsWithContext(aws.Context, *dynamodb.TransactGetItemsInput, ...request.Option) (*dynamodb.TransactGetItemsOutput, error)
 	TransactWriteItemsWithContext(aws.Context, *dynamodb.TransactWriteItemsInput, ...request.Option) (*dynamodb.TransactWriteItemsOutput, error)
 }
 
+/*DynamoAdmin is the part of the dynamo db api that manages tables*/
+type DynamoAdmin interface {
+	CreateTableWithContext(aws.Context, *dynamodb.CreateTableInput, ...request.Option) (*dynamodb.CreateTableOutput, error)
+	DeleteTableWithContext(aws.Context, *dynamodb.DeleteTableInput, ...request.Option) (*dynamodb.DeleteTableOutput, error)
+	DescribeTableWithContext(aws.Context, *dynamodb.DescribeTableInput, ...request.Option) (*dynamodb.DescribeTableOutput, error)
+	UpdateTableWithContext(aws.Context, *dynamodb.UpdateTableInput, ...request.Option) (*dynamodb.UpdateTableOutput, error)
+	DescribeLimitsWithContext(aws.Context, *dynamodb.DescribeLimitsInput, ...request.Option) (*dynamodb.DescribeLimitsOutput, error)
+}
+
+/*DynamoDBIFace is the interface to the underlying aws dynamo db api*/
+type DynamoDBIFace interface {
+	DynamoReader
+	DynamoWriter
+	DynamoAdmin
+}
+
 type DynamoDBValue map[string]*dynamodb.AttributeValue
 
 // Loader is the interface that specifies the ability to deserialize and load data from dynamodb attrbiute value map
@@ -37,8 +76,101 @@ type Loader interface {
 	LoadDynamoDBValue(av DynamoDBValue) (err error)
 }
 
-func deserializeTo(av DynamoDBValue, item interface{}) (err error) {
-	if len(av) <= 0 {
+/*GetString ... The string attribute field. False if it's missing or not a string*/
+func (v DynamoDBValue) GetString(field string) (string, bool) {
+	if av := v[field]; av != nil && av.S != nil {
+		return *av.S, true
+	}
+	return "", false
+}
+
+/*GetInt ... The number attribute field as an integer. False if it's missing, not a number, or not a whole int64*/
+func (v DynamoDBValue) GetInt(field string) (int64, bool) {
+	av := v[field]
+	if av == nil || av.N == nil {
+		return 0, false
+	}
+	if i, err := strconv.ParseInt(*av.N, 10, 64); err == nil {
+		return i, true
+	}
+	// Dynamo preserves the number as written, so whole numbers may carry a fraction or exponent, i.e. 1.0 or 1e3
+	f, ok := new(big.Float).SetPrec(256).SetString(*av.N)
+	if !ok || !f.IsInt() {
+		return 0, false
+	}
+	i, acc := f.Int64()
+	return i, acc == big.Exact
+}
+
+/*GetFloat ... The number attribute field as a float. False if it's missing, not a number, or out of range*/
+func (v DynamoDBValue) GetFloat(field string) (float64, bool) {
+	av := v[field]
+	if av == nil || av.N == nil {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(*av.N, 64)
+	return f, err == nil
+}
+
+/*GetBool ... The boolean attribute field. False if it's missing or not a boolean*/
+func (v DynamoDBValue) GetBool(field string) (value bool, ok bool) {
+	if av := v[field]; av != nil && av.BOOL != nil {
+		return *av.BOOL, true
+	}
+	return false, false
+}
+
+/*GetBytes ... The binary attribute field. False if it's missing or not binary*/
+func (v DynamoDBValue) GetBytes(field string) ([]byte, bool) {
+	if av := v[field]; av != nil && av.B != nil {
+		return av.B, true
+	}
+	return nil, false
+}
+
+/*GetStringSet ... The string set attribute field. False if it's missing or not a string set*/
+func (v DynamoDBValue) GetStringSet(field string) ([]string, bool) {
+	if av := v[field]; av != nil && av.SS != nil {
+		return aws.StringValueSlice(av.SS), true
+	}
+	return nil, false
+}
+
+/*GetMap ... The map attribute field. False if it's missing or not a map*/
+func (v DynamoDBValue) GetMap(field string) (DynamoDBValue, bool) {
+	if av := v[field]; av != nil && av.M != nil {
+		return DynamoDBValue(av.M), true
+	}
+	return nil, false
+}
+
+/*Key ... The item's primary key in table. Numbers are returned as dynamodbattribute.Number, so they marshal unchanged*/
+func (v DynamoDBValue) Key(table DynamoTable) KeyValue {
+	k := KeyValue{PartitionKey: keyAttribute(v[table.PartitionKey.Name()])}
+	if table.RangeKey != nil && !table.RangeKey.IsEmpty() {
+		k.RangeKey = keyAttribute(v[table.RangeKey.Name()])
+	}
+	return k
+}
+
+/*keyAttribute is the go value of a string, number or binary key attribute*/
+func keyAttribute(av *dynamodb.AttributeValue) interface{} {
+	switch {
+	case av == nil:
+		return nil
+	case av.S != nil:
+		return *av.S
+	case av.N != nil:
+		return dynamodbattribute.Number(*av.N)
+	case av.B != nil:
+		return av.B
+	}
+	return nil
+}
+
+/*deserializeTo unmarshals av into item, with the table's decoder if one is configured*/
+func deserializeTo(decoder *dynamodbattribute.Decoder, av DynamoDBValue, item interface{}) (err error) {
+	if err = checkTarget(item); err != nil || len(av) <= 0 {
 		return
 	}
 
@@ -46,24 +178,194 @@ func deserializeTo(av DynamoDBValue, item interface{}) (err error) {
 	case Loader:
 		err = t.LoadDynamoDBValue(av)
 	default:
-		err = dynamodbattribute.UnmarshalMap(av, item)
+		if decoder == nil {
+			decoder = defaultDecoder
+		}
+		if v := reflect.ValueOf(item); v.Kind() == reflect.Map && !v.IsNil() {
+			/*The sdk only decodes through pointers, but a map, i.e. map[string]interface{}, can be filled in place*/
+			decoded := reflect.New(v.Type())
+			if err = decoder.Decode(&dynamodb.AttributeValue{M: av}, decoded.Interface()); err == nil {
+				for _, k := range decoded.Elem().MapKeys() {
+					v.SetMapIndex(k, decoded.Elem().MapIndex(k))
+				}
+			}
+			return
+		}
+		if fields := decodableFields(reflect.TypeOf(item), decoder.MarshalOptions); fields != nil {
+			err = fields.decode(decoder, av, reflect.ValueOf(item).Elem())
+		} else {
+			err = decoder.Decode(&dynamodb.AttributeValue{M: av}, item)
+		}
 	}
 	return
 }
 
+/*checkTarget rejects deserialization targets that can't be filled in: values, nil pointers and nil maps*/
+func checkTarget(item interface{}) error {
+	v := reflect.ValueOf(item)
+	switch {
+	case !v.IsValid():
+		return errors.New("domino: target must be a non-nil pointer, got nil")
+	case v.Kind() == reflect.Ptr || v.Kind() == reflect.Map:
+		if v.IsNil() {
+			return fmt.Errorf("domino: target must be a non-nil pointer, got nil %T", item)
+		}
+		return nil
+	case v.Type().Implements(loaderType):
+		return nil
+	}
+	return fmt.Errorf("domino: target must be a non-nil pointer, got %T", item)
+}
+
+var loaderType = reflect.TypeOf((*Loader)(nil)).Elem()
+
+/*ItemError ... A result that couldn't be deserialized, with the key of the item and the page, or batch, it was read in*/
+type ItemError struct {
+	Key  DynamoDBValue //The item's key attributes, including index keys when reading an index
+	Page int           //Zero based, counting the pages or batches read by the failing call
+	Err  error
+}
+
+func (e *ItemError) Error() string {
+	return fmt.Sprintf("item %s (page %d): %v", debugValue(&dynamodb.AttributeValue{M: e.Key}), e.Page, e.Err)
+}
+
+func (e *ItemError) Unwrap() error {
+	return e.Err
+}
+
+/*decodeItem is deserializeTo, wrapping decoding errors in an ItemError. Bad targets are the caller's fault, and returned as is*/
+func decodeItem(decoder *dynamodbattribute.Decoder, av DynamoDBValue, item interface{}, keyOf func(DynamoDBValue) DynamoDBValue, page int) error {
+	if err := checkTarget(item); err != nil {
+		return err
+	}
+	if err := deserializeTo(decoder, av, item); err != nil {
+		return &ItemError{Key: keyOf(av), Page: page, Err: err}
+	}
+	return nil
+}
+
+/*defaultDecoder is shared by tables without a decoder of their own, rather than allocating one per item*/
+var defaultDecoder = dynamodbattribute.NewDecoder()
+
+/**
+ ** structFields caches the attribute to field mapping of flat structs. The sdk recomputes it, allocating heavily, for
+ ** every item it decodes into a struct. Structs with embedded fields, or fields tagged string or unixtime, aren't
+ ** cached and are left to the sdk.
+ */
+type structFields struct {
+	names []string
+	index map[string]int
+}
+
+type structFieldsKey struct {
+	t       reflect.Type
+	tagKey  string
+	useJSON bool
+}
+
+var structFieldsCache sync.Map
+
+var unmarshalerType = reflect.TypeOf((*dynamodbattribute.Unmarshaler)(nil)).Elem()
+
+/*decodableFields returns the cached fields of t, a struct pointer, or nil if the sdk must decode it*/
+func decodableFields(t reflect.Type, opts dynamodbattribute.MarshalOptions) *structFields {
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct || t.Implements(unmarshalerType) {
+		return nil
+	}
+	key := structFieldsKey{t.Elem(), opts.TagKey, opts.SupportJSONTags}
+	if cached, ok := structFieldsCache.Load(key); ok {
+		return cached.(*structFields)
+	}
+	fields := newStructFields(key)
+	structFieldsCache.Store(key, fields)
+	return fields
+}
+
+func newStructFields(key structFieldsKey) *structFields {
+	fields := &structFields{index: map[string]int{}}
+	for i := 0; i < key.t.NumField(); i++ {
+		sf := key.t.Field(i)
+		if sf.Anonymous {
+			return nil
+		}
+		if sf.PkgPath != "" {
+			fields.names = append(fields.names, "")
+			continue
+		}
+
+		// Mirror the sdk's tag precedence: dynamodbav, then the configured tag key, then json
+		tag := sf.Tag.Get("dynamodbav")
+		if tag == "" && key.tagKey != "" {
+			tag = sf.Tag.Get(key.tagKey)
+		} else if tag == "" && key.useJSON {
+			tag = sf.Tag.Get("json")
+		}
+		parts := strings.Split(tag, ",")
+		for _, opt := range parts[1:] {
+			if opt == "string" || opt == "unixtime" {
+				return nil
+			}
+		}
+
+		name := parts[0]
+		if name == "-" {
+			fields.names = append(fields.names, "")
+			continue
+		} else if name == "" {
+			name = sf.Name
+		}
+		if _, ok := fields.index[name]; ok {
+			// The sdk drops fields with clashing names
+			return nil
+		}
+		fields.index[name] = i
+		fields.names = append(fields.names, name)
+	}
+	return fields
+}
+
+/*decode sets each field of v named in av, matching names exactly before falling back to case insensitively*/
+func (fields *structFields) decode(decoder *dynamodbattribute.Decoder, av DynamoDBValue, v reflect.Value) error {
+	for name, a := range av {
+		i, ok := fields.index[name]
+		if !ok {
+			i = -1
+			for j, n := range fields.names {
+				if n != "" && strings.EqualFold(n, name) {
+					i = j
+					break
+				}
+			}
+			if i < 0 {
+				continue
+			}
+		}
+		if err := decoder.Decode(a, v.Field(i).Addr().Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // ToValue is the interface that specifies the ability to serialize data to a value that can be persisted in dynamodb
 type ToValue interface {
 	ToDynamoDBValue() (bm interface{})
 }
 
-func serialize(item interface{}) (av map[string]*dynamodb.AttributeValue, err error) {
-	switch t := item.(type) {
-	case ToValue:
-		av, err = dynamodbattribute.MarshalMap(t.ToDynamoDBValue())
-	default:
-		av, err = dynamodbattribute.MarshalMap(item)
+/*serialize marshals item, with the table's encoder if one is configured*/
+func serialize(encoder *dynamodbattribute.Encoder, item interface{}) (av map[string]*dynamodb.AttributeValue, err error) {
+	if t, ok := item.(ToValue); ok {
+		item = t.ToDynamoDBValue()
 	}
-	return
+	if encoder == nil {
+		return dynamodbattribute.MarshalMap(item)
+	}
+	v, err := encoder.Encode(item)
+	if err != nil {
+		return
+	}
+	return v.M, nil
 }
 
 func marshal(m map[string]interface{}) (o map[string]*dynamodb.AttributeValue) {
@@ -111,6 +413,12 @@ const (
 
 var (
 	BatchSizeExceededError = errors.New("TransactItems batch size maximum of 10 exceeded. Reduce the number of items to write.")
+	/*ErrCapacityBudgetExceeded stops a query or scan that has consumed its SetCapacityBudget. Resume from its LastEvaluatedKey*/
+	ErrCapacityBudgetExceeded = errors.New("Capacity budget exceeded. Resume from the output's LastEvaluatedKey.")
+	MissingCounterError       = errors.New("Updated counter attribute missing from the UpdateItem response.")
+	ErrVersionConflict        = awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "Item version does not match the expected version.", nil)
+	/*ErrItemDeleted is returned by GetOrCreate when the item another writer created is deleted before it's read back*/
+	ErrItemDeleted = errors.New("Item was deleted before it could be read back. Retry GetOrCreate.")
 )
 
 /*DynamoTable is a static table definition representing a dynamo table*/
@@ -120,6 +428,285 @@ type DynamoTable struct {
 	RangeKey               DynamoFieldIFace //Optional param. If no range key set to EmptyDynamoField()
 	GlobalSecondaryIndexes []GlobalSecondaryIndex
 	LocalSecondaryIndexes  []LocalSecondaryIndex
+	Encoder                *dynamodbattribute.Encoder //Optional. Marshals items written to the table. Defaults to dynamodbattribute.MarshalMap
+	Decoder                *dynamodbattribute.Decoder //Optional. Unmarshals items read from the table. Defaults to dynamodbattribute.UnmarshalMap
+	Defaults               TableDefaults
+	sanitizeWrites         bool
+	timestamps             *timestamps
+	hooks                  *writeHooks
+	sharded                *ShardedPartition
+	batchConcurrency       int
+}
+
+type timestamps struct {
+	created Numeric
+	updated Numeric
+	clock   func() time.Time
+}
+
+/*TableDefaults ... Request defaults applied by GetItem, BatchGetItem, Query and Scan. Builders can override them per call*/
+type TableDefaults struct {
+	ConsistentRead         bool
+	ReturnConsumedCapacity string           //Optional. INDEXES, TOTAL or NONE. Defaults to INDEXES for GetItem and BatchGetItem
+	Options                []request.Option //Applied before the options passed to ExecuteWith
+}
+
+/*KeyOf ... Extract the primary key of an item, i.e. a domain object this table stores*/
+func (table DynamoTable) KeyOf(item interface{}) (key KeyValue, err error) {
+	av, err := serialize(table.Encoder, item)
+	if err != nil {
+		return
+	}
+	decoder := dynamodbattribute.NewDecoder(func(d *dynamodbattribute.Decoder) {
+		d.UseNumber = true
+	})
+	value := func(name string) (v interface{}, err error) {
+		a, ok := av[name]
+		if !ok || (a.NULL != nil && *a.NULL) {
+			return nil, fmt.Errorf("KeyOf %s: item is missing key attribute %s.", table.Name, name)
+		}
+		err = decoder.Decode(a, &v)
+		return
+	}
+
+	if key.PartitionKey, err = value(table.PartitionKey.Name()); err != nil {
+		return
+	}
+	if table.RangeKey != nil && !table.RangeKey.IsEmpty() {
+		key.RangeKey, err = value(table.RangeKey.Name())
+	}
+	return
+}
+
+/*validateKey checks that key supplies exactly the key attributes the table's schema requires*/
+func (table DynamoTable) validateKey(op string, key KeyValue) error {
+	hasRangeKey := table.RangeKey != nil && !table.RangeKey.IsEmpty()
+	if !hasRangeKey && key.PartitionKey == nil && key.RangeKey != nil {
+		return fmt.Errorf("%s %s: table has no range key, but %s was given in its place. Use PK(%s).", op, table.Name, debugKey(key.RangeKey), debugKey(key.RangeKey))
+	}
+	if isMissingKey(key.PartitionKey) {
+		return fmt.Errorf("%s %s: missing partition key %s.", op, table.Name, table.PartitionKey.Name())
+	}
+	if err := checkKeyType(op, table.Name, "partition", table.PartitionKey, key.PartitionKey); err != nil {
+		return err
+	}
+	if hasRangeKey {
+		if isMissingKey(key.RangeKey) {
+			return fmt.Errorf("%s %s: missing range key %s.", op, table.Name, table.RangeKey.Name())
+		}
+		return checkKeyType(op, table.Name, "range", table.RangeKey, key.RangeKey)
+	} else if key.RangeKey != nil {
+		return fmt.Errorf("%s %s: table has no range key, but %s was given.", op, table.Name, debugKey(key.RangeKey))
+	}
+	return nil
+}
+
+/**
+ ** checkKeyType rejects binary values for non binary key fields and vice versa. Dynamo would fail the request with a type
+ ** mismatch, but only once it is sent, and binary values are easy to pass by accident as strings, i.e. string(hash)
+ */
+func checkKeyType(op, name, kind string, field DynamoFieldIFace, v interface{}) error {
+	av, err := dynamodbattribute.Marshal(v)
+	if err != nil || (field.Type() == dB) == (av.B != nil) {
+		/*Marshaling errors are reported when the key is built*/
+		return nil
+	}
+	return fmt.Errorf("%s %s: %s key %s has type %s, but %s was given.", op, name, kind, field.Name(), field.Type(), debugKey(v))
+}
+
+/*debugKey renders a key value for errors, so binary keys read as hex rather than a list of bytes*/
+func debugKey(v interface{}) string {
+	if av, err := dynamodbattribute.Marshal(v); err == nil && av.B != nil {
+		return debugBinary(av.B)
+	}
+	return fmt.Sprint(v)
+}
+
+/*isMissingKey reports nil and empty string or binary values, which dynamo never accepts as keys*/
+func isMissingKey(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	r := reflect.ValueOf(v)
+	switch r.Kind() {
+	case reflect.Ptr:
+		return r.IsNil() || isMissingKey(r.Elem().Interface())
+	case reflect.String, reflect.Slice:
+		return r.Len() == 0
+	}
+	return false
+}
+
+/*withDefaultOptions puts the table's default request options ahead of opts, so per call options win*/
+func withDefaultOptions(defaults []request.Option, opts []request.Option) []request.Option {
+	if len(defaults) <= 0 {
+		return opts
+	}
+	return append(append([]request.Option(nil), defaults...), opts...)
+}
+
+/*SanitizeWrites ... Returns a copy of the table that strips empty and NULL attributes, other than keys, from put items*/
+func (table DynamoTable) SanitizeWrites() DynamoTable {
+	table.sanitizeWrites = true
+	return table
+}
+
+/*WithBatchConcurrency ... Returns a copy of the table whose BatchDelete sends up to n batches at once. Defaults to 1*/
+func (table DynamoTable) WithBatchConcurrency(n int) DynamoTable {
+	table.batchConcurrency = n
+	return table
+}
+
+/**
+ ** WithTimestamps ... Stamp writes with the clock's time, in epoch seconds. Puts set both fields, and updates set updated
+ ** and, if_not_exists, created. Values the item or update already sets are left as is.
+ ** clock - Defaults to time.Now
+ */
+func (table DynamoTable) WithTimestamps(created Numeric, updated Numeric, clock func() time.Time) DynamoTable {
+	if clock == nil {
+		clock = time.Now
+	}
+	table.timestamps = &timestamps{created, updated, clock}
+	return table
+}
+
+/*stamp copies item, setting the timestamp fields it doesn't have*/
+func (t *timestamps) stamp(item DynamoDBValue) DynamoDBValue {
+	now := &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(t.clock().Unix(), 10))}
+	stamped := make(DynamoDBValue, len(item)+2)
+	for k, v := range item {
+		stamped[k] = v
+	}
+	for _, f := range []Numeric{t.created, t.updated} {
+		if v, ok := stamped[f.Name()]; !ok || v == nil || v.NULL != nil {
+			stamped[f.Name()] = now
+		}
+	}
+	return stamped
+}
+
+/*stampUpdate sets the timestamp fields an update doesn't already set*/
+func (t *timestamps) stampUpdate(d *UpdateInput) error {
+	sets := func(f Numeric) bool {
+		for _, clause := range d.updateClauses["SET"] {
+			// The attribute may be referenced by a name placeholder, as DiffUpdate and MapPath do
+			path := strings.SplitN(clause, " = ", 2)[0]
+			if name, ok := d.input.ExpressionAttributeNames[path]; ok {
+				path = aws.StringValue(name)
+			}
+			if path == f.Name() {
+				return true
+			}
+		}
+		return false
+	}
+	now := t.clock().Unix()
+	var exprs []*UpdateExpression
+	if !sets(t.updated) {
+		exprs = append(exprs, t.updated.SetField(now, false))
+	}
+	if !sets(t.created) {
+		exprs = append(exprs, t.created.SetField(now, true))
+	}
+	if len(exprs) > 0 {
+		d.AddUpdateExpression(exprs...)
+	}
+	return nil
+}
+
+type writeHooks struct {
+	beforePut    []func(DynamoDBValue) error
+	beforeUpdate []func(*dynamodb.UpdateItemInput) error
+	afterWrite   []func(string, DynamoDBValue, error)
+}
+
+/*withHooks copies the table with a copy of its hooks, for f to add to*/
+func (table DynamoTable) withHooks(f func(*writeHooks)) DynamoTable {
+	h := writeHooks{}
+	if table.hooks != nil {
+		h.beforePut = append(h.beforePut, table.hooks.beforePut...)
+		h.beforeUpdate = append(h.beforeUpdate, table.hooks.beforeUpdate...)
+		h.afterWrite = append(h.afterWrite, table.hooks.afterWrite...)
+	}
+	f(&h)
+	table.hooks = &h
+	return table
+}
+
+/*BeforePut ... Check or amend each item put, including batch puts. An error aborts the call before it's sent*/
+func (table DynamoTable) BeforePut(f func(item DynamoDBValue) error) DynamoTable {
+	return table.withHooks(func(h *writeHooks) { h.beforePut = append(h.beforePut, f) })
+}
+
+/*BeforeUpdate ... Check or amend each built update. An error aborts the call before it's sent*/
+func (table DynamoTable) BeforeUpdate(f func(*dynamodb.UpdateItemInput) error) DynamoTable {
+	return table.withHooks(func(h *writeHooks) { h.beforeUpdate = append(h.beforeUpdate, f) })
+}
+
+/**
+ ** AfterWrite ... Observe each PutItem, UpdateItem and DeleteItem, and each item of a batch write, once sent.
+ ** op is the operation, i.e. PutItem, and key the item's primary key. Unprocessed batch items aren't reported.
+ */
+func (table DynamoTable) AfterWrite(f func(op string, key DynamoDBValue, err error)) DynamoTable {
+	return table.withHooks(func(h *writeHooks) { h.afterWrite = append(h.afterWrite, f) })
+}
+
+/*put runs the put hooks on a copy of item, so a builder executed twice isn't amended twice, and returns the copy*/
+func (h *writeHooks) put(item DynamoDBValue) (DynamoDBValue, error) {
+	if h == nil || len(h.beforePut) <= 0 {
+		return item, nil
+	}
+	item = cloneValue(item)
+	for _, f := range h.beforePut {
+		if err := f(item); err != nil {
+			return nil, err
+		}
+	}
+	return item, nil
+}
+
+/*update runs the update hooks. Build only copies the input shallowly, so the maps they may amend are copied first*/
+func (h *writeHooks) update(input *dynamodb.UpdateItemInput) error {
+	if h == nil || len(h.beforeUpdate) <= 0 {
+		return nil
+	}
+	input.Key = cloneValue(input.Key)
+	input.ExpressionAttributeNames = cloneNames(input.ExpressionAttributeNames)
+	input.ExpressionAttributeValues = cloneValue(input.ExpressionAttributeValues)
+	for _, f := range h.beforeUpdate {
+		if err := f(input); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *writeHooks) after(op string, key DynamoDBValue, err error) {
+	if h == nil {
+		return
+	}
+	for _, f := range h.afterWrite {
+		f(op, key, err)
+	}
+}
+
+/*preparePut readies an item to be put outside of PutItem: stamped, sanitized and through the before put hooks*/
+func (table DynamoTable) preparePut(item DynamoDBValue) (DynamoDBValue, error) {
+	if table.timestamps != nil {
+		item = table.timestamps.stamp(item)
+	}
+	if table.sanitizeWrites {
+		item = sanitize(item, table.keyNames())
+	}
+	return table.hooks.put(item)
+}
+
+/*keyNames are the table's primary key attribute names*/
+func (table DynamoTable) keyNames() []string {
+	if table.RangeKey == nil || table.RangeKey.IsEmpty() {
+		return []string{table.PartitionKey.Name()}
+	}
+	return []string{table.PartitionKey.Name(), table.RangeKey.Name()}
 }
 
 type DynamoFieldIFace interface {
@@ -144,6 +731,7 @@ type dynamoCollectionField struct {
 
 type dynamoListField struct {
 	dynamoCollectionField
+	orEmpty bool //If true, list operations treat a missing attribute as an empty list
 }
 type dynamoSetField struct {
 	dynamoCollectionField
@@ -171,6 +759,7 @@ type Empty struct {
 /*Numeric - A numeric dynamo field*/
 type Numeric struct {
 	dynamoValueField
+	epoch TimeEncoding //Unit of timestamps stored in the field. Defaults to EpochSeconds
 }
 
 /*NumericSet - A numeric set dynamo field*/
@@ -181,6 +770,7 @@ type NumericSet struct {
 /*String - A string dynamo field*/
 type String struct {
 	dynamoValueField
+	separator string //Joins composite key segments. Defaults to DefaultCompositeSeparator
 }
 
 /*StringSet - A string set dynamo field*/
@@ -208,6 +798,22 @@ type List struct {
 	dynamoListField
 }
 
+/*Time - A timestamp dynamo field, stored as a number or a string depending on its encoding*/
+type Time struct {
+	dynamoValueField
+	encoding TimeEncoding
+}
+
+/*TimeEncoding - How a Time field is represented in dynamo*/
+type TimeEncoding int
+
+const (
+	EpochSeconds TimeEncoding = iota
+	EpochMillis
+	EpochNanos
+	RFC3339 //UTC, with fixed width nanoseconds so values sort lexically
+)
+
 /*Map - A map dynamo field*/
 type Map struct {
 	dynamoMapField
@@ -226,7 +832,7 @@ func EmptyField() Empty {
 /*NumericField ... A constructor for a numeric dynamo field*/
 func NumericField(name string) Numeric {
 	return Numeric{
-		dynamoValueField{
+		dynamoValueField: dynamoValueField{
 			DynamoField{
 				name:  name,
 				_type: dN,
@@ -252,7 +858,7 @@ func NumericSetField(name string) NumericSet {
 /*StringField ... A constructor for a string dynamo field*/
 func StringField(name string) String {
 	return String{
-		dynamoValueField{
+		dynamoValueField: dynamoValueField{
 			DynamoField{
 				name:  name,
 				_type: dS,
@@ -317,7 +923,7 @@ func StringSetField(name string) StringSet {
 func ListField(name string) List {
 	return List{
 		dynamoListField{
-			dynamoCollectionField{
+			dynamoCollectionField: dynamoCollectionField{
 				DynamoField{
 					name:  name,
 					_type: dL,
@@ -327,13 +933,30 @@ func ListField(name string) List {
 	}
 }
 
+/*TimeField ... A constructor for a timestamp dynamo field*/
+func TimeField(name string, enc TimeEncoding) Time {
+	t := dN
+	if enc == RFC3339 {
+		t = dS
+	}
+	return Time{
+		dynamoValueField{
+			DynamoField{
+				name:  name,
+				_type: t,
+			},
+		},
+		enc,
+	}
+}
+
 /*MapField ... A constructor for a map dynamo field*/
 func MapField(name string) Map {
 	return Map{
 		dynamoMapField{
 			DynamoField{
 				name:  name,
-				_type: dL,
+				_type: dM,
 			},
 		},
 	}
@@ -365,17 +988,66 @@ type KeyValue struct {
 	RangeKey     interface{}
 }
 
+/*PK ... The key of an item in a table without a range key*/
+func PK(v interface{}) KeyValue {
+	return KeyValue{PartitionKey: v}
+}
+
+/*PKRK ... The key of an item in a table with a range key*/
+func PKRK(pk, rk interface{}) KeyValue {
+	return KeyValue{PartitionKey: pk, RangeKey: rk}
+}
+
 type TableName string
 type Keys *dynamodb.KeysAndAttributes
 
+/*Output ... The surface shared by every operation's output, for use in generic middleware like logging and metrics*/
+type Output interface {
+	Error() error
+	ConditionalCheckFailed() bool
+	ConsumedCapacityUnits() float64
+	Attempts() int
+}
+
 type dynamoResult struct {
-	err error
+	err      error
+	attempts int
+	capacity float64
 }
 
 func (r *dynamoResult) Error() error {
 	return r.err
 }
 
+/*Attempts ... The number of requests sent to dynamo, e.g. one per page or batch*/
+func (r *dynamoResult) Attempts() int {
+	return r.attempts
+}
+
+/*ConsumedCapacityUnits ... The capacity consumed across all requests. Zero unless consumed capacity was returned*/
+func (r *dynamoResult) ConsumedCapacityUnits() float64 {
+	return r.capacity
+}
+
+/*record accounts for a request sent to dynamo and the capacity its response reported*/
+func (r *dynamoResult) record(capacity ...*dynamodb.ConsumedCapacity) {
+	r.attempts++
+	for _, c := range capacity {
+		if c != nil {
+			r.capacity += aws.Float64Value(c.CapacityUnits)
+		}
+	}
+}
+
+/*deserialize hydrates item from av, recording any error on the result so later Error calls see it. A nil item discards av*/
+func (r *dynamoResult) deserialize(decoder *dynamodbattribute.Decoder, av DynamoDBValue, item interface{}) error {
+	if r.err != nil || item == nil {
+		return r.err
+	}
+	r.err = deserializeTo(decoder, av, item)
+	return r.err
+}
+
 func (r *dynamoResult) ConditionalCheckFailed() (b bool) {
 	if err := r.Error(); err != nil {
 		if awsErr, ok := err.(awserr.Error); ok {
@@ -394,37 +1066,74 @@ func (r *dynamoResult) ConditionalCheckFailed() (b bool) {
 /***************************************************************************************/
 /************************************** GetItem ****************************************/
 /***************************************************************************************/
-type getInput dynamodb.GetItemInput
-type getOutput struct {
+type GetInput struct {
+	dynamodb.GetItemInput
+	decoder *dynamodbattribute.Decoder
+	options []request.Option
+	err     error
+}
+type GetOutput struct {
 	*dynamoResult
 	*dynamodb.GetItemOutput
+	decoder *dynamodbattribute.Decoder
 }
 
 /*GetItem Primary constructor for creating a  get item query*/
-func (table DynamoTable) GetItem(key KeyValue) *getInput {
-	q := getInput(dynamodb.GetItemInput{})
+func (table DynamoTable) GetItem(key KeyValue) *GetInput {
+	q := GetInput{decoder: table.Decoder, options: table.Defaults.Options}
 	q.TableName = &table.Name
-	appendAttribute(&q.Key, table.PartitionKey.Name(), key.PartitionKey)
-	if table.RangeKey != nil && !table.RangeKey.IsEmpty() {
-		appendAttribute(&q.Key, table.RangeKey.Name(), key.RangeKey)
+	if table.Defaults.ConsistentRead {
+		q.SetConsistentRead(true)
+	}
+	if table.Defaults.ReturnConsumedCapacity != "" {
+		q.ReturnConsumedCapacity = aws.String(table.Defaults.ReturnConsumedCapacity)
+	}
+	if q.err = table.validateKey("GetItem", key); q.err == nil {
+		q.err = appendStoredKey(&q.Key, table, key)
 	}
 	return &q
 }
 
-/*SetConsistentRead ... */
-func (d *getInput) SetConsistentRead(c bool) *getInput {
-	d.ConsistentRead = &c
+/*GetItemOf ... A get item query for the stored version of item*/
+func (table DynamoTable) GetItemOf(item interface{}) (*GetInput, error) {
+	key, err := table.KeyOf(item)
+	if err != nil {
+		return nil, err
+	}
+	return table.GetItem(key), nil
+}
+
+/**
+ ** Exists ... Whether an item is stored under key. Only the partition key attribute is read, keeping the request as cheap
+ ** as possible. A missing table is reported as an error, not as false
+ */
+func (table DynamoTable) Exists(ctx context.Context, dynamo DynamoReader, key KeyValue) (bool, error) {
+	q := table.GetItem(key).SetProjectionExpression("#key0")
+	q.ExpressionAttributeNames = map[string]*string{"#key0": aws.String(table.PartitionKey.Name())}
+
+	out := q.ExecuteWith(ctx, dynamo)
+	if err := out.Error(); err != nil {
+		return false, err
+	}
+	return out.Found(), nil
+}
+
+/*SetConsistentRead ... */
+func (d *GetInput) SetConsistentRead(c bool) *GetInput {
+	d.ConsistentRead = &c
 	return d
 }
 
-func (d *getInput) SetProjectionExpression(exp string) *getInput {
+func (d *GetInput) SetProjectionExpression(exp string) *GetInput {
 	d.ProjectionExpression = &exp
 	return d
 }
 
-func (d *getInput) Build() *dynamodb.GetItemInput {
-	r := dynamodb.GetItemInput(*d)
-	r.ReturnConsumedCapacity = aws.String("INDEXES")
+func (d *GetInput) Build() *dynamodb.GetItemInput {
+	r := d.GetItemInput
+	if r.ReturnConsumedCapacity == nil {
+		r.ReturnConsumedCapacity = aws.String("INDEXES")
+	}
 	return &r
 }
 
@@ -436,45 +1145,80 @@ func (d *getInput) Build() *dynamodb.GetItemInput {
  **
  ** Returns a tuple of the hydrated item struct, or an error
  */
-func (d *getInput) ExecuteWith(ctx context.Context, dynamo DynamoDBIFace, opts ...request.Option) (out *getOutput) {
+func (d *GetInput) ExecuteWith(ctx context.Context, dynamo DynamoReader, opts ...request.Option) (out *GetOutput) {
+	if d.err != nil {
+		return &GetOutput{&dynamoResult{err: d.err}, nil, d.decoder}
+	}
 
-	o, err := dynamo.GetItemWithContext(ctx, d.Build(), opts...)
+	o, err := dynamo.GetItemWithContext(ctx, d.Build(), withDefaultOptions(d.options, opts)...)
 	dr := &dynamoResult{
-		err,
+		err: err,
 	}
-	out = &getOutput{
+	out = &GetOutput{
 		dr,
 		o,
+		d.decoder,
+	}
+	if o != nil {
+		dr.record(o.ConsumedCapacity)
+	} else {
+		dr.record()
 	}
 
 	return
 }
 
-func (o *getOutput) Result(item interface{}) (err error) {
-	err = o.Error()
-	if o.GetItemOutput == nil || err != nil || item == nil {
-		return
+/*Execute ... ExecuteWith, also returning the output's error. A missing item is not an error*/
+func (d *GetInput) Execute(ctx context.Context, dynamo DynamoReader, opts ...request.Option) (*GetOutput, error) {
+	out := d.ExecuteWith(ctx, dynamo, opts...)
+	return out, out.Error()
+}
+
+/*Found ... Whether the item exists. False on error*/
+func (o *GetOutput) Found() bool {
+	return o.Error() == nil && o.GetItemOutput != nil && len(o.Item) > 0
+}
+
+func (o *GetOutput) Result(item interface{}) (err error) {
+	if o.GetItemOutput == nil {
+		return o.Error()
+	}
+	return o.deserialize(o.decoder, o.Item, item)
+}
+
+/*ResultOK ... Like Result, but reports whether the item was found. item is left untouched on a miss*/
+func (o *GetOutput) ResultOK(item interface{}) (found bool, err error) {
+	if found = o.Found(); !found {
+		return false, o.Error()
 	}
-	return deserializeTo(o.Item, item)
+	return true, o.Result(item)
 }
 
 /***************************************************************************************/
 /************************************** BatchGetItem ***********************************/
 /***************************************************************************************/
-type batchGetInput struct {
+type BatchGetInput struct {
 	input *[]*dynamodb.BatchGetItemInput
+	table DynamoTable
 
-	consistentRead bool
+	consistentRead         bool
+	maxRetries             int
+	returnConsumedCapacity string
+	decoder                *dynamodbattribute.Decoder
+	options                []request.Option
 	/*A set of mutational operations that might error out, i.e. not pure, and therefore not conducive to a fluent dsl*/
 	delayedFunctions []func() error
 }
-type batchGetOutput struct {
+type BatchGetOutput struct {
 	*dynamoResult
-	results []*dynamodb.BatchGetItemOutput
+	results     []*dynamodb.BatchGetItemOutput
+	unprocessed []map[string]*dynamodb.AttributeValue
+	table       DynamoTable
+	decoder     *dynamodbattribute.Decoder
 }
 
 /*BatchGetItem represents dynamo batch get item call*/
-func (table DynamoTable) BatchGetItem(items ...KeyValue) *batchGetInput {
+func (table DynamoTable) BatchGetItem(items ...KeyValue) *BatchGetInput {
 	/*Delay the attribute value construction, until Build time*/
 	input := &[]*dynamodb.BatchGetItemInput{}
 	delayed := func() error {
@@ -484,14 +1228,11 @@ func (table DynamoTable) BatchGetItem(items ...KeyValue) *batchGetInput {
 		k[table.Name] = keysAndAttribs
 		ss := []map[string]*dynamodb.KeysAndAttributes{k}
 
-		for i, kv := range items {
-
-			if (i-1)%100 == 99 {
-				k = make(map[string]*dynamodb.KeysAndAttributes)
-				ss = append(ss, k)
-
-				keysAndAttribs = &dynamodb.KeysAndAttributes{}
-				k[table.Name] = keysAndAttribs
+		/*Dynamo rejects batches that repeat a key. Keys are compared marshaled, so binary keys dedupe by content*/
+		seen := map[string]bool{}
+		for _, kv := range items {
+			if err := table.validateKey("BatchGetItem", kv); err != nil {
+				return err
 			}
 
 			m := map[string]interface{}{
@@ -506,7 +1247,20 @@ func (table DynamoTable) BatchGetItem(items ...KeyValue) *batchGetInput {
 			if err != nil {
 				return err
 			}
+			table.shardKey(attributes)
+			if key := cacheKey(table.Name, attributes); seen[key] {
+				continue
+			} else {
+				seen[key] = true
+			}
+
+			if len(keysAndAttribs.Keys) == 100 {
+				k = make(map[string]*dynamodb.KeysAndAttributes)
+				ss = append(ss, k)
 
+				keysAndAttribs = &dynamodb.KeysAndAttributes{}
+				k[table.Name] = keysAndAttribs
+			}
 			(*keysAndAttribs).Keys = append((*keysAndAttribs).Keys, attributes)
 
 		}
@@ -518,15 +1272,26 @@ func (table DynamoTable) BatchGetItem(items ...KeyValue) *batchGetInput {
 		return nil
 	}
 
-	q := &batchGetInput{
-		input:            input,
-		delayedFunctions: []func() error{delayed},
+	q := &BatchGetInput{
+		input:                  input,
+		table:                  table,
+		consistentRead:         table.Defaults.ConsistentRead,
+		maxRetries:             10,
+		returnConsumedCapacity: "INDEXES",
+		decoder:                table.Decoder,
+		options:                table.Defaults.Options,
+		delayedFunctions:       []func() error{delayed},
+	}
+	if table.Defaults.ReturnConsumedCapacity != "" {
+		q.returnConsumedCapacity = table.Defaults.ReturnConsumedCapacity
 	}
 
 	return q
 }
 
-func (d *batchGetInput) Build() (input []*dynamodb.BatchGetItemInput, err error) {
+func (d *BatchGetInput) Build() (input []*dynamodb.BatchGetItemInput, err error) {
+	// Rebuild from scratch so that repeated builds don't duplicate requests
+	*d.input = nil
 	for _, function := range d.delayedFunctions {
 		err = function()
 		if err != nil {
@@ -535,11 +1300,11 @@ func (d *batchGetInput) Build() (input []*dynamodb.BatchGetItemInput, err error)
 	}
 	input = *(d.input)
 	for _, i := range input {
-		i.ReturnConsumedCapacity = aws.String("INDEXES")
+		i.ReturnConsumedCapacity = aws.String(d.returnConsumedCapacity)
 
 		// set read consistency on individual items.
 		// this cannot be done in a delayedFunction because it depends on the context
-		// of the batchGetInput items.
+		// of the BatchGetInput items.
 		for _, a := range i.RequestItems {
 			a.ConsistentRead = &d.consistentRead
 		}
@@ -549,19 +1314,27 @@ func (d *batchGetInput) Build() (input []*dynamodb.BatchGetItemInput, err error)
 }
 
 /*SetConsistentRead ... */
-func (d *batchGetInput) SetConsistentRead(c bool) *batchGetInput {
+func (d *BatchGetInput) SetConsistentRead(c bool) *BatchGetInput {
 	d.consistentRead = c
 	return d
 }
 
+/*SetMaxRetries ... Give up on unprocessed keys after n retries with exponential backoff. Defaults to 10*/
+func (d *BatchGetInput) SetMaxRetries(n int) *BatchGetInput {
+	d.maxRetries = n
+	return d
+}
+
 /**
  ** ExecuteWith ... Execute a dynamo BatchGetItem call with a passed in dynamodb instance and next item pointer
  ** dynamo - The underlying dynamodb api
  **
  */
-func (d *batchGetInput) ExecuteWith(ctx context.Context, dynamo DynamoDBIFace, opts ...request.Option) (out *batchGetOutput) {
-	out = &batchGetOutput{
+func (d *BatchGetInput) ExecuteWith(ctx context.Context, dynamo DynamoReader, opts ...request.Option) (out *BatchGetOutput) {
+	out = &BatchGetOutput{
 		dynamoResult: &dynamoResult{},
+		table:        d.table,
+		decoder:      d.decoder,
 	}
 
 	var input []*dynamodb.BatchGetItemInput
@@ -570,40 +1343,83 @@ func (d *batchGetInput) ExecuteWith(ctx context.Context, dynamo DynamoDBIFace, o
 		return
 	}
 
-	for _, bg := range input {
-		retry := 0
-	Execute:
-		var result *dynamodb.BatchGetItemOutput
-		if result, out.err = dynamo.BatchGetItemWithContext(ctx, bg, opts...); out.err != nil {
-			return
-		}
-		out.results = append(out.results, result)
+	for i, bg := range input {
+		backoff := retryBackoff
+		for retry := 0; ; retry++ {
+			var result *dynamodb.BatchGetItemOutput
+			if result, out.err = dynamo.BatchGetItemWithContext(ctx, bg, withDefaultOptions(d.options, opts)...); out.err != nil {
+				out.record()
+				out.abandon(input[i:])
+				return
+			}
+			out.record(result.ConsumedCapacity...)
+			out.results = append(out.results, result)
 
-		if result.UnprocessedKeys != nil && len(result.UnprocessedKeys) > 0 {
+			if len(result.UnprocessedKeys) <= 0 {
+				break
+			}
 			bg.RequestItems = result.UnprocessedKeys
-			retry++
-			goto Execute
+			if retry >= d.maxRetries {
+				out.abandon(input[i : i+1])
+				break
+			}
+			select {
+			case <-ctx.Done():
+				out.err = ctx.Err()
+				out.abandon(input[i:])
+				return
+			case <-time.After(backoff):
+			}
+			backoff *= 2
 		}
 	}
 
 	return
 }
 
+/*Execute ... ExecuteWith, also returning the output's error. Keys left unprocessed are not an error; see UnprocessedKeys*/
+func (d *BatchGetInput) Execute(ctx context.Context, dynamo DynamoReader, opts ...request.Option) (*BatchGetOutput, error) {
+	out := d.ExecuteWith(ctx, dynamo, opts...)
+	return out, out.Error()
+}
+
+/*abandon records the keys of requests that won't be sent again*/
+func (o *BatchGetOutput) abandon(requests []*dynamodb.BatchGetItemInput) {
+	for _, r := range requests {
+		if ka := r.RequestItems[o.table.Name]; ka != nil {
+			o.unprocessed = append(o.unprocessed, ka.Keys...)
+		}
+	}
+}
+
+/**
+ ** UnprocessedKeys ... The keys that were never fetched, because dynamo left them unprocessed once retries ran out,
+ ** or because the call failed first. Re-enqueue them to read the missing items.
+ */
+func (o *BatchGetOutput) UnprocessedKeys() []KeyValue {
+	keys := make([]KeyValue, len(o.unprocessed))
+	for i, k := range o.unprocessed {
+		keys[i] = DynamoDBValue(k).Key(o.table)
+	}
+	return keys
+}
+
 /** Results ... Deserialize the results using a user provided target object generator function
  ** nextItem - The item pointer function, which is called on each new object returned from dynamodb. The function should
  ** 		   store each item in an array before returning.
  **/
 
-func (o *batchGetOutput) Results(nextItem func() interface{}) (err error) {
+func (o *BatchGetOutput) Results(nextItem func() interface{}) (err error) {
 	err = o.Error()
 	if o.Error() != nil || nextItem == nil {
 		return
 	}
-	for _, result := range o.results {
+	keyOf := func(av DynamoDBValue) DynamoDBValue { return itemKey(o.table, nil, av) }
+	for batch, result := range o.results {
 		for _, items := range result.Responses {
 			for _, av := range items {
-				if o.err = deserializeTo(av, nextItem()); o.err != nil {
-					return
+				if o.err = decodeItem(o.decoder, av, nextItem(), keyOf, batch); o.err != nil {
+					return o.err
 				}
 			}
 		}
@@ -611,22 +1427,240 @@ func (o *batchGetOutput) Results(nextItem func() interface{}) (err error) {
 	return
 }
 
+/***************************************************************************************/
+/************************************** Batch Reader ***********************************/
+/***************************************************************************************/
+
+/*BatchReaderOption ... Configures DynamoTable.BatchReader*/
+type BatchReaderOption func(*batchReaderConfig)
+
+type batchReaderConfig struct {
+	linger         time.Duration
+	maxRetries     int
+	consistentRead bool
+}
+
+/*ReaderLinger ... Wait up to d for more keys before fetching a partial batch. Defaults to 5 milliseconds*/
+func ReaderLinger(d time.Duration) BatchReaderOption {
+	return func(c *batchReaderConfig) { c.linger = d }
+}
+
+/*ReaderMaxRetries ... Give up on unprocessed keys after n retries with exponential backoff. Defaults to 10*/
+func ReaderMaxRetries(n int) BatchReaderOption {
+	return func(c *batchReaderConfig) { c.maxRetries = n }
+}
+
+/*ReaderConsistentRead ... Fetch with consistent reads. Defaults to the table's ConsistentRead default*/
+func ReaderConsistentRead(c bool) BatchReaderOption {
+	return func(config *batchReaderConfig) { config.consistentRead = c }
+}
+
+/**
+ ** BatchReader ... Coalesces single key reads into BatchGetItem calls
+ ** Keys requested within the linger window of each other are fetched together, up to 100 at a time, so
+ ** code reading one item per loop iteration makes batched round trips without restructuring. A key requested
+ ** again while its read is in flight shares that read.
+ */
+type BatchReader struct {
+	table   DynamoTable
+	dynamo  DynamoReader
+	ctx     context.Context
+	config  batchReaderConfig
+	workers chan struct{}
+	wg      sync.WaitGroup
+
+	mu       sync.Mutex
+	pending  []KeyValue
+	inFlight map[string]*ReadFuture
+	timer    *time.Timer
+	closed   bool
+}
+
+/*ReadFuture ... The eventual result of a BatchReader request*/
+type ReadFuture struct {
+	Key     KeyValue
+	done    chan struct{}
+	item    DynamoDBValue
+	err     error
+	decoder *dynamodbattribute.Decoder
+}
+
+/*Done ... Closed once the read has finished*/
+func (f *ReadFuture) Done() <-chan struct{} {
+	return f.done
+}
+
+/*Item ... Wait for the read. The item is nil if it doesn't exist*/
+func (f *ReadFuture) Item() (DynamoDBValue, error) {
+	<-f.done
+	return f.item, f.err
+}
+
+/*ResultOK ... Wait for the read and deserialize the item into item, reporting whether it was found*/
+func (f *ReadFuture) ResultOK(item interface{}) (found bool, err error) {
+	<-f.done
+	if f.err != nil || f.item == nil {
+		return false, f.err
+	}
+	return true, deserializeTo(f.decoder, f.item, item)
+}
+
+func (f *ReadFuture) resolve(item DynamoDBValue, err error) {
+	f.item, f.err = item, err
+	close(f.done)
+}
+
+/*BatchReader ... Start a BatchReader. See WithBatchConcurrency to fetch batches concurrently*/
+func (table DynamoTable) BatchReader(ctx context.Context, dynamo DynamoReader, opts ...BatchReaderOption) *BatchReader {
+	config := batchReaderConfig{linger: 5 * time.Millisecond, maxRetries: 10, consistentRead: table.Defaults.ConsistentRead}
+	for _, o := range opts {
+		o(&config)
+	}
+	workers := table.batchConcurrency
+	if workers < 1 {
+		workers = 1
+	}
+	return &BatchReader{
+		table:    table,
+		dynamo:   dynamo,
+		ctx:      ctx,
+		config:   config,
+		workers:  make(chan struct{}, workers),
+		inFlight: map[string]*ReadFuture{},
+	}
+}
+
+/*Request ... Queue a read of the item at key*/
+func (r *BatchReader) Request(key KeyValue) *ReadFuture {
+	f := &ReadFuture{Key: key, done: make(chan struct{}), decoder: r.table.Decoder}
+	if err := r.table.validateKey("BatchReader", key); err != nil {
+		f.resolve(nil, err)
+		return f
+	}
+	av := map[string]*dynamodb.AttributeValue{}
+	if err := appendStoredKey(&av, r.table, key); err != nil {
+		f.resolve(nil, err)
+		return f
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		f.resolve(nil, fmt.Errorf("BatchReader %s: closed.", r.table.Name))
+		return f
+	}
+	k := cacheKey(r.table.Name, av)
+	if shared := r.inFlight[k]; shared != nil {
+		return shared
+	}
+	r.inFlight[k] = f
+	r.pending = append(r.pending, key)
+	if len(r.pending) >= 100 {
+		r.dispatch()
+	} else if len(r.pending) == 1 {
+		r.timer = time.AfterFunc(r.config.linger, func() {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.dispatch()
+		})
+	}
+	return f
+}
+
+/*Get ... Request the item at key and wait for it*/
+func (r *BatchReader) Get(key KeyValue) (DynamoDBValue, error) {
+	return r.Request(key).Item()
+}
+
+/*Close ... Fetch the pending keys and wait for every read to finish. Later requests fail*/
+func (r *BatchReader) Close() {
+	r.mu.Lock()
+	r.closed = true
+	r.dispatch()
+	r.mu.Unlock()
+	r.wg.Wait()
+}
+
+/*dispatch fetches the pending keys in the background. r.mu must be held*/
+func (r *BatchReader) dispatch() {
+	if r.timer != nil {
+		r.timer.Stop()
+		r.timer = nil
+	}
+	if len(r.pending) <= 0 {
+		return
+	}
+	keys := r.pending
+	r.pending = nil
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		r.workers <- struct{}{}
+		defer func() { <-r.workers }()
+		r.fetch(keys)
+	}()
+}
+
+/*fetch reads one batch of keys and resolves their futures*/
+func (r *BatchReader) fetch(keys []KeyValue) {
+	out := r.table.BatchGetItem(keys...).
+		SetConsistentRead(r.config.consistentRead).
+		SetMaxRetries(r.config.maxRetries).
+		ExecuteWith(r.ctx, r.dynamo)
+
+	items := map[string]DynamoDBValue{}
+	for _, result := range out.results {
+		for _, item := range result.Responses[r.table.Name] {
+			items[cacheKey(r.table.Name, itemKey(r.table, nil, item))] = item
+		}
+	}
+	unprocessed := map[string]bool{}
+	for _, key := range out.unprocessed {
+		unprocessed[cacheKey(r.table.Name, key)] = true
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, key := range keys {
+		av := map[string]*dynamodb.AttributeValue{}
+		appendStoredKey(&av, r.table, key)
+		k := cacheKey(r.table.Name, av)
+		f := r.inFlight[k]
+		delete(r.inFlight, k)
+
+		switch {
+		case items[k] != nil:
+			f.resolve(items[k], nil)
+		case out.Error() != nil:
+			f.resolve(nil, out.Error())
+		case unprocessed[k]:
+			f.resolve(nil, fmt.Errorf("BatchReader %s: key still unprocessed after %d retries.", r.table.Name, r.config.maxRetries))
+		default:
+			f.resolve(nil, nil)
+		}
+	}
+}
+
 /***************************************************************************************/
 /************************************** TransactGetItems ***********************************/
 /***************************************************************************************/
-type transactGetInput struct {
-	input []*dynamodb.TransactGetItemsInput
+type TransactGetInput struct {
+	input      []*dynamodb.TransactGetItemsInput
+	decoder    *dynamodbattribute.Decoder
+	maxRetries int
+	err        error
 }
-type transactGetOutput struct {
+type TransactGetOutput struct {
 	*dynamoResult
 	results []*dynamodb.TransactGetItemsOutput
+	decoder *dynamodbattribute.Decoder
 }
 
 /*TransactGetItems represents dynamo transact get items call*/
 /*Maximum of 10 items are allowed to be fetched, per call. If more are requested,
 they will be segmented and fetched in batches of 10*/
-func (table DynamoTable) TransactGetItems(items ...KeyValue) *transactGetInput {
-	r := &transactGetInput{}
+func (table DynamoTable) TransactGetItems(items ...KeyValue) *TransactGetInput {
+	r := &TransactGetInput{decoder: table.Decoder, maxRetries: 3}
 
 	l := math.Ceil(float64(len(items)) / 10.0)
 	if l <= 0 {
@@ -648,7 +1682,13 @@ func (table DynamoTable) TransactGetItems(items ...KeyValue) *transactGetInput {
 				TableName: &table.Name,
 			},
 		}
-		appendKeyAttribute(&tr.Get.Key, table, kv)
+		if err := table.validateKey("TransactGetItems", kv); err != nil {
+			if r.err == nil {
+				r.err = err
+			}
+		} else if err := appendKeyAttribute(&tr.Get.Key, table, kv); err != nil && r.err == nil {
+			r.err = err
+		}
 		tgi.TransactItems = append(tgi.TransactItems, tr)
 
 	}
@@ -656,7 +1696,19 @@ func (table DynamoTable) TransactGetItems(items ...KeyValue) *transactGetInput {
 	return r
 }
 
-func (d *transactGetInput) Build() (input []*dynamodb.TransactGetItemsInput, err error) {
+/**
+ ** SetMaxRetries ... Retry a read that conflicts with a transaction in flight on the same items n times, with
+ ** exponential backoff. Defaults to 3
+ */
+func (d *TransactGetInput) SetMaxRetries(n int) *TransactGetInput {
+	d.maxRetries = n
+	return d
+}
+
+func (d *TransactGetInput) Build() (input []*dynamodb.TransactGetItemsInput, err error) {
+	if d.err != nil {
+		return nil, d.err
+	}
 	input = d.input
 	for _, i := range d.input {
 		i.ReturnConsumedCapacity = aws.String("INDEXES")
@@ -670,9 +1722,10 @@ func (d *transactGetInput) Build() (input []*dynamodb.TransactGetItemsInput, err
  ** dynamo - The underlying dynamodb api
  **
  */
-func (d *transactGetInput) ExecuteWith(ctx context.Context, dynamo DynamoDBIFace, opts ...request.Option) (out *transactGetOutput) {
-	out = &transactGetOutput{
+func (d *TransactGetInput) ExecuteWith(ctx context.Context, dynamo DynamoReader, opts ...request.Option) (out *TransactGetOutput) {
+	out = &TransactGetOutput{
 		dynamoResult: &dynamoResult{},
+		decoder:      d.decoder,
 	}
 
 	var input []*dynamodb.TransactGetItemsInput
@@ -682,29 +1735,52 @@ func (d *transactGetInput) ExecuteWith(ctx context.Context, dynamo DynamoDBIFace
 	}
 
 	for _, bg := range input {
-		var result *dynamodb.TransactGetItemsOutput
-		if result, out.err = dynamo.TransactGetItemsWithContext(ctx, bg, opts...); out.err != nil {
-			return
+		backoff := retryBackoff
+		for retry := 0; ; retry++ {
+			var result *dynamodb.TransactGetItemsOutput
+			var reasons []*dynamodb.CancellationReason
+			options := append(append([]request.Option(nil), opts...), captureCancellation(&reasons))
+			if result, out.err = dynamo.TransactGetItemsWithContext(ctx, bg, options...); out.err == nil {
+				out.record(result.ConsumedCapacity...)
+				out.results = append(out.results, result)
+				break
+			}
+			out.record()
+			if retry >= d.maxRetries || !isTransactionConflict(out.err, cancellationReasons(out.err, reasons, nil)) {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				out.err = ctx.Err()
+				return
+			case <-time.After(backoff):
+			}
+			backoff *= 2
 		}
-		out.results = append(out.results, result)
 	}
 
 	return
 }
 
+/*Execute ... ExecuteWith, also returning the output's error*/
+func (d *TransactGetInput) Execute(ctx context.Context, dynamo DynamoReader, opts ...request.Option) (*TransactGetOutput, error) {
+	out := d.ExecuteWith(ctx, dynamo, opts...)
+	return out, out.Error()
+}
+
 /** Results ... Deserialize the results using a user provided target object generator function
  ** nextItem - The item pointer function, which is called on each new object returned from dynamodb. The function should
  ** 		   store each item in an array before returning.
  **/
 
-func (o *transactGetOutput) Results(nextItem func() interface{}) (err error) {
+func (o *TransactGetOutput) Results(nextItem func() interface{}) (err error) {
 	err = o.Error()
 	if o.Error() != nil || nextItem == nil {
 		return
 	}
 	for _, result := range o.results {
 		for _, av := range result.Responses {
-			if o.err = deserializeTo(av.Item, nextItem()); o.err != nil {
+			if o.err = deserializeTo(o.decoder, av.Item, nextItem()); o.err != nil {
 				return
 			}
 		}
@@ -715,42 +1791,167 @@ func (o *transactGetOutput) Results(nextItem func() interface{}) (err error) {
 /***************************************************************************************/
 /************************************** PutItem ****************************************/
 /***************************************************************************************/
-type putInput dynamodb.PutItemInput
-type putOutput struct {
+type PutInput struct {
+	*dynamodb.PutItemInput
+	table      DynamoTable
+	conditions []Expression
+	versioned  bool
+}
+type PutOutput struct {
 	*dynamodb.PutItemOutput
 	*dynamoResult
+	decoder *dynamodbattribute.Decoder
+	item    DynamoDBValue
 }
 
 /*PutItem represents dynamo put item call*/
-func (table DynamoTable) PutItem(i interface{}) *putInput {
-	q := putInput(dynamodb.PutItemInput{})
+func (table DynamoTable) PutItem(i interface{}) *PutInput {
+	q := PutInput{PutItemInput: &dynamodb.PutItemInput{}, table: table}
 	q.TableName = &table.Name
-	q.Item, _ = dynamodbattribute.MarshalMap(i)
+	q.Item, _ = serialize(table.Encoder, i)
 	return &q
 }
 
-func (d *putInput) ReturnAllOld() *putInput {
-	(*dynamodb.PutItemInput)(d).SetReturnValues("ALL_OLD")
+/*PutIfNotExists represents a dynamo put item call that only succeeds if no item with the same key exists*/
+func (table DynamoTable) PutIfNotExists(i interface{}) *PutInput {
+	return table.PutItem(i).SetConditionExpression(table.notExists())
+}
+
+/**
+ ** GetOrCreate ... Load the item at key into out, creating it first if it is missing
+ ** create - builds the item to put, and must carry key
+ ** out - a pointer to the domain object to hydrate with the stored item
+ **
+ ** Returns whether this call created the item. If a concurrent writer wins the race, the
+ ** stored item is read back instead, and ErrItemDeleted returned should it be deleted in the meantime
+ */
+func (table DynamoTable) GetOrCreate(ctx context.Context, dynamo DynamoDBIFace, key KeyValue, create func() interface{}, out interface{}) (created bool, err error) {
+	item := create()
+	if err = table.checkItemKey("GetOrCreate", key, item); err != nil {
+		return
+	}
+	put := table.PutIfNotExists(item).ExecuteWith(ctx, dynamo)
+	if put.ConditionalCheckFailed() {
+		var found bool
+		found, err = table.GetItem(key).SetConsistentRead(true).ExecuteWith(ctx, dynamo).ResultOK(out)
+		if err == nil && !found {
+			err = ErrItemDeleted
+		}
+		return
+	} else if err = put.Error(); err != nil {
+		return
+	}
+	/*The item sent carries what the table's timestamps and hooks added*/
+	return true, deserializeTo(table.Decoder, put.item, out)
+}
+
+/*checkItemKey checks that item carries key, so the item created and the item read back are the same*/
+func (table DynamoTable) checkItemKey(op string, key KeyValue, item interface{}) error {
+	if err := table.validateKey(op, key); err != nil {
+		return err
+	}
+	av, err := serialize(table.Encoder, item)
+	if err != nil {
+		return err
+	}
+	want := map[string]*dynamodb.AttributeValue{}
+	if err = appendKeyAttribute(&want, table, key); err != nil {
+		return err
+	}
+	for _, name := range table.keyNames() {
+		if got, ok := av[name]; !ok || !attributeEqual(got, want[name]) {
+			return fmt.Errorf("%s %s: the item created doesn't carry the key's %s %s.", op, table.Name, name, debugValue(want[name]))
+		}
+	}
+	return nil
+}
+
+func (table DynamoTable) exists() Expression {
+	pk := DynamoField{name: table.PartitionKey.Name()}
+	if table.RangeKey == nil || table.RangeKey.IsEmpty() {
+		return pk.Exists()
+	}
+	rk := DynamoField{name: table.RangeKey.Name()}
+	return And(pk.Exists(), rk.Exists())
+}
+
+func (table DynamoTable) notExists() Expression {
+	pk := DynamoField{name: table.PartitionKey.Name()}
+	if table.RangeKey == nil || table.RangeKey.IsEmpty() {
+		return pk.NotExists()
+	}
+	rk := DynamoField{name: table.RangeKey.Name()}
+	return And(pk.NotExists(), rk.NotExists())
+}
+
+func (d *PutInput) ReturnAllOld() *PutInput {
+	d.PutItemInput.SetReturnValues("ALL_OLD")
 	return d
 }
-func (d *putInput) ReturnNone() *putInput {
-	(*dynamodb.PutItemInput)(d).SetReturnValues("NONE")
+func (d *PutInput) ReturnNone() *PutInput {
+	d.PutItemInput.SetReturnValues("NONE")
 	return d
 }
-func (d *putInput) SetConditionExpression(c Expression) *putInput {
-	s, n, m, _ := c.construct("cond", 1, true)
+
+/*SetConditionExpression ... Set the condition expression. Repeated calls are and'd together*/
+func (d *PutInput) SetConditionExpression(c Expression) *PutInput {
+	d.conditions = append(d.conditions, c)
+	s, n, m, _ := conjunction(d.conditions).construct("cond", 1, true)
 	d.ConditionExpression = &s
 
-	d.ExpressionAttributeNames = n
+	appendNames(&d.ExpressionAttributeNames, n)
+	appendValues(&d.ExpressionAttributeValues, marshal(m))
+
+	return d
+}
+
+/*WithOptimisticLock ... Only write if the stored item is at the version the item carries, and bump it. A lost race is reported as ErrVersionConflict*/
+func (d *PutInput) WithOptimisticLock(field Numeric) *PutInput {
+	var current int64
+	if av, ok := d.Item[field.Name()]; ok && av.N != nil {
+		current, _ = strconv.ParseInt(*av.N, 10, 64)
+	}
+	if current == 0 {
+		d.SetConditionExpression(field.NotExists())
+	} else {
+		d.SetConditionExpression(field.Equals(current))
+	}
+	if d.Item == nil {
+		d.Item = DynamoDBValue{}
+	}
+	d.Item[field.Name()] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(current+1, 10))}
+	d.versioned = true
+	return d
+}
 
-	d.ExpressionAttributeValues = marshal(m)
+/*SetExpiry ... Set the item's TTL field to expire ttl from now, in the epoch seconds dynamo's TTL requires*/
+func (d *PutInput) SetExpiry(field Numeric, ttl time.Duration) *PutInput {
+	if d.Item == nil {
+		d.Item = DynamoDBValue{}
+	}
+	d.Item[field.Name()] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(expiresAt(ttl), 10))}
+	return d
+}
 
+/*SanitizeWrites ... Strip empty string, empty binary and NULL attributes from the item. Key attributes are never stripped*/
+func (d *PutInput) SanitizeWrites() *PutInput {
+	d.table.sanitizeWrites = true
 	return d
 }
 
-func (d *putInput) Build() *dynamodb.PutItemInput {
-	r := dynamodb.PutItemInput(*d)
-	return &r
+func (d *PutInput) Build() (*dynamodb.PutItemInput, error) {
+	r := *d.PutItemInput
+	if d.table.timestamps != nil {
+		r.Item = d.table.timestamps.stamp(r.Item)
+	}
+	if d.table.sanitizeWrites {
+		r.Item = sanitize(r.Item, d.table.keyNames())
+	}
+	if err := validateExpressions("PutItem", r.ExpressionAttributeNames, r.ExpressionAttributeValues,
+		namedExpression{"condition", r.ConditionExpression}); err != nil {
+		return nil, err
+	}
+	return &r, nil
 }
 
 /**
@@ -759,166 +1960,281 @@ func (d *putInput) Build() *dynamodb.PutItemInput {
  ** dynamo - The underlying dynamodb api
  **
  */
-func (d *putInput) ExecuteWith(ctx context.Context, dynamo DynamoDBIFace, opts ...request.Option) (out *putOutput) {
-	out = &putOutput{
+func (d *PutInput) ExecuteWith(ctx context.Context, dynamo DynamoWriter, opts ...request.Option) (out *PutOutput) {
+	out = &PutOutput{
 		dynamoResult: &dynamoResult{},
+		decoder:      d.table.Decoder,
+	}
+	input, err := d.Build()
+	if err == nil {
+		input.Item, err = d.table.hooks.put(input.Item)
+	}
+	if err != nil {
+		out.err = err
+		return
 	}
-	if result, err := dynamo.PutItemWithContext(ctx, d.Build(), opts...); err != nil {
+	out.item = input.Item
+	if result, err := dynamo.PutItemWithContext(ctx, input, opts...); err != nil {
 		out.err = err
+		out.record()
 	} else {
 		out.PutItemOutput = result
+		out.record(result.ConsumedCapacity)
+	}
+	if d.versioned && out.ConditionalCheckFailed() {
+		out.err = ErrVersionConflict
 	}
+	d.table.hooks.after("PutItem", itemKey(d.table, nil, input.Item), out.err)
 
 	return
 }
 
-func (o *putOutput) Result(item interface{}) (err error) {
-	err = o.Error()
-	if err != nil || o.PutItemOutput == nil || item == nil {
-		return
+/*Execute ... ExecuteWith, also returning the output's error, so a failed condition can't go unnoticed*/
+func (d *PutInput) Execute(ctx context.Context, dynamo DynamoWriter, opts ...request.Option) (*PutOutput, error) {
+	out := d.ExecuteWith(ctx, dynamo, opts...)
+	return out, out.Error()
+}
+
+func (o *PutOutput) Result(item interface{}) (err error) {
+	if o.PutItemOutput == nil {
+		return o.Error()
 	}
-	deserializeTo(o.PutItemOutput.Attributes, item)
-	return
+	return o.deserialize(o.decoder, o.PutItemOutput.Attributes, item)
 }
 
 /***************************************************************************************/
 /************************************** TransactWriteItems *********************************/
 /***************************************************************************************/
-type transactWriteItemsInput struct {
+type TransactWriteItemsInput struct {
 	*dynamodb.TransactWriteItemsInput
 	table            DynamoTable
+	autoToken        bool
+	tokenItems       []*dynamodb.TransactWriteItem
+	maxRetries       int
 	delayedFunctions []func() error
 }
 
-type transactWriteItemsOutput struct {
+type TransactWriteItemsOutput struct {
 	*dynamoResult
 	results *dynamodb.TransactWriteItemsOutput
+	items   []*dynamodb.TransactWriteItem
+	reasons []*dynamodb.CancellationReason
 }
 
 /*TransactWriteItems represents dynamo batch write item call*/
-func (table DynamoTable) TransactWriteItems() *transactWriteItemsInput {
-	r := transactWriteItemsInput{
+func (table DynamoTable) TransactWriteItems() *TransactWriteItemsInput {
+	r := TransactWriteItemsInput{
 		TransactWriteItemsInput: &dynamodb.TransactWriteItemsInput{},
-		table: table,
+		table:                   table,
+		maxRetries:              3,
 	}
 	return &r
 }
 
-func (d *transactWriteItemsInput) WithClientRequestToken(token string) *transactWriteItemsInput {
+/**
+ ** SetMaxRetries ... Retry a transaction that conflicts with another in flight on the same items n times, with
+ ** exponential backoff. Defaults to 3
+ */
+func (d *TransactWriteItemsInput) SetMaxRetries(n int) *TransactWriteItemsInput {
+	d.maxRetries = n
+	return d
+}
+
+/*SetClientRequestToken ... Make the transaction idempotent: dynamo applies it once however often the token is sent within 10 minutes*/
+func (d *TransactWriteItemsInput) SetClientRequestToken(token string) *TransactWriteItemsInput {
 	d.ClientRequestToken = &token
 	return d
 }
 
-func (d *transactWriteItemsInput) writeItem(item interface{}, f func(DynamoDBValue) *dynamodb.TransactWriteItem) *transactWriteItemsInput {
+/*WithClientRequestToken ... Same as SetClientRequestToken*/
+func (d *TransactWriteItemsInput) WithClientRequestToken(token string) *TransactWriteItemsInput {
+	return d.SetClientRequestToken(token)
+}
 
-	delayed := func() error {
+/**
+ ** SetAutoClientRequestToken ... Derive the client request token from a hash of the built transaction, so redriving
+ ** the same writes, i.e. after a crash, doesn't apply them twice. An explicit token takes precedence. Timestamps set
+ ** by WithTimestamps are left out of the hash, so a redrive in a later second still matches.
+ */
+func (d *TransactWriteItemsInput) SetAutoClientRequestToken() *TransactWriteItemsInput {
+	d.autoToken = true
+	return d
+}
 
-		// Error if batch size exceeds DynamoBatchSize
-		if len(d.TransactItems) > DynamoBatchSize {
-			return BatchSizeExceededError
-		}
+/*requestToken hashes the transaction's writes, truncated to dynamo's 36 character limit*/
+func requestToken(items []*dynamodb.TransactWriteItem) (string, error) {
+	b, err := json.Marshal(items)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:18]), nil
+}
+
+/**
+ ** TransactWriteEntry ... A put, update, delete or condition check on one table's item. Entries from any table can be
+ ** added to a transaction with Add.
+ */
+type TransactWriteEntry struct {
+	table     DynamoTable
+	build     func(DynamoTable) (*dynamodb.TransactWriteItem, error)
+	returnOld bool
+}
+
+/*ReturnItemOnConditionFailure ... Include the item in the entry's CancellationReason when its condition doesn't hold*/
+func (e *TransactWriteEntry) ReturnItemOnConditionFailure() *TransactWriteEntry {
+	e.returnOld = true
+	return e
+}
+
+/*item builds the entry's write against table, either the entry's own or an unstamped copy of it*/
+func (e *TransactWriteEntry) item(table DynamoTable) (*dynamodb.TransactWriteItem, error) {
+	write, err := e.build(table)
+	if err != nil || !e.returnOld {
+		return write, err
+	}
+	allOld := aws.String(dynamodb.ReturnValuesOnConditionCheckFailureAllOld)
+	switch {
+	case write.Put != nil:
+		write.Put.ReturnValuesOnConditionCheckFailure = allOld
+	case write.Update != nil:
+		write.Update.ReturnValuesOnConditionCheckFailure = allOld
+	case write.Delete != nil:
+		write.Delete.ReturnValuesOnConditionCheckFailure = allOld
+	case write.ConditionCheck != nil:
+		write.ConditionCheck.ReturnValuesOnConditionCheckFailure = allOld
+	}
+	return write, nil
+}
+
+/*unstamped is a copy of the table whose timestamps all read the epoch, so they don't vary a transaction's token*/
+func (table DynamoTable) unstamped() DynamoTable {
+	if table.timestamps != nil {
+		t := *table.timestamps
+		t.clock = func() time.Time { return time.Unix(0, 0) }
+		table.timestamps = &t
+	}
+	return table
+}
 
-		var write *dynamodb.TransactWriteItem
+func (table DynamoTable) transactEntry(item interface{}, f func(DynamoDBValue, DynamoTable) (*dynamodb.TransactWriteItem, error)) *TransactWriteEntry {
+	build := func(table DynamoTable) (*dynamodb.TransactWriteItem, error) {
 		switch t := item.(type) {
 		case KeyValue:
+			if err := table.validateKey("TransactWriteItems", t); err != nil {
+				return nil, err
+			}
 			m := make(map[string]*dynamodb.AttributeValue)
-			appendKeyAttribute(&m, d.table, t)
-			write = f(m)
+			if err := appendKeyAttribute(&m, table, t); err != nil {
+				return nil, err
+			}
+			return f(m, table)
 		default:
-			dynamoItem, err := dynamodbattribute.MarshalMap(item)
+			dynamoItem, err := serialize(table.Encoder, item)
 			if err != nil {
-				return err
+				return nil, err
 			}
-			write = f(dynamoItem)
+			return f(dynamoItem, table)
 		}
-
-		d.TransactItems = append(d.TransactItems, write)
-
-		return nil
 	}
-
-	d.delayedFunctions = append(d.delayedFunctions, delayed)
-
-	return d
+	return &TransactWriteEntry{table: table, build: build}
 }
 
-func (d *transactWriteItemsInput) PutItem(item interface{}, c ...Expression) *transactWriteItemsInput {
-	i := d.table.PutItem(item)
-	if len(c) > 0 {
-		i.SetConditionExpression(c[0])
-	}
-	return d.writeItem(item, func(v DynamoDBValue) *dynamodb.TransactWriteItem {
+/*TransactPut ... A transaction entry putting item, if the optional condition holds*/
+func (table DynamoTable) TransactPut(item interface{}, c ...Expression) *TransactWriteEntry {
+	return table.transactEntry(item, func(_ DynamoDBValue, table DynamoTable) (*dynamodb.TransactWriteItem, error) {
+		i := table.PutItem(item)
+		if len(c) > 0 {
+			i.SetConditionExpression(c[0])
+		}
+		b, err := i.Build()
+		if err != nil {
+			return nil, err
+		}
+		// The built item, rather than v, carries the timestamps and sanitizing the table applies to puts
 		r := &dynamodb.TransactWriteItem{
 			Put: &dynamodb.Put{
-				Item:      v,
-				TableName: &d.table.Name,
+				Item:      b.Item,
+				TableName: aws.String(table.Name),
 			},
 		}
-		b := i.Build()
 		r.Put.ConditionExpression = b.ConditionExpression
 		r.Put.ExpressionAttributeNames = b.ExpressionAttributeNames
 		r.Put.ExpressionAttributeValues = b.ExpressionAttributeValues
 
-		return r
+		return r, nil
 
 	})
 }
 
-func (d *transactWriteItemsInput) UpdateItem(key KeyValue, update *UpdateExpression, c ...Expression) *transactWriteItemsInput {
+/*TransactUpdate ... A transaction entry updating the item at key, if the optional condition holds*/
+func (table DynamoTable) TransactUpdate(key KeyValue, update *UpdateExpression, c ...Expression) *TransactWriteEntry {
 
-	i := d.table.UpdateItem(key).SetUpdateExpression(update)
-	if len(c) > 0 {
-		i.SetConditionExpression(c[0])
-	}
-	return d.writeItem(key, func(v DynamoDBValue) *dynamodb.TransactWriteItem {
+	return table.transactEntry(key, func(v DynamoDBValue, table DynamoTable) (*dynamodb.TransactWriteItem, error) {
+		i := table.UpdateItem(key).SetUpdateExpression(update)
+		if len(c) > 0 {
+			i.SetConditionExpression(c[0])
+		}
 		r := &dynamodb.TransactWriteItem{
 			Update: &dynamodb.Update{
 				Key:       v,
-				TableName: &d.table.Name,
+				TableName: aws.String(table.Name),
 			},
 		}
-		b, _ := i.Build()
+		b, err := i.Build()
+		if err != nil {
+			return nil, err
+		}
 		r.Update.ConditionExpression = b.ConditionExpression
 		r.Update.UpdateExpression = b.UpdateExpression
 		r.Update.ExpressionAttributeNames = b.ExpressionAttributeNames
 		r.Update.ExpressionAttributeValues = b.ExpressionAttributeValues
 
-		return r
+		return r, nil
 	})
 }
-func (d *transactWriteItemsInput) DeleteItem(key KeyValue, c ...Expression) *transactWriteItemsInput {
 
-	i := d.table.DeleteItem(key)
+/*TransactDelete ... A transaction entry deleting the item at key, if the optional condition holds*/
+func (table DynamoTable) TransactDelete(key KeyValue, c ...Expression) *TransactWriteEntry {
+
+	i := table.DeleteItem(key)
 	if len(c) > 0 {
 		i.SetConditionExpression(c[0])
 	}
 
-	return d.writeItem(key, func(v DynamoDBValue) *dynamodb.TransactWriteItem {
+	return table.transactEntry(key, func(v DynamoDBValue, _ DynamoTable) (*dynamodb.TransactWriteItem, error) {
 		r := &dynamodb.TransactWriteItem{
 			Delete: &dynamodb.Delete{
 				Key:       v,
-				TableName: &d.table.Name,
+				TableName: aws.String(table.Name),
 			},
 		}
 
-		b := i.Build()
+		b, err := i.Build()
+		if err != nil {
+			return nil, err
+		}
 		r.Delete.ConditionExpression = b.ConditionExpression
 		r.Delete.ExpressionAttributeNames = b.ExpressionAttributeNames
 		r.Delete.ExpressionAttributeValues = b.ExpressionAttributeValues
 
-		return r
+		return r, nil
 	})
 
 }
 
-func (d *transactWriteItemsInput) ConditionCheck(key KeyValue, c Expression) *transactWriteItemsInput {
+/**
+ ** ConditionCheck ... A transaction entry asserting c of the item at key without modifying it. The transaction is
+ ** canceled if c doesn't hold.
+ */
+func (table DynamoTable) ConditionCheck(key KeyValue, c Expression) *TransactWriteEntry {
 
-	return d.writeItem(key, func(v DynamoDBValue) *dynamodb.TransactWriteItem {
+	return table.transactEntry(key, func(v DynamoDBValue, _ DynamoTable) (*dynamodb.TransactWriteItem, error) {
 
 		r := &dynamodb.TransactWriteItem{
 			ConditionCheck: &dynamodb.ConditionCheck{
 				Key:       v,
-				TableName: &d.table.Name,
+				TableName: aws.String(table.Name),
 			},
 		}
 
@@ -928,23 +2244,87 @@ func (d *transactWriteItemsInput) ConditionCheck(key KeyValue, c Expression) *tr
 		r.ConditionCheck.ExpressionAttributeNames = n
 		r.ConditionCheck.ExpressionAttributeValues = marshal(m)
 
-		return r
+		return r, validateExpressions("ConditionCheck", r.ConditionCheck.ExpressionAttributeNames, r.ConditionCheck.ExpressionAttributeValues,
+			namedExpression{"condition", r.ConditionCheck.ConditionExpression})
 	})
 }
 
-func (d *transactWriteItemsInput) Build() (input *dynamodb.TransactWriteItemsInput, err error) {
+/*Add ... Append entries, from this or any other table, to the transaction in order*/
+func (d *TransactWriteItemsInput) Add(entries ...*TransactWriteEntry) *TransactWriteItemsInput {
+	for _, e := range entries {
+		e := e
+		delayed := func() error {
+
+			// Error if batch size exceeds DynamoBatchSize
+			if len(d.TransactItems) > DynamoBatchSize {
+				return BatchSizeExceededError
+			}
+
+			write, err := e.item(e.table)
+			if err != nil {
+				return err
+			}
+
+			d.TransactItems = append(d.TransactItems, write)
+
+			if d.autoToken && d.ClientRequestToken == nil {
+				hashed, err := e.item(e.table.unstamped())
+				if err != nil {
+					return err
+				}
+				d.tokenItems = append(d.tokenItems, hashed)
+			}
+
+			return nil
+		}
+
+		d.delayedFunctions = append(d.delayedFunctions, delayed)
+	}
+
+	return d
+}
+
+func (d *TransactWriteItemsInput) PutItem(item interface{}, c ...Expression) *TransactWriteItemsInput {
+	return d.Add(d.table.TransactPut(item, c...))
+}
+
+func (d *TransactWriteItemsInput) UpdateItem(key KeyValue, update *UpdateExpression, c ...Expression) *TransactWriteItemsInput {
+	return d.Add(d.table.TransactUpdate(key, update, c...))
+}
+
+func (d *TransactWriteItemsInput) DeleteItem(key KeyValue, c ...Expression) *TransactWriteItemsInput {
+	return d.Add(d.table.TransactDelete(key, c...))
+}
+
+func (d *TransactWriteItemsInput) ConditionCheck(key KeyValue, c Expression) *TransactWriteItemsInput {
+	return d.Add(d.table.ConditionCheck(key, c))
+}
+
+func (d *TransactWriteItemsInput) Build() (input *dynamodb.TransactWriteItemsInput, err error) {
 	for _, function := range d.delayedFunctions {
 		if err = function(); err != nil {
 			return
 		}
 	}
 
+	if d.autoToken && d.ClientRequestToken == nil {
+		var token string
+		if token, err = requestToken(d.tokenItems); err != nil {
+			return
+		}
+		d.ClientRequestToken = &token
+	}
+	// Replays are only told apart by the capacity they consume
+	if d.ClientRequestToken != nil && aws.StringValue(d.ReturnConsumedCapacity) != dynamodb.ReturnConsumedCapacityIndexes {
+		d.ReturnConsumedCapacity = aws.String(dynamodb.ReturnConsumedCapacityIndexes)
+	}
+
 	input = d.TransactWriteItemsInput
 	return
 }
 
-func (d *transactWriteItemsInput) ExecuteWith(ctx context.Context, dynamo DynamoDBIFace, opts ...request.Option) (out *transactWriteItemsOutput) {
-	out = &transactWriteItemsOutput{
+func (d *TransactWriteItemsInput) ExecuteWith(ctx context.Context, dynamo DynamoWriter, opts ...request.Option) (out *TransactWriteItemsOutput) {
+	out = &TransactWriteItemsOutput{
 		dynamoResult: &dynamoResult{},
 	}
 
@@ -953,42 +2333,228 @@ func (d *transactWriteItemsInput) ExecuteWith(ctx context.Context, dynamo Dynamo
 		out.err = err
 		return
 	}
-	out.results, out.err = dynamo.TransactWriteItemsWithContext(ctx, input, opts...)
+	out.items = input.TransactItems
+	backoff := retryBackoff
+	for retry := 0; ; retry++ {
+		out.reasons = nil
+		options := append(append([]request.Option(nil), opts...), captureCancellation(&out.reasons))
+		out.results, out.err = dynamo.TransactWriteItemsWithContext(ctx, input, options...)
+		if out.results != nil {
+			out.record(out.results.ConsumedCapacity...)
+		} else {
+			out.record()
+		}
+		if retry >= d.maxRetries || !isTransactionConflict(out.err, out.CancellationReasons()) {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			out.err = ctx.Err()
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
 
-	return
+/*Execute ... ExecuteWith, also returning the output's error*/
+func (d *TransactWriteItemsInput) Execute(ctx context.Context, dynamo DynamoWriter, opts ...request.Option) (*TransactWriteItemsOutput, error) {
+	out := d.ExecuteWith(ctx, dynamo, opts...)
+	return out, out.Error()
 }
 
-func (d *transactWriteItemsOutput) Results() (*dynamodb.TransactWriteItemsOutput, error) {
+func (d *TransactWriteItemsOutput) Results() (*dynamodb.TransactWriteItemsOutput, error) {
 	return d.results, d.Error()
 }
 
+/**
+ ** IdempotentReplay ... Whether dynamo replayed an earlier transaction with the same client request token instead
+ ** of applying it again. Dynamo reports a replay by consuming only read capacity.
+ */
+func (d *TransactWriteItemsOutput) IdempotentReplay() bool {
+	if d.err != nil || d.results == nil || len(d.results.ConsumedCapacity) <= 0 {
+		return false
+	}
+	for _, c := range d.results.ConsumedCapacity {
+		read, write := aws.Float64Value(c.ReadCapacityUnits), aws.Float64Value(c.WriteCapacityUnits)
+		if c.Table != nil {
+			read += aws.Float64Value(c.Table.ReadCapacityUnits)
+			write += aws.Float64Value(c.Table.WriteCapacityUnits)
+		}
+		if write > 0 || read <= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+/*CancellationReason ... Why an entry of a canceled transaction failed*/
+type CancellationReason struct {
+	Index     int           //The entry's position in the transaction
+	TableName string        //The entry's table
+	Code      string        //i.e. ConditionalCheckFailed, TransactionConflict or ProvisionedThroughputExceeded
+	Message   string        //Dynamo's description, when the client received one
+	Item      DynamoDBValue //The item, for entries that set ReturnItemOnConditionFailure
+}
+
+/*Conditional ... Whether the entry's condition didn't hold, rather than the entry failing for capacity or contention*/
+func (r CancellationReason) Conditional() bool {
+	return r.Code == "ConditionalCheckFailed"
+}
+
+/**
+ ** CancellationReasons ... The entries that canceled the transaction, in transaction order. Nil if it wasn't canceled.
+ ** Reasons are decoded from the error response. Clients that don't return one, i.e. stubs, are read from the
+ ** codes listed in the error's message, without messages or items.
+ */
+func (d *TransactWriteItemsOutput) CancellationReasons() []CancellationReason {
+	return cancellationReasons(d.err, d.reasons, func(i int) string {
+		if i < len(d.items) {
+			return transactTableName(d.items[i])
+		}
+		return ""
+	})
+}
+
+/*cancellationReasons are the failed entries of a canceled transaction, from the decoded reasons, or else err's message*/
+func cancellationReasons(err error, reasons []*dynamodb.CancellationReason, tableName func(int) string) (failed []CancellationReason) {
+	awsErr, ok := err.(awserr.Error)
+	if !ok || awsErr.Code() != dynamodb.ErrCodeTransactionCanceledException {
+		return nil
+	}
+	if len(reasons) <= 0 {
+		for _, code := range cancellationCodes(awsErr.Message()) {
+			reasons = append(reasons, &dynamodb.CancellationReason{Code: aws.String(code)})
+		}
+	}
+	for i, r := range reasons {
+		if code := aws.StringValue(r.Code); code == "" || code == "None" {
+			continue
+		}
+		c := CancellationReason{Index: i, Code: aws.StringValue(r.Code), Message: aws.StringValue(r.Message), Item: r.Item}
+		if tableName != nil {
+			c.TableName = tableName(i)
+		}
+		failed = append(failed, c)
+	}
+	return
+}
+
+/*ConditionFailed ... The first entry whose condition didn't hold, if that's what canceled the transaction*/
+func (d *TransactWriteItemsOutput) ConditionFailed() (CancellationReason, bool) {
+	for _, c := range d.CancellationReasons() {
+		if c.Conditional() {
+			return c, true
+		}
+	}
+	return CancellationReason{}, false
+}
+
+/**
+ ** isTransactionConflict reports a transaction that failed only because another was in flight on the same items, so
+ ** retrying it may succeed
+ */
+func isTransactionConflict(err error, reasons []CancellationReason) bool {
+	awsErr, ok := err.(awserr.Error)
+	if !ok {
+		return false
+	}
+	switch awsErr.Code() {
+	case dynamodb.ErrCodeTransactionConflictException:
+		return true
+	case dynamodb.ErrCodeTransactionCanceledException:
+		for _, r := range reasons {
+			if r.Code != "TransactionConflict" {
+				return false
+			}
+		}
+		return len(reasons) > 0
+	}
+	return false
+}
+
+/**
+ ** captureCancellation is a request option that decodes the per entry reasons of a canceled transaction, which the
+ ** sdk drops when it unmarshals the error, into reasons
+ */
+func captureCancellation(reasons *[]*dynamodb.CancellationReason) request.Option {
+	return func(r *request.Request) {
+		r.Handlers.UnmarshalError.PushFront(func(r *request.Request) {
+			if r.HTTPResponse == nil || r.HTTPResponse.Body == nil {
+				return
+			}
+			body, err := ioutil.ReadAll(r.HTTPResponse.Body)
+			r.HTTPResponse.Body.Close()
+			r.HTTPResponse.Body = ioutil.NopCloser(bytes.NewReader(body))
+			if err != nil {
+				return
+			}
+			var canceled struct {
+				CancellationReasons []*dynamodb.CancellationReason
+			}
+			if json.Unmarshal(body, &canceled) == nil {
+				*reasons = canceled.CancellationReasons
+			}
+		})
+	}
+}
+
+/*cancellationCodes reads the reasons listed at the end of a cancellation message, i.e. "... reasons [None, ConditionalCheckFailed]"*/
+func cancellationCodes(message string) []string {
+	open, close := strings.LastIndex(message, "["), strings.LastIndex(message, "]")
+	if open < 0 || close < open {
+		return nil
+	}
+	codes := strings.Split(message[open+1:close], ",")
+	for i := range codes {
+		codes[i] = strings.TrimSpace(codes[i])
+	}
+	return codes
+}
+
+func transactTableName(item *dynamodb.TransactWriteItem) string {
+	switch {
+	case item.Put != nil:
+		return aws.StringValue(item.Put.TableName)
+	case item.Update != nil:
+		return aws.StringValue(item.Update.TableName)
+	case item.Delete != nil:
+		return aws.StringValue(item.Delete.TableName)
+	case item.ConditionCheck != nil:
+		return aws.StringValue(item.ConditionCheck.TableName)
+	}
+	return ""
+}
+
 /***************************************************************************************/
 /************************************** BatchWriteItem *********************************/
 /***************************************************************************************/
-type batchWriteInput struct {
+type BatchWriteInput struct {
 	batches          []*dynamodb.BatchWriteItemInput
 	table            DynamoTable
-	delayedFunctions []func() error
+	delayedFunctions []func(*BatchWriteInput) error
 }
-type batchPutOutput struct {
+type BatchWriteOutput struct {
 	*dynamoResult
 	results []*dynamodb.BatchWriteItemOutput
+	table   DynamoTable
+	decoder *dynamodbattribute.Decoder
 }
 
 /*BatchWriteItem represents dynamo batch write item call*/
-func (table DynamoTable) BatchWriteItem() *batchWriteInput {
-	r := batchWriteInput{
+func (table DynamoTable) BatchWriteItem() *BatchWriteInput {
+	r := BatchWriteInput{
 		batches: []*dynamodb.BatchWriteItemInput{},
 		table:   table,
 	}
 	return &r
 }
 
-func (d *batchWriteInput) writeItems(putOnly bool, items ...interface{}) *batchWriteInput {
+func (d *BatchWriteInput) writeItems(putOnly bool, items ...interface{}) *BatchWriteInput {
 	if len(items) <= 0 {
 		return d
 	}
-	delayed := func() error {
+	delayed := func(d *BatchWriteInput) error {
 		var batch *dynamodb.BatchWriteItemInput
 
 		for _, item := range items {
@@ -999,19 +2565,26 @@ func (d *batchWriteInput) writeItems(putOnly bool, items ...interface{}) *batchW
 				d.batches = append(d.batches, batch)
 			}
 
-			dynamoItem, err := dynamodbattribute.MarshalMap(item)
+			dynamoItem, err := serialize(d.table.Encoder, item)
 
 			if err != nil {
 				return err
 			}
 			var write *dynamodb.WriteRequest
 			if putOnly {
+				if d.table.timestamps != nil {
+					dynamoItem = d.table.timestamps.stamp(dynamoItem)
+				}
+				if d.table.sanitizeWrites {
+					dynamoItem = sanitize(dynamoItem, d.table.keyNames())
+				}
 				write = &dynamodb.WriteRequest{
 					PutRequest: &dynamodb.PutRequest{
 						Item: dynamoItem,
 					},
 				}
 			} else {
+				d.table.shardKey(dynamoItem)
 				write = &dynamodb.WriteRequest{
 					DeleteRequest: &dynamodb.DeleteRequest{
 						Key: dynamoItem,
@@ -1032,11 +2605,23 @@ func (d *batchWriteInput) writeItems(putOnly bool, items ...interface{}) *batchW
 	return d
 }
 
-func (d *batchWriteInput) PutItems(items ...interface{}) *batchWriteInput {
+/*SanitizeWrites ... Strip empty string, empty binary and NULL attributes from put items. Key attributes are never stripped*/
+func (d *BatchWriteInput) SanitizeWrites() *BatchWriteInput {
+	d.table.sanitizeWrites = true
+	return d
+}
+
+func (d *BatchWriteInput) PutItems(items ...interface{}) *BatchWriteInput {
 	d.writeItems(true, items...)
 	return d
 }
-func (d *batchWriteInput) DeleteItems(keys ...KeyValue) *batchWriteInput {
+func (d *BatchWriteInput) DeleteItems(keys ...KeyValue) *BatchWriteInput {
+	for _, key := range keys {
+		if err := d.table.validateKey("DeleteItems", key); err != nil {
+			d.delayedFunctions = append(d.delayedFunctions, func(*BatchWriteInput) error { return err })
+			return d
+		}
+	}
 	a := []interface{}{}
 	for _, key := range keys {
 		m := map[string]interface{}{}
@@ -1047,9 +2632,20 @@ func (d *batchWriteInput) DeleteItems(keys ...KeyValue) *batchWriteInput {
 	return d
 }
 
-func (d *batchWriteInput) Build() (input []*dynamodb.BatchWriteItemInput, err error) {
+/*Clone ... Copy the batch write so it can be modified and executed independently of the original*/
+func (d *BatchWriteInput) Clone() *BatchWriteInput {
+	return &BatchWriteInput{
+		batches:          []*dynamodb.BatchWriteItemInput{},
+		table:            d.table,
+		delayedFunctions: append([]func(*BatchWriteInput) error(nil), d.delayedFunctions...),
+	}
+}
+
+func (d *BatchWriteInput) Build() (input []*dynamodb.BatchWriteItemInput, err error) {
+	// Rebuild from scratch so that repeated builds don't duplicate requests
+	d.batches = []*dynamodb.BatchWriteItemInput{}
 	for _, function := range d.delayedFunctions {
-		if err = function(); err != nil {
+		if err = function(d); err != nil {
 			return
 		}
 	}
@@ -1065,9 +2661,11 @@ func (d *batchWriteInput) Build() (input []*dynamodb.BatchWriteItemInput, err er
  ** 				The function should store each item pointer in an array before returning.
  **
  */
-func (d *batchWriteInput) ExecuteWith(ctx context.Context, dynamo DynamoDBIFace, opts ...request.Option) (out *batchPutOutput) {
-	out = &batchPutOutput{
+func (d *BatchWriteInput) ExecuteWith(ctx context.Context, dynamo DynamoWriter, opts ...request.Option) (out *BatchWriteOutput) {
+	out = &BatchWriteOutput{
 		dynamoResult: &dynamoResult{},
+		table:        d.table,
+		decoder:      d.table.Decoder,
 	}
 
 	batches, err := d.Build()
@@ -1075,576 +2673,1187 @@ func (d *batchWriteInput) ExecuteWith(ctx context.Context, dynamo DynamoDBIFace,
 		out.err = err
 		return
 	}
+	hooks := d.table.hooks
+	if hooks != nil {
+		for _, batch := range batches {
+			for _, write := range batch.RequestItems[d.table.Name] {
+				if write.PutRequest == nil {
+					continue
+				}
+				if write.PutRequest.Item, out.err = hooks.put(write.PutRequest.Item); out.err != nil {
+					return
+				}
+			}
+		}
+	}
 	for _, batch := range batches {
 		result, err := dynamo.BatchWriteItemWithContext(ctx, batch, opts...)
+		if hooks != nil {
+			d.afterBatch(batch, result, err)
+		}
 		if err != nil {
 			out.err = err
+			out.record()
 			return
 		}
+		out.record(result.ConsumedCapacity...)
 		out.results = append(out.results, result)
 	}
 
 	return
 }
 
-func (d *batchPutOutput) Results(unprocessedItem func() interface{}) (err error) {
-	err = d.Error()
-	if err != nil || d.results == nil || unprocessedItem == nil {
-		return
+/*Execute ... ExecuteWith, also returning the output's error. Unprocessed items are not an error; see UnprocessedPuts*/
+func (d *BatchWriteInput) Execute(ctx context.Context, dynamo DynamoWriter, opts ...request.Option) (*BatchWriteOutput, error) {
+	out := d.ExecuteWith(ctx, dynamo, opts...)
+	return out, out.Error()
+}
+
+/*afterBatch reports each item of a sent batch to the table's hooks, leaving out the unprocessed ones*/
+func (d *BatchWriteInput) afterBatch(batch *dynamodb.BatchWriteItemInput, result *dynamodb.BatchWriteItemOutput, err error) {
+	writeKey := func(write *dynamodb.WriteRequest) (string, DynamoDBValue) {
+		if write.PutRequest != nil {
+			return "PutItem", itemKey(d.table, nil, write.PutRequest.Item)
+		}
+		return "DeleteItem", write.DeleteRequest.Key
 	}
-	for _, result := range d.results {
-		for _, items := range result.UnprocessedItems {
-			for _, item := range items {
-				if err = deserializeTo(item.PutRequest.Item, unprocessedItem()); err != nil {
-					d.err = err
-					return
-				}
-			}
+	// Unprocessed items come back as copies, so are matched by key
+	unprocessed := map[string]bool{}
+	if result != nil {
+		for _, write := range result.UnprocessedItems[d.table.Name] {
+			op, key := writeKey(write)
+			unprocessed[op+cacheKey(d.table.Name, key)] = true
+		}
+	}
+	for _, write := range batch.RequestItems[d.table.Name] {
+		if op, key := writeKey(write); !unprocessed[op+cacheKey(d.table.Name, key)] {
+			d.table.hooks.after(op, key, err)
 		}
 	}
-	return
-}
-
-/***************************************************************************************/
-/*************************************** DeleteItem ************************************/
-/***************************************************************************************/
-type deleteItemInput dynamodb.DeleteItemInput
-type deleteItemOutput struct {
-	*dynamoResult
-	*dynamodb.DeleteItemOutput
-}
-
-/*DeleteItemInput represents dynamo delete item call*/
-func (table DynamoTable) DeleteItem(key KeyValue) *deleteItemInput {
-	q := deleteItemInput(dynamodb.DeleteItemInput{})
-	q.TableName = &table.Name
-	appendKeyAttribute(&q.Key, table, key)
-	return &q
 }
 
-func (d *deleteItemInput) ReturnAllOld() *deleteItemInput {
-	(*dynamodb.DeleteItemInput)(d).SetReturnValues("ALL_OLD")
-	return d
+/*Results ... Deserialize the unprocessed puts, as UnprocessedPuts. Unprocessed deletes are left to UnprocessedDeleteKeys*/
+func (d *BatchWriteOutput) Results(unprocessedItem func() interface{}) (err error) {
+	return d.UnprocessedPuts(unprocessedItem)
 }
 
-func (d *deleteItemInput) ReturnNone() *deleteItemInput {
-	(*dynamodb.DeleteItemInput)(d).SetReturnValues("NONE")
-	return d
+/*unprocessed are the writes dynamo left unprocessed, across every batch*/
+func (d *BatchWriteOutput) unprocessed() (writes []*dynamodb.WriteRequest) {
+	for _, result := range d.results {
+		writes = append(writes, result.UnprocessedItems[d.table.Name]...)
+	}
+	return
 }
 
-func (d *deleteItemInput) SetConditionExpression(c Expression) *deleteItemInput {
-	s, n, m, _ := c.construct("cond", 1, true)
-	d.ConditionExpression = &s
-
-	d.ExpressionAttributeNames = n
-
-	if d.ExpressionAttributeValues == nil {
-		d.ExpressionAttributeValues = marshal(m)
-	} else {
-		for k, v := range marshal(m) {
-			d.ExpressionAttributeValues[k] = v
+/*UnprocessedPuts ... Deserialize each put item dynamo left unprocessed, using a user provided target object generator function*/
+func (d *BatchWriteOutput) UnprocessedPuts(next func() interface{}) (err error) {
+	err = d.Error()
+	if err != nil || next == nil {
+		return
+	}
+	for _, write := range d.unprocessed() {
+		if write.PutRequest == nil {
+			continue
+		}
+		if err = deserializeTo(d.decoder, write.PutRequest.Item, next()); err != nil {
+			d.err = err
+			return
 		}
 	}
-
-	return d
+	return
 }
 
-func (d *deleteItemInput) Build() *dynamodb.DeleteItemInput {
-	r := dynamodb.DeleteItemInput(*d)
-	return &r
+/*UnprocessedDeleteKeys ... The keys of the deletes dynamo left unprocessed*/
+func (d *BatchWriteOutput) UnprocessedDeleteKeys() (keys []KeyValue, err error) {
+	if err = d.Error(); err != nil {
+		return
+	}
+	for _, write := range d.unprocessed() {
+		if write.DeleteRequest == nil {
+			continue
+		}
+		key := DynamoDBValue(write.DeleteRequest.Key).Key(d.table)
+		if err = d.table.validateKey("UnprocessedDeleteKeys", key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return
 }
 
 /**
- ** ExecuteWith ... Execute a dynamo DeleteItem call with a passed in dynamodb instance
- ** ctx - An instance of context
- ** dynamo - The underlying dynamodb api
+ ** BatchDelete ... Delete the items at keys in batches of 25, retrying unprocessed deletes with exponential backoff.
+ ** Every key is validated before anything is sent, and repeated keys are deleted once. See WithBatchConcurrency
+ ** to send batches concurrently. AfterWrite hooks run for each delete once its batch is sent.
  **
+ ** Returns the number of items deleted, and the keys left undeleted once retries ran out or an error stopped the deletes
  */
-func (d *deleteItemInput) ExecuteWith(ctx context.Context, dynamo DynamoDBIFace, opts ...request.Option) (out *deleteItemOutput) {
-	out = &deleteItemOutput{
-		dynamoResult: &dynamoResult{},
+func (table DynamoTable) BatchDelete(ctx context.Context, dynamo DynamoWriter, keys ...KeyValue) (deleted int, unprocessed []KeyValue, err error) {
+	var writes []*dynamodb.WriteRequest
+	seen := map[string]bool{}
+	for _, key := range keys {
+		if err = table.validateKey("BatchDelete", key); err != nil {
+			return 0, nil, err
+		}
+		var av map[string]*dynamodb.AttributeValue
+		if err = appendStoredKey(&av, table, key); err != nil {
+			return 0, nil, err
+		}
+		if k := cacheKey(table.Name, av); !seen[k] {
+			seen[k] = true
+			writes = append(writes, &dynamodb.WriteRequest{DeleteRequest: &dynamodb.DeleteRequest{Key: av}})
+		}
 	}
-	result, err := dynamo.DeleteItemWithContext(ctx, d.Build(), opts...)
-	if err != nil {
-		out.err = err
-		return
+	var batches [][]*dynamodb.WriteRequest
+	for start := 0; start < len(writes); start += 25 {
+		end := start + 25
+		if end > len(writes) {
+			end = len(writes)
+		}
+		batches = append(batches, writes[start:end])
 	}
-	out.DeleteItemOutput = result
-	return
-}
 
-func (o *deleteItemOutput) Result(item interface{}) (err error) {
-	err = o.err
-	if err != nil || o.DeleteItemOutput == nil || item == nil {
-		return
+	workers := table.batchConcurrency
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(batches) {
+		workers = len(batches)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	/*Batches that are never sent stay unprocessed*/
+	remaining := append([][]*dynamodb.WriteRequest(nil), batches...)
+	next := make(chan int)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				n, left, e := table.sendBatch(ctx, dynamo, batches[i], 10, nil)
+				mu.Lock()
+				deleted += n
+				remaining[i] = left
+				if e != nil && err == nil {
+					err = e
+					cancel()
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+feed:
+	for i := range batches {
+		select {
+		case next <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(next)
+	wg.Wait()
+	if err == nil {
+		err = ctx.Err()
 	}
-	if err = deserializeTo(o.DeleteItemOutput.Attributes, item); err != nil {
-		o.err = err
+
+	for _, batch := range remaining {
+		for _, write := range batch {
+			unprocessed = append(unprocessed, DynamoDBValue(write.DeleteRequest.Key).Key(table))
+		}
 	}
 	return
 }
 
 /***************************************************************************************/
-/*********************************** UpdateItem ****************************************/
+/*************************************** Batch Writer **********************************/
 /***************************************************************************************/
-type UpdateInput struct {
-	input            dynamodb.UpdateItemInput
-	delayedFunctions []func() error
-}
 
-type UpdateOutput struct {
-	*dynamodb.UpdateItemOutput
-	*dynamoResult
-}
+/*BatchWriterOption ... Configures DynamoTable.BatchWriter*/
+type BatchWriterOption func(*batchWriterConfig)
 
-/*UpdateInputItem represents dynamo batch get item call*/
-func (table DynamoTable) UpdateItem(key KeyValue) *UpdateInput {
-	q := &UpdateInput{input: dynamodb.UpdateItemInput{TableName: &table.Name}}
-	appendKeyAttribute(&(q.input.Key), table, key)
-	return q
+type batchWriterConfig struct {
+	flushInterval time.Duration
+	maxRetries    int
 }
 
-func (d *UpdateInput) ReturnAllNew() *UpdateInput {
-	d.input.SetReturnValues("ALL_NEW")
-	return d
+/*WriterFlushInterval ... Send a partial batch once d has passed without a full one. Defaults to 1 second*/
+func WriterFlushInterval(d time.Duration) BatchWriterOption {
+	return func(c *batchWriterConfig) { c.flushInterval = d }
 }
 
-func (d *UpdateInput) ReturnAllOld() *UpdateInput {
-	d.input.SetReturnValues("ALL_OLD")
-	return d
+/*WriterMaxRetries ... Give up on a batch's unprocessed writes after n throttled retries. Defaults to 10*/
+func WriterMaxRetries(n int) BatchWriterOption {
+	return func(c *batchWriterConfig) { c.maxRetries = n }
 }
 
-func (d *UpdateInput) ReturnUpdatedNew() *UpdateInput {
-	d.input.SetReturnValues("UPDATED_NEW")
-	return d
-}
+/**
+ ** BatchWriter ... Packs puts and deletes into BatchWriteItem requests in the background
+ ** A batch is sent once 25 writes accumulate or the flush interval passes. Put and Delete block while
+ ** every worker is busy, so a slow table pushes back on its producers. Unprocessed writes are retried with
+ ** exponential backoff; those still unprocessed are reported by Close. The first error, or ctx being done,
+ ** stops the writer.
+ */
+type BatchWriter struct {
+	table    DynamoTable
+	config   batchWriterConfig
+	ctx      context.Context
+	cancel   context.CancelFunc
+	requests chan *dynamodb.WriteRequest
+	batches  chan []*dynamodb.WriteRequest
+	wg       sync.WaitGroup
+	closing  sync.RWMutex
+	closed   bool
+	once     sync.Once
+
+	mu          sync.Mutex
+	written     int
+	unprocessed []*dynamodb.WriteRequest
+	err         error
+}
+
+/*BatchWriter ... Start a BatchWriter. See WithBatchConcurrency to send batches concurrently*/
+func (table DynamoTable) BatchWriter(ctx context.Context, dynamo DynamoWriter, opts ...BatchWriterOption) *BatchWriter {
+	config := batchWriterConfig{flushInterval: time.Second, maxRetries: 10}
+	for _, o := range opts {
+		o(&config)
+	}
+	workers := table.batchConcurrency
+	if workers < 1 {
+		workers = 1
+	}
 
-func (d *UpdateInput) ReturnUpdatedOld() *UpdateInput {
-	d.input.SetReturnValues("UPDATED_OLD")
-	return d
+	w := &BatchWriter{
+		table:    table,
+		config:   config,
+		requests: make(chan *dynamodb.WriteRequest),
+		batches:  make(chan []*dynamodb.WriteRequest),
+	}
+	w.ctx, w.cancel = context.WithCancel(ctx)
+	for i := 0; i < workers; i++ {
+		w.wg.Add(1)
+		go w.write(dynamo)
+	}
+	go w.flush()
+	return w
 }
 
-func (d *UpdateInput) ReturnNone() *UpdateInput {
-	d.input.SetReturnValues("NONE")
-	return d
+/*Put ... Queue an item to be put*/
+func (w *BatchWriter) Put(item interface{}) error {
+	av, err := serialize(w.table.Encoder, item)
+	if err != nil {
+		return err
+	}
+	if av, err = w.table.preparePut(av); err != nil {
+		return err
+	}
+	return w.enqueue(&dynamodb.WriteRequest{PutRequest: &dynamodb.PutRequest{Item: av}})
 }
 
-func (d *UpdateInput) SetConditionExpression(c Expression) *UpdateInput {
-	delayed := func() error {
-		s, n, m, _ := c.construct("cond", 1, true)
-		d.input.ConditionExpression = &s
-
-		d.input.ExpressionAttributeNames = n
-
-		if d.input.ExpressionAttributeValues == nil {
-			d.input.ExpressionAttributeValues = marshal(m)
-		} else {
-			for k, v := range marshal(m) {
-				d.input.ExpressionAttributeValues[k] = v
-			}
-		}
+/*Delete ... Queue the item at key to be deleted*/
+func (w *BatchWriter) Delete(key KeyValue) error {
+	if err := w.table.validateKey("BatchWriter", key); err != nil {
+		return err
+	}
+	var av map[string]*dynamodb.AttributeValue
+	if err := appendStoredKey(&av, w.table, key); err != nil {
+		return err
+	}
+	return w.enqueue(&dynamodb.WriteRequest{DeleteRequest: &dynamodb.DeleteRequest{Key: av}})
+}
 
+func (w *BatchWriter) enqueue(write *dynamodb.WriteRequest) error {
+	w.closing.RLock()
+	defer w.closing.RUnlock()
+	if w.closed {
+		return fmt.Errorf("BatchWriter %s: closed.", w.table.Name)
+	}
+	select {
+	case w.requests <- write:
 		return nil
+	case <-w.ctx.Done():
+		return w.stopped()
 	}
-	d.delayedFunctions = append(d.delayedFunctions, delayed)
-	return d
 }
 
-func (d *UpdateInput) SetUpdateExpression(exprs ...*UpdateExpression) *UpdateInput {
-	m := make(map[string]interface{})
-	ms := make(map[string]string)
+/*stopped is the error that stopped the writer, or ctx's*/
+func (w *BatchWriter) stopped() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return w.err
+	}
+	return w.ctx.Err()
+}
 
-	c := uint(100)
-	for _, expr := range exprs {
-		s, mv, mr, nc := expr.f(c)
-		c = nc
-		for k, v := range mr {
-			m[k] = v
+/**
+ ** Close ... Flush the queued writes and wait for them to finish
+ ** Returns the number of writes processed, the writes left unprocessed once retries ran out or the writer
+ ** stopped, and the error that stopped it
+ */
+func (w *BatchWriter) Close() (written int, unprocessed []*dynamodb.WriteRequest, err error) {
+	w.once.Do(func() {
+		w.closing.Lock()
+		w.closed = true
+		close(w.requests)
+		w.closing.Unlock()
+		w.wg.Wait()
+		w.mu.Lock()
+		if w.err == nil {
+			w.err = w.ctx.Err()
 		}
-		if d.input.ExpressionAttributeNames == nil && len(mv) > 0 {
-			d.input.ExpressionAttributeNames = mv
-		} else {
-			for k, v := range mv {
-				d.input.ExpressionAttributeNames[k] = v
-			}
+		w.mu.Unlock()
+		w.cancel()
+	})
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.written, w.unprocessed, w.err
+}
+
+/*flush packs queued writes into batches. A key already in the batch starts a new one, as dynamo rejects duplicates*/
+func (w *BatchWriter) flush() {
+	defer close(w.batches)
+	ticker := time.NewTicker(w.config.flushInterval)
+	defer ticker.Stop()
+
+	var pending []*dynamodb.WriteRequest
+	keys := map[string]bool{}
+	send := func() bool {
+		if len(pending) <= 0 {
+			return true
 		}
+		select {
+		case w.batches <- pending:
+			pending, keys = nil, map[string]bool{}
+			return true
+		case <-w.ctx.Done():
+			return false
+		}
+	}
+	defer func() {
+		w.mu.Lock()
+		w.unprocessed = append(w.unprocessed, pending...)
+		w.mu.Unlock()
+	}()
 
-		if ms[expr.op] == "" {
-			ms[expr.op] = s
-		} else {
-			ms[expr.op] += ", " + s
+	for {
+		select {
+		case write, ok := <-w.requests:
+			if !ok {
+				send()
+				return
+			}
+			key := cacheKey(w.table.Name, w.key(write))
+			if keys[key] && !send() {
+				pending = append(pending, write)
+				return
+			}
+			pending, keys[key] = append(pending, write), true
+			if len(pending) >= 25 && !send() {
+				return
+			}
+		case <-ticker.C:
+			if !send() {
+				return
+			}
+		case <-w.ctx.Done():
+			return
 		}
 	}
+}
+
+func (w *BatchWriter) key(write *dynamodb.WriteRequest) DynamoDBValue {
+	return w.table.writeKey(write)
+}
 
-	var s string
-	for k, v := range ms {
-		s += k + " " + v + " "
+/*writeKey is the primary key a batch write request writes*/
+func (table DynamoTable) writeKey(write *dynamodb.WriteRequest) DynamoDBValue {
+	if write.PutRequest != nil {
+		return itemKey(table, nil, write.PutRequest.Item)
 	}
+	return write.DeleteRequest.Key
+}
 
-	d.input.UpdateExpression = &s
+/*afterBatch runs the after write hooks for a sent batch. Writes left unprocessed only count as failed on an error*/
+func (table DynamoTable) afterBatch(batch, left []*dynamodb.WriteRequest, err error) {
+	if table.hooks == nil {
+		return
+	}
+	op := func(write *dynamodb.WriteRequest) string {
+		if write.PutRequest != nil {
+			return "PutItem"
+		}
+		return "DeleteItem"
+	}
+	// Unprocessed writes come back as copies, so are matched by key
+	failed := map[string]bool{}
+	for _, write := range left {
+		failed[op(write)+cacheKey(table.Name, table.writeKey(write))] = true
+	}
+	for _, write := range batch {
+		key := table.writeKey(write)
+		if !failed[op(write)+cacheKey(table.Name, key)] {
+			table.hooks.after(op(write), key, nil)
+		} else if err != nil {
+			table.hooks.after(op(write), key, err)
+		}
+	}
+}
 
-	if d.input.ExpressionAttributeValues == nil {
-		d.input.ExpressionAttributeValues = marshal(m)
-	} else {
-		for k, v := range marshal(m) {
-			d.input.ExpressionAttributeValues[k] = v
+/**
+ ** sendBatch is writeBatch to the table, running its after write hooks once the batch is sent. Every bulk write
+ ** (BatchWriter, BatchDelete, DeleteByQuery, ImportJSON and CopyTable) goes through it, so hooks observe them as they
+ ** do single writes. Put items are expected to have been through preparePut.
+ */
+func (table DynamoTable) sendBatch(ctx context.Context, dynamo DynamoWriter, writes []*dynamodb.WriteRequest, maxRetries int,
+	capacity func(...*dynamodb.ConsumedCapacity)) (written int, unprocessed []*dynamodb.WriteRequest, err error) {
+
+	written, unprocessed, err = writeBatch(ctx, dynamo, table.Name, writes, maxRetries, capacity)
+	table.afterBatch(writes, unprocessed, err)
+	return
+}
+
+/**
+ ** sendWithRetry is sendBatch, failing if any writes are still unprocessed once retries run out. Those are reported
+ ** to the after write hooks as failed.
+ **
+ ** Returns the number of requests processed, up to any error
+ */
+func (table DynamoTable) sendWithRetry(ctx context.Context, dynamo DynamoWriter, writes []*dynamodb.WriteRequest, maxRetries int,
+	capacity func(...*dynamodb.ConsumedCapacity)) (written int, err error) {
+
+	written, unprocessed, err := table.sendBatch(ctx, dynamo, writes, maxRetries, capacity)
+	if err == nil && len(unprocessed) > 0 {
+		err = fmt.Errorf("Batch write to %s: items still unprocessed after %d retries.", table.Name, maxRetries)
+		table.afterBatch(unprocessed, unprocessed, err)
+	}
+	return written, err
+}
+
+/*write sends batches until there are none left, or the writer stops*/
+func (w *BatchWriter) write(dynamo DynamoWriter) {
+	defer w.wg.Done()
+	for batch := range w.batches {
+		var n int
+		var left []*dynamodb.WriteRequest
+		err := w.ctx.Err()
+		if err == nil {
+			n, left, err = w.table.sendBatch(w.ctx, dynamo, batch, w.config.maxRetries, nil)
+		} else {
+			left = batch
+			w.table.afterBatch(batch, left, err)
+		}
+
+		w.mu.Lock()
+		w.written += n
+		w.unprocessed = append(w.unprocessed, left...)
+		if err != nil && w.err == nil {
+			w.err = err
+			w.cancel()
 		}
+		w.mu.Unlock()
+	}
+}
+
+/***************************************************************************************/
+/*************************************** DeleteItem ************************************/
+/***************************************************************************************/
+type DeleteItemInput struct {
+	*dynamodb.DeleteItemInput
+	conditions []Expression
+	decoder    *dynamodbattribute.Decoder
+	hooks      *writeHooks
+	err        error
+}
+type DeleteItemOutput struct {
+	*dynamoResult
+	*dynamodb.DeleteItemOutput
+	decoder *dynamodbattribute.Decoder
+}
+
+/*DeleteItemInput represents dynamo delete item call*/
+func (table DynamoTable) DeleteItem(key KeyValue) *DeleteItemInput {
+	q := DeleteItemInput{DeleteItemInput: &dynamodb.DeleteItemInput{}, decoder: table.Decoder, hooks: table.hooks}
+	q.TableName = &table.Name
+	if q.err = table.validateKey("DeleteItem", key); q.err == nil {
+		q.err = appendStoredKey(&q.Key, table, key)
 	}
+	return &q
+}
+
+/*DeleteItemOf ... A delete item call for the stored version of item*/
+func (table DynamoTable) DeleteItemOf(item interface{}) (*DeleteItemInput, error) {
+	key, err := table.KeyOf(item)
+	if err != nil {
+		return nil, err
+	}
+	return table.DeleteItem(key), nil
+}
 
+func (d *DeleteItemInput) ReturnAllOld() *DeleteItemInput {
+	d.DeleteItemInput.SetReturnValues("ALL_OLD")
 	return d
 }
 
-func (d *UpdateInput) Build() (r *dynamodb.UpdateItemInput, err error) {
+func (d *DeleteItemInput) ReturnNone() *DeleteItemInput {
+	d.DeleteItemInput.SetReturnValues("NONE")
+	return d
+}
 
-	for _, function := range d.delayedFunctions {
-		err = function()
-		if err != nil {
-			return nil, err
-		}
+/*SetConditionExpression ... Set the condition expression. Repeated calls are and'd together*/
+func (d *DeleteItemInput) SetConditionExpression(c Expression) *DeleteItemInput {
+	d.conditions = append(d.conditions, c)
+	s, n, m, _ := conjunction(d.conditions).construct("cond", 1, true)
+	d.ConditionExpression = &s
+
+	appendNames(&d.ExpressionAttributeNames, n)
+	appendValues(&d.ExpressionAttributeValues, marshal(m))
+
+	return d
+}
+
+func (d *DeleteItemInput) Build() (*dynamodb.DeleteItemInput, error) {
+	if d.err != nil {
+		return nil, d.err
 	}
-	rr := dynamodb.UpdateItemInput((*d).input)
-	return &rr, err
+	r := *d.DeleteItemInput
+	if err := validateExpressions("DeleteItem", r.ExpressionAttributeNames, r.ExpressionAttributeValues,
+		namedExpression{"condition", r.ConditionExpression}); err != nil {
+		return nil, err
+	}
+	return &r, nil
 }
 
 /**
- ** ExecuteWith ... Execute a dynamo BatchGetItem call with a passed in dynamodb instance
- ** ctx - an instance of context
+ ** ExecuteWith ... Execute a dynamo DeleteItem call with a passed in dynamodb instance
+ ** ctx - An instance of context
  ** dynamo - The underlying dynamodb api
  **
  */
-func (d *UpdateInput) ExecuteWith(ctx context.Context, dynamo DynamoDBIFace, opts ...request.Option) (out *UpdateOutput) {
-	out = &UpdateOutput{
+func (d *DeleteItemInput) ExecuteWith(ctx context.Context, dynamo DynamoWriter, opts ...request.Option) (out *DeleteItemOutput) {
+	out = &DeleteItemOutput{
 		dynamoResult: &dynamoResult{},
+		decoder:      d.decoder,
 	}
 	input, err := d.Build()
 	if err != nil {
 		out.err = err
 		return
 	}
-	out.UpdateItemOutput, out.err = dynamo.UpdateItemWithContext(ctx, input, opts...)
-
+	result, err := dynamo.DeleteItemWithContext(ctx, input, opts...)
+	d.hooks.after("DeleteItem", input.Key, err)
+	if err != nil {
+		out.err = err
+		out.record()
+		return
+	}
+	out.record(result.ConsumedCapacity)
+	out.DeleteItemOutput = result
 	return
 }
-func (o *UpdateOutput) Result(item interface{}) (err error) {
-	err = o.err
-	if err != nil || o.UpdateItemOutput == nil || item == nil {
-		return
+
+/*Execute ... ExecuteWith, also returning the output's error*/
+func (d *DeleteItemInput) Execute(ctx context.Context, dynamo DynamoWriter, opts ...request.Option) (*DeleteItemOutput, error) {
+	out := d.ExecuteWith(ctx, dynamo, opts...)
+	return out, out.Error()
+}
+
+func (o *DeleteItemOutput) Result(item interface{}) (err error) {
+	if o.DeleteItemOutput == nil {
+		return o.Error()
 	}
-	if err := deserializeTo(o.UpdateItemOutput.Attributes, item); err != nil {
-		o.err = err
+	return o.deserialize(o.decoder, o.DeleteItemOutput.Attributes, item)
+}
+
+/*ResultOK ... Like Result, but reports whether any attributes were returned. There are none if ReturnValues was NONE or no item was deleted*/
+func (o *DeleteItemOutput) ResultOK(item interface{}) (found bool, err error) {
+	if o.err != nil || o.DeleteItemOutput == nil || len(o.Attributes) <= 0 {
+		return false, o.err
 	}
-	return
+	return true, o.Result(item)
 }
 
 /***************************************************************************************/
-/********************************************** Query **********************************/
+/*********************************** UpdateItem ****************************************/
 /***************************************************************************************/
-type QueryInput struct {
-	*dynamodb.QueryInput
-	pageSize         *int64
-	capacityHandlers []func(*dynamodb.ConsumedCapacity)
+type UpdateInput struct {
+	input            dynamodb.UpdateItemInput
+	conditions       []Expression
+	updateClauses    map[string][]string
+	updateCounter    uint
+	versioned        bool
+	keyExists        Expression
+	keyNotExists     Expression
+	decoder          *dynamodbattribute.Decoder
+	hooks            *writeHooks
+	delayedFunctions []func(*UpdateInput) error
 }
 
-type QueryOutput struct {
+type UpdateOutput struct {
+	*dynamodb.UpdateItemOutput
 	*dynamoResult
-	outputFunc func() (*dynamodb.QueryOutput, error)
-	limit      *int64
-	ctx        context.Context
+	decoder *dynamodbattribute.Decoder
 }
 
-/*QueryInput represents dynamo batch get item call*/
-func (table DynamoTable) Query(partitionKeyCondition KeyCondition, rangeKeyCondition *KeyCondition) *QueryInput {
-	q := QueryInput{
-		QueryInput: &dynamodb.QueryInput{},
+/*UpdateInputItem represents dynamo batch get item call*/
+func (table DynamoTable) UpdateItem(key KeyValue) *UpdateInput {
+	q := table.update()
+	err := table.validateKey("UpdateItem", key)
+	if err == nil {
+		err = appendStoredKey(&(q.input.Key), table, key)
 	}
-
-	var e Expression
-	if rangeKeyCondition != nil {
-		e = And(partitionKeyCondition, *rangeKeyCondition)
-	} else {
-		e = partitionKeyCondition
+	if err != nil {
+		q.delayedFunctions = append(q.delayedFunctions, func(*UpdateInput) error { return err })
 	}
-
-	s, n, m, _ := e.construct("cond", 0, true)
-	q.TableName = &table.Name
-	q.KeyConditionExpression = &s
-	q.ExpressionAttributeNames = n
-	q.ExpressionAttributeValues = marshal(m)
-
-	return &q
+	return q
 }
 
-func (d *QueryInput) SetConsistentRead(c bool) *QueryInput {
-	(*d).ConsistentRead = &c
-	return d
-}
-func (d *QueryInput) SetAttributesToGet(fields []DynamoField) *QueryInput {
-	a := make([]*string, len(fields))
-	for i, f := range fields {
-		v := f.Name()
-		a[i] = &v
+/*update is an update of the table with no key, which the caller sets*/
+func (table DynamoTable) update() *UpdateInput {
+	q := &UpdateInput{
+		input:         dynamodb.UpdateItemInput{TableName: &table.Name},
+		updateCounter: 100,
+		keyExists:     table.exists(),
+		keyNotExists:  table.notExists(),
+		decoder:       table.Decoder,
+		hooks:         table.hooks,
 	}
-	(*d).AttributesToGet = a
-	return d
+	if table.timestamps != nil {
+		q.delayedFunctions = append(q.delayedFunctions, table.timestamps.stampUpdate)
+	}
+	return q
 }
 
-func (d *QueryInput) SetLimit(limit int) *QueryInput {
-	s := int64(limit)
-	d.Limit = &s
+func (d *UpdateInput) ReturnAllNew() *UpdateInput {
+	d.input.SetReturnValues("ALL_NEW")
 	return d
 }
 
-func (d *QueryInput) SetPageSize(pageSize int) *QueryInput {
-	ps := int64(pageSize)
-	d.pageSize = &ps
+func (d *UpdateInput) ReturnAllOld() *UpdateInput {
+	d.input.SetReturnValues("ALL_OLD")
 	return d
 }
 
-func (d *QueryInput) SetScanForward(forward bool) *QueryInput {
-	d.ScanIndexForward = &forward
+func (d *UpdateInput) ReturnUpdatedNew() *UpdateInput {
+	d.input.SetReturnValues("UPDATED_NEW")
 	return d
 }
 
-func (d *QueryInput) WithConsumedCapacityHandler(f func(*dynamodb.ConsumedCapacity)) *QueryInput {
-	d.ReturnConsumedCapacity = aws.String("INDEXES")
-	d.capacityHandlers = append(d.capacityHandlers, f)
+func (d *UpdateInput) ReturnUpdatedOld() *UpdateInput {
+	d.input.SetReturnValues("UPDATED_OLD")
 	return d
 }
 
-func (d *QueryInput) WithLastEvaluatedKey(key DynamoDBValue) *QueryInput {
-	d.ExclusiveStartKey = key
+func (d *UpdateInput) ReturnNone() *UpdateInput {
+	d.input.SetReturnValues("NONE")
 	return d
 }
 
-func (d *QueryInput) SetFilterExpression(c Expression) *QueryInput {
-	s, n, m, _ := c.construct("filter", 1, true)
-	d.FilterExpression = &s
+/*SetConditionExpression ... Set the condition expression. Repeated calls are and'd together*/
+func (d *UpdateInput) SetConditionExpression(c Expression) *UpdateInput {
+	d.conditions = append(d.conditions, c)
+	s, n, m, _ := conjunction(d.conditions).construct("cond", 1, true)
+	d.input.ConditionExpression = &s
 
-	d.ExpressionAttributeNames = n
-	if d.ExpressionAttributeValues == nil {
-		d.ExpressionAttributeValues = marshal(m)
-	} else {
-		for k, v := range marshal(m) {
-			d.ExpressionAttributeValues[k] = v
-		}
-	}
+	appendNames(&d.input.ExpressionAttributeNames, n)
+	appendValues(&d.input.ExpressionAttributeValues, marshal(m))
 
 	return d
 }
 
-func (d *QueryInput) SetLocalIndex(idx LocalSecondaryIndex) *QueryInput {
-	d.IndexName = &idx.Name
-	return d
+/*RequireExists ... Only update an item that already exists, rather than upserting a partial item. And'd with any other condition*/
+func (d *UpdateInput) RequireExists() *UpdateInput {
+	return d.SetConditionExpression(d.keyExists)
 }
 
-func (d *QueryInput) SetGlobalIndex(idx GlobalSecondaryIndex) *QueryInput {
-	d.IndexName = &idx.Name
-	return d
+/*RequireNotExists ... Only update, creating it, an item that doesn't exist yet. And'd with any other condition*/
+func (d *UpdateInput) RequireNotExists() *UpdateInput {
+	return d.SetConditionExpression(d.keyNotExists)
 }
 
-func (d *QueryInput) Build() *dynamodb.QueryInput {
-	r := dynamodb.QueryInput(*d.QueryInput)
-	if d.pageSize != nil {
-		r.Limit = d.pageSize
-	}
-
-	return &r
+/*SetUpdateExpression ... Register update expressions. Repeated calls are additive, as with AddUpdateExpression. nil expressions are skipped*/
+func (d *UpdateInput) SetUpdateExpression(exprs ...*UpdateExpression) *UpdateInput {
+	return d.AddUpdateExpression(exprs...)
 }
 
-/**
- ** StreamWith ... Execute a dynamo Stream call with a passed in dynamodb instance and next item pointer
- ** ctx - An instance of context
- ** dynamo - The underlying dynamodb api
- **
- */
-
-func (d *QueryInput) ExecuteWith(ctx context.Context, db DynamoDBIFace, opts ...request.Option) (out *QueryOutput) {
-
-	out = &QueryOutput{
-		dynamoResult: &dynamoResult{},
-		ctx:          ctx,
-		limit:        d.Limit,
+/*AddUpdateExpression ... Merge update expressions with any previously registered on this update. nil expressions are skipped, so optional updates needn't be filtered out first*/
+func (d *UpdateInput) AddUpdateExpression(exprs ...*UpdateExpression) *UpdateInput {
+	m := make(map[string]interface{})
+	if d.updateClauses == nil {
+		d.updateClauses = make(map[string][]string)
 	}
 
-	q := d.Build()
-
-	out.outputFunc = func() (o *dynamodb.QueryOutput, err error) {
-		if q == nil {
-			return
+	for _, expr := range exprs {
+		if expr == nil {
+			continue
 		}
-		o, err = db.QueryWithContext(ctx, q, opts...)
-		if err != nil {
-			out.err = err
-			return
+		s, mv, mr, nc := expr.f(d.updateCounter)
+		d.updateCounter = nc
+		for k, v := range mr {
+			m[k] = v
 		}
-		for _, handler := range d.capacityHandlers {
-			handler(o.ConsumedCapacity)
+		appendNames(&d.input.ExpressionAttributeNames, mv)
+
+		if s != "" {
+			d.updateClauses[expr.op] = append(d.updateClauses[expr.op], s)
 		}
+	}
 
-		if o.LastEvaluatedKey != nil {
-			q.ExclusiveStartKey = o.LastEvaluatedKey
-		} else {
-			q = nil
+	var clauses []string
+	for _, op := range updateOps {
+		if len(d.updateClauses[op]) > 0 {
+			clauses = append(clauses, op+" "+strings.Join(d.updateClauses[op], ", "))
 		}
-		return
 	}
+	s := strings.Join(clauses, " ")
 
-	return
+	d.input.UpdateExpression = &s
+
+	appendValues(&d.input.ExpressionAttributeValues, marshal(m))
 
+	return d
 }
 
-/**
- ** Results ... given a next() function, continually hydrates the returned interface. Results are comprehensive,
- ** in that no manual paging (via LastEvaluatedKey) is required to fetch additional results.
- ** next - function which returns successive structs for hydration.
- **
- */
+/*DiffOption ... Configures DiffUpdate*/
+type DiffOption func(*diffConfig)
 
-func (o *QueryOutput) Results(next func() interface{}) (err error) {
-	err = o.err
-	if err != nil || o.outputFunc == nil {
-		return
-	}
-	var count int64
+type diffConfig struct {
+	nested bool
+}
 
-	//loop, calling output function until the results are empty
-	//output function transparently pages using LastEvaluatedKey internally
-	for {
-		var out *dynamodb.QueryOutput
-		if out, err = o.outputFunc(); err != nil {
-			o.err = err
-			return
-		} else if out == nil || len(out.Items) <= 0 {
-			return
-		}
+/*DiffNested ... Descend into maps present in both items, emitting path updates rather than replacing the whole map*/
+func DiffNested() DiffOption {
+	return func(c *diffConfig) { c.nested = true }
+}
 
-		for _, av := range out.Items {
-			if o.limit != nil && count >= *o.limit {
-				return
-			}
-			count++
-			item := next()
-			if err = deserializeTo(av, item); err != nil {
-				o.err = err
-				return
-			}
+/*DiffUpdate ... Compute the SET and REMOVE expressions that turn old into new. Key attributes are never touched. The result can be passed to SetUpdateExpression*/
+func (table DynamoTable) DiffUpdate(old, new interface{}, opts ...DiffOption) ([]*UpdateExpression, error) {
+	c := diffConfig{}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	o, err := serialize(table.Encoder, old)
+	if err != nil {
+		return nil, err
+	}
+	n, err := serialize(table.Encoder, new)
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range table.keyNames() {
+		delete(o, k)
+		delete(n, k)
+	}
+	var exprs []*UpdateExpression
+	for _, name := range diffNames(o, n) {
+		// A path without keys renders the attribute itself through a name placeholder, so reserved words are safe
+		path := MapPath{name: name}
+		before, had := o[name]
+		after, has := n[name]
+		switch {
+		case !has:
+			exprs = append(exprs, path.Remove())
+		case had && c.nested && before.M != nil && after.M != nil:
+			exprs = append(exprs, diffMap(path, before.M, after.M)...)
+		case !had || !attributeEqual(before, after):
+			exprs = append(exprs, path.Set(after))
+		}
+	}
+	return exprs, nil
+}
+
+/*diffMap recursively diffs two maps found at path p*/
+func diffMap(p MapPath, o, n map[string]*dynamodb.AttributeValue) (exprs []*UpdateExpression) {
+	for _, k := range diffNames(o, n) {
+		path := MapPath{name: p.name, keys: append(append([]string{}, p.keys...), k)}
+		before, had := o[k]
+		after, has := n[k]
+		switch {
+		case !has:
+			exprs = append(exprs, path.Remove())
+		case had && before.M != nil && after.M != nil:
+			exprs = append(exprs, diffMap(path, before.M, after.M)...)
+		case !had || !attributeEqual(before, after):
+			exprs = append(exprs, path.Set(after))
 		}
-
 	}
 	return
 }
 
+/*diffNames are the sorted union of both maps' attribute names*/
+func diffNames(o, n map[string]*dynamodb.AttributeValue) []string {
+	names := make([]string, 0, len(n))
+	for k := range n {
+		names = append(names, k)
+	}
+	for k := range o {
+		if _, ok := n[k]; !ok {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+/*attributeEqual compares attribute values, ignoring the order of set members*/
+func attributeEqual(a, b *dynamodb.AttributeValue) bool {
+	switch {
+	case a.SS != nil && b.SS != nil:
+		return sameMembers(aws.StringValueSlice(a.SS), aws.StringValueSlice(b.SS))
+	case a.NS != nil && b.NS != nil:
+		return sameMembers(aws.StringValueSlice(a.NS), aws.StringValueSlice(b.NS))
+	case a.BS != nil && b.BS != nil:
+		as, bs := make([]string, len(a.BS)), make([]string, len(b.BS))
+		for i, v := range a.BS {
+			as[i] = string(v)
+		}
+		for i, v := range b.BS {
+			bs[i] = string(v)
+		}
+		return sameMembers(as, bs)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func sameMembers(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a, b = append([]string{}, a...), append([]string{}, b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	return reflect.DeepEqual(a, b)
+}
+
+/*WithOptimisticLock ... Only update if the stored item is at version current, 0 meaning unversioned, and bump it. A lost race is reported as ErrVersionConflict*/
+func (d *UpdateInput) WithOptimisticLock(field Numeric, current int64) *UpdateInput {
+	if current == 0 {
+		d.SetConditionExpression(field.NotExists())
+	} else {
+		d.SetConditionExpression(field.Equals(current))
+	}
+	d.versioned = true
+	return d.AddUpdateExpression(field.SetField(current+1, false))
+}
+
+/*Clone ... Deep copy the update so it can be modified and executed independently of the original*/
+func (d *UpdateInput) Clone() *UpdateInput {
+	c := &UpdateInput{
+		input:            d.input,
+		conditions:       append([]Expression(nil), d.conditions...),
+		updateClauses:    make(map[string][]string, len(d.updateClauses)),
+		updateCounter:    d.updateCounter,
+		versioned:        d.versioned,
+		keyExists:        d.keyExists,
+		keyNotExists:     d.keyNotExists,
+		decoder:          d.decoder,
+		hooks:            d.hooks,
+		delayedFunctions: append([]func(*UpdateInput) error(nil), d.delayedFunctions...),
+	}
+	for op, clauses := range d.updateClauses {
+		c.updateClauses[op] = append([]string(nil), clauses...)
+	}
+	c.input.Key = cloneValue(d.input.Key)
+	c.input.ExpressionAttributeNames = cloneNames(d.input.ExpressionAttributeNames)
+	c.input.ExpressionAttributeValues = cloneValue(d.input.ExpressionAttributeValues)
+	return c
+}
+
+func (d *UpdateInput) Build() (r *dynamodb.UpdateItemInput, err error) {
+
+	for _, function := range d.delayedFunctions {
+		err = function(d)
+		if err != nil {
+			return nil, err
+		}
+	}
+	rr := dynamodb.UpdateItemInput((*d).input)
+	if aws.StringValue(rr.UpdateExpression) == "" {
+		return nil, fmt.Errorf("UpdateItem %s: no update expressions were given.", aws.StringValue(rr.TableName))
+	}
+	if err = validateExpressions("UpdateItem", rr.ExpressionAttributeNames, rr.ExpressionAttributeValues,
+		namedExpression{"update", rr.UpdateExpression}, namedExpression{"condition", rr.ConditionExpression}); err != nil {
+		return nil, err
+	}
+	return &rr, nil
+}
+
+/*DebugString ... Render the update's key, expression and condition with values inlined. For debugging only*/
+func (d *UpdateInput) DebugString() string {
+	input, err := d.Clone().Build()
+	if err != nil {
+		return fmt.Sprintf("<%v>", err)
+	}
+	clauses := []string{"KEY " + debugValue(&dynamodb.AttributeValue{M: input.Key})}
+	if input.UpdateExpression != nil {
+		clauses = append(clauses, debugSubstitute(*input.UpdateExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues))
+	}
+	if input.ConditionExpression != nil {
+		clauses = append(clauses, "CONDITION "+debugSubstitute(*input.ConditionExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues))
+	}
+	return strings.Join(clauses, " ")
+}
+
 /**
- ** ResultsList ... Return a page of results, along with the LastEvaludatedKey or an error
- ** Results can be hydrated to domain objects via the LoadDynamoDBValue function, if the domain object
- ** implements the Loader interface.
+ ** ExecuteWith ... Execute a dynamo BatchGetItem call with a passed in dynamodb instance
+ ** ctx - an instance of context
+ ** dynamo - The underlying dynamodb api
  **
  */
-
-func (o *QueryOutput) ResultsList() (values []DynamoDBValue, LastEvaluatedKey DynamoDBValue, err error) {
-	var out *dynamodb.QueryOutput
-	if out, err = o.outputFunc(); err != nil {
+func (d *UpdateInput) ExecuteWith(ctx context.Context, dynamo DynamoWriter, opts ...request.Option) (out *UpdateOutput) {
+	out = &UpdateOutput{
+		dynamoResult: &dynamoResult{},
+		decoder:      d.decoder,
+	}
+	input, err := d.Build()
+	if err == nil {
+		err = d.hooks.update(input)
+	}
+	if err != nil {
+		out.err = err
 		return
 	}
-
-	LastEvaluatedKey = out.LastEvaluatedKey
-	for _, i := range out.Items {
-		values = append(values, i)
+	out.UpdateItemOutput, out.err = dynamo.UpdateItemWithContext(ctx, input, opts...)
+	if out.UpdateItemOutput != nil {
+		out.record(out.ConsumedCapacity)
+	} else {
+		out.record()
 	}
+	if d.versioned && out.ConditionalCheckFailed() {
+		out.err = ErrVersionConflict
+	}
+	d.hooks.after("UpdateItem", input.Key, out.err)
 
 	return
+}
 
+/*Execute ... ExecuteWith, also returning the output's error, so a failed condition can't go unnoticed*/
+func (d *UpdateInput) Execute(ctx context.Context, dynamo DynamoWriter, opts ...request.Option) (*UpdateOutput, error) {
+	out := d.ExecuteWith(ctx, dynamo, opts...)
+	return out, out.Error()
+}
+func (o *UpdateOutput) Result(item interface{}) (err error) {
+	if o.UpdateItemOutput == nil {
+		return o.Error()
+	}
+	return o.deserialize(o.decoder, o.UpdateItemOutput.Attributes, item)
 }
 
-func (o *QueryOutput) StreamWithChannel(channel interface{}) (errChan chan error) {
-	t := reflect.TypeOf(channel).Elem()
-	isPtr := t.Kind() == reflect.Ptr
-	if isPtr {
-		t = t.Elem()
+/*ResultOK ... Like Result, but reports whether any attributes were returned. There are none if ReturnValues was NONE*/
+func (o *UpdateOutput) ResultOK(item interface{}) (found bool, err error) {
+	if o.err != nil || o.UpdateItemOutput == nil || len(o.Attributes) <= 0 {
+		return false, o.err
 	}
-	vc := reflect.ValueOf(channel)
-	errChan = make(chan error, 1)
-	count := int64(0)
-	go func() {
-		defer close(errChan)
-		defer vc.Close()
+	return true, o.Result(item)
+}
 
-		for {
-			out, err := o.outputFunc()
-			if err != nil {
-				errChan <- err
-				return
-			} else if out == nil || len(out.Items) <= 0 {
-				return
-			}
-			for _, av := range out.Items {
-				if o.limit != nil && count >= *o.limit {
-					return
-				}
-				item := reflect.New(t).Interface()
-				count++
-				if err := deserializeTo(av, item); err != nil {
-					errChan <- err
-					return
-				} else {
-					value := reflect.ValueOf(item)
-					if !isPtr {
-						value = reflect.Indirect(value)
-					}
-					c := reflect.SelectCase{
-						Dir:  reflect.SelectSend,
-						Chan: vc,
-						Send: value,
-					}
-					d := reflect.SelectCase{
-						Dir:  reflect.SelectRecv,
-						Chan: reflect.ValueOf(o.ctx.Done()),
-						Send: reflect.Value{},
-					}
-					if idx, _, _ := reflect.Select([]reflect.SelectCase{c, d}); idx == 1 {
-						// ctx done
-						return
-					}
-				}
-			}
+/**
+ ** IncrementField ... Atomically add by to a numeric field and return its new value
+ ** key - the item to update
+ ** field - the counter to increment. A negative by decrements it
+ ** bounds - optional conditions on the existing item, e.g. field.LessThan(max) to cap the counter.
+ ** A failed bound surfaces as a ConditionalCheckFailedException
+ **
+ */
+func (table DynamoTable) IncrementField(ctx context.Context, dynamo DynamoWriter, key KeyValue, field Numeric, by int64, bounds ...Expression) (int64, error) {
+	q := table.UpdateItem(key).
+		SetUpdateExpression(field.AddInt(by)).
+		ReturnUpdatedNew()
+	if len(bounds) > 0 {
+		q.SetConditionExpression(conjunction(bounds))
+	}
+
+	out := q.ExecuteWith(ctx, dynamo)
+	if err := out.Error(); err != nil {
+		return 0, err
+	}
+	av, ok := out.Attributes[field.Name()]
+	if !ok || av.N == nil {
+		return 0, MissingCounterError
+	}
+	return strconv.ParseInt(*av.N, 10, 64)
+}
+
+/**
+ ** ResetItem ... Strip an item down to its keys, keeping the row. Returns the removed attribute names
+ ** key - the item to reset
+ ** keep - fields to preserve besides the table's keys
+ **
+ ** The item is read consistently first, a missing item has nothing to remove
+ */
+func (table DynamoTable) ResetItem(ctx context.Context, dynamo DynamoDBIFace, key KeyValue, keep ...DynamoFieldIFace) ([]string, error) {
+	out := table.GetItem(key).SetConsistentRead(true).ExecuteWith(ctx, dynamo)
+	if !out.Found() {
+		return nil, out.Error()
+	}
+	attributes := make([]string, 0, len(out.Item))
+	for name := range out.Item {
+		attributes = append(attributes, name)
+	}
+	return table.ResetItemAttributes(ctx, dynamo, key, attributes, keep...)
+}
+
+/**
+ ** ResetItemAttributes ... Like ResetItem, for callers that already know the item's attribute names
+ ** attributes - the item's current attribute names. Keys and kept fields among them are left alone
+ **
+ ** The removal is conditioned on the item existing, so a concurrently deleted item isn't recreated
+ */
+func (table DynamoTable) ResetItemAttributes(ctx context.Context, dynamo DynamoWriter, key KeyValue, attributes []string, keep ...DynamoFieldIFace) ([]string, error) {
+	kept := map[string]bool{}
+	for _, name := range table.keyNames() {
+		kept[name] = true
+	}
+	for _, field := range keep {
+		kept[field.Name()] = true
+	}
+	if table.timestamps != nil {
+		/*The update stamps them again*/
+		kept[table.timestamps.created.Name()] = true
+		kept[table.timestamps.updated.Name()] = true
+	}
+	var removed []string
+	for _, name := range attributes {
+		if !kept[name] {
+			kept[name] = true
+			removed = append(removed, name)
 		}
-	}()
+	}
+	if len(removed) <= 0 {
+		return nil, nil
+	}
+	sort.Strings(removed)
 
-	return
+	err := table.UpdateItem(key).
+		SetUpdateExpression(removeAttributes(removed)).
+		RequireExists().
+		ExecuteWith(ctx, dynamo).
+		Error()
+	if err != nil {
+		return nil, err
+	}
+	return removed, nil
 }
 
 /***************************************************************************************/
-/********************************************** Scan **********************************/
+/********************************************** Query **********************************/
 /***************************************************************************************/
-type ScanInput struct {
-	*dynamodb.ScanInput
-	pageSize *int64
+type QueryInput struct {
+	*dynamodb.QueryInput
+	table            DynamoTable
+	filters          []Expression
+	pageSize         *int64
+	singlePage       bool
+	index            *secondaryIndex
+	err              error
+	workers          int
+	maxScanned       *int64
+	deadlineMargin   *time.Duration
+	capacityBudget   *float64
+	capacityHandlers []func(*dynamodb.ConsumedCapacity)
+	pageHandlers     []func(int, []DynamoDBValue, DynamoDBValue)
 }
 
-type ScanOutput struct {
+type QueryOutput struct {
 	*dynamoResult
-	outputFunc func() (*dynamodb.ScanOutput, error)
-	Error      error
-	limit      *int64
-	ctx        context.Context
+	outputFunc       func() (*dynamodb.QueryOutput, error)
+	limit            *int64
+	lastEvaluatedKey DynamoDBValue
+	keyOf            func(DynamoDBValue) DynamoDBValue
+	count            int64
+	scannedCount     int64
+	maxScanned       bool
+	partial          bool
+	decoder          *dynamodbattribute.Decoder
+	workers          int
+	ctx              context.Context
+}
+
+/*Page ... A page of query or scan results, with the metadata dynamo returned for it*/
+type Page struct {
+	Items            []DynamoDBValue
+	LastEvaluatedKey DynamoDBValue
+	Count            int64
+	ScannedCount     int64
+	ConsumedCapacity *dynamodb.ConsumedCapacity
 }
 
-/*ScanOutput represents dynamo scan item call*/
-func (table DynamoTable) Scan() (q *ScanInput) {
+/*QueryInput represents dynamo batch get item call*/
+func (table DynamoTable) Query(partitionKeyCondition KeyCondition, rangeKeyCondition *KeyCondition) *QueryInput {
+	q := QueryInput{
+		QueryInput: &dynamodb.QueryInput{},
+		table:      table,
+	}
+	if table.Defaults.ConsistentRead {
+		q.SetConsistentRead(true)
+	}
+	if table.Defaults.ReturnConsumedCapacity != "" {
+		q.ReturnConsumedCapacity = aws.String(table.Defaults.ReturnConsumedCapacity)
+	}
 
-	q = &ScanInput{
-		ScanInput: &dynamodb.ScanInput{},
+	var e Expression
+	if rangeKeyCondition != nil {
+		e = And(partitionKeyCondition, *rangeKeyCondition)
+	} else {
+		e = partitionKeyCondition
 	}
 
+	s, n, m, _ := e.construct("cond", 0, true)
 	q.TableName = &table.Name
-	return
+	q.KeyConditionExpression = &s
+	q.ExpressionAttributeNames = n
+	q.ExpressionAttributeValues = marshal(m)
+
+	return &q
 }
 
-func (d *ScanInput) SetConsistentRead(c bool) *ScanInput {
+/**
+ ** QueryIndex ... Query a global secondary index. The key conditions are checked against the index's
+ ** declared keys, and Build returns any mismatch. The table's consistent read default doesn't apply,
+ ** global indexes don't support it
+ */
+func (table DynamoTable) QueryIndex(gsi GlobalSecondaryIndex, partitionKeyCondition KeyCondition, rangeKeyCondition *KeyCondition) *QueryInput {
+	q := table.Query(partitionKeyCondition, rangeKeyCondition).SetGlobalIndex(gsi)
+	q.ConsistentRead = nil
+	q.err = table.validateIndexConditions("QueryIndex", q.index, partitionKeyCondition, rangeKeyCondition)
+	return q
+}
+
+/*QueryLocalIndex ... Query a local secondary index. The key conditions are checked against the index's declared keys*/
+func (table DynamoTable) QueryLocalIndex(lsi LocalSecondaryIndex, partitionKeyCondition KeyCondition, rangeKeyCondition *KeyCondition) *QueryInput {
+	q := table.Query(partitionKeyCondition, rangeKeyCondition).SetLocalIndex(lsi)
+	q.err = table.validateIndexConditions("QueryLocalIndex", q.index, partitionKeyCondition, rangeKeyCondition)
+	return q
+}
+
+/*validateIndexConditions checks the index is the table's, and the key conditions are on its keys*/
+func (table DynamoTable) validateIndexConditions(op string, index *secondaryIndex, partitionKeyCondition KeyCondition, rangeKeyCondition *KeyCondition) error {
+	if table.secondaryIndex(index.name) == nil {
+		return fmt.Errorf("%s %s: table has no index %s.", op, table.Name, index.name)
+	}
+	schema := table.Name + " index " + index.name
+	if partitionKeyCondition.field != index.partitionKey.Name() {
+		return fmt.Errorf("%s %s: partition key condition is on %s, expected %s.", op, schema, partitionKeyCondition.field, index.partitionKey.Name())
+	}
+	if rangeKeyCondition == nil {
+		return nil
+	}
+	if index.rangeKey == nil || index.rangeKey.IsEmpty() {
+		return fmt.Errorf("%s %s: index has no range key, but a range key condition on %s was given.", op, schema, rangeKeyCondition.field)
+	}
+	if rangeKeyCondition.field != index.rangeKey.Name() {
+		return fmt.Errorf("%s %s: range key condition is on %s, expected %s.", op, schema, rangeKeyCondition.field, index.rangeKey.Name())
+	}
+	return nil
+}
+
+func (d *QueryInput) SetConsistentRead(c bool) *QueryInput {
 	(*d).ConsistentRead = &c
 	return d
 }
-func (d *ScanInput) SetAttributesToGet(fields []DynamoField) *ScanInput {
+func (d *QueryInput) SetAttributesToGet(fields []DynamoField) *QueryInput {
 	a := make([]*string, len(fields))
 	for i, f := range fields {
 		v := f.Name()
@@ -1654,120 +3863,323 @@ func (d *ScanInput) SetAttributesToGet(fields []DynamoField) *ScanInput {
 	return d
 }
 
-func (d *ScanInput) SetLimit(limit int) *ScanInput {
+func (d *QueryInput) SetLimit(limit int) *QueryInput {
 	s := int64(limit)
 	d.Limit = &s
 	return d
 }
 
-func (d *ScanInput) SetPageSize(pageSize int) *ScanInput {
+func (d *QueryInput) SetPageSize(pageSize int) *QueryInput {
 	ps := int64(pageSize)
 	d.pageSize = &ps
 	return d
 }
 
-func (d *ScanInput) SetFilterExpression(c Expression) *ScanInput {
-	s, n, m, _ := c.construct("filter", 1, true)
-	d.FilterExpression = &s
-
-	d.ExpressionAttributeNames = n
-	if d.ExpressionAttributeValues == nil {
-		d.ExpressionAttributeValues = marshal(m)
-	} else {
-		for k, v := range marshal(m) {
-			d.ExpressionAttributeValues[k] = v
-		}
-	}
-
+func (d *QueryInput) SetScanForward(forward bool) *QueryInput {
+	d.ScanIndexForward = &forward
 	return d
 }
 
-func (d *ScanInput) SetLocalIndex(idx LocalSecondaryIndex) *ScanInput {
-	d.IndexName = &idx.Name
+/*SinglePage ... Restrict execution to a single dynamo request. The output's LastEvaluatedKey can be used to fetch the next page*/
+func (d *QueryInput) SinglePage() *QueryInput {
+	d.singlePage = true
 	return d
 }
 
-func (d *ScanInput) SetGlobalIndex(idx GlobalSecondaryIndex) *ScanInput {
-	d.IndexName = &idx.Name
+/*SetUnmarshalWorkers ... Deserialize the items of each page across n goroutines when streaming. Order is preserved*/
+func (d *QueryInput) SetUnmarshalWorkers(n int) *QueryInput {
+	d.workers = n
 	return d
 }
 
-func (d *ScanInput) WithLastEvaluatedKey(key DynamoDBValue) *ScanInput {
-	d.ExclusiveStartKey = key
+/**
+ ** SetMaxScannedCount ... Stop paging once n items have been evaluated, matching the filter or not, bounding the read
+ ** capacity a selective filter can consume. The output reports MaxScannedCountReached, with a LastEvaluatedKey to resume from.
+ */
+func (d *QueryInput) SetMaxScannedCount(n int64) *QueryInput {
+	d.maxScanned = &n
 	return d
 }
 
-func (d *ScanInput) Build() *dynamodb.ScanInput {
-	r := dynamodb.ScanInput(*d.ScanInput)
-	if d.pageSize != nil {
-		r.Limit = d.pageSize
-	}
-	return &r
+/**
+ ** StopBeforeDeadline ... Stop paging, rather than fail mid page, once less than margin is left before the context's
+ ** deadline. The output reports Partial, with a LastEvaluatedKey to resume from.
+ */
+func (d *QueryInput) StopBeforeDeadline(margin time.Duration) *QueryInput {
+	d.deadlineMargin = &margin
+	return d
 }
 
 /**
- ** ExecuteWith ... Execute a dynamo Scan call with a passed in dynamodb instance and next item pointer
- ** dynamo - The underlying dynamodb api
- ** nextItem - The item pointer function, which is called on each new object returned from dynamodb. The function SHOULD NOT
- ** 		   store each item. It should simply return an empty struct pointer. Each of which is hydrated and pushed on
- ** 		   the returned channel.
- **
+ ** SetCapacityBudget ... Stop paging once the pages fetched have consumed units of capacity, failing with
+ ** ErrCapacityBudgetExceeded. The page that crosses the budget is still read, and LastEvaluatedKey resumes after it.
  */
-func (d *ScanInput) ExecuteWith(ctx context.Context, db DynamoDBIFace, opts ...request.Option) (out *ScanOutput) {
+func (d *QueryInput) SetCapacityBudget(units float64) *QueryInput {
+	d.capacityBudget = &units
+	return d
+}
 
-	out = &ScanOutput{
-		dynamoResult: &dynamoResult{},
-		ctx:          ctx,
-		limit:        d.Limit,
-	}
+func (d *QueryInput) WithConsumedCapacityHandler(f func(*dynamodb.ConsumedCapacity)) *QueryInput {
+	d.ReturnConsumedCapacity = aws.String("INDEXES")
+	d.capacityHandlers = append(d.capacityHandlers, f)
+	return d
+}
 
-	q := d.Build()
+/*OnPage ... Register a handler called with each page fetched, before its items are deserialized*/
+func (d *QueryInput) OnPage(f func(pageIndex int, items []DynamoDBValue, lastKey DynamoDBValue)) *QueryInput {
+	d.pageHandlers = append(d.pageHandlers, f)
+	return d
+}
 
-	out.outputFunc = func() (o *dynamodb.ScanOutput, err error) {
-		if q == nil {
-			return
-		}
-		o, err = db.ScanWithContext(ctx, q, opts...)
-		if err != nil {
-			out.err = err
-			return
-		}
+func (d *QueryInput) WithLastEvaluatedKey(key DynamoDBValue) *QueryInput {
+	d.ExclusiveStartKey = key
+	return d
+}
 
-		if o.LastEvaluatedKey != nil {
-			q.ExclusiveStartKey = o.LastEvaluatedKey
-		} else {
-			q = nil
-		}
-		return
+/**
+ ** WithStartKeyValue ... Resume after the item with the given keys, i.e. the last item processed
+ ** indexKey - The item's index keys, required when reading an index. Set the index before calling this.
+ */
+func (d *QueryInput) WithStartKeyValue(tableKey KeyValue, indexKey *KeyValue) *QueryInput {
+	key, err := d.table.startKey(d.IndexName, tableKey, indexKey)
+	d.ExclusiveStartKey = key
+	// Keep an earlier error, i.e. key conditions that don't match the index
+	if d.err == nil {
+		d.err = err
+	}
+	return d
+}
+
+/*SetFilterExpression ... Set the filter expression. Repeated calls are and'd together*/
+func (d *QueryInput) SetFilterExpression(c Expression) *QueryInput {
+	d.filters = append(d.filters, c)
+	s, n, m, _ := conjunction(d.filters).construct("filter", 1, true)
+	d.FilterExpression = &s
+
+	appendNames(&d.ExpressionAttributeNames, n)
+	appendValues(&d.ExpressionAttributeValues, marshal(m))
+
+	return d
+}
+
+func (d *QueryInput) SetLocalIndex(idx LocalSecondaryIndex) *QueryInput {
+	d.IndexName = &idx.Name
+	d.index = &secondaryIndex{name: idx.Name, partitionKey: idx.PartitionKey, rangeKey: idx.SortKey}
+	return d
+}
+
+/*SetGlobalIndex ... Read from a global secondary index. These don't support consistent reads*/
+func (d *QueryInput) SetGlobalIndex(idx GlobalSecondaryIndex) *QueryInput {
+	d.IndexName = &idx.Name
+	d.index = &secondaryIndex{name: idx.Name, partitionKey: idx.PartitionKey, rangeKey: idx.RangeKey, global: true}
+	return d
+}
+
+/*SetIndexName ... Read from one of the table's indexes, global or local, by name. Build fails if the table doesn't define it*/
+func (d *QueryInput) SetIndexName(name string) *QueryInput {
+	d.IndexName = &name
+	if d.index = d.table.secondaryIndex(name); d.index == nil {
+		d.err = fmt.Errorf("Query %s: table has no index %s.", d.table.Name, name)
+	}
+	return d
+}
+
+/*Clone ... Deep copy the query so it can be modified and executed independently of the original*/
+func (d *QueryInput) Clone() *QueryInput {
+	c := *d
+	input := *d.QueryInput
+	input.AttributesToGet = append([]*string(nil), input.AttributesToGet...)
+	input.ExpressionAttributeNames = cloneNames(input.ExpressionAttributeNames)
+	input.ExpressionAttributeValues = cloneValue(input.ExpressionAttributeValues)
+	input.ExclusiveStartKey = cloneValue(input.ExclusiveStartKey)
+	c.QueryInput = &input
+	c.filters = append([]Expression(nil), d.filters...)
+	c.capacityHandlers = append(([]func(*dynamodb.ConsumedCapacity))(nil), d.capacityHandlers...)
+	c.pageHandlers = append(([]func(int, []DynamoDBValue, DynamoDBValue))(nil), d.pageHandlers...)
+	return &c
+}
+
+func (d *QueryInput) Build() (*dynamodb.QueryInput, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	if d.index != nil && d.index.global && aws.BoolValue(d.ConsistentRead) {
+		return nil, fmt.Errorf("Cannot query global secondary index %s with consistent reads, only local indexes support them.", aws.StringValue(d.IndexName))
+	}
+	if err := d.table.validateStartKey("Query", d.IndexName, d.index, d.ExclusiveStartKey); err != nil {
+		return nil, err
+	}
+	r := dynamodb.QueryInput(*d.QueryInput)
+	if d.pageSize != nil {
+		r.Limit = d.pageSize
+	}
+	if err := validateExpressions("Query", r.ExpressionAttributeNames, r.ExpressionAttributeValues,
+		namedExpression{"key condition", r.KeyConditionExpression}, namedExpression{"filter", r.FilterExpression},
+		namedExpression{"projection", r.ProjectionExpression}); err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+/*DebugString ... Render the key condition and filter with values inlined. For debugging only*/
+func (d *QueryInput) DebugString() string {
+	clauses := []string{"KEY " + debugSubstitute(aws.StringValue(d.KeyConditionExpression), d.ExpressionAttributeNames, d.ExpressionAttributeValues)}
+	if d.FilterExpression != nil {
+		clauses = append(clauses, "FILTER "+debugSubstitute(*d.FilterExpression, d.ExpressionAttributeNames, d.ExpressionAttributeValues))
+	}
+	return strings.Join(clauses, " ")
+}
+
+/**
+ ** StreamWith ... Execute a dynamo Stream call with a passed in dynamodb instance and next item pointer
+ ** ctx - An instance of context
+ ** dynamo - The underlying dynamodb api
+ **
+ */
+
+func (d *QueryInput) ExecuteWith(ctx context.Context, db DynamoReader, opts ...request.Option) (out *QueryOutput) {
+	opts = withDefaultOptions(d.table.Defaults.Options, opts)
+
+	out = &QueryOutput{
+		dynamoResult:     &dynamoResult{},
+		decoder:          d.table.Decoder,
+		workers:          d.workers,
+		ctx:              ctx,
+		limit:            d.Limit,
+		lastEvaluatedKey: d.ExclusiveStartKey,
+		keyOf: func(av DynamoDBValue) DynamoDBValue {
+			return itemKey(d.table, d.IndexName, av)
+		},
+	}
+
+	q, err := d.Build()
+	if err != nil {
+		out.err = err
+		out.outputFunc = func() (*dynamodb.QueryOutput, error) { return nil, err }
+		return
+	}
+	// The budget is counted from the capacity dynamo reports
+	if rcc := aws.StringValue(q.ReturnConsumedCapacity); d.capacityBudget != nil && (rcc == "" || rcc == dynamodb.ReturnConsumedCapacityNone) {
+		q.ReturnConsumedCapacity = aws.String(dynamodb.ReturnConsumedCapacityTotal)
+	}
+	page := 0
+	pageSize := q.Limit
+	fetched := int64(0)
+
+	out.outputFunc = func() (o *dynamodb.QueryOutput, err error) {
+		if q == nil {
+			return
+		}
+		// Never request more items than are still needed to reach the limit
+		if out.limit != nil {
+			remaining := *out.limit - fetched
+			if remaining <= 0 {
+				return
+			}
+			q.Limit = &remaining
+			if pageSize != nil && *pageSize < remaining {
+				q.Limit = pageSize
+			}
+		}
+		// dynamo's limit bounds the items evaluated, so cap it at what's left of the scanned count
+		if d.maxScanned != nil {
+			budget := *d.maxScanned - out.scannedCount
+			if budget <= 0 {
+				out.maxScanned = true
+				return
+			}
+			if q.Limit == nil || *q.Limit > budget {
+				q.Limit = &budget
+			}
+		}
+		if d.deadlineMargin != nil {
+			if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < *d.deadlineMargin {
+				out.partial = true
+				return
+			}
+		}
+		if d.capacityBudget != nil && out.capacity >= *d.capacityBudget {
+			return nil, ErrCapacityBudgetExceeded
+		}
+		o, err = db.QueryWithContext(ctx, q, opts...)
+		if err != nil {
+			out.err = err
+			out.record()
+			return
+		}
+		out.record(o.ConsumedCapacity)
+		fetched += int64(len(o.Items))
+		out.count += aws.Int64Value(o.Count)
+		out.scannedCount += aws.Int64Value(o.ScannedCount)
+		for _, handler := range d.capacityHandlers {
+			handler(o.ConsumedCapacity)
+		}
+		if len(d.pageHandlers) > 0 {
+			items := toValues(o.Items)
+			for _, handler := range d.pageHandlers {
+				handler(page, items, o.LastEvaluatedKey)
+			}
+		}
+		page++
+
+		out.lastEvaluatedKey = o.LastEvaluatedKey
+		if o.LastEvaluatedKey != nil && !d.singlePage {
+			q.ExclusiveStartKey = o.LastEvaluatedKey
+		} else {
+			q = nil
+		}
+		return
 	}
 
 	return
 
 }
 
-func (o *ScanOutput) Results(next func() interface{}) (err error) {
-	err = o.Error
+/**
+ ** Execute ... ExecuteWith, also returning the output's error. Pages are only fetched as results are read, so the
+ ** error is one found building the query; later errors are returned by Results and the other readers.
+ */
+func (d *QueryInput) Execute(ctx context.Context, dynamo DynamoReader, opts ...request.Option) (*QueryOutput, error) {
+	out := d.ExecuteWith(ctx, dynamo, opts...)
+	return out, out.Error()
+}
+
+/**
+ ** Results ... given a next() function, continually hydrates the returned interface. Results are comprehensive,
+ ** in that no manual paging (via LastEvaluatedKey) is required to fetch additional results.
+ ** next - function which returns successive structs for hydration.
+ **
+ */
+
+func (o *QueryOutput) Results(next func() interface{}) (err error) {
+	err = o.err
 	if err != nil || o.outputFunc == nil {
 		return
 	}
 	var count int64
-	for {
-		var out *dynamodb.ScanOutput
+
+	//loop, calling output function until the results are empty
+	//output function transparently pages using LastEvaluatedKey internally
+	for page := 0; ; page++ {
+		start := o.lastEvaluatedKey
+		var out *dynamodb.QueryOutput
 		if out, err = o.outputFunc(); err != nil {
 			o.err = err
 			return
-		} else if out == nil || len(out.Items) <= 0 {
+		} else if out == nil {
 			return
 		}
 
-		for _, av := range out.Items {
+		for i, av := range out.Items {
 			if o.limit != nil && count >= *o.limit {
+				o.lastEvaluatedKey = resumeKey(o.keyOf, start, out.Items, i)
 				return
 			}
 			count++
 			item := next()
-			o.err = deserializeTo(av, item)
-			if err = o.err; err != nil {
+			if err = decodeItem(o.decoder, av, item, o.keyOf, page); err != nil {
+				o.err = err
 				return
 			}
 		}
@@ -1777,28 +4189,145 @@ func (o *ScanOutput) Results(next func() interface{}) (err error) {
 }
 
 /**
- ** ResultsList ... Return a page of results, along with the LastEvaludatedKey or an error
- ** Results can be hydrated to domain objects via the LoadDynamoDBValue function, if the domain object
- ** implements the Loader interface.
- **
+ ** ResultsFunc ... Like Results, but passes each raw item to f, skipping deserialization entirely. Returning an error
+ ** from f stops the iteration and is returned.
  */
+func (o *QueryOutput) ResultsFunc(f func(av DynamoDBValue) error) (err error) {
+	err = o.err
+	if err != nil || o.outputFunc == nil {
+		return
+	}
+	var count int64
+	for {
+		start := o.lastEvaluatedKey
+		var out *dynamodb.QueryOutput
+		if out, err = o.outputFunc(); err != nil {
+			o.err = err
+			return
+		} else if out == nil {
+			return
+		}
 
-func (o *ScanOutput) ResultsList() (values []DynamoDBValue, LastEvaluatedKey DynamoDBValue, err error) {
-	var out *dynamodb.ScanOutput
-	if out, err = o.outputFunc(); err != nil {
+		for i, av := range out.Items {
+			if o.limit != nil && count >= *o.limit {
+				o.lastEvaluatedKey = resumeKey(o.keyOf, start, out.Items, i)
+				return
+			}
+			count++
+			if err = f(av); err != nil {
+				o.lastEvaluatedKey = resumeKey(o.keyOf, start, out.Items, i)
+				return
+			}
+		}
+	}
+}
+
+/**
+ ** LastEvaluatedKey ... The key to resume the query from, or nil if it was exhausted. When results were cut short by
+ ** the limit or context cancellation, this is the key of the last item delivered. Set once Results returns or the
+ ** stream channel is closed.
+ */
+func (o *QueryOutput) LastEvaluatedKey() DynamoDBValue {
+	return o.lastEvaluatedKey
+}
+
+/**
+ ** Count ... The number of items returned by dynamo across every page fetched, after filtering. Pages are fetched
+ ** whole, so this may exceed the number of items consumed when a limit truncates the results.
+ */
+func (o *QueryOutput) Count() int64 {
+	return o.count
+}
+
+/*ScannedCount ... The number of items evaluated by dynamo across every page fetched, before filtering*/
+func (o *QueryOutput) ScannedCount() int64 {
+	return o.scannedCount
+}
+
+/*MaxScannedCountReached ... Whether paging stopped at the SetMaxScannedCount bound, with items left to evaluate*/
+func (o *QueryOutput) MaxScannedCountReached() bool {
+	return o.maxScanned
+}
+
+/*Partial ... Whether paging stopped short of the context's deadline, as set by StopBeforeDeadline, with items left to read*/
+func (o *QueryOutput) Partial() bool {
+	return o.partial
+}
+
+/**
+ ** First ... Deserialize the first result into item, i.e. the newest with SetScanForward(false). Pages of a single
+ ** item are fetched until one passes the filter or the query is exhausted, in which case found is false.
+ */
+func (o *QueryOutput) First(item interface{}) (found bool, err error) {
+	if o.err != nil || o.outputFunc == nil {
+		return false, o.err
+	}
+	o.limit = aws.Int64(1)
+	for {
+		var out *dynamodb.QueryOutput
+		if out, err = o.outputFunc(); err != nil || out == nil {
+			return
+		}
+		if len(out.Items) > 0 {
+			return true, o.deserialize(o.decoder, out.Items[0], item)
+		}
+	}
+}
+
+/*ResultsList ... Fetch the next page's items and the key to resume from. See ResultsPage for the page's metadata*/
+func (o *QueryOutput) ResultsList() (values []DynamoDBValue, LastEvaluatedKey DynamoDBValue, err error) {
+	page, err := o.ResultsPage()
+	return page.Items, page.LastEvaluatedKey, err
+}
+
+/*ResultsPage ... Fetch the next page, with its counts and consumed capacity*/
+func (o *QueryOutput) ResultsPage() (page Page, err error) {
+	var out *dynamodb.QueryOutput
+	if out, err = o.outputFunc(); err != nil || out == nil {
 		return
 	}
 
-	LastEvaluatedKey = out.LastEvaluatedKey
+	page.LastEvaluatedKey = out.LastEvaluatedKey
+	page.Count = aws.Int64Value(out.Count)
+	page.ScannedCount = aws.Int64Value(out.ScannedCount)
+	page.ConsumedCapacity = out.ConsumedCapacity
 	for _, i := range out.Items {
-		values = append(values, i)
+		page.Items = append(page.Items, i)
 	}
 
 	return
+}
 
+/**
+ ** QueryKeys ... Fetch only the keys of the matching items, every page unless the query is limited or cut short.
+ ** The projection is the table's keys, plus the index's when one is selected, so lastKey can resume the query.
+ ** The query itself is left unchanged.
+ */
+func (d *QueryInput) QueryKeys(ctx context.Context, dynamo DynamoReader, opts ...request.Option) (keys []KeyValue, lastKey DynamoDBValue, err error) {
+	out := d.Clone().keysOnly().ExecuteWith(ctx, dynamo, opts...)
+	err = out.ResultsFunc(func(av DynamoDBValue) error {
+		keys = append(keys, av.Key(d.table))
+		return nil
+	})
+	return keys, out.LastEvaluatedKey(), err
 }
 
-func (o *ScanOutput) StreamWithChannel(channel interface{}) (errChan chan error) {
+/**
+ ** ResultsMaps ... Deserialize every result into a map, for tables without a go struct. Numbers decode as float64,
+ ** or as dynamodbattribute.Number with a UseNumber decoder.
+ */
+func (o *QueryOutput) ResultsMaps() (items []map[string]interface{}, err error) {
+	err = o.Results(func() interface{} {
+		items = append(items, nil)
+		return &items[len(items)-1]
+	})
+	return
+}
+
+func (o *QueryOutput) StreamWithChannel(channel interface{}) (errChan chan error) {
+	if err := checkChannel(channel); err != nil {
+		return failedStream(err)
+	}
 	t := reflect.TypeOf(channel).Elem()
 	isPtr := t.Kind() == reflect.Ptr
 	if isPtr {
@@ -1807,47 +4336,50 @@ func (o *ScanOutput) StreamWithChannel(channel interface{}) (errChan chan error)
 	vc := reflect.ValueOf(channel)
 	errChan = make(chan error, 1)
 	count := int64(0)
+	cases := []reflect.SelectCase{
+		{Dir: reflect.SelectSend, Chan: vc},
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(o.ctx.Done())},
+	}
 	go func() {
 		defer close(errChan)
 		defer vc.Close()
 
-		for {
+		for page := 0; ; page++ {
+			start := o.lastEvaluatedKey
 			out, err := o.outputFunc()
 			if err != nil {
 				errChan <- err
 				return
-			} else if out == nil || len(out.Items) <= 0 {
+			} else if out == nil {
 				return
 			}
-			for _, av := range out.Items {
-				if o.limit != nil && count >= *o.limit {
-					return
-				}
-				item := reflect.New(t).Interface()
+			items := out.Items
+			if o.limit != nil && *o.limit-count < int64(len(items)) {
+				items = items[:*o.limit-count]
+			}
+			stopped := false
+			err = decodePage(o.decoder, items, t, o.workers, o.keyOf, page, func(i int, item interface{}) bool {
 				count++
-				if err := deserializeTo(av, item); err != nil {
-					errChan <- err
-					return
-				} else {
-					value := reflect.ValueOf(item)
-					if !isPtr {
-						value = reflect.Indirect(value)
-					}
-					c := reflect.SelectCase{
-						Dir:  reflect.SelectSend,
-						Chan: vc,
-						Send: value,
-					}
-					d := reflect.SelectCase{
-						Dir:  reflect.SelectRecv,
-						Chan: reflect.ValueOf(o.ctx.Done()),
-						Send: reflect.Value{},
-					}
-					if idx, _, _ := reflect.Select([]reflect.SelectCase{c, d}); idx == 1 {
-						// ctx done
-						return
-					}
+				value := reflect.ValueOf(item)
+				if !isPtr {
+					value = reflect.Indirect(value)
+				}
+				cases[0].Send = value
+				if idx, _, _ := reflect.Select(cases); idx == 1 {
+					// ctx done
+					o.lastEvaluatedKey = resumeKey(o.keyOf, start, out.Items, i)
+					stopped = true
 				}
+				return !stopped
+			})
+			if err != nil {
+				errChan <- err
+				return
+			} else if stopped {
+				return
+			} else if len(items) < len(out.Items) {
+				o.lastEvaluatedKey = resumeKey(o.keyOf, start, out.Items, len(items))
+				return
 			}
 		}
 	}()
@@ -1855,57 +4387,2520 @@ func (o *ScanOutput) StreamWithChannel(channel interface{}) (errChan chan error)
 	return
 }
 
-/**********************************************************************************************/
-/********************************************** Create Table **********************************/
-/**********************************************************************************************/
-type createTable dynamodb.CreateTableInput
+/***************************************************************************************/
+/************************************** Sharded Keys **********************************/
+/***************************************************************************************/
 
-func (table DynamoTable) CreateTable() *createTable {
-	pk := table.PartitionKey.Name()
-	pkt := "HASH"
-	pktt := table.PartitionKey.Type()
+/*ShardedPartition ... A partition key spread over a number of suffixed shards, to relieve a hot partition*/
+type ShardedPartition struct {
+	field  String
+	shards int
+	random bool
+}
 
-	k := []*dynamodb.KeySchemaElement{
-		&dynamodb.KeySchemaElement{
-			AttributeName: &pk,
-			KeyType:       &pkt,
-		},
-	}
-	r := int64(100)
-	w := int64(100)
-	p := &dynamodb.ProvisionedThroughput{
-		ReadCapacityUnits:  &r,
-		WriteCapacityUnits: &w,
+/**
+ ** ShardedKey ... Shard the partition key field over n suffixes, i.e. user#0 to user#7. Writes are assigned a shard
+ ** by hashing the partition and range key values, so an item always lands on the same shard. Install the write hooks
+ ** with DynamoTable.WithShardedKey, and read every shard back with DynamoTable.QuerySharded.
+ */
+func ShardedKey(field String, shards int) ShardedPartition {
+	if shards < 1 {
+		shards = 1
 	}
+	return ShardedPartition{field: field, shards: shards}
+}
 
-	a := []*dynamodb.AttributeDefinition{
-		&dynamodb.AttributeDefinition{
-			AttributeName: &pk,
-			AttributeType: &pktt,
-		},
-	}
+/*Random ... Assign puts a random shard instead. Updates are left as is, as their shard can't be recovered*/
+func (s ShardedPartition) Random() ShardedPartition {
+	s.random = true
+	return s
+}
 
-	if table.RangeKey != nil && !table.RangeKey.IsEmpty() {
-		rk := table.RangeKey.Name()
-		rkt := "RANGE"
-		rktt := table.RangeKey.Type()
-		k = append(k, &dynamodb.KeySchemaElement{AttributeName: &rk, KeyType: &rkt})
-		a = append(a, &dynamodb.AttributeDefinition{AttributeName: &rk, AttributeType: &rktt})
+/*Shards ... The number of shards*/
+func (s ShardedPartition) Shards() int {
+	return s.shards
+}
+
+/*Key ... The partition key value of the given shard*/
+func (s ShardedPartition) Key(value string, shard int) string {
+	return s.field.Composite(value, shard)
+}
+
+/**
+ ** KeyFor ... The partition key value an item with the logical partition value and range key value is stored under,
+ ** i.e. to address it outside of the table's builders. rangeKey is nil on tables without one. Random shards pick anew
+ */
+func (s ShardedPartition) KeyFor(value string, rangeKey interface{}) string {
+	var av *dynamodb.AttributeValue
+	if rangeKey != nil {
+		av, _ = dynamodbattribute.Marshal(rangeKey)
 	}
+	return s.Key(value, s.shard(value, av))
+}
 
-	t := dynamodb.CreateTableInput{
-		TableName:             &table.Name,
-		KeySchema:             k,
-		ProvisionedThroughput: p,
-		AttributeDefinitions:  a,
+/*shard picks the shard of an item with the given partition and range key values*/
+func (s ShardedPartition) shard(value string, rangeKey *dynamodb.AttributeValue) int {
+	if s.random {
+		return rand.Intn(s.shards)
+	}
+	h := fnv.New32a()
+	h.Write([]byte(value))
+	if rangeKey != nil {
+		b, _ := json.Marshal(rawJSON(rangeKey))
+		h.Write(b)
 	}
-	c := createTable(t)
+	return int(h.Sum32() % uint32(s.shards))
+}
 
-	// add GlobalSecondaryIndexes
-	if len(table.GlobalSecondaryIndexes) > 0 {
-		for _, gsi := range table.GlobalSecondaryIndexes {
-			c = *c.WithGlobalSecondaryIndex(gsi)
-		}
+/**
+ ** Unshard ... The logical value of a stored partition key value, i.e. one read back through QuerySharded, and whether
+ ** value was suffixed with a shard at all. Writes always suffix the value they're given, so an item read back must be
+ ** unsharded before it's put again, or it's stored as a new item under a doubly suffixed key.
+ */
+func (s ShardedPartition) Unshard(value string) (string, bool) {
+	segments := s.field.SplitComposite(value)
+	if len(segments) != 2 {
+		return value, false
+	}
+	shard, err := strconv.Atoi(segments[1])
+	if err != nil || shard < 0 || shard >= s.shards || s.Key(segments[0], shard) != value {
+		return value, false
+	}
+	return segments[0], true
+}
+
+/*assign suffixes the logical partition key value in item, or key, with its shard*/
+func (s ShardedPartition) assign(table DynamoTable, item DynamoDBValue) {
+	v, ok := item[s.field.Name()]
+	if !ok || v == nil || v.S == nil {
+		return
+	}
+	var rangeKey *dynamodb.AttributeValue
+	if table.RangeKey != nil && !table.RangeKey.IsEmpty() {
+		rangeKey = item[table.RangeKey.Name()]
+	}
+	item[s.field.Name()] = &dynamodb.AttributeValue{S: aws.String(s.Key(*v.S, s.shard(*v.S, rangeKey)))}
+}
+
+/**
+ ** WithShardedKey ... Suffix the sharded field with its shard on every put and, unless shards are random, in the keys
+ ** given to GetItem, BatchGetItem, UpdateItem, DeleteItem and the batch deletes. Values are always taken to be
+ ** logical; see ShardedPartition.Unshard to write back an item read through QuerySharded. Keys read from the table,
+ ** i.e. by DeleteByQuery, are already stored ones and left as is.
+ */
+func (table DynamoTable) WithShardedKey(s ShardedPartition) DynamoTable {
+	table = table.BeforePut(func(item DynamoDBValue) error {
+		s.assign(table, item)
+		return nil
+	})
+	table.sharded = &s
+	return table
+}
+
+/*shardKey suffixes the partition key of a logical key with its shard, on sharded tables whose shards can be recovered*/
+func (table DynamoTable) shardKey(key DynamoDBValue) {
+	if table.sharded != nil && !table.sharded.random {
+		table.sharded.assign(table, key)
+	}
+}
+
+/*ShardedQueryInput ... A query run against every shard of a partition*/
+type ShardedQueryInput struct {
+	query             *QueryInput
+	table             DynamoTable
+	rangeKeyCondition *KeyCondition
+	configure         []func(*QueryInput)
+	sharded           ShardedPartition
+	value             string
+	sorted            bool
+}
+
+/**
+ ** QuerySharded ... Query every shard of the partition value concurrently, merging the results into a single output.
+ ** The shards' results are concatenated in shard order, unless sorted with SortByRangeKey.
+ */
+func (table DynamoTable) QuerySharded(value string, rangeKeyCondition *KeyCondition, s ShardedPartition) *ShardedQueryInput {
+	d := &ShardedQueryInput{
+		table:             table,
+		rangeKeyCondition: rangeKeyCondition,
+		sharded:           s,
+		value:             value,
+	}
+	d.query = d.shardQuery(0)
+	return d
+}
+
+/*Configure ... Amend the query each shard runs, i.e. to set a filter, index or limit. f runs once per shard*/
+func (d *ShardedQueryInput) Configure(f func(*QueryInput)) *ShardedQueryInput {
+	d.configure = append(d.configure, f)
+	f(d.query)
+	return d
+}
+
+/*shardQuery builds the query of a single shard, with its own partition key condition*/
+func (d *ShardedQueryInput) shardQuery(shard int) *QueryInput {
+	q := d.table.Query(d.sharded.field.Equals(d.sharded.Key(d.value, shard)), d.rangeKeyCondition)
+	for _, f := range d.configure {
+		f(q)
+	}
+	return q
+}
+
+/*SortByRangeKey ... Merge the shards by the range key of the table, or of the local index queried, in the query's direction*/
+func (d *ShardedQueryInput) SortByRangeKey() *ShardedQueryInput {
+	d.sorted = true
+	return d
+}
+
+/*rangeKey is the name of the attribute the results are sorted by*/
+func (d *ShardedQueryInput) rangeKey() string {
+	q := d.query
+	if q.IndexName != nil {
+		if lsi, ok := q.table.LSI(*q.IndexName); ok {
+			return lsi.SortKey.Name()
+		}
+		if gsi, ok := q.table.GSI(*q.IndexName); ok && gsi.RangeKey != nil {
+			return gsi.RangeKey.Name()
+		}
+	}
+	if q.table.RangeKey == nil || q.table.RangeKey.IsEmpty() {
+		return ""
+	}
+	return q.table.RangeKey.Name()
+}
+
+/**
+ ** ExecuteWith ... Query the shards, all pages of each, as soon as results are read. The first error cancels the
+ ** remaining shards. The limit applies to the merged results. Their LastEvaluatedKey can't resume the query.
+ */
+func (d *ShardedQueryInput) ExecuteWith(ctx context.Context, db DynamoReader, opts ...request.Option) (out *QueryOutput) {
+	q := d.query
+	out = &QueryOutput{
+		dynamoResult: &dynamoResult{},
+		decoder:      q.table.Decoder,
+		ctx:          ctx,
+		limit:        q.Limit,
+		keyOf: func(av DynamoDBValue) DynamoDBValue {
+			return itemKey(q.table, q.IndexName, av)
+		},
+	}
+	if _, err := q.Build(); err != nil {
+		out.err = err
+		out.outputFunc = func() (*dynamodb.QueryOutput, error) { return nil, err }
+		return
+	}
+
+	done := false
+	out.outputFunc = func() (*dynamodb.QueryOutput, error) {
+		if done {
+			return nil, nil
+		}
+		done = true
+		items, err := d.fanOut(ctx, db, out, opts)
+		if err != nil {
+			out.err = err
+			return nil, err
+		}
+		return &dynamodb.QueryOutput{Items: items, Count: aws.Int64(int64(len(items)))}, nil
+	}
+	return
+}
+
+/*Execute ... ExecuteWith, also returning the output's error. As with QueryInput, read errors come later*/
+func (d *ShardedQueryInput) Execute(ctx context.Context, dynamo DynamoReader, opts ...request.Option) (*QueryOutput, error) {
+	out := d.ExecuteWith(ctx, dynamo, opts...)
+	return out, out.Error()
+}
+
+/*fanOut runs the query of each shard, and merges their items*/
+func (d *ShardedQueryInput) fanOut(ctx context.Context, db DynamoReader, out *QueryOutput, opts []request.Option) ([]map[string]*dynamodb.AttributeValue, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([][]map[string]*dynamodb.AttributeValue, d.sharded.shards)
+	var first error
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < d.sharded.shards; i++ {
+		q := d.shardQuery(i)
+		wg.Add(1)
+		go func(i int, q *QueryInput) {
+			defer wg.Done()
+			o := q.ExecuteWith(ctx, db, opts...)
+			err := o.ResultsFunc(func(av DynamoDBValue) error {
+				results[i] = append(results[i], av)
+				return nil
+			})
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil && first == nil {
+				// Later errors are most likely the cancellation of the remaining shards
+				first = err
+				cancel()
+			}
+			out.attempts += o.attempts
+			out.capacity += o.capacity
+			out.count += o.count
+			out.scannedCount += o.scannedCount
+		}(i, q)
+	}
+	wg.Wait()
+	if first != nil {
+		return nil, first
+	}
+
+	var items []map[string]*dynamodb.AttributeValue
+	for _, r := range results {
+		items = append(items, r...)
+	}
+	if name := d.rangeKey(); d.sorted && name != "" {
+		forward := d.query.ScanIndexForward == nil || *d.query.ScanIndexForward
+		sort.SliceStable(items, func(i, j int) bool {
+			if forward {
+				return compareAttributes(items[i][name], items[j][name]) < 0
+			}
+			return compareAttributes(items[i][name], items[j][name]) > 0
+		})
+	}
+	return items, nil
+}
+
+/*compareAttributes orders scalar key attribute values of the same type, missing values first*/
+func compareAttributes(a, b *dynamodb.AttributeValue) int {
+	switch {
+	case a == nil || b == nil:
+		switch {
+		case a == b:
+			return 0
+		case a == nil:
+			return -1
+		}
+		return 1
+	case a.N != nil && b.N != nil:
+		x, _ := new(big.Float).SetString(*a.N)
+		y, _ := new(big.Float).SetString(*b.N)
+		if x != nil && y != nil {
+			return x.Cmp(y)
+		}
+		return strings.Compare(*a.N, *b.N)
+	case a.S != nil && b.S != nil:
+		return strings.Compare(*a.S, *b.S)
+	}
+	return bytes.Compare(a.B, b.B)
+}
+
+/***************************************************************************************/
+/********************************************** Scan **********************************/
+/***************************************************************************************/
+type ScanInput struct {
+	*dynamodb.ScanInput
+	table          DynamoTable
+	filters        []Expression
+	pageSize       *int64
+	singlePage     bool
+	index          *secondaryIndex
+	err            error
+	workers        int
+	maxScanned     *int64
+	deadlineMargin *time.Duration
+	capacityBudget *float64
+	pageHandlers   []func(int, []DynamoDBValue, DynamoDBValue)
+}
+
+type ScanOutput struct {
+	*dynamoResult
+	outputFunc       func() (*dynamodb.ScanOutput, error)
+	limit            *int64
+	lastEvaluatedKey DynamoDBValue
+	keyOf            func(DynamoDBValue) DynamoDBValue
+	count            int64
+	scannedCount     int64
+	maxScanned       bool
+	partial          bool
+	decoder          *dynamodbattribute.Decoder
+	workers          int
+	ctx              context.Context
+}
+
+/*ScanOutput represents dynamo scan item call*/
+func (table DynamoTable) Scan() (q *ScanInput) {
+
+	q = &ScanInput{
+		ScanInput: &dynamodb.ScanInput{},
+		table:     table,
+	}
+	if table.Defaults.ConsistentRead {
+		q.SetConsistentRead(true)
+	}
+	if table.Defaults.ReturnConsumedCapacity != "" {
+		q.ReturnConsumedCapacity = aws.String(table.Defaults.ReturnConsumedCapacity)
+	}
+
+	q.TableName = &table.Name
+	return
+}
+
+/*ScanIndex ... Scan a global secondary index. The table's consistent read default doesn't apply, global indexes don't support it*/
+func (table DynamoTable) ScanIndex(gsi GlobalSecondaryIndex) *ScanInput {
+	q := table.Scan().SetGlobalIndex(gsi)
+	q.ConsistentRead = nil
+	if table.secondaryIndex(gsi.Name) == nil {
+		q.err = fmt.Errorf("ScanIndex %s: table has no index %s.", table.Name, gsi.Name)
+	}
+	return q
+}
+
+/*ScanLocalIndex ... Scan a local secondary index*/
+func (table DynamoTable) ScanLocalIndex(lsi LocalSecondaryIndex) *ScanInput {
+	q := table.Scan().SetLocalIndex(lsi)
+	if table.secondaryIndex(lsi.Name) == nil {
+		q.err = fmt.Errorf("ScanLocalIndex %s: table has no index %s.", table.Name, lsi.Name)
+	}
+	return q
+}
+
+func (d *ScanInput) SetConsistentRead(c bool) *ScanInput {
+	(*d).ConsistentRead = &c
+	return d
+}
+
+/*SetSegment ... Scan only segment of a parallel scan split into totalSegments*/
+func (d *ScanInput) SetSegment(segment int, totalSegments int) *ScanInput {
+	d.Segment = aws.Int64(int64(segment))
+	d.TotalSegments = aws.Int64(int64(totalSegments))
+	return d
+}
+func (d *ScanInput) SetAttributesToGet(fields []DynamoField) *ScanInput {
+	a := make([]*string, len(fields))
+	for i, f := range fields {
+		v := f.Name()
+		a[i] = &v
+	}
+	(*d).AttributesToGet = a
+	return d
+}
+
+func (d *ScanInput) SetLimit(limit int) *ScanInput {
+	s := int64(limit)
+	d.Limit = &s
+	return d
+}
+
+func (d *ScanInput) SetPageSize(pageSize int) *ScanInput {
+	ps := int64(pageSize)
+	d.pageSize = &ps
+	return d
+}
+
+/*SetFilterExpression ... Set the filter expression. Repeated calls are and'd together*/
+func (d *ScanInput) SetFilterExpression(c Expression) *ScanInput {
+	d.filters = append(d.filters, c)
+	s, n, m, _ := conjunction(d.filters).construct("filter", 1, true)
+	d.FilterExpression = &s
+
+	appendNames(&d.ExpressionAttributeNames, n)
+	appendValues(&d.ExpressionAttributeValues, marshal(m))
+
+	return d
+}
+
+func (d *ScanInput) SetLocalIndex(idx LocalSecondaryIndex) *ScanInput {
+	d.IndexName = &idx.Name
+	d.index = &secondaryIndex{name: idx.Name, partitionKey: idx.PartitionKey, rangeKey: idx.SortKey}
+	return d
+}
+
+/*SetGlobalIndex ... Read from a global secondary index. These don't support consistent reads*/
+func (d *ScanInput) SetGlobalIndex(idx GlobalSecondaryIndex) *ScanInput {
+	d.IndexName = &idx.Name
+	d.index = &secondaryIndex{name: idx.Name, partitionKey: idx.PartitionKey, rangeKey: idx.RangeKey, global: true}
+	return d
+}
+
+/*SetIndexName ... Read from one of the table's indexes, global or local, by name. Build fails if the table doesn't define it*/
+func (d *ScanInput) SetIndexName(name string) *ScanInput {
+	d.IndexName = &name
+	if d.index = d.table.secondaryIndex(name); d.index == nil {
+		d.err = fmt.Errorf("Scan %s: table has no index %s.", d.table.Name, name)
+	}
+	return d
+}
+
+func (d *ScanInput) WithLastEvaluatedKey(key DynamoDBValue) *ScanInput {
+	d.ExclusiveStartKey = key
+	return d
+}
+
+/**
+ ** WithStartKeyValue ... Resume after the item with the given keys, i.e. the last item processed
+ ** indexKey - The item's index keys, required when reading an index. Set the index before calling this.
+ */
+func (d *ScanInput) WithStartKeyValue(tableKey KeyValue, indexKey *KeyValue) *ScanInput {
+	key, err := d.table.startKey(d.IndexName, tableKey, indexKey)
+	d.ExclusiveStartKey = key
+	// Keep an earlier error, i.e. key conditions that don't match the index
+	if d.err == nil {
+		d.err = err
+	}
+	return d
+}
+
+/*SinglePage ... Restrict execution to a single dynamo request. The output's LastEvaluatedKey can be used to fetch the next page*/
+func (d *ScanInput) SinglePage() *ScanInput {
+	d.singlePage = true
+	return d
+}
+
+/*SetUnmarshalWorkers ... Deserialize the items of each page across n goroutines when streaming. Order is preserved*/
+func (d *ScanInput) SetUnmarshalWorkers(n int) *ScanInput {
+	d.workers = n
+	return d
+}
+
+/**
+ ** SetMaxScannedCount ... Stop paging once n items have been evaluated, matching the filter or not, bounding the read
+ ** capacity a selective filter can consume. The output reports MaxScannedCountReached, with a LastEvaluatedKey to resume from.
+ */
+func (d *ScanInput) SetMaxScannedCount(n int64) *ScanInput {
+	d.maxScanned = &n
+	return d
+}
+
+/**
+ ** StopBeforeDeadline ... Stop paging, rather than fail mid page, once less than margin is left before the context's
+ ** deadline. The output reports Partial, with a LastEvaluatedKey to resume from.
+ */
+func (d *ScanInput) StopBeforeDeadline(margin time.Duration) *ScanInput {
+	d.deadlineMargin = &margin
+	return d
+}
+
+/**
+ ** SetCapacityBudget ... Stop paging once the pages fetched have consumed units of capacity, failing with
+ ** ErrCapacityBudgetExceeded. The page that crosses the budget is still read, and LastEvaluatedKey resumes after it.
+ */
+func (d *ScanInput) SetCapacityBudget(units float64) *ScanInput {
+	d.capacityBudget = &units
+	return d
+}
+
+/*OnPage ... Register a handler called with each page fetched, before its items are deserialized*/
+func (d *ScanInput) OnPage(f func(pageIndex int, items []DynamoDBValue, lastKey DynamoDBValue)) *ScanInput {
+	d.pageHandlers = append(d.pageHandlers, f)
+	return d
+}
+
+/*Clone ... Deep copy the scan so it can be modified and executed independently of the original*/
+func (d *ScanInput) Clone() *ScanInput {
+	c := *d
+	input := *d.ScanInput
+	input.AttributesToGet = append([]*string(nil), input.AttributesToGet...)
+	input.ExpressionAttributeNames = cloneNames(input.ExpressionAttributeNames)
+	input.ExpressionAttributeValues = cloneValue(input.ExpressionAttributeValues)
+	input.ExclusiveStartKey = cloneValue(input.ExclusiveStartKey)
+	c.ScanInput = &input
+	c.filters = append([]Expression(nil), d.filters...)
+	c.pageHandlers = append(([]func(int, []DynamoDBValue, DynamoDBValue))(nil), d.pageHandlers...)
+	return &c
+}
+
+func (d *ScanInput) Build() (*dynamodb.ScanInput, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	if d.index != nil && d.index.global && aws.BoolValue(d.ConsistentRead) {
+		return nil, fmt.Errorf("Cannot scan global secondary index %s with consistent reads, only local indexes support them.", aws.StringValue(d.IndexName))
+	}
+	if err := d.table.validateStartKey("Scan", d.IndexName, d.index, d.ExclusiveStartKey); err != nil {
+		return nil, err
+	}
+	r := dynamodb.ScanInput(*d.ScanInput)
+	if d.pageSize != nil {
+		r.Limit = d.pageSize
+	}
+	if err := validateExpressions("Scan", r.ExpressionAttributeNames, r.ExpressionAttributeValues,
+		namedExpression{"filter", r.FilterExpression}, namedExpression{"projection", r.ProjectionExpression}); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+/*DebugString ... Render the filter with values inlined. For debugging only*/
+func (d *ScanInput) DebugString() string {
+	if d.FilterExpression == nil {
+		return ""
+	}
+	return "FILTER " + debugSubstitute(*d.FilterExpression, d.ExpressionAttributeNames, d.ExpressionAttributeValues)
+}
+
+/**
+ ** ExecuteWith ... Execute a dynamo Scan call with a passed in dynamodb instance and next item pointer
+ ** dynamo - The underlying dynamodb api
+ ** nextItem - The item pointer function, which is called on each new object returned from dynamodb. The function SHOULD NOT
+ ** 		   store each item. It should simply return an empty struct pointer. Each of which is hydrated and pushed on
+ ** 		   the returned channel.
+ **
+ */
+func (d *ScanInput) ExecuteWith(ctx context.Context, db DynamoReader, opts ...request.Option) (out *ScanOutput) {
+	opts = withDefaultOptions(d.table.Defaults.Options, opts)
+
+	out = &ScanOutput{
+		dynamoResult:     &dynamoResult{},
+		decoder:          d.table.Decoder,
+		workers:          d.workers,
+		ctx:              ctx,
+		limit:            d.Limit,
+		lastEvaluatedKey: d.ExclusiveStartKey,
+		keyOf: func(av DynamoDBValue) DynamoDBValue {
+			return itemKey(d.table, d.IndexName, av)
+		},
+	}
+
+	q, err := d.Build()
+	if err != nil {
+		out.err = err
+		out.outputFunc = func() (*dynamodb.ScanOutput, error) { return nil, err }
+		return
+	}
+	// The budget is counted from the capacity dynamo reports
+	if rcc := aws.StringValue(q.ReturnConsumedCapacity); d.capacityBudget != nil && (rcc == "" || rcc == dynamodb.ReturnConsumedCapacityNone) {
+		q.ReturnConsumedCapacity = aws.String(dynamodb.ReturnConsumedCapacityTotal)
+	}
+	page := 0
+	pageSize := q.Limit
+	fetched := int64(0)
+
+	out.outputFunc = func() (o *dynamodb.ScanOutput, err error) {
+		if q == nil {
+			return
+		}
+		// Never request more items than are still needed to reach the limit
+		if out.limit != nil {
+			remaining := *out.limit - fetched
+			if remaining <= 0 {
+				return
+			}
+			q.Limit = &remaining
+			if pageSize != nil && *pageSize < remaining {
+				q.Limit = pageSize
+			}
+		}
+		// dynamo's limit bounds the items evaluated, so cap it at what's left of the scanned count
+		if d.maxScanned != nil {
+			budget := *d.maxScanned - out.scannedCount
+			if budget <= 0 {
+				out.maxScanned = true
+				return
+			}
+			if q.Limit == nil || *q.Limit > budget {
+				q.Limit = &budget
+			}
+		}
+		if d.deadlineMargin != nil {
+			if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < *d.deadlineMargin {
+				out.partial = true
+				return
+			}
+		}
+		if d.capacityBudget != nil && out.capacity >= *d.capacityBudget {
+			return nil, ErrCapacityBudgetExceeded
+		}
+		o, err = db.ScanWithContext(ctx, q, opts...)
+		if err != nil {
+			out.err = err
+			out.record()
+			return
+		}
+		out.record(o.ConsumedCapacity)
+		fetched += int64(len(o.Items))
+		out.count += aws.Int64Value(o.Count)
+		out.scannedCount += aws.Int64Value(o.ScannedCount)
+		if len(d.pageHandlers) > 0 {
+			items := toValues(o.Items)
+			for _, handler := range d.pageHandlers {
+				handler(page, items, o.LastEvaluatedKey)
+			}
+		}
+		page++
+
+		out.lastEvaluatedKey = o.LastEvaluatedKey
+		if o.LastEvaluatedKey != nil && !d.singlePage {
+			q.ExclusiveStartKey = o.LastEvaluatedKey
+		} else {
+			q = nil
+		}
+		return
+	}
+
+	return
+
+}
+
+/**
+ ** Execute ... ExecuteWith, also returning the output's error. As with queries, this is an error building the
+ ** scan; errors fetching pages are returned by Results and the other readers.
+ */
+func (d *ScanInput) Execute(ctx context.Context, dynamo DynamoReader, opts ...request.Option) (*ScanOutput, error) {
+	out := d.ExecuteWith(ctx, dynamo, opts...)
+	return out, out.Error()
+}
+
+func (o *ScanOutput) Results(next func() interface{}) (err error) {
+	err = o.err
+	if err != nil || o.outputFunc == nil {
+		return
+	}
+	var count int64
+	for page := 0; ; page++ {
+		start := o.lastEvaluatedKey
+		var out *dynamodb.ScanOutput
+		if out, err = o.outputFunc(); err != nil {
+			o.err = err
+			return
+		} else if out == nil {
+			return
+		}
+
+		for i, av := range out.Items {
+			if o.limit != nil && count >= *o.limit {
+				o.lastEvaluatedKey = resumeKey(o.keyOf, start, out.Items, i)
+				return
+			}
+			count++
+			item := next()
+			o.err = decodeItem(o.decoder, av, item, o.keyOf, page)
+			if err = o.err; err != nil {
+				return
+			}
+		}
+
+	}
+	return
+}
+
+/**
+ ** ResultsFunc ... Like Results, but passes each raw item to f, skipping deserialization entirely. Returning an error
+ ** from f stops the iteration and is returned.
+ */
+func (o *ScanOutput) ResultsFunc(f func(av DynamoDBValue) error) (err error) {
+	err = o.err
+	if err != nil || o.outputFunc == nil {
+		return
+	}
+	var count int64
+	for {
+		start := o.lastEvaluatedKey
+		var out *dynamodb.ScanOutput
+		if out, err = o.outputFunc(); err != nil {
+			o.err = err
+			return
+		} else if out == nil {
+			return
+		}
+
+		for i, av := range out.Items {
+			if o.limit != nil && count >= *o.limit {
+				o.lastEvaluatedKey = resumeKey(o.keyOf, start, out.Items, i)
+				return
+			}
+			count++
+			if err = f(av); err != nil {
+				o.lastEvaluatedKey = resumeKey(o.keyOf, start, out.Items, i)
+				return
+			}
+		}
+	}
+}
+
+/**
+ ** LastEvaluatedKey ... The key to resume the scan from, or nil if it was exhausted. When results were cut short by
+ ** the limit or context cancellation, this is the key of the last item delivered. Set once Results returns or the
+ ** stream channel is closed.
+ */
+func (o *ScanOutput) LastEvaluatedKey() DynamoDBValue {
+	return o.lastEvaluatedKey
+}
+
+/**
+ ** Count ... The number of items returned by dynamo across every page fetched, after filtering. Pages are fetched
+ ** whole, so this may exceed the number of items consumed when a limit truncates the results.
+ */
+func (o *ScanOutput) Count() int64 {
+	return o.count
+}
+
+/*ScannedCount ... The number of items evaluated by dynamo across every page fetched, before filtering*/
+func (o *ScanOutput) ScannedCount() int64 {
+	return o.scannedCount
+}
+
+/*MaxScannedCountReached ... Whether paging stopped at the SetMaxScannedCount bound, with items left to evaluate*/
+func (o *ScanOutput) MaxScannedCountReached() bool {
+	return o.maxScanned
+}
+
+/*Partial ... Whether paging stopped short of the context's deadline, as set by StopBeforeDeadline, with items left to read*/
+func (o *ScanOutput) Partial() bool {
+	return o.partial
+}
+
+/*ResultsList ... Fetch the next page's items and the key to resume from. See ResultsPage for the page's metadata*/
+func (o *ScanOutput) ResultsList() (values []DynamoDBValue, LastEvaluatedKey DynamoDBValue, err error) {
+	page, err := o.ResultsPage()
+	return page.Items, page.LastEvaluatedKey, err
+}
+
+/*ResultsPage ... Fetch the next page, with its counts and consumed capacity*/
+func (o *ScanOutput) ResultsPage() (page Page, err error) {
+	var out *dynamodb.ScanOutput
+	if out, err = o.outputFunc(); err != nil || out == nil {
+		return
+	}
+
+	page.LastEvaluatedKey = out.LastEvaluatedKey
+	page.Count = aws.Int64Value(out.Count)
+	page.ScannedCount = aws.Int64Value(out.ScannedCount)
+	page.ConsumedCapacity = out.ConsumedCapacity
+	for _, i := range out.Items {
+		page.Items = append(page.Items, i)
+	}
+
+	return
+}
+
+/**
+ ** ResultsMaps ... Deserialize every result into a map, for tables without a go struct. Numbers decode as float64,
+ ** or as dynamodbattribute.Number with a UseNumber decoder.
+ */
+func (o *ScanOutput) ResultsMaps() (items []map[string]interface{}, err error) {
+	err = o.Results(func() interface{} {
+		items = append(items, nil)
+		return &items[len(items)-1]
+	})
+	return
+}
+
+func (o *ScanOutput) StreamWithChannel(channel interface{}) (errChan chan error) {
+	if err := checkChannel(channel); err != nil {
+		return failedStream(err)
+	}
+	t := reflect.TypeOf(channel).Elem()
+	isPtr := t.Kind() == reflect.Ptr
+	if isPtr {
+		t = t.Elem()
+	}
+	vc := reflect.ValueOf(channel)
+	errChan = make(chan error, 1)
+	count := int64(0)
+	cases := []reflect.SelectCase{
+		{Dir: reflect.SelectSend, Chan: vc},
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(o.ctx.Done())},
+	}
+	go func() {
+		defer close(errChan)
+		defer vc.Close()
+
+		for page := 0; ; page++ {
+			start := o.lastEvaluatedKey
+			out, err := o.outputFunc()
+			if err != nil {
+				errChan <- err
+				return
+			} else if out == nil {
+				return
+			}
+			items := out.Items
+			if o.limit != nil && *o.limit-count < int64(len(items)) {
+				items = items[:*o.limit-count]
+			}
+			stopped := false
+			err = decodePage(o.decoder, items, t, o.workers, o.keyOf, page, func(i int, item interface{}) bool {
+				count++
+				value := reflect.ValueOf(item)
+				if !isPtr {
+					value = reflect.Indirect(value)
+				}
+				cases[0].Send = value
+				if idx, _, _ := reflect.Select(cases); idx == 1 {
+					// ctx done
+					o.lastEvaluatedKey = resumeKey(o.keyOf, start, out.Items, i)
+					stopped = true
+				}
+				return !stopped
+			})
+			if err != nil {
+				errChan <- err
+				return
+			} else if stopped {
+				return
+			} else if len(items) < len(out.Items) {
+				o.lastEvaluatedKey = resumeKey(o.keyOf, start, out.Items, len(items))
+				return
+			}
+		}
+	}()
+
+	return
+}
+
+/*checkChannel rejects StreamWithChannel arguments that items can't be sent on*/
+func checkChannel(channel interface{}) error {
+	t := reflect.TypeOf(channel)
+	if t == nil || t.Kind() != reflect.Chan || t.ChanDir()&reflect.SendDir == 0 || reflect.ValueOf(channel).IsNil() {
+		return fmt.Errorf("domino: channel must be a non-nil channel items can be sent on, got %T", channel)
+	}
+	return nil
+}
+
+/*failedStream is the closed error channel of a stream that couldn't start*/
+func failedStream(err error) chan error {
+	errChan := make(chan error, 1)
+	errChan <- err
+	close(errChan)
+	return errChan
+}
+
+/**
+ ** decodePage ... Deserialize items into new values of type t, passing each to send in their original order until
+ ** send returns false. With more than one worker, items are decoded concurrently and reassembled by sequence number.
+ ** Returns the first error, once the items before it have been sent.
+ */
+func decodePage(decoder *dynamodbattribute.Decoder, items []map[string]*dynamodb.AttributeValue, t reflect.Type, workers int,
+	keyOf func(DynamoDBValue) DynamoDBValue, page int, send func(i int, item interface{}) bool) error {
+
+	if workers <= 1 || len(items) <= 1 {
+		for i, av := range items {
+			item := reflect.New(t).Interface()
+			if err := decodeItem(decoder, av, item, keyOf, page); err != nil {
+				return err
+			}
+			if !send(i, item) {
+				return nil
+			}
+		}
+		return nil
+	}
+
+	type decoded struct {
+		seq  int
+		item interface{}
+		err  error
+	}
+	jobs := make(chan int)
+	results := make(chan decoded, workers)
+	quit := make(chan struct{})
+	defer close(quit)
+
+	go func() {
+		defer close(jobs)
+		for i := range items {
+			select {
+			case jobs <- i:
+			case <-quit:
+				return
+			}
+		}
+	}()
+	for w := 0; w < workers; w++ {
+		go func() {
+			for i := range jobs {
+				item := reflect.New(t).Interface()
+				select {
+				case results <- decoded{i, item, decodeItem(decoder, items[i], item, keyOf, page)}:
+				case <-quit:
+					return
+				}
+			}
+		}()
+	}
+
+	pending := make(map[int]decoded, workers)
+	for next := 0; next < len(items); {
+		d := <-results
+		pending[d.seq] = d
+		for r, ok := pending[next]; ok; r, ok = pending[next] {
+			delete(pending, next)
+			if r.err != nil {
+				return r.err
+			}
+			if !send(next, r.item) {
+				return nil
+			}
+			next++
+		}
+	}
+	return nil
+}
+
+/**********************************************************************************************/
+/********************************************** Parallel Scan *********************************/
+/**********************************************************************************************/
+
+/**
+ ** Checkpointer ... Persists the progress of each segment of a parallel scan so an interrupted scan can resume.
+ ** Checkpoints are only meaningful to a scan with the same filter and number of segments.
+ */
+type Checkpointer interface {
+	/*Load returns the key to resume segment after, and whether it already finished. A nil key starts from the beginning*/
+	Load(segment int) (lastKey DynamoDBValue, done bool, err error)
+	/*Save records that segment has been processed up to lastKey, which is nil once done*/
+	Save(segment int, lastKey DynamoDBValue, done bool) error
+}
+
+type segmentCheckpoint struct {
+	LastKey DynamoDBValue `json:"lastKey,omitempty"`
+	Done    bool          `json:"done"`
+}
+
+/*MemoryCheckpointer ... Keeps checkpoints in memory, to resume a scan within the same process*/
+type MemoryCheckpointer struct {
+	mutex       sync.Mutex
+	checkpoints map[int]segmentCheckpoint
+}
+
+func NewMemoryCheckpointer() *MemoryCheckpointer {
+	return &MemoryCheckpointer{checkpoints: map[int]segmentCheckpoint{}}
+}
+
+func (c *MemoryCheckpointer) Load(segment int) (DynamoDBValue, bool, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	cp := c.checkpoints[segment]
+	return cp.LastKey, cp.Done, nil
+}
+
+func (c *MemoryCheckpointer) Save(segment int, lastKey DynamoDBValue, done bool) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.checkpoints[segment] = segmentCheckpoint{lastKey, done}
+	return nil
+}
+
+/*FileCheckpointer ... Keeps checkpoints in a json file, to resume a scan after the process restarts*/
+type FileCheckpointer struct {
+	path        string
+	mutex       sync.Mutex
+	checkpoints map[int]segmentCheckpoint
+}
+
+/*NewFileCheckpointer ... Checkpoint to the file at path. A missing file starts every segment from the beginning*/
+func NewFileCheckpointer(path string) *FileCheckpointer {
+	return &FileCheckpointer{path: path}
+}
+
+/*load reads the file the first time it's needed. Must hold the mutex*/
+func (c *FileCheckpointer) load() error {
+	if c.checkpoints != nil {
+		return nil
+	}
+	c.checkpoints = map[int]segmentCheckpoint{}
+	b, err := ioutil.ReadFile(c.path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, &c.checkpoints)
+}
+
+func (c *FileCheckpointer) Load(segment int) (DynamoDBValue, bool, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if err := c.load(); err != nil {
+		return nil, false, err
+	}
+	cp := c.checkpoints[segment]
+	return cp.LastKey, cp.Done, nil
+}
+
+/*Save rewrites the whole file, through a rename so a crash never leaves it half written*/
+func (c *FileCheckpointer) Save(segment int, lastKey DynamoDBValue, done bool) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if err := c.load(); err != nil {
+		return err
+	}
+	c.checkpoints[segment] = segmentCheckpoint{lastKey, done}
+	b, err := json.Marshal(c.checkpoints)
+	if err != nil {
+		return err
+	}
+	tmp := c.path + ".tmp"
+	if err = ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
+
+type ParallelScanInput struct {
+	scan         *ScanInput
+	segments     int
+	concurrency  int
+	order        []int //The order segments are scanned in, when not all at once. Defaults to ascending
+	checkpointer Checkpointer
+}
+
+/*errStopSegments is returned by a handler to stop starting segments, while letting those in flight finish*/
+var errStopSegments = errors.New("stop scanning segments")
+
+/*ParallelScan ... Split the scan into segments scanned concurrently*/
+func (d *ScanInput) ParallelScan(segments int) *ParallelScanInput {
+	if segments < 1 {
+		segments = 1
+	}
+	return &ParallelScanInput{scan: d, segments: segments}
+}
+
+/**
+ ** SetConcurrency ... Scan at most n segments at once, starting the next as each finishes. Defaults to every segment.
+ ** A capacity budget set on the scan is shared by all segments, and a segment only reads one page with SinglePage.
+ */
+func (d *ParallelScanInput) SetConcurrency(n int) *ParallelScanInput {
+	d.concurrency = n
+	return d
+}
+
+/*WithCheckpointer ... Checkpoint each segment after every page, and resume from the stored checkpoints*/
+func (d *ParallelScanInput) WithCheckpointer(cp Checkpointer) *ParallelScanInput {
+	d.checkpointer = cp
+	return d
+}
+
+/**
+ ** ExecuteWith ... Scan every segment concurrently, calling handler with each page of items.
+ ** A segment is checkpointed only once handler returns for a page, so after an interruption at most the
+ ** pages in flight are handled again. The first error stops all segments. A spent capacity budget stops each
+ ** segment at its next page, and ErrCapacityBudgetExceeded is returned once they have.
+ **
+ ** handler - Called concurrently from each segment
+ */
+func (d *ParallelScanInput) ExecuteWith(ctx context.Context, dynamo DynamoReader, handler func(segment int, items []DynamoDBValue) error, opts ...request.Option) error {
+	opts = withDefaultOptions(d.scan.table.Defaults.Options, opts)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	order := d.order
+	if order == nil {
+		order = make([]int, d.segments)
+		for i := range order {
+			order[i] = i
+		}
+	}
+	workers := d.concurrency
+	if workers <= 0 || workers > len(order) {
+		workers = len(order)
+	}
+
+	var mutex sync.Mutex
+	next := 0
+	var stopped error
+	/*take hands out segments in order, until they run out or the scan is stopped*/
+	take := func() (int, bool) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if stopped != nil || next >= len(order) {
+			return 0, false
+		}
+		next++
+		return order[next-1], true
+	}
+	/*stop records why the scan stopped. Errors other than the soft stops also cancel the segments in flight*/
+	stop := func(err error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		soft := err == errStopSegments || err == ErrCapacityBudgetExceeded
+		if stopped == nil || (!soft && (stopped == errStopSegments || stopped == ErrCapacityBudgetExceeded)) {
+			stopped = err
+		}
+		if !soft {
+			cancel()
+		}
+	}
+
+	spent := &dynamoResult{}
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for segment, ok := take(); ok; segment, ok = take() {
+				input, err := d.scan.Clone().SetSegment(segment, d.segments).Build()
+				if err == nil {
+					err = d.scanSegment(ctx, dynamo, segment, input, handler, spent, &mutex, opts)
+				}
+				if err != nil {
+					stop(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if stopped == errStopSegments {
+		return nil
+	}
+	return stopped
+}
+
+func (d *ParallelScanInput) scanSegment(ctx context.Context, dynamo DynamoReader, segment int, input *dynamodb.ScanInput,
+	handler func(int, []DynamoDBValue) error, spent *dynamoResult, mutex *sync.Mutex, opts []request.Option) error {
+
+	if d.checkpointer != nil {
+		lastKey, done, err := d.checkpointer.Load(segment)
+		if err != nil || done {
+			return err
+		}
+		if len(lastKey) > 0 {
+			input.ExclusiveStartKey = lastKey
+		}
+	}
+	budget := d.scan.capacityBudget
+	// The budget is counted from the capacity dynamo reports
+	if rcc := aws.StringValue(input.ReturnConsumedCapacity); budget != nil && (rcc == "" || rcc == dynamodb.ReturnConsumedCapacityNone) {
+		input.ReturnConsumedCapacity = aws.String(dynamodb.ReturnConsumedCapacityTotal)
+	}
+
+	for {
+		if budget != nil {
+			mutex.Lock()
+			exceeded := spent.capacity >= *budget
+			mutex.Unlock()
+			if exceeded {
+				return ErrCapacityBudgetExceeded
+			}
+		}
+		out, err := dynamo.ScanWithContext(ctx, input, opts...)
+		if err != nil {
+			return err
+		}
+		mutex.Lock()
+		spent.record(out.ConsumedCapacity)
+		mutex.Unlock()
+		if err = handler(segment, toValues(out.Items)); err != nil {
+			return err
+		}
+
+		done := len(out.LastEvaluatedKey) <= 0
+		if d.checkpointer != nil {
+			if err = d.checkpointer.Save(segment, out.LastEvaluatedKey, done); err != nil {
+				return err
+			}
+		}
+		if done || d.scan.singlePage {
+			return nil
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+}
+
+/**********************************************************************************************/
+/********************************************** Sample ****************************************/
+/**********************************************************************************************/
+
+/*SampleOption ... Configures DynamoTable.Sample*/
+type SampleOption func(*sampleConfig)
+
+type sampleConfig struct {
+	segments    int
+	concurrency int
+	maxCapacity float64
+	rand        *rand.Rand
+}
+
+/*SampleSegments ... Split the table into n segments to pick from. Defaults to 1000; fewer suit small tables*/
+func SampleSegments(n int) SampleOption {
+	return func(c *sampleConfig) { c.segments = n }
+}
+
+/*SampleConcurrency ... Read up to n segments at once. Defaults to 10*/
+func SampleConcurrency(n int) SampleOption {
+	return func(c *sampleConfig) { c.concurrency = n }
+}
+
+/*SampleMaxCapacity ... Stop sampling once units of read capacity have been consumed*/
+func SampleMaxCapacity(units float64) SampleOption {
+	return func(c *sampleConfig) { c.maxCapacity = units }
+}
+
+/*SampleSeed ... Pick segments from a source seeded with seed, to repeat a sample*/
+func SampleSeed(seed int64) SampleOption {
+	return func(c *sampleConfig) { c.rand = rand.New(rand.NewSource(seed)) }
+}
+
+/**
+ ** Sample ... Read up to n items from random segments of the table, without scanning all of it. n <= 0 reads nothing
+ ** Segments are read in parallel, in random order, taking only the first few items of each, until n items are read,
+ ** every segment has been visited or the capacity budget is spent. The sample is only roughly uniform: items at the
+ ** start of a segment are favored, so the more segments there are per item, the better. Random start keys within a
+ ** segment aren't used, as dynamo's hashing of keys into segments is private.
+ */
+func (table DynamoTable) Sample(ctx context.Context, dynamo DynamoReader, n int, opts ...SampleOption) (items []DynamoDBValue, err error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	config := sampleConfig{segments: 1000, concurrency: 10}
+	for _, o := range opts {
+		o(&config)
+	}
+	if config.segments < 1 {
+		config.segments = 1
+	}
+	perm := rand.Perm
+	if config.rand != nil {
+		perm = config.rand.Perm
+	}
+
+	perSegment := (n + config.segments - 1) / config.segments
+	scan := table.Scan().SetLimit(perSegment).SinglePage()
+	if config.maxCapacity > 0 {
+		scan.SetCapacityBudget(config.maxCapacity)
+	}
+	p := scan.ParallelScan(config.segments).SetConcurrency(config.concurrency)
+	p.order = perm(config.segments)
+
+	var mutex sync.Mutex
+	read := 0
+	pages := map[int][]DynamoDBValue{}
+	err = p.ExecuteWith(ctx, dynamo, func(segment int, page []DynamoDBValue) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		pages[segment] = page
+		if read += len(page); read >= n {
+			return errStopSegments
+		}
+		return nil
+	})
+	if err == ErrCapacityBudgetExceeded {
+		err = nil
+	}
+
+	// Segments are started in order and never cut short, so a seeded sample repeats despite the concurrency
+	for _, segment := range p.order {
+		items = append(items, pages[segment]...)
+	}
+	if len(items) > n {
+		items = items[:n]
+	}
+	return
+}
+
+/**********************************************************************************************/
+/********************************************** Iterators *************************************/
+/**********************************************************************************************/
+
+/**
+ ** ResultIterator ... Pull based access to query and scan results, fetching pages only as they're consumed
+ **	it := table.Query(...).ExecuteWith(ctx, db).Iterator()
+ **	for it.Next() {
+ **		err := it.Item(&user)
+ **	}
+ **	err := it.Err()
+ */
+type ResultIterator struct {
+	fetch   func() ([]map[string]*dynamodb.AttributeValue, bool, error)
+	decoder *dynamodbattribute.Decoder
+	ctx     context.Context
+	limit   *int64
+	keyOf   func(DynamoDBValue) DynamoDBValue
+	lastKey *DynamoDBValue
+	start   DynamoDBValue
+	page    []map[string]*dynamodb.AttributeValue
+	pages   int
+	i       int
+	count   int64
+	item    DynamoDBValue
+	done    bool
+	err     error
+}
+
+/*Iterator ... Iterate the results one at a time. The output's LastEvaluatedKey is set once iteration stops*/
+func (o *QueryOutput) Iterator() *ResultIterator {
+	return &ResultIterator{
+		fetch: func() ([]map[string]*dynamodb.AttributeValue, bool, error) {
+			if o.err != nil || o.outputFunc == nil {
+				return nil, false, o.err
+			}
+			out, err := o.outputFunc()
+			if err != nil || out == nil {
+				return nil, false, err
+			}
+			return out.Items, true, nil
+		},
+		decoder: o.decoder,
+		ctx:     o.ctx,
+		limit:   o.limit,
+		keyOf:   o.keyOf,
+		lastKey: &o.lastEvaluatedKey,
+	}
+}
+
+/*Iterator ... Iterate the results one at a time. The output's LastEvaluatedKey is set once iteration stops*/
+func (o *ScanOutput) Iterator() *ResultIterator {
+	return &ResultIterator{
+		fetch: func() ([]map[string]*dynamodb.AttributeValue, bool, error) {
+			if o.err != nil || o.outputFunc == nil {
+				return nil, false, o.err
+			}
+			out, err := o.outputFunc()
+			if err != nil || out == nil {
+				return nil, false, err
+			}
+			return out.Items, true, nil
+		},
+		decoder: o.decoder,
+		ctx:     o.ctx,
+		limit:   o.limit,
+		keyOf:   o.keyOf,
+		lastKey: &o.lastEvaluatedKey,
+	}
+}
+
+/*Next ... Advance to the next result, fetching another page if needed. False once exhausted, or on error*/
+func (it *ResultIterator) Next() bool {
+	if it.done {
+		return false
+	}
+	if it.limit != nil && it.count >= *it.limit {
+		it.stop()
+		return false
+	}
+	if it.ctx != nil && it.ctx.Err() != nil {
+		it.err = it.ctx.Err()
+		it.stop()
+		return false
+	}
+	for it.i >= len(it.page) {
+		it.start = *it.lastKey
+		page, ok, err := it.fetch()
+		if err != nil || !ok {
+			it.err = err
+			it.done = true
+			it.item = nil
+			return false
+		}
+		it.page, it.i = page, 0
+		it.pages++
+	}
+	it.item = it.page[it.i]
+	it.i++
+	it.count++
+	return true
+}
+
+/*stop ends iteration early, leaving the resume key at the last item returned*/
+func (it *ResultIterator) stop() {
+	if !it.done && it.i < len(it.page) {
+		*it.lastKey = resumeKey(it.keyOf, it.start, it.page, it.i)
+	}
+	it.done = true
+	it.item = nil
+}
+
+/*Item ... Deserialize the current result into target*/
+func (it *ResultIterator) Item(target interface{}) error {
+	if it.item == nil {
+		return errors.New("Iterator has no current item, call Next first.")
+	}
+	return decodeItem(it.decoder, it.item, target, it.keyOf, it.pages-1)
+}
+
+/*Raw ... The current result, undeserialized*/
+func (it *ResultIterator) Raw() DynamoDBValue {
+	return it.item
+}
+
+/*Err ... The error that ended iteration, if any, including context cancellation*/
+func (it *ResultIterator) Err() error {
+	return it.err
+}
+
+/**********************************************************************************************/
+/********************************************** JSON Export ***********************************/
+/**********************************************************************************************/
+
+/*JSONOption ... Configures ExportJSON and ImportJSON*/
+type JSONOption func(*jsonConfig)
+
+type jsonConfig struct {
+	raw bool
+}
+
+/**
+ ** JSONRaw ... Use dynamo's type annotated json, i.e. {"name":{"S":"bob"}}, which round trips sets and binaries.
+ ** Plain json, the default, writes sets as arrays and binaries as base64 strings, which import back as lists and strings.
+ */
+func JSONRaw() JSONOption {
+	return func(c *jsonConfig) { c.raw = true }
+}
+
+func newJSONConfig(opts []JSONOption) (c jsonConfig) {
+	for _, o := range opts {
+		o(&c)
+	}
+	return
+}
+
+/*ExportJSON ... Write every item as a json object per line, paging as needed*/
+func (o *QueryOutput) ExportJSON(ctx context.Context, w io.Writer, opts ...JSONOption) error {
+	return o.ResultsFunc(exportJSON(ctx, w, opts))
+}
+
+/*ExportJSON ... Write every item as a json object per line, paging as needed*/
+func (o *ScanOutput) ExportJSON(ctx context.Context, w io.Writer, opts ...JSONOption) error {
+	return o.ResultsFunc(exportJSON(ctx, w, opts))
+}
+
+func exportJSON(ctx context.Context, w io.Writer, opts []JSONOption) func(DynamoDBValue) error {
+	config := newJSONConfig(opts)
+	decoder := dynamodbattribute.NewDecoder(func(d *dynamodbattribute.Decoder) { d.UseNumber = true })
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+
+	return func(av DynamoDBValue) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if config.raw {
+			item := make(map[string]interface{}, len(av))
+			for k, v := range av {
+				item[k] = rawJSON(v)
+			}
+			return encoder.Encode(item)
+		}
+		var item map[string]interface{}
+		if err := decoder.Decode(&dynamodb.AttributeValue{M: av}, &item); err != nil {
+			return err
+		}
+		return encoder.Encode(convertNumbers(item, func(n string) interface{} { return json.Number(n) }))
+	}
+}
+
+/*rawJSON renders an attribute value in dynamo's json format, leaving out the unset fields*/
+func rawJSON(av *dynamodb.AttributeValue) interface{} {
+	switch {
+	case av == nil:
+		return nil
+	case av.L != nil:
+		l := make([]interface{}, len(av.L))
+		for i, v := range av.L {
+			l[i] = rawJSON(v)
+		}
+		return map[string]interface{}{"L": l}
+	case av.M != nil:
+		m := make(map[string]interface{}, len(av.M))
+		for k, v := range av.M {
+			m[k] = rawJSON(v)
+		}
+		return map[string]interface{}{"M": m}
+	case av.S != nil:
+		return map[string]interface{}{"S": *av.S}
+	case av.N != nil:
+		return map[string]interface{}{"N": *av.N}
+	case av.B != nil:
+		return map[string]interface{}{"B": av.B}
+	case av.BOOL != nil:
+		return map[string]interface{}{"BOOL": *av.BOOL}
+	case av.NULL != nil:
+		return map[string]interface{}{"NULL": *av.NULL}
+	case av.SS != nil:
+		return map[string]interface{}{"SS": av.SS}
+	case av.NS != nil:
+		return map[string]interface{}{"NS": av.NS}
+	case av.BS != nil:
+		return map[string]interface{}{"BS": av.BS}
+	}
+	return map[string]interface{}{}
+}
+
+/*convertNumbers replaces the numbers decoded by one library with those the other encodes as numbers*/
+func convertNumbers(v interface{}, f func(string) interface{}) interface{} {
+	switch t := v.(type) {
+	case dynamodbattribute.Number:
+		return f(string(t))
+	case json.Number:
+		return f(string(t))
+	case []dynamodbattribute.Number:
+		l := make([]interface{}, len(t))
+		for i, n := range t {
+			l[i] = f(string(n))
+		}
+		return l
+	case []interface{}:
+		for i, e := range t {
+			t[i] = convertNumbers(e, f)
+		}
+	case map[string]interface{}:
+		for k, e := range t {
+			t[k] = convertNumbers(e, f)
+		}
+	}
+	return v
+}
+
+/**
+ ** ImportJSON ... Read items written by ExportJSON, calling f with each. Returning an error from f stops the import
+ ** and is returned. Pass the same options the items were exported with.
+ */
+func ImportJSON(r io.Reader, f func(DynamoDBValue) error, opts ...JSONOption) error {
+	config := newJSONConfig(opts)
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+
+	for {
+		av, err := decodeJSONItem(decoder, config.raw)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err = f(av); err != nil {
+			return err
+		}
+	}
+}
+
+/*decodeJSONItem reads the next item written by ExportJSON, returning io.EOF after the last*/
+func decodeJSONItem(decoder *json.Decoder, raw bool) (av DynamoDBValue, err error) {
+	if raw {
+		err = decoder.Decode(&av)
+		return
+	}
+	var item map[string]interface{}
+	if err = decoder.Decode(&item); err != nil {
+		return
+	}
+	item = convertNumbers(item, func(n string) interface{} { return dynamodbattribute.Number(n) }).(map[string]interface{})
+	return dynamodbattribute.MarshalMap(item)
+}
+
+/*ImportOption ... Configures DynamoTable.ImportJSON*/
+type ImportOption func(*importConfig)
+
+type importConfig struct {
+	raw          bool
+	dryRun       bool
+	skipExisting bool
+	concurrency  int
+	maxRetries   int
+}
+
+/*ImportRaw ... Read dynamo's type annotated json, as written by ExportJSON with JSONRaw*/
+func ImportRaw() ImportOption {
+	return func(c *importConfig) { c.raw = true }
+}
+
+/*ImportDryRun ... Parse and validate every line without writing anything*/
+func ImportDryRun() ImportOption {
+	return func(c *importConfig) { c.dryRun = true }
+}
+
+/*ImportSkipExisting ... Put each item only if its key doesn't exist yet. Items are written one at a time*/
+func ImportSkipExisting() ImportOption {
+	return func(c *importConfig) { c.skipExisting = true }
+}
+
+/*ImportConcurrency ... Write up to n batches, or items when skipping existing keys, at once. Defaults to 1*/
+func ImportConcurrency(n int) ImportOption {
+	return func(c *importConfig) { c.concurrency = n }
+}
+
+/*ImportMaxRetries ... Give up on a write after n throttled attempts. Defaults to 10*/
+func ImportMaxRetries(n int) ImportOption {
+	return func(c *importConfig) { c.maxRetries = n }
+}
+
+/*ImportLineError ... A line that couldn't be parsed or written*/
+type ImportLineError struct {
+	Line int
+	Err  error
+}
+
+func (e ImportLineError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+/*ImportResult ... Totals of an ImportJSON job*/
+type ImportResult struct {
+	Lines    int               //Non empty lines read
+	Imported int64             //Items written, or that would be on a dry run
+	Skipped  int64             //Items whose key already existed
+	Errors   []ImportLineError //Lines that failed, in no particular order
+}
+
+type importItem struct {
+	line int
+	item DynamoDBValue
+}
+
+/**
+ ** ImportJSON ... Write the items of a json lines stream, as written by ExportJSON, into the table
+ ** Items are batch written 25 at a time, retrying unprocessed items with exponential backoff. Lines that fail to
+ ** parse, lack the table's keys or fail to write are reported in the result, and the import carries on.
+ ** Items are stamped and sanitized as puts are, and go through the table's put and after write hooks.
+ **
+ ** Returns an error only if reading r fails or ctx is done
+ */
+func (table DynamoTable) ImportJSON(ctx context.Context, dynamo DynamoWriter, r io.Reader, opts ...ImportOption) (result ImportResult, err error) {
+	config := importConfig{concurrency: 1, maxRetries: 10}
+	for _, o := range opts {
+		o(&config)
+	}
+	if config.concurrency < 1 {
+		config.concurrency = 1
+	}
+
+	var mutex sync.Mutex
+	report := func(imported, skipped int64, errs ...ImportLineError) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		result.Imported += imported
+		result.Skipped += skipped
+		result.Errors = append(result.Errors, errs...)
+	}
+
+	var wg sync.WaitGroup
+	batches := make(chan []importItem)
+	for i := 0; i < config.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				if config.skipExisting {
+					table.importIfNotExists(ctx, dynamo, batch, config, report)
+				} else {
+					table.importBatch(ctx, dynamo, batch, config, report)
+				}
+			}
+		}()
+	}
+
+	send := func(batch []importItem) bool {
+		if config.dryRun {
+			report(int64(len(batch)), 0)
+			return true
+		}
+		select {
+		case batches <- batch:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	var batch []importItem
+	for line := 1; scanner.Scan(); line++ {
+		b := bytes.TrimSpace(scanner.Bytes())
+		if len(b) == 0 {
+			continue
+		}
+		result.Lines++
+
+		decoder := json.NewDecoder(bytes.NewReader(b))
+		decoder.UseNumber()
+		item, parseErr := decodeJSONItem(decoder, config.raw)
+		if parseErr == nil {
+			parseErr = table.validateItemKey(item)
+		}
+		if parseErr == nil {
+			item, parseErr = table.preparePut(item)
+		}
+		if parseErr != nil {
+			report(0, 0, ImportLineError{line, parseErr})
+			continue
+		}
+
+		if batch = append(batch, importItem{line, item}); len(batch) == 25 {
+			if !send(batch) {
+				break
+			}
+			batch = nil
+		}
+	}
+	if len(batch) > 0 {
+		send(batch)
+	}
+	close(batches)
+	wg.Wait()
+
+	if err = scanner.Err(); err == nil {
+		err = ctx.Err()
+	}
+	return
+}
+
+/*validateItemKey checks that item holds the table's keys*/
+func (table DynamoTable) validateItemKey(item DynamoDBValue) error {
+	for _, f := range []DynamoFieldIFace{table.PartitionKey, table.RangeKey} {
+		if f == nil || f.IsEmpty() {
+			continue
+		}
+		if _, ok := item[f.Name()]; !ok {
+			return fmt.Errorf("missing key %s.", f.Name())
+		}
+	}
+	return nil
+}
+
+func (table DynamoTable) importBatch(ctx context.Context, dynamo DynamoWriter, batch []importItem, config importConfig,
+	report func(int64, int64, ...ImportLineError)) {
+
+	writes := make([]*dynamodb.WriteRequest, len(batch))
+	for i, b := range batch {
+		writes[i] = &dynamodb.WriteRequest{PutRequest: &dynamodb.PutRequest{Item: b.item}}
+	}
+	written, err := table.sendWithRetry(ctx, dynamo, writes, config.maxRetries, nil)
+	if err == nil {
+		report(int64(written), 0)
+		return
+	}
+	/*Dynamo doesn't say which items of a failed batch were written, so every line is reported*/
+	errs := make([]ImportLineError, len(batch))
+	for i, b := range batch {
+		errs[i] = ImportLineError{b.line, err}
+	}
+	report(0, 0, errs...)
+}
+
+func (table DynamoTable) importIfNotExists(ctx context.Context, dynamo DynamoWriter, batch []importItem, config importConfig,
+	report func(int64, int64, ...ImportLineError)) {
+
+	// The key is referenced by a name placeholder, so reserved words such as name or user are safe
+	pk := generateNamePlaceholder("cond", 0)
+	condition := "attribute_not_exists(" + pk + ")"
+	for _, b := range batch {
+		input := &dynamodb.PutItemInput{
+			TableName:                aws.String(table.Name),
+			Item:                     b.item,
+			ConditionExpression:      aws.String(condition),
+			ExpressionAttributeNames: map[string]*string{pk: aws.String(table.PartitionKey.Name())},
+		}
+		err := putWithRetry(ctx, dynamo, input, config.maxRetries, table.Defaults.Options)
+		table.hooks.after("PutItem", itemKey(table, nil, b.item), err)
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			report(0, 1)
+		} else if err != nil {
+			report(0, 0, ImportLineError{b.line, err})
+		} else {
+			report(1, 0)
+		}
+	}
+}
+
+/*putWithRetry puts a single item, retrying throttled attempts with exponential backoff*/
+func putWithRetry(ctx context.Context, dynamo DynamoWriter, input *dynamodb.PutItemInput, maxRetries int, opts []request.Option) error {
+	backoff := retryBackoff
+	for retry := 0; ; retry++ {
+		_, err := dynamo.PutItemWithContext(ctx, input, opts...)
+		if err == nil || !isThrottled(err) || retry >= maxRetries {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+/**********************************************************************************************/
+/********************************************** Copy Table ************************************/
+/**********************************************************************************************/
+
+/*CopyProgress ... Running totals of a CopyTable job*/
+type CopyProgress struct {
+	Scanned int64 //Items read from the source
+	Written int64 //Items written to the destination
+	Skipped int64 //Items dropped by the transform
+}
+
+/*CopyOption ... Configures CopyTable*/
+type CopyOption func(*copyConfig)
+
+type copyConfig struct {
+	segments   int
+	pageSize   int
+	maxRetries int
+	transform  func(DynamoDBValue) (DynamoDBValue, bool)
+	progress   []func(CopyProgress)
+	capacity   []func(*dynamodb.ConsumedCapacity)
+}
+
+/*CopySegments ... Scan the source with n parallel segments. Defaults to 1*/
+func CopySegments(n int) CopyOption {
+	return func(c *copyConfig) { c.segments = n }
+}
+
+/*CopyPageSize ... Limit the items read per scan request*/
+func CopyPageSize(n int) CopyOption {
+	return func(c *copyConfig) { c.pageSize = n }
+}
+
+/*CopyMaxRetries ... Give up after n retries of unprocessed writes. Defaults to 10*/
+func CopyMaxRetries(n int) CopyOption {
+	return func(c *copyConfig) { c.maxRetries = n }
+}
+
+/*CopyTransform ... Rewrite each item before it is written. Returning false skips the item*/
+func CopyTransform(f func(DynamoDBValue) (DynamoDBValue, bool)) CopyOption {
+	return func(c *copyConfig) { c.transform = f }
+}
+
+/*CopyProgressHandler ... Called with the running totals after each page is copied. Calls are serialized*/
+func CopyProgressHandler(f func(CopyProgress)) CopyOption {
+	return func(c *copyConfig) { c.progress = append(c.progress, f) }
+}
+
+/*CopyCapacityHandler ... Called with the capacity consumed by each scan and batch write request. Calls are serialized*/
+func CopyCapacityHandler(f func(*dynamodb.ConsumedCapacity)) CopyOption {
+	return func(c *copyConfig) { c.capacity = append(c.capacity, f) }
+}
+
+/**
+ ** CopyTable ... Copy every item of src into dst
+ ** Scans src, in parallel segments if configured, and batch writes each page into dst, retrying unprocessed
+ ** items with exponential backoff. The first error stops all segments. Copied items are written as dst puts them:
+ ** stamped, sanitized and through its put and after write hooks.
+ **
+ ** Returns the totals copied, up to any error
+ */
+func CopyTable(ctx context.Context, dynamo DynamoDBIFace, src, dst DynamoTable, opts ...CopyOption) (progress CopyProgress, err error) {
+	config := copyConfig{segments: 1, maxRetries: 10}
+	for _, o := range opts {
+		o(&config)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var mutex sync.Mutex
+	report := func(scanned, written, skipped int64, capacity ...*dynamodb.ConsumedCapacity) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		for _, c := range capacity {
+			for _, f := range config.capacity {
+				if c != nil {
+					f(c)
+				}
+			}
+		}
+		progress.Scanned += scanned
+		progress.Written += written
+		progress.Skipped += skipped
+		if scanned > 0 {
+			for _, f := range config.progress {
+				f(progress)
+			}
+		}
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, config.segments)
+	for segment := 0; segment < config.segments; segment++ {
+		q := src.Scan()
+		if config.segments > 1 {
+			q.SetSegment(segment, config.segments)
+		}
+		if config.pageSize > 0 {
+			q.SetPageSize(config.pageSize)
+		}
+		if len(config.capacity) > 0 {
+			q.ReturnConsumedCapacity = aws.String("TOTAL")
+		}
+
+		input, buildErr := q.Build()
+		if buildErr != nil {
+			errs <- buildErr
+			cancel()
+			break
+		}
+
+		wg.Add(1)
+		go func(input *dynamodb.ScanInput) {
+			defer wg.Done()
+			if err := copySegment(ctx, dynamo, input, dst, config, report); err != nil {
+				errs <- err
+				cancel()
+			}
+		}(input)
+	}
+	wg.Wait()
+	close(errs)
+
+	err = <-errs
+	return
+}
+
+func copySegment(ctx context.Context, dynamo DynamoDBIFace, input *dynamodb.ScanInput, dst DynamoTable, config copyConfig,
+	report func(scanned, written, skipped int64, capacity ...*dynamodb.ConsumedCapacity)) error {
+
+	for {
+		out, err := dynamo.ScanWithContext(ctx, input)
+		if err != nil {
+			return err
+		}
+		report(0, 0, 0, out.ConsumedCapacity)
+
+		var capacity func(...*dynamodb.ConsumedCapacity)
+		if len(config.capacity) > 0 {
+			capacity = func(c ...*dynamodb.ConsumedCapacity) { report(0, 0, 0, c...) }
+		}
+		var writes []*dynamodb.WriteRequest
+		skipped := int64(0)
+		for _, item := range out.Items {
+			if config.transform != nil {
+				var ok bool
+				if item, ok = config.transform(item); !ok {
+					skipped++
+					continue
+				}
+			}
+			if item, err = dst.preparePut(item); err != nil {
+				return err
+			}
+			writes = append(writes, &dynamodb.WriteRequest{PutRequest: &dynamodb.PutRequest{Item: item}})
+		}
+
+		for len(writes) > 0 {
+			n := len(writes)
+			if n > 25 {
+				n = 25
+			}
+			if _, err = dst.sendWithRetry(ctx, dynamo, writes[:n], config.maxRetries, capacity); err != nil {
+				return err
+			}
+			writes = writes[n:]
+		}
+		report(int64(len(out.Items)), int64(len(out.Items))-skipped, skipped)
+
+		if len(out.LastEvaluatedKey) <= 0 {
+			return nil
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+}
+
+/*retryBackoff is the initial delay before retrying unprocessed writes, doubling on each attempt*/
+var retryBackoff = 50 * time.Millisecond
+
+/**
+ ** writeBatch ... Write a single batch of at most 25 requests to the named table, retrying unprocessed
+ ** requests with exponential backoff. capacity, when set, is called with the capacity consumed by each attempt.
+ ** It doesn't run any hooks: write through the table's sendBatch instead.
+ **
+ ** Returns the number of requests processed, and those left unprocessed once retries run out or on an error
+ */
+func writeBatch(ctx context.Context, dynamo DynamoWriter, table string, writes []*dynamodb.WriteRequest, maxRetries int,
+	capacity func(...*dynamodb.ConsumedCapacity)) (written int, unprocessed []*dynamodb.WriteRequest, err error) {
+
+	input := &dynamodb.BatchWriteItemInput{RequestItems: map[string][]*dynamodb.WriteRequest{table: writes}}
+	if capacity != nil {
+		input.ReturnConsumedCapacity = aws.String("TOTAL")
+	}
+	backoff := retryBackoff
+	for retry := 0; ; retry++ {
+		pending := input.RequestItems[table]
+		out, err := dynamo.BatchWriteItemWithContext(ctx, input)
+		if err != nil {
+			return written, pending, err
+		}
+		if capacity != nil {
+			capacity(out.ConsumedCapacity...)
+		}
+		written += len(pending) - len(out.UnprocessedItems[table])
+
+		if len(out.UnprocessedItems[table]) <= 0 {
+			return written, nil, nil
+		} else if retry >= maxRetries {
+			return written, out.UnprocessedItems[table], nil
+		}
+		input.RequestItems = out.UnprocessedItems
+
+		select {
+		case <-ctx.Done():
+			return written, out.UnprocessedItems[table], ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+/**********************************************************************************************/
+/********************************************** Delete By Query *******************************/
+/**********************************************************************************************/
+
+/*BulkOption ... Configures the bulk by-query helpers*/
+type BulkOption func(*bulkConfig)
+
+type bulkConfig struct {
+	filter     Expression
+	dryRun     bool
+	maxRetries int
+}
+
+func newBulkConfig(opts []BulkOption) bulkConfig {
+	config := bulkConfig{maxRetries: 10}
+	for _, o := range opts {
+		o(&config)
+	}
+	return config
+}
+
+/*BulkFilter ... Only act on items matching the filter expression*/
+func BulkFilter(e Expression) BulkOption {
+	return func(c *bulkConfig) { c.filter = e }
+}
+
+/*BulkDryRun ... Count the matching items without modifying them*/
+func BulkDryRun() BulkOption {
+	return func(c *bulkConfig) { c.dryRun = true }
+}
+
+/*BulkMaxRetries ... Give up after n retries of a throttled or unprocessed write. Defaults to 10*/
+func BulkMaxRetries(n int) BulkOption {
+	return func(c *bulkConfig) { c.maxRetries = n }
+}
+
+/*keysOnly restricts the query to return only the table's primary key attributes, and the index's if one is selected*/
+func (d *QueryInput) keysOnly() *QueryInput {
+	var projection []string
+	for i, f := range d.table.keyFields(d.IndexName) {
+		placeholder := "#key" + strconv.Itoa(i)
+		appendNames(&d.ExpressionAttributeNames, map[string]*string{placeholder: aws.String(f.Name())})
+		projection = append(projection, placeholder)
+	}
+	d.ProjectionExpression = aws.String(strings.Join(projection, ", "))
+	d.AttributesToGet = nil
+	d.Select = nil
+	return d
+}
+
+/**
+ ** DeleteByQuery ... Delete every item matching the key conditions
+ ** Queries for the matching keys page by page and batch deletes them, retrying unprocessed deletes with
+ ** exponential backoff. With BulkDryRun the matching items are only counted. AfterWrite hooks run for each delete
+ ** once its batch is sent.
+ **
+ ** Returns the number of items deleted (or matched, on a dry run), up to any error
+ */
+func (table DynamoTable) DeleteByQuery(ctx context.Context, dynamo DynamoDBIFace, partitionKeyCondition KeyCondition, rangeKeyCondition *KeyCondition, opts ...BulkOption) (deleted int64, err error) {
+	config := newBulkConfig(opts)
+
+	q := table.Query(partitionKeyCondition, rangeKeyCondition)
+	if config.filter != nil {
+		q.SetFilterExpression(config.filter)
+	}
+	input, err := q.keysOnly().Build()
+	if err != nil {
+		return
+	}
+
+	for {
+		out, err := dynamo.QueryWithContext(ctx, input, table.Defaults.Options...)
+		if err != nil {
+			return deleted, err
+		}
+
+		if config.dryRun {
+			deleted += int64(len(out.Items))
+		} else {
+			for start := 0; start < len(out.Items); start += 25 {
+				end := start + 25
+				if end > len(out.Items) {
+					end = len(out.Items)
+				}
+				var writes []*dynamodb.WriteRequest
+				for _, item := range out.Items[start:end] {
+					writes = append(writes, &dynamodb.WriteRequest{DeleteRequest: &dynamodb.DeleteRequest{Key: itemKey(table, nil, item)}})
+				}
+				n, err := table.sendWithRetry(ctx, dynamo, writes, config.maxRetries, nil)
+				deleted += int64(n)
+				if err != nil {
+					return deleted, err
+				}
+			}
+		}
+
+		if len(out.LastEvaluatedKey) <= 0 {
+			return deleted, nil
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+}
+
+/**********************************************************************************************/
+/********************************************** Update By Query *******************************/
+/**********************************************************************************************/
+type UpdateByQueryInput struct {
+	query       *QueryInput
+	update      *UpdateInput
+	concurrency int
+	maxRetries  int
+}
+
+/*UpdateByQueryResult ... The outcome of an UpdateByQuery call*/
+type UpdateByQueryResult struct {
+	Matched    int64           //Items returned by the query
+	Updated    int64           //Items successfully updated
+	Failed     int64           //Items whose update failed, including failed conditions
+	FailedKeys []DynamoDBValue //The keys of the failed items
+}
+
+/**
+ ** UpdateByQuery ... Apply the update expressions to every item matching the key conditions and filter
+ ** partitionKeyCondition, rangeKeyCondition - select the items to update
+ ** filter - an optional filter expression, may be nil
+ **
+ ** Each update requires the item to still exist, so an item deleted after the query matched it fails rather than
+ ** being upserted back as a partial item. BeforeUpdate and AfterWrite hooks run for every update
+ */
+func (table DynamoTable) UpdateByQuery(partitionKeyCondition KeyCondition, rangeKeyCondition *KeyCondition, filter Expression, updates ...*UpdateExpression) *UpdateByQueryInput {
+	q := table.Query(partitionKeyCondition, rangeKeyCondition)
+	if filter != nil {
+		q.SetFilterExpression(filter)
+	}
+	return &UpdateByQueryInput{
+		query:       q.keysOnly(),
+		update:      table.update().SetUpdateExpression(updates...).RequireExists(),
+		concurrency: 1,
+		maxRetries:  10,
+	}
+}
+
+/*SetConcurrency ... Issue up to n UpdateItem calls at once*/
+func (d *UpdateByQueryInput) SetConcurrency(n int) *UpdateByQueryInput {
+	d.concurrency = n
+	return d
+}
+
+/*SetMaxRetries ... Give up on an item after n throttled attempts. Defaults to 10*/
+func (d *UpdateByQueryInput) SetMaxRetries(n int) *UpdateByQueryInput {
+	d.maxRetries = n
+	return d
+}
+
+/*SetConditionExpression ... Apply a condition to each item's update. Repeated calls are and'd together*/
+func (d *UpdateByQueryInput) SetConditionExpression(c Expression) *UpdateByQueryInput {
+	d.update.SetConditionExpression(c)
+	return d
+}
+
+/**
+ ** ExecuteWith ... Page through the query, updating each matching item with bounded concurrency.
+ ** Throttled updates are retried with exponential backoff. Items that still fail, or fail their condition,
+ ** are reported in the result rather than as an error.
+ **
+ ** Returns an error only if the query itself fails or ctx is done
+ */
+func (d *UpdateByQueryInput) ExecuteWith(ctx context.Context, dynamo DynamoDBIFace, opts ...request.Option) (result UpdateByQueryResult, err error) {
+	template, err := d.update.Build()
+	if err != nil {
+		return
+	}
+	input, err := d.query.Build()
+	if err != nil {
+		return
+	}
+	opts = withDefaultOptions(d.query.table.Defaults.Options, opts)
+
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	keys := make(chan DynamoDBValue)
+	workers := d.concurrency
+	if workers < 1 {
+		workers = 1
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for key := range keys {
+				input := *template
+				input.Key = key
+				updateErr := d.update.hooks.update(&input)
+				if updateErr == nil {
+					updateErr = updateWithRetry(ctx, dynamo, &input, d.maxRetries, opts)
+					d.update.hooks.after("UpdateItem", input.Key, updateErr)
+				}
+
+				mutex.Lock()
+				if updateErr != nil {
+					result.Failed++
+					result.FailedKeys = append(result.FailedKeys, key)
+				} else {
+					result.Updated++
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	for err == nil {
+		var out *dynamodb.QueryOutput
+		if out, err = dynamo.QueryWithContext(ctx, input, opts...); err != nil {
+			break
+		}
+		for _, item := range out.Items {
+			select {
+			case keys <- itemKey(d.query.table, nil, item):
+				mutex.Lock()
+				result.Matched++
+				mutex.Unlock()
+			case <-ctx.Done():
+				err = ctx.Err()
+			}
+			if err != nil {
+				break
+			}
+		}
+		if len(out.LastEvaluatedKey) <= 0 {
+			break
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+	close(keys)
+	wg.Wait()
+	return
+}
+
+/*updateWithRetry issues the update, retrying throttling errors with exponential backoff*/
+func updateWithRetry(ctx context.Context, dynamo DynamoWriter, input *dynamodb.UpdateItemInput, maxRetries int, opts []request.Option) error {
+	backoff := retryBackoff
+	for retry := 0; ; retry++ {
+		_, err := dynamo.UpdateItemWithContext(ctx, input, opts...)
+		if err == nil || !isThrottled(err) || retry >= maxRetries {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+/**********************************************************************************************/
+/********************************************** Table From Struct *****************************/
+/**********************************************************************************************/
+
+/**
+ ** TableFromStruct ... Derive a table definition from the domino struct tags of v, a struct or struct pointer
+ ** Attribute names come from the dynamodbav tag, falling back to the field name. Tags are ; separated roles:
+ **   domino:"pk"                  table partition key
+ **   domino:"rk"                  table range key
+ **   domino:"gsi:name-index,pk"   global secondary index partition key
+ **   domino:"gsi:name-index,rk"   global secondary index range key
+ **   domino:"lsi:date-index"      local secondary index sort key
+ ** i.e. `domino:"rk;gsi:name-index,pk"`. Indexes project all attributes
+ */
+func TableFromStruct(name string, v interface{}) (table DynamoTable, err error) {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return table, fmt.Errorf("TableFromStruct %s: %v is not a struct.", name, reflect.TypeOf(v))
+	}
+
+	table = DynamoTable{Name: name, RangeKey: EmptyField()}
+	gsis := map[string]*GlobalSecondaryIndex{}
+	lsis := map[string]*LocalSecondaryIndex{}
+	var gsiNames, lsiNames []string
+
+	fields, err := taggedFields(t)
+	if err != nil {
+		return table, fmt.Errorf("TableFromStruct %s: %v", name, err)
+	}
+	for _, f := range fields {
+		for _, role := range strings.Split(f.tag, ";") {
+			kind, index := role, ""
+			if i := strings.Index(role, ":"); i >= 0 {
+				kind, index = role[:i], role[i+1:]
+			}
+			switch kind {
+			case "pk":
+				if table.PartitionKey != nil {
+					err = fmt.Errorf("duplicate partition key %s.", f.field.Name())
+				}
+				table.PartitionKey = f.field
+			case "rk":
+				if !table.RangeKey.IsEmpty() {
+					err = fmt.Errorf("duplicate range key %s.", f.field.Name())
+				}
+				table.RangeKey = f.field
+			case "gsi":
+				parts := strings.Split(index, ",")
+				if len(parts) != 2 || parts[0] == "" {
+					err = fmt.Errorf("malformed tag %q on %s, expected gsi:<index>,pk|rk.", role, f.field.Name())
+					break
+				}
+				gsi, ok := gsis[parts[0]]
+				if !ok {
+					if _, ok := lsis[parts[0]]; ok {
+						err = fmt.Errorf("duplicate index name %s.", parts[0])
+						break
+					}
+					gsi = &GlobalSecondaryIndex{Name: parts[0], RangeKey: EmptyField(), ProjectionType: ProjectionTypeALL}
+					gsis[parts[0]] = gsi
+					gsiNames = append(gsiNames, parts[0])
+				}
+				switch {
+				case parts[1] == "pk" && gsi.PartitionKey == nil:
+					gsi.PartitionKey = f.field
+				case parts[1] == "rk" && gsi.RangeKey.IsEmpty():
+					gsi.RangeKey = f.field
+				case parts[1] == "pk" || parts[1] == "rk":
+					err = fmt.Errorf("duplicate %s for index %s.", parts[1], parts[0])
+				default:
+					err = fmt.Errorf("malformed tag %q on %s, expected gsi:<index>,pk|rk.", role, f.field.Name())
+				}
+			case "lsi":
+				_, isGsi := gsis[index]
+				if _, ok := lsis[index]; ok || isGsi {
+					err = fmt.Errorf("duplicate index name %s.", index)
+				} else if index == "" {
+					err = fmt.Errorf("malformed tag %q on %s, expected lsi:<index>.", role, f.field.Name())
+				} else {
+					lsis[index] = &LocalSecondaryIndex{Name: index, SortKey: f.field, ProjectionType: ProjectionTypeALL}
+					lsiNames = append(lsiNames, index)
+				}
+			default:
+				err = fmt.Errorf("unknown role %q on %s.", role, f.field.Name())
+			}
+			if err != nil {
+				return table, fmt.Errorf("TableFromStruct %s: %v", name, err)
+			}
+		}
+	}
+
+	if table.PartitionKey == nil {
+		return table, fmt.Errorf("TableFromStruct %s: missing partition key, tag a field with domino:\"pk\".", name)
+	}
+	for _, n := range gsiNames {
+		if gsis[n].PartitionKey == nil {
+			return table, fmt.Errorf("TableFromStruct %s: index %s is missing a partition key.", name, n)
+		}
+		table.GlobalSecondaryIndexes = append(table.GlobalSecondaryIndexes, *gsis[n])
+	}
+	for _, n := range lsiNames {
+		if table.RangeKey.IsEmpty() {
+			return table, fmt.Errorf("TableFromStruct %s: local index %s requires a table range key.", name, n)
+		}
+		lsis[n].PartitionKey = table.PartitionKey
+		table.LocalSecondaryIndexes = append(table.LocalSecondaryIndexes, *lsis[n])
+	}
+	return
+}
+
+type taggedField struct {
+	field DynamoFieldIFace
+	tag   string
+}
+
+/*taggedFields collects the domino tagged fields of t, including those of embedded structs*/
+func taggedFields(t reflect.Type) (fields []taggedField, err error) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, ok := sf.Tag.Lookup("domino")
+		if !ok {
+			if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+				var embedded []taggedField
+				if embedded, err = taggedFields(sf.Type); err != nil {
+					return
+				}
+				fields = append(fields, embedded...)
+			}
+			continue
+		}
+
+		name := sf.Name
+		if av := strings.Split(sf.Tag.Get("dynamodbav"), ",")[0]; av != "" {
+			name = av
+		}
+		var field DynamoFieldIFace
+		if field, err = keyField(name, sf.Type); err != nil {
+			return
+		}
+		fields = append(fields, taggedField{field, tag})
+	}
+	return
+}
+
+/*keyField infers a key attribute field from a go type. Keys may only be strings, numbers or binary*/
+func keyField(name string, t reflect.Type) (DynamoFieldIFace, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return StringField(name), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return NumericField(name), nil
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return BinaryField(name), nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported key type %v for %s, keys must be strings, numbers or []byte.", t, name)
+}
+
+/**********************************************************************************************/
+/********************************************** Create Table **********************************/
+/**********************************************************************************************/
+type CreateTableInput dynamodb.CreateTableInput
+
+func (table DynamoTable) CreateTable() *CreateTableInput {
+	pk := table.PartitionKey.Name()
+	pkt := "HASH"
+	pktt := table.PartitionKey.Type()
+
+	k := []*dynamodb.KeySchemaElement{
+		&dynamodb.KeySchemaElement{
+			AttributeName: &pk,
+			KeyType:       &pkt,
+		},
+	}
+	r := int64(100)
+	w := int64(100)
+	p := &dynamodb.ProvisionedThroughput{
+		ReadCapacityUnits:  &r,
+		WriteCapacityUnits: &w,
+	}
+
+	a := []*dynamodb.AttributeDefinition{
+		&dynamodb.AttributeDefinition{
+			AttributeName: &pk,
+			AttributeType: &pktt,
+		},
+	}
+
+	if table.RangeKey != nil && !table.RangeKey.IsEmpty() {
+		rk := table.RangeKey.Name()
+		rkt := "RANGE"
+		rktt := table.RangeKey.Type()
+		k = append(k, &dynamodb.KeySchemaElement{AttributeName: &rk, KeyType: &rkt})
+		a = append(a, &dynamodb.AttributeDefinition{AttributeName: &rk, AttributeType: &rktt})
+	}
+
+	t := dynamodb.CreateTableInput{
+		TableName:             &table.Name,
+		KeySchema:             k,
+		ProvisionedThroughput: p,
+		AttributeDefinitions:  a,
+	}
+	c := CreateTableInput(t)
+
+	// add GlobalSecondaryIndexes
+	if len(table.GlobalSecondaryIndexes) > 0 {
+		for _, gsi := range table.GlobalSecondaryIndexes {
+			c = *c.WithGlobalSecondaryIndex(gsi)
+		}
 	}
 
 	// add LocalSecondaryIndexes
@@ -1914,221 +6909,1554 @@ func (table DynamoTable) CreateTable() *createTable {
 			c = *c.WithLocalSecondaryIndex(lsi)
 		}
 	}
-
-	return &c
+
+	return &c
+}
+
+func (d *CreateTableInput) WithLocalSecondaryIndex(lsi LocalSecondaryIndex) *CreateTableInput {
+	// handle projection types and NonKeyAttributes
+	var pt *string
+	var nka []*string
+	if lsi.ProjectionType == "" {
+		pt = aws.String(ProjectionTypeALL)
+	} else {
+		// ALL, INCLUDE, KEYS_ONLY
+		pt = aws.String(lsi.ProjectionType)
+		if lsi.ProjectionType == ProjectionTypeINCLUDE {
+			// Non-key attributes are only projected, dynamo rejects attribute definitions unused by any key schema
+			for _, key := range lsi.NonKeyAttributes {
+				nka = append(nka, aws.String(key.Name()))
+			}
+		}
+	}
+
+	// populate missing AttributeDefinitions
+	pk := &dynamodb.AttributeDefinition{
+		AttributeName: aws.String(lsi.PartitionKey.Name()),
+		AttributeType: aws.String(lsi.PartitionKey.Type()),
+	}
+
+	rk := &dynamodb.AttributeDefinition{
+		AttributeName: aws.String(lsi.SortKey.Name()),
+		AttributeType: aws.String(lsi.SortKey.Type()),
+	}
+	d.AttributeDefinitions = append(d.AttributeDefinitions, pk)
+	d.AttributeDefinitions = append(d.AttributeDefinitions, rk)
+
+	// create lsi obj
+	dynamoLsi := dynamodb.LocalSecondaryIndex{
+		IndexName: &lsi.Name,
+		KeySchema: []*dynamodb.KeySchemaElement{
+			{
+				AttributeName: aws.String(lsi.PartitionKey.Name()),
+				KeyType:       aws.String("HASH"),
+			},
+			{
+				AttributeName: aws.String(lsi.SortKey.Name()),
+				KeyType:       aws.String("RANGE"),
+			},
+		},
+		Projection: &dynamodb.Projection{
+			ProjectionType:   pt,
+			NonKeyAttributes: nka,
+		},
+	}
+
+	// append lsi to *CreateTableInput
+	d.LocalSecondaryIndexes = append(d.LocalSecondaryIndexes, &dynamoLsi)
+	return d
+}
+
+func (d *CreateTableInput) WithGlobalSecondaryIndex(gsi GlobalSecondaryIndex) *CreateTableInput {
+	// handle projection types and NonKeyAttributes
+	var pt *string
+	var nka []*string
+	if gsi.ProjectionType == "" {
+		pt = aws.String(ProjectionTypeALL)
+	} else {
+		// ALL, INCLUDE, KEYS_ONLY
+		pt = aws.String(gsi.ProjectionType)
+		if gsi.ProjectionType == ProjectionTypeINCLUDE {
+			// Non-key attributes are only projected, dynamo rejects attribute definitions unused by any key schema
+			for _, key := range gsi.NonKeyAttributes {
+				nka = append(nka, aws.String(key.Name()))
+			}
+		}
+	}
+
+	// setup default provisioning
+	var gsir *int64
+	var gsiw *int64
+
+	if gsi.ReadUnits != 0 {
+		gsir = &gsi.ReadUnits
+	} else {
+		gsir = aws.Int64(DynamoBatchSize)
+	}
+	if gsi.WriteUnits != 0 {
+		gsiw = &gsi.WriteUnits
+	} else {
+		gsiw = aws.Int64(DynamoBatchSize)
+	}
+
+	keySchema := []*dynamodb.KeySchemaElement{
+		{
+			AttributeName: aws.String(gsi.PartitionKey.Name()),
+			KeyType:       aws.String("HASH"),
+		},
+	}
+
+	// populate missing AttributeDefinitions
+	pk := &dynamodb.AttributeDefinition{
+		AttributeName: aws.String(gsi.PartitionKey.Name()),
+		AttributeType: aws.String(gsi.PartitionKey.Type()),
+	}
+	d.AttributeDefinitions = append(d.AttributeDefinitions, pk)
+
+	if gsi.RangeKey != nil && !gsi.RangeKey.IsEmpty() {
+		rk := &dynamodb.AttributeDefinition{
+			AttributeName: aws.String(gsi.RangeKey.Name()),
+			AttributeType: aws.String(gsi.RangeKey.Type()),
+		}
+		d.AttributeDefinitions = append(d.AttributeDefinitions, rk)
+		keySchema = append(keySchema, &dynamodb.KeySchemaElement{
+			AttributeName: aws.String(gsi.RangeKey.Name()),
+			KeyType:       aws.String("RANGE"),
+		})
+	}
+
+	// create gsi obj
+	dynamoGsi := dynamodb.GlobalSecondaryIndex{
+		IndexName: &gsi.Name,
+		KeySchema: keySchema,
+		Projection: &dynamodb.Projection{
+			ProjectionType:   pt,
+			NonKeyAttributes: nka,
+		},
+		ProvisionedThroughput: &dynamodb.ProvisionedThroughput{
+			ReadCapacityUnits:  gsir,
+			WriteCapacityUnits: gsiw,
+		},
+	}
+
+	// append gsi to *CreateTableInput
+	d.GlobalSecondaryIndexes = append(d.GlobalSecondaryIndexes, &dynamoGsi)
+	return d
+}
+
+func (c *CreateTableInput) Build() *dynamodb.CreateTableInput {
+	// Dedupe attribute defns
+	at := make(map[string]*dynamodb.AttributeDefinition)
+	for _, t := range c.AttributeDefinitions {
+		at[*t.AttributeName] = t
+	}
+	c.AttributeDefinitions = nil
+
+	for _, v := range at {
+		c.AttributeDefinitions = append(c.AttributeDefinitions, v)
+	}
+	r := dynamodb.CreateTableInput(*c)
+	return &r
+}
+
+func (d *CreateTableInput) ExecuteWith(ctx context.Context, dynamo DynamoAdmin, opts ...request.Option) error {
+	defer time.Sleep(time.Duration(500) * time.Millisecond)
+	_, err := dynamo.CreateTableWithContext(ctx, d.Build(), opts...)
+	return err
+}
+
+/**********************************************************************************************/
+/********************************************** Validate Schema *******************************/
+/**********************************************************************************************/
+
+/*SchemaDiff ... A single difference between a declared table definition and the live table*/
+type SchemaDiff struct {
+	Path     string //What differs, i.e. "range key type" or "GSI name-index projection"
+	Declared string //Empty if the live table has something the definition does not
+	Actual   string //Empty if the live table is missing something the definition declares
+}
+
+func (d SchemaDiff) String() string {
+	switch {
+	case d.Actual == "":
+		return d.Path + " missing"
+	case d.Declared == "":
+		return d.Path + " not declared"
+	default:
+		return fmt.Sprintf("%s mismatch: declared %s, actual %s", d.Path, d.Declared, d.Actual)
+	}
+}
+
+/**
+ ** ValidateSchema ... Compare the table definition against the provisioned table
+ ** Compares the key schema, key attribute types, and the keys and projections of global and local secondary indexes.
+ ** Returns an empty list if the definition matches
+ */
+func (table DynamoTable) ValidateSchema(ctx context.Context, dynamo DynamoAdmin, opts ...request.Option) (diffs []SchemaDiff, err error) {
+	out, err := dynamo.DescribeTableWithContext(ctx, &dynamodb.DescribeTableInput{TableName: &table.Name}, opts...)
+	if err != nil {
+		return
+	}
+	declared := table.CreateTable().Build()
+	declaredAttrs := declared.AttributeDefinitions
+	actual := out.Table
+	actualAttrs := actual.AttributeDefinitions
+
+	diffs = diffKeySchema("", declared.KeySchema, declared.AttributeDefinitions, actual.KeySchema, actual.AttributeDefinitions)
+
+	type index struct {
+		keys       []*dynamodb.KeySchemaElement
+		projection *dynamodb.Projection
+	}
+	compare := func(kind string, declared, actual map[string]index, names []string) {
+		for _, name := range names {
+			path := kind + " " + name
+			d := declared[name]
+			a, ok := actual[name]
+			if !ok {
+				diffs = append(diffs, SchemaDiff{Path: path, Declared: "present"})
+				continue
+			}
+			diffs = append(diffs, diffKeySchema(path+" ", d.keys, declaredAttrs, a.keys, actualAttrs)...)
+			diffs = append(diffs, diffProjection(path+" ", d.projection, a.projection)...)
+		}
+		var extra []string
+		for name := range actual {
+			if _, ok := declared[name]; !ok {
+				extra = append(extra, name)
+			}
+		}
+		sort.Strings(extra)
+		for _, name := range extra {
+			diffs = append(diffs, SchemaDiff{Path: kind + " " + name, Actual: "present"})
+		}
+	}
+
+	var names []string
+	dg, ag := map[string]index{}, map[string]index{}
+	for _, i := range declared.GlobalSecondaryIndexes {
+		dg[*i.IndexName] = index{i.KeySchema, i.Projection}
+		names = append(names, *i.IndexName)
+	}
+	for _, i := range actual.GlobalSecondaryIndexes {
+		ag[*i.IndexName] = index{i.KeySchema, i.Projection}
+	}
+	compare("GSI", dg, ag, names)
+
+	names = nil
+	dl, al := map[string]index{}, map[string]index{}
+	for _, i := range declared.LocalSecondaryIndexes {
+		dl[*i.IndexName] = index{i.KeySchema, i.Projection}
+		names = append(names, *i.IndexName)
+	}
+	for _, i := range actual.LocalSecondaryIndexes {
+		al[*i.IndexName] = index{i.KeySchema, i.Projection}
+	}
+	compare("LSI", dl, al, names)
+
+	return
+}
+
+func diffKeySchema(path string, declared []*dynamodb.KeySchemaElement, declaredAttrs []*dynamodb.AttributeDefinition,
+	actual []*dynamodb.KeySchemaElement, actualAttrs []*dynamodb.AttributeDefinition) (diffs []SchemaDiff) {
+
+	keyOf := func(keys []*dynamodb.KeySchemaElement, keyType string) string {
+		for _, k := range keys {
+			if aws.StringValue(k.KeyType) == keyType {
+				return aws.StringValue(k.AttributeName)
+			}
+		}
+		return ""
+	}
+	typeOf := func(attrs []*dynamodb.AttributeDefinition, name string) string {
+		for _, a := range attrs {
+			if aws.StringValue(a.AttributeName) == name {
+				return aws.StringValue(a.AttributeType)
+			}
+		}
+		return ""
+	}
+
+	for _, k := range []struct{ keyType, label string }{{"HASH", "hash key"}, {"RANGE", "range key"}} {
+		d, a := keyOf(declared, k.keyType), keyOf(actual, k.keyType)
+		if d != a {
+			diffs = append(diffs, SchemaDiff{Path: path + k.label, Declared: d, Actual: a})
+		} else if d != "" {
+			if dt, at := typeOf(declaredAttrs, d), typeOf(actualAttrs, a); dt != at {
+				diffs = append(diffs, SchemaDiff{Path: path + k.label + " type", Declared: dt, Actual: at})
+			}
+		}
+	}
+	return
+}
+
+func diffProjection(path string, declared, actual *dynamodb.Projection) (diffs []SchemaDiff) {
+	if declared == nil || actual == nil {
+		return
+	}
+	if d, a := aws.StringValue(declared.ProjectionType), aws.StringValue(actual.ProjectionType); d != a {
+		diffs = append(diffs, SchemaDiff{Path: path + "projection", Declared: d, Actual: a})
+	}
+	d, a := aws.StringValueSlice(declared.NonKeyAttributes), aws.StringValueSlice(actual.NonKeyAttributes)
+	sort.Strings(d)
+	sort.Strings(a)
+	if strings.Join(d, ",") != strings.Join(a, ",") {
+		diffs = append(diffs, SchemaDiff{Path: path + "non-key attributes", Declared: strings.Join(d, ","), Actual: strings.Join(a, ",")})
+	}
+	return
+}
+
+/**********************************************************************************************/
+/********************************************** Ensure Table *********************************/
+/**********************************************************************************************/
+
+/*ensureTablePollInterval is how often EnsureTable and WaitForIndexActive check whether the table and its indexes are ACTIVE*/
+var ensureTablePollInterval = 2 * time.Second
+
+/**
+ ** EnsureTable ... Create the table if it does not exist, or add any declared global secondary indexes it is missing
+ ** Waits for the table and each added index to become ACTIVE. Differences that cannot be reconciled in place,
+ ** i.e. key or projection mismatches and missing local indexes, are returned as an error without changing anything.
+ **
+ ** Returns a description of each action taken
+ */
+func (table DynamoTable) EnsureTable(ctx context.Context, dynamo DynamoAdmin, opts ...request.Option) (actions []string, err error) {
+	described, err := dynamo.DescribeTableWithContext(ctx, &dynamodb.DescribeTableInput{TableName: &table.Name}, opts...)
+	if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == dynamodb.ErrCodeResourceNotFoundException {
+		if _, err = dynamo.CreateTableWithContext(ctx, table.CreateTable().Build(), opts...); err != nil {
+			return
+		}
+		actions = append(actions, "created table "+table.Name)
+		err = table.waitForActive(ctx, dynamo, opts...)
+		return
+	} else if err != nil {
+		return
+	}
+
+	diffs, err := table.ValidateSchema(ctx, dynamo, opts...)
+	if err != nil {
+		return
+	}
+	declared := table.CreateTable().Build()
+	var missing []*dynamodb.GlobalSecondaryIndex
+	var conflicts []string
+	for _, d := range diffs {
+		var gsi *dynamodb.GlobalSecondaryIndex
+		for _, g := range declared.GlobalSecondaryIndexes {
+			if d.Path == "GSI "+*g.IndexName {
+				gsi = g
+			}
+		}
+		switch {
+		case gsi != nil:
+			missing = append(missing, gsi)
+		case d.Declared == "" && strings.HasPrefix(d.Path, "GSI ") && strings.Count(d.Path, " ") == 1:
+			// Undeclared indexes are left in place
+		default:
+			conflicts = append(conflicts, d.String())
+		}
+	}
+	if len(conflicts) > 0 {
+		return nil, fmt.Errorf("EnsureTable %s: cannot reconcile %s.", table.Name, strings.Join(conflicts, "; "))
+	}
+
+	// Indexes of an on demand table take its billing mode, and dynamo rejects any throughput given for them
+	onDemand := described.Table != nil && described.Table.BillingModeSummary != nil &&
+		aws.StringValue(described.Table.BillingModeSummary.BillingMode) == dynamodb.BillingModePayPerRequest
+	for _, gsi := range missing {
+		create := &dynamodb.CreateGlobalSecondaryIndexAction{
+			IndexName:  gsi.IndexName,
+			KeySchema:  gsi.KeySchema,
+			Projection: gsi.Projection,
+		}
+		if !onDemand {
+			create.ProvisionedThroughput = gsi.ProvisionedThroughput
+		}
+		input := &dynamodb.UpdateTableInput{
+			TableName:                   &table.Name,
+			GlobalSecondaryIndexUpdates: []*dynamodb.GlobalSecondaryIndexUpdate{{Create: create}},
+		}
+		for _, k := range gsi.KeySchema {
+			for _, a := range declared.AttributeDefinitions {
+				if *a.AttributeName == *k.AttributeName {
+					input.AttributeDefinitions = append(input.AttributeDefinitions, a)
+				}
+			}
+		}
+		if _, err = dynamo.UpdateTableWithContext(ctx, input, opts...); err != nil {
+			return
+		}
+		actions = append(actions, "added GSI "+*gsi.IndexName)
+		if err = table.WaitForIndexActive(ctx, dynamo, *gsi.IndexName, WaitRequestOptions(opts...)); err != nil {
+			return
+		}
+	}
+	return
+}
+
+/*waitForActive polls until the table is ACTIVE*/
+func (table DynamoTable) waitForActive(ctx context.Context, dynamo DynamoAdmin, opts ...request.Option) error {
+	for {
+		out, err := dynamo.DescribeTableWithContext(ctx, &dynamodb.DescribeTableInput{TableName: &table.Name}, opts...)
+		if err != nil {
+			return err
+		}
+		if aws.StringValue(out.Table.TableStatus) == dynamodb.TableStatusActive {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(ensureTablePollInterval):
+		}
+	}
+}
+
+/*IndexProgress ... The state of a global secondary index on one poll of WaitForIndexActive*/
+type IndexProgress struct {
+	IndexName   string
+	Status      string
+	Backfilling bool  //Whether existing items are still being added to the index
+	ItemCount   int64 //Items in the index. Dynamo only refreshes this about every six hours
+	TableItems  int64 //Items in the table, as of the same refresh
+}
+
+/*WaitOption ... Configures DynamoTable.WaitForIndexActive*/
+type WaitOption func(*waitConfig)
+
+type waitConfig struct {
+	interval time.Duration
+	progress func(IndexProgress)
+	opts     []request.Option
+}
+
+/*WaitInterval ... Poll DescribeTable every d. Defaults to 2 seconds*/
+func WaitInterval(d time.Duration) WaitOption {
+	return func(c *waitConfig) { c.interval = d }
+}
+
+/*WaitProgress ... Call f with the index's state after every poll*/
+func WaitProgress(f func(IndexProgress)) WaitOption {
+	return func(c *waitConfig) { c.progress = f }
+}
+
+/*WaitRequestOptions ... Pass opts to each DescribeTable call*/
+func WaitRequestOptions(opts ...request.Option) WaitOption {
+	return func(c *waitConfig) { c.opts = opts }
+}
+
+/**
+ ** WaitForIndexActive ... Poll until a global secondary index is ACTIVE and done backfilling
+ ** An index added through UpdateTable can't be queried reliably until then. Gives up when ctx is done, or
+ ** if the index is missing or being deleted.
+ */
+func (table DynamoTable) WaitForIndexActive(ctx context.Context, dynamo DynamoAdmin, indexName string, opts ...WaitOption) error {
+	config := waitConfig{interval: ensureTablePollInterval}
+	for _, o := range opts {
+		o(&config)
+	}
+
+	for {
+		out, err := dynamo.DescribeTableWithContext(ctx, &dynamodb.DescribeTableInput{TableName: &table.Name}, config.opts...)
+		if err != nil {
+			return err
+		}
+		var gsi *dynamodb.GlobalSecondaryIndexDescription
+		for _, g := range out.Table.GlobalSecondaryIndexes {
+			if aws.StringValue(g.IndexName) == indexName {
+				gsi = g
+			}
+		}
+		if gsi == nil {
+			return fmt.Errorf("WaitForIndexActive %s: table has no index %s.", table.Name, indexName)
+		}
+
+		progress := IndexProgress{
+			IndexName:   indexName,
+			Status:      aws.StringValue(gsi.IndexStatus),
+			Backfilling: aws.BoolValue(gsi.Backfilling),
+			ItemCount:   aws.Int64Value(gsi.ItemCount),
+			TableItems:  aws.Int64Value(out.Table.ItemCount),
+		}
+		if config.progress != nil {
+			config.progress(progress)
+		}
+		switch progress.Status {
+		case dynamodb.IndexStatusActive:
+			if !progress.Backfilling {
+				return nil
+			}
+		case dynamodb.IndexStatusDeleting:
+			return fmt.Errorf("WaitForIndexActive %s: index %s is being deleted.", table.Name, indexName)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(config.interval):
+		}
+	}
+}
+
+/**********************************************************************************************/
+/********************************************** Delete Table **********************************/
+/**********************************************************************************************/
+type DeleteTableInput dynamodb.DeleteTableInput
+
+func (table DynamoTable) DeleteTable() *DeleteTableInput {
+	r := DeleteTableInput(dynamodb.DeleteTableInput{TableName: &table.Name})
+	return &r
+}
+
+func (d *DeleteTableInput) Build() *dynamodb.DeleteTableInput {
+	r := dynamodb.DeleteTableInput(*d)
+	return &r
+}
+
+func (d *DeleteTableInput) ExecuteWith(ctx context.Context, dynamo DynamoAdmin, opts ...request.Option) error {
+	defer time.Sleep(time.Duration(500) * time.Millisecond)
+	_, err := dynamo.DeleteTableWithContext(ctx, d.Build(), opts...)
+	return err
+}
+
+/**********************************************************************************************/
+/********************************************** Describe Limits *******************************/
+/**********************************************************************************************/
+type DescribeLimitsInput dynamodb.DescribeLimitsInput
+
+/*DescribeLimits ... The account's provisioned capacity limits in the client's region*/
+func DescribeLimits() *DescribeLimitsInput {
+	return &DescribeLimitsInput{}
+}
+
+func (d *DescribeLimitsInput) Build() *dynamodb.DescribeLimitsInput {
+	r := dynamodb.DescribeLimitsInput(*d)
+	return &r
+}
+
+func (d *DescribeLimitsInput) ExecuteWith(ctx context.Context, dynamo DynamoAdmin, opts ...request.Option) (*dynamodb.DescribeLimitsOutput, error) {
+	return dynamo.DescribeLimitsWithContext(ctx, d.Build(), opts...)
+}
+
+/*TableThroughput ... A table's provisioned throughput, alongside the limits it can be raised to*/
+type TableThroughput struct {
+	ReadCapacityUnits  int64 //Zero when the table is billed per request
+	WriteCapacityUnits int64
+	OnDemand           bool
+	Indexes            []IndexThroughput //Global secondary indexes, in the order dynamo describes them
+
+	AccountMaxReadCapacityUnits  int64 //Across all the account's tables and indexes in the region
+	AccountMaxWriteCapacityUnits int64
+	TableMaxReadCapacityUnits    int64 //For any one table or index
+	TableMaxWriteCapacityUnits   int64
+}
+
+/*IndexThroughput ... A global secondary index's provisioned throughput*/
+type IndexThroughput struct {
+	IndexName          string
+	ReadCapacityUnits  int64
+	WriteCapacityUnits int64
+}
+
+/*ReadCapacityUnitsTotal ... The read capacity provisioned for the table and its global secondary indexes*/
+func (t TableThroughput) ReadCapacityUnitsTotal() int64 {
+	total := t.ReadCapacityUnits
+	for _, idx := range t.Indexes {
+		total += idx.ReadCapacityUnits
+	}
+	return total
+}
+
+/*WriteCapacityUnitsTotal ... The write capacity provisioned for the table and its global secondary indexes*/
+func (t TableThroughput) WriteCapacityUnitsTotal() int64 {
+	total := t.WriteCapacityUnits
+	for _, idx := range t.Indexes {
+		total += idx.WriteCapacityUnits
+	}
+	return total
+}
+
+/*ThroughputInfo ... Describe the table's current throughput and the account's capacity limits*/
+func (table DynamoTable) ThroughputInfo(ctx context.Context, dynamo DynamoAdmin, opts ...request.Option) (info TableThroughput, err error) {
+	described, err := dynamo.DescribeTableWithContext(ctx, &dynamodb.DescribeTableInput{TableName: &table.Name}, opts...)
+	if err != nil {
+		return
+	}
+	limits, err := DescribeLimits().ExecuteWith(ctx, dynamo, opts...)
+	if err != nil {
+		return
+	}
+
+	t := described.Table
+	if t.BillingModeSummary != nil {
+		info.OnDemand = aws.StringValue(t.BillingModeSummary.BillingMode) == dynamodb.BillingModePayPerRequest
+	}
+	if t.ProvisionedThroughput != nil {
+		info.ReadCapacityUnits = aws.Int64Value(t.ProvisionedThroughput.ReadCapacityUnits)
+		info.WriteCapacityUnits = aws.Int64Value(t.ProvisionedThroughput.WriteCapacityUnits)
+	}
+	for _, gsi := range t.GlobalSecondaryIndexes {
+		idx := IndexThroughput{IndexName: aws.StringValue(gsi.IndexName)}
+		if gsi.ProvisionedThroughput != nil {
+			idx.ReadCapacityUnits = aws.Int64Value(gsi.ProvisionedThroughput.ReadCapacityUnits)
+			idx.WriteCapacityUnits = aws.Int64Value(gsi.ProvisionedThroughput.WriteCapacityUnits)
+		}
+		info.Indexes = append(info.Indexes, idx)
+	}
+
+	info.AccountMaxReadCapacityUnits = aws.Int64Value(limits.AccountMaxReadCapacityUnits)
+	info.AccountMaxWriteCapacityUnits = aws.Int64Value(limits.AccountMaxWriteCapacityUnits)
+	info.TableMaxReadCapacityUnits = aws.Int64Value(limits.TableMaxReadCapacityUnits)
+	info.TableMaxWriteCapacityUnits = aws.Int64Value(limits.TableMaxWriteCapacityUnits)
+	return
+}
+
+/**********************************************************************************************/
+/********************************************** Cached Client *********************************/
+/**********************************************************************************************/
+
+/*Cache ... An item store for CachedClient. A nil item records that the key doesn't exist*/
+type Cache interface {
+	Get(key string) (item DynamoDBValue, ok bool)
+	Set(key string, item DynamoDBValue)
+	Delete(key string)
+}
+
+/*MemoryCache ... An in process, least recently used Cache expiring entries after a ttl*/
+type MemoryCache struct {
+	mutex      sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	entries    map[string]*list.Element
+	lru        *list.List
+}
+
+type cacheEntry struct {
+	key     string
+	item    DynamoDBValue
+	expires time.Time
+}
+
+/*NewMemoryCache ... Cache up to maxEntries items, for ttl each. Zero means no limit*/
+func NewMemoryCache(ttl time.Duration, maxEntries int) *MemoryCache {
+	return &MemoryCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    map[string]*list.Element{},
+		lru:        list.New(),
+	}
+}
+
+func (c *MemoryCache) Get(key string) (DynamoDBValue, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := e.Value.(*cacheEntry)
+	if c.ttl > 0 && time.Now().After(entry.expires) {
+		c.lru.Remove(e)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.lru.MoveToFront(e)
+	return entry.item, true
+}
+
+func (c *MemoryCache) Set(key string, item DynamoDBValue) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	entry := &cacheEntry{key, item, time.Now().Add(c.ttl)}
+	if e, ok := c.entries[key]; ok {
+		e.Value = entry
+		c.lru.MoveToFront(e)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+	if c.maxEntries > 0 && c.lru.Len() > c.maxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+func (c *MemoryCache) Delete(key string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if e, ok := c.entries[key]; ok {
+		c.lru.Remove(e)
+		delete(c.entries, key)
+	}
+}
+
+/**
+ ** CachedClient ... A read through cache in front of GetItem and BatchGetItem. Writes through the client invalidate
+ ** the keys they touch. Consistent reads and reads of projected attributes bypass the cache, and every other
+ ** call passes through untouched.
+ */
+type CachedClient struct {
+	DynamoDBIFace
+	cache      Cache
+	keyNames   sync.Map //Table name to its key attribute names, learned from the keys read
+	hits       int64
+	misses     int64
+	fetchMutex sync.Mutex
+	fetching   map[string]*cacheFetch //Cache keys being read from dynamo
+}
+
+/*cacheFetch counts the reads of a key in flight, and its invalidations since they began*/
+type cacheFetch struct {
+	reads      int
+	generation uint64
+}
+
+/*NewCachedClient ... Cache up to maxEntries items read through dynamo, for ttl each*/
+func NewCachedClient(dynamo DynamoDBIFace, ttl time.Duration, maxEntries int) *CachedClient {
+	return NewCachedClientWithCache(dynamo, NewMemoryCache(ttl, maxEntries))
+}
+
+/*NewCachedClientWithCache ... Cache items read through dynamo in the given store*/
+func NewCachedClientWithCache(dynamo DynamoDBIFace, cache Cache) *CachedClient {
+	return &CachedClient{DynamoDBIFace: dynamo, cache: cache}
+}
+
+/*Hits ... The number of keys served from the cache*/
+func (c *CachedClient) Hits() int64 {
+	return atomic.LoadInt64(&c.hits)
+}
+
+/*Misses ... The number of cacheable keys read from dynamo*/
+func (c *CachedClient) Misses() int64 {
+	return atomic.LoadInt64(&c.misses)
+}
+
+/*cacheKey identifies an item by table and key. Values are rendered as json, which sorts map keys*/
+func cacheKey(table string, key map[string]*dynamodb.AttributeValue) string {
+	m := make(map[string]interface{}, len(key))
+	for k, v := range key {
+		m[k] = rawJSON(v)
+	}
+	b, _ := json.Marshal(m)
+	return table + "/" + string(b)
+}
+
+/*cacheable reports whether a read is of whole items with eventual consistency, the only kind cached*/
+func cacheable(consistentRead *bool, projection *string, attributesToGet []*string) bool {
+	return !aws.BoolValue(consistentRead) && projection == nil && len(attributesToGet) == 0
+}
+
+func (c *CachedClient) learnKey(table string, key map[string]*dynamodb.AttributeValue) {
+	if _, ok := c.keyNames.Load(table); ok {
+		return
+	}
+	names := make([]string, 0, len(key))
+	for k := range key {
+		names = append(names, k)
+	}
+	c.keyNames.Store(table, names)
+}
+
+/*itemCacheKey derives the cache key of a whole item, once its table has been read through the cache*/
+func (c *CachedClient) itemCacheKey(table string, item map[string]*dynamodb.AttributeValue) (string, bool) {
+	names, ok := c.keyNames.Load(table)
+	if !ok {
+		return "", false
+	}
+	key := make(map[string]*dynamodb.AttributeValue, len(names.([]string)))
+	for _, n := range names.([]string) {
+		key[n] = item[n]
+	}
+	return cacheKey(table, key), true
+}
+
+/*beginFetch registers a read of key from dynamo, returning the key's generation to hand to endFetch*/
+func (c *CachedClient) beginFetch(key string) uint64 {
+	c.fetchMutex.Lock()
+	defer c.fetchMutex.Unlock()
+	if c.fetching == nil {
+		c.fetching = map[string]*cacheFetch{}
+	}
+	f := c.fetching[key]
+	if f == nil {
+		f = &cacheFetch{}
+		c.fetching[key] = f
+	}
+	f.reads++
+	return f.generation
+}
+
+/**
+ ** endFetch ends a read begun by beginFetch, caching item if ok. An item read while the key was invalidated may
+ ** predate the write, so it's only cached if the generation is unchanged.
+ */
+func (c *CachedClient) endFetch(key string, generation uint64, item DynamoDBValue, ok bool) {
+	c.fetchMutex.Lock()
+	defer c.fetchMutex.Unlock()
+	f := c.fetching[key]
+	if ok && f.generation == generation {
+		c.cache.Set(key, cloneValue(item))
+	}
+	if f.reads--; f.reads <= 0 {
+		delete(c.fetching, key)
+	}
+}
+
+func (c *CachedClient) invalidate(key string) {
+	c.fetchMutex.Lock()
+	if f := c.fetching[key]; f != nil {
+		f.generation++
+	}
+	c.fetchMutex.Unlock()
+	c.cache.Delete(key)
+}
+
+func (c *CachedClient) invalidateKey(table *string, key map[string]*dynamodb.AttributeValue) {
+	c.invalidate(cacheKey(aws.StringValue(table), key))
+}
+
+func (c *CachedClient) invalidateItem(table *string, item map[string]*dynamodb.AttributeValue) {
+	if k, ok := c.itemCacheKey(aws.StringValue(table), item); ok {
+		c.invalidate(k)
+	}
+}
+
+func (c *CachedClient) GetItemWithContext(ctx aws.Context, in *dynamodb.GetItemInput, opts ...request.Option) (*dynamodb.GetItemOutput, error) {
+	if !cacheable(in.ConsistentRead, in.ProjectionExpression, in.AttributesToGet) {
+		return c.DynamoDBIFace.GetItemWithContext(ctx, in, opts...)
+	}
+	table := aws.StringValue(in.TableName)
+	key := cacheKey(table, in.Key)
+	if item, ok := c.cache.Get(key); ok {
+		atomic.AddInt64(&c.hits, 1)
+		return &dynamodb.GetItemOutput{Item: cloneValue(item)}, nil
+	}
+	atomic.AddInt64(&c.misses, 1)
+
+	/*The key is learned ahead of the read, so puts of the item invalidate it meanwhile*/
+	c.learnKey(table, in.Key)
+	generation := c.beginFetch(key)
+	out, err := c.DynamoDBIFace.GetItemWithContext(ctx, in, opts...)
+	var item DynamoDBValue
+	if err == nil {
+		item = out.Item
+	}
+	c.endFetch(key, generation, item, err == nil)
+	return out, err
+}
+
+/*BatchGetItemWithContext ... Serve the cached keys, fetching only the rest*/
+func (c *CachedClient) BatchGetItemWithContext(ctx aws.Context, in *dynamodb.BatchGetItemInput, opts ...request.Option) (*dynamodb.BatchGetItemOutput, error) {
+	out := &dynamodb.BatchGetItemOutput{Responses: map[string][]map[string]*dynamodb.AttributeValue{}}
+	fetch := &dynamodb.BatchGetItemInput{
+		RequestItems:           map[string]*dynamodb.KeysAndAttributes{},
+		ReturnConsumedCapacity: in.ReturnConsumedCapacity,
+	}
+	for table, ka := range in.RequestItems {
+		if !cacheable(ka.ConsistentRead, ka.ProjectionExpression, ka.AttributesToGet) {
+			fetch.RequestItems[table] = ka
+			continue
+		}
+		var missing []map[string]*dynamodb.AttributeValue
+		for _, key := range ka.Keys {
+			if item, ok := c.cache.Get(cacheKey(table, key)); ok {
+				atomic.AddInt64(&c.hits, 1)
+				if item != nil {
+					out.Responses[table] = append(out.Responses[table], cloneValue(item))
+				}
+			} else {
+				atomic.AddInt64(&c.misses, 1)
+				missing = append(missing, key)
+			}
+		}
+		if len(missing) > 0 {
+			r := *ka
+			r.Keys = missing
+			fetch.RequestItems[table] = &r
+		}
+	}
+	if len(fetch.RequestItems) <= 0 {
+		return out, nil
+	}
+
+	generations := map[string]uint64{}
+	for table, ka := range fetch.RequestItems {
+		if !cacheable(ka.ConsistentRead, ka.ProjectionExpression, ka.AttributesToGet) || len(ka.Keys) <= 0 {
+			continue
+		}
+		c.learnKey(table, ka.Keys[0])
+		for _, key := range ka.Keys {
+			k := cacheKey(table, key)
+			generations[k] = c.beginFetch(k)
+		}
+	}
+	fetched, err := c.DynamoDBIFace.BatchGetItemWithContext(ctx, fetch, opts...)
+	if err != nil {
+		for k, generation := range generations {
+			c.endFetch(k, generation, nil, false)
+		}
+		return fetched, err
+	}
+	for table, items := range fetched.Responses {
+		out.Responses[table] = append(out.Responses[table], items...)
+	}
+	out.UnprocessedKeys = fetched.UnprocessedKeys
+	out.ConsumedCapacity = fetched.ConsumedCapacity
+
+	/*Keys that were processed but not returned don't exist*/
+	for table, ka := range fetch.RequestItems {
+		if !cacheable(ka.ConsistentRead, ka.ProjectionExpression, ka.AttributesToGet) || len(ka.Keys) <= 0 {
+			continue
+		}
+		found := map[string]DynamoDBValue{}
+		for _, item := range fetched.Responses[table] {
+			k, _ := c.itemCacheKey(table, item)
+			found[k] = item
+		}
+		unprocessed := map[string]bool{}
+		if u := fetched.UnprocessedKeys[table]; u != nil {
+			for _, key := range u.Keys {
+				unprocessed[cacheKey(table, key)] = true
+			}
+		}
+		for _, key := range ka.Keys {
+			k := cacheKey(table, key)
+			c.endFetch(k, generations[k], found[k], !unprocessed[k])
+		}
+	}
+	return out, nil
+}
+
+func (c *CachedClient) PutItemWithContext(ctx aws.Context, in *dynamodb.PutItemInput, opts ...request.Option) (*dynamodb.PutItemOutput, error) {
+	defer c.invalidateItem(in.TableName, in.Item)
+	return c.DynamoDBIFace.PutItemWithContext(ctx, in, opts...)
+}
+
+func (c *CachedClient) UpdateItemWithContext(ctx aws.Context, in *dynamodb.UpdateItemInput, opts ...request.Option) (*dynamodb.UpdateItemOutput, error) {
+	defer c.invalidateKey(in.TableName, in.Key)
+	return c.DynamoDBIFace.UpdateItemWithContext(ctx, in, opts...)
+}
+
+func (c *CachedClient) DeleteItemWithContext(ctx aws.Context, in *dynamodb.DeleteItemInput, opts ...request.Option) (*dynamodb.DeleteItemOutput, error) {
+	defer c.invalidateKey(in.TableName, in.Key)
+	return c.DynamoDBIFace.DeleteItemWithContext(ctx, in, opts...)
+}
+
+func (c *CachedClient) BatchWriteItemWithContext(ctx aws.Context, in *dynamodb.BatchWriteItemInput, opts ...request.Option) (*dynamodb.BatchWriteItemOutput, error) {
+	defer func() {
+		for table, writes := range in.RequestItems {
+			for _, w := range writes {
+				if w.PutRequest != nil {
+					c.invalidateItem(&table, w.PutRequest.Item)
+				}
+				if w.DeleteRequest != nil {
+					c.invalidateKey(&table, w.DeleteRequest.Key)
+				}
+			}
+		}
+	}()
+	return c.DynamoDBIFace.BatchWriteItemWithContext(ctx, in, opts...)
+}
+
+func (c *CachedClient) TransactWriteItemsWithContext(ctx aws.Context, in *dynamodb.TransactWriteItemsInput, opts ...request.Option) (*dynamodb.TransactWriteItemsOutput, error) {
+	defer func() {
+		for _, item := range in.TransactItems {
+			if item.Put != nil {
+				c.invalidateItem(item.Put.TableName, item.Put.Item)
+			}
+			if item.Update != nil {
+				c.invalidateKey(item.Update.TableName, item.Update.Key)
+			}
+			if item.Delete != nil {
+				c.invalidateKey(item.Delete.TableName, item.Delete.Key)
+			}
+		}
+	}()
+	return c.DynamoDBIFace.TransactWriteItemsWithContext(ctx, in, opts...)
+}
+
+/**********************************************************************************************/
+/********************************************** Clients ***************************************/
+/**********************************************************************************************/
+
+/*NewClient ... A dynamo client for cfg*/
+func NewClient(cfg aws.Config) DynamoDBIFace {
+	return dynamodb.New(session.Must(session.NewSession(&cfg)))
+}
+
+/**
+ ** NewLocalClient ... A client for a local dynamo, i.e. DynamoDB Local, at endpoint, with placeholder credentials and
+ ** region. opts adjust the config before the client is built.
+ */
+func NewLocalClient(endpoint string, opts ...func(*aws.Config)) DynamoDBIFace {
+	cfg := aws.NewConfig().
+		WithRegion("us-west-2").
+		WithCredentials(credentials.NewStaticCredentials("local", "local", "")).
+		WithEndpoint(endpoint).
+		WithHTTPClient(http.DefaultClient)
+	for _, o := range opts {
+		o(cfg)
+	}
+	return NewClient(*cfg)
+}
+
+/*tableLister is the part of the dynamo api HealthCheck needs. Clients from NewClient implement it*/
+type tableLister interface {
+	ListTablesWithContext(aws.Context, *dynamodb.ListTablesInput, ...request.Option) (*dynamodb.ListTablesOutput, error)
+}
+
+/*HealthCheck ... List a single table, to fail fast when dynamo, i.e. a local emulator that isn't running, can't be reached*/
+func HealthCheck(ctx context.Context, dynamo DynamoDBIFace) error {
+	lister, ok := dynamo.(tableLister)
+	if !ok {
+		return fmt.Errorf("HealthCheck: %T can't list tables.", dynamo)
+	}
+	_, err := lister.ListTablesWithContext(ctx, &dynamodb.ListTablesInput{Limit: aws.Int64(1)})
+	return err
+}
+
+/**********************************************************************************************/
+/********************************************** Classic Client ********************************/
+/**********************************************************************************************/
+
+/*ClassicDynamoIFace ... A dynamo client exposing only the plain, context free calls*/
+type ClassicDynamoIFace interface {
+	CreateTable(*dynamodb.CreateTableInput) (*dynamodb.CreateTableOutput, error)
+	DeleteTable(*dynamodb.DeleteTableInput) (*dynamodb.DeleteTableOutput, error)
+	DescribeTable(*dynamodb.DescribeTableInput) (*dynamodb.DescribeTableOutput, error)
+	UpdateTable(*dynamodb.UpdateTableInput) (*dynamodb.UpdateTableOutput, error)
+	DescribeLimits(*dynamodb.DescribeLimitsInput) (*dynamodb.DescribeLimitsOutput, error)
+	GetItem(*dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error)
+	BatchGetItem(*dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error)
+	PutItem(*dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error)
+	Query(*dynamodb.QueryInput) (*dynamodb.QueryOutput, error)
+	Scan(*dynamodb.ScanInput) (*dynamodb.ScanOutput, error)
+	UpdateItem(*dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error)
+	DeleteItem(*dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error)
+	BatchWriteItem(*dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error)
+	TransactGetItems(*dynamodb.TransactGetItemsInput) (*dynamodb.TransactGetItemsOutput, error)
+	TransactWriteItems(*dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error)
+}
+
+type classicClient struct {
+	ClassicDynamoIFace
+}
+
+/**
+ ** WrapClassic ... Adapt a client without the WithContext calls to DynamoDBIFace. The plain calls can't be
+ ** interrupted, so a done ctx returns straight away, leaving the call to finish in the background.
+ ** Request options are ignored.
+ */
+func WrapClassic(c ClassicDynamoIFace) DynamoDBIFace {
+	return classicClient{c}
+}
+
+/*callClassic races call against ctx, failing like the sdk does when a request's context is done*/
+func callClassic(ctx aws.Context, call func() (interface{}, error)) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, awserr.New(request.CanceledErrorCode, "request context canceled", err)
+	}
+	type result struct {
+		out interface{}
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		out, err := call()
+		done <- result{out, err}
+	}()
+	select {
+	case r := <-done:
+		return r.out, r.err
+	case <-ctx.Done():
+		return nil, awserr.New(request.CanceledErrorCode, "request context canceled", ctx.Err())
+	}
+}
+
+func (c classicClient) CreateTableWithContext(ctx aws.Context, in *dynamodb.CreateTableInput, _ ...request.Option) (*dynamodb.CreateTableOutput, error) {
+	out, err := callClassic(ctx, func() (interface{}, error) { return c.CreateTable(in) })
+	o, _ := out.(*dynamodb.CreateTableOutput)
+	return o, err
+}
+
+func (c classicClient) DeleteTableWithContext(ctx aws.Context, in *dynamodb.DeleteTableInput, _ ...request.Option) (*dynamodb.DeleteTableOutput, error) {
+	out, err := callClassic(ctx, func() (interface{}, error) { return c.DeleteTable(in) })
+	o, _ := out.(*dynamodb.DeleteTableOutput)
+	return o, err
+}
+
+func (c classicClient) DescribeTableWithContext(ctx aws.Context, in *dynamodb.DescribeTableInput, _ ...request.Option) (*dynamodb.DescribeTableOutput, error) {
+	out, err := callClassic(ctx, func() (interface{}, error) { return c.DescribeTable(in) })
+	o, _ := out.(*dynamodb.DescribeTableOutput)
+	return o, err
+}
+
+func (c classicClient) UpdateTableWithContext(ctx aws.Context, in *dynamodb.UpdateTableInput, _ ...request.Option) (*dynamodb.UpdateTableOutput, error) {
+	out, err := callClassic(ctx, func() (interface{}, error) { return c.UpdateTable(in) })
+	o, _ := out.(*dynamodb.UpdateTableOutput)
+	return o, err
+}
+
+func (c classicClient) DescribeLimitsWithContext(ctx aws.Context, in *dynamodb.DescribeLimitsInput, _ ...request.Option) (*dynamodb.DescribeLimitsOutput, error) {
+	out, err := callClassic(ctx, func() (interface{}, error) { return c.DescribeLimits(in) })
+	o, _ := out.(*dynamodb.DescribeLimitsOutput)
+	return o, err
+}
+
+func (c classicClient) GetItemWithContext(ctx aws.Context, in *dynamodb.GetItemInput, _ ...request.Option) (*dynamodb.GetItemOutput, error) {
+	out, err := callClassic(ctx, func() (interface{}, error) { return c.GetItem(in) })
+	o, _ := out.(*dynamodb.GetItemOutput)
+	return o, err
+}
+
+func (c classicClient) BatchGetItemWithContext(ctx aws.Context, in *dynamodb.BatchGetItemInput, _ ...request.Option) (*dynamodb.BatchGetItemOutput, error) {
+	out, err := callClassic(ctx, func() (interface{}, error) { return c.BatchGetItem(in) })
+	o, _ := out.(*dynamodb.BatchGetItemOutput)
+	return o, err
+}
+
+func (c classicClient) PutItemWithContext(ctx aws.Context, in *dynamodb.PutItemInput, _ ...request.Option) (*dynamodb.PutItemOutput, error) {
+	out, err := callClassic(ctx, func() (interface{}, error) { return c.PutItem(in) })
+	o, _ := out.(*dynamodb.PutItemOutput)
+	return o, err
+}
+
+func (c classicClient) QueryWithContext(ctx aws.Context, in *dynamodb.QueryInput, _ ...request.Option) (*dynamodb.QueryOutput, error) {
+	out, err := callClassic(ctx, func() (interface{}, error) { return c.Query(in) })
+	o, _ := out.(*dynamodb.QueryOutput)
+	return o, err
+}
+
+func (c classicClient) ScanWithContext(ctx aws.Context, in *dynamodb.ScanInput, _ ...request.Option) (*dynamodb.ScanOutput, error) {
+	out, err := callClassic(ctx, func() (interface{}, error) { return c.Scan(in) })
+	o, _ := out.(*dynamodb.ScanOutput)
+	return o, err
+}
+
+func (c classicClient) UpdateItemWithContext(ctx aws.Context, in *dynamodb.UpdateItemInput, _ ...request.Option) (*dynamodb.UpdateItemOutput, error) {
+	out, err := callClassic(ctx, func() (interface{}, error) { return c.UpdateItem(in) })
+	o, _ := out.(*dynamodb.UpdateItemOutput)
+	return o, err
+}
+
+func (c classicClient) DeleteItemWithContext(ctx aws.Context, in *dynamodb.DeleteItemInput, _ ...request.Option) (*dynamodb.DeleteItemOutput, error) {
+	out, err := callClassic(ctx, func() (interface{}, error) { return c.DeleteItem(in) })
+	o, _ := out.(*dynamodb.DeleteItemOutput)
+	return o, err
+}
+
+func (c classicClient) BatchWriteItemWithContext(ctx aws.Context, in *dynamodb.BatchWriteItemInput, _ ...request.Option) (*dynamodb.BatchWriteItemOutput, error) {
+	out, err := callClassic(ctx, func() (interface{}, error) { return c.BatchWriteItem(in) })
+	o, _ := out.(*dynamodb.BatchWriteItemOutput)
+	return o, err
+}
+
+func (c classicClient) TransactGetItemsWithContext(ctx aws.Context, in *dynamodb.TransactGetItemsInput, _ ...request.Option) (*dynamodb.TransactGetItemsOutput, error) {
+	out, err := callClassic(ctx, func() (interface{}, error) { return c.TransactGetItems(in) })
+	o, _ := out.(*dynamodb.TransactGetItemsOutput)
+	return o, err
+}
+
+func (c classicClient) TransactWriteItemsWithContext(ctx aws.Context, in *dynamodb.TransactWriteItemsInput, _ ...request.Option) (*dynamodb.TransactWriteItemsOutput, error) {
+	out, err := callClassic(ctx, func() (interface{}, error) { return c.TransactWriteItems(in) })
+	o, _ := out.(*dynamodb.TransactWriteItemsOutput)
+	return o, err
+}
+
+/*****************************************   Helpers  ******************************************/
+/*sanitize copies av without empty string, empty binary or NULL attributes, recursing into maps and lists. Attributes in keep are left as is*/
+func sanitize(av DynamoDBValue, keep []string) DynamoDBValue {
+	if av == nil {
+		return nil
+	}
+	r := make(DynamoDBValue, len(av))
+	for k, v := range av {
+		r[k] = v
+	}
+	for k, v := range av {
+		kept := false
+		for _, name := range keep {
+			kept = kept || name == k
+		}
+		if kept {
+			continue
+		}
+		if v = sanitizeValue(v); v == nil {
+			delete(r, k)
+		} else {
+			r[k] = v
+		}
+	}
+	return r
 }
 
-func (d *createTable) WithLocalSecondaryIndex(lsi LocalSecondaryIndex) *createTable {
-	// handle projection types and NonKeyAttributes
-	var pt *string
-	var nka []*string
-	if lsi.ProjectionType == "" {
-		pt = aws.String(ProjectionTypeALL)
-	} else {
-		// ALL, INCLUDE, KEYS_ONLY
-		pt = aws.String(lsi.ProjectionType)
-		if lsi.ProjectionType == ProjectionTypeINCLUDE {
-			for _, key := range lsi.NonKeyAttributes {
-				newAttr := &dynamodb.AttributeDefinition{
-					AttributeName: aws.String(key.Name()),
-					AttributeType: aws.String(key.Type()),
-				}
-				d.AttributeDefinitions = append(d.AttributeDefinitions, newAttr)
-				nka = append(nka, aws.String(key.Name()))
+/*sanitizeValue returns nil if a is empty, otherwise a with its empty map entries and list elements removed*/
+func sanitizeValue(a *dynamodb.AttributeValue) *dynamodb.AttributeValue {
+	switch {
+	case a == nil, a.NULL != nil && *a.NULL:
+		return nil
+	case a.S != nil && *a.S == "":
+		return nil
+	case a.B != nil && len(a.B) == 0:
+		return nil
+	case a.M != nil:
+		return &dynamodb.AttributeValue{M: sanitize(a.M, nil)}
+	case a.L != nil:
+		l := make([]*dynamodb.AttributeValue, 0, len(a.L))
+		for _, e := range a.L {
+			if e = sanitizeValue(e); e != nil {
+				l = append(l, e)
 			}
 		}
+		return &dynamodb.AttributeValue{L: l}
 	}
+	return a
+}
 
-	// populate missing AttributeDefinitions
-	pk := &dynamodb.AttributeDefinition{
-		AttributeName: aws.String(lsi.PartitionKey.Name()),
-		AttributeType: aws.String(lsi.PartitionKey.Type()),
+/*isThrottled reports whether err is dynamo rejecting a request for exceeding capacity or rate limits*/
+func isThrottled(err error) bool {
+	if awsErr, ok := err.(awserr.Error); ok {
+		switch awsErr.Code() {
+		case dynamodb.ErrCodeProvisionedThroughputExceededException, dynamodb.ErrCodeRequestLimitExceeded, "ThrottlingException":
+			return true
+		}
 	}
+	return false
+}
 
-	rk := &dynamodb.AttributeDefinition{
-		AttributeName: aws.String(lsi.SortKey.Name()),
-		AttributeType: aws.String(lsi.SortKey.Type()),
+func appendKeyInterface(m *map[string]interface{}, table DynamoTable, key KeyValue) {
+	if *m == nil {
+		*m = map[string]interface{}{}
 	}
-	d.AttributeDefinitions = append(d.AttributeDefinitions, pk)
-	d.AttributeDefinitions = append(d.AttributeDefinitions, rk)
+	(*m)[table.PartitionKey.Name()] = key.PartitionKey
 
-	// create lsi obj
-	dynamoLsi := dynamodb.LocalSecondaryIndex{
-		IndexName: &lsi.Name,
-		KeySchema: []*dynamodb.KeySchemaElement{
-			{
-				AttributeName: aws.String(lsi.PartitionKey.Name()),
-				KeyType:       aws.String("HASH"),
-			},
-			{
-				AttributeName: aws.String(lsi.SortKey.Name()),
-				KeyType:       aws.String("RANGE"),
-			},
-		},
-		Projection: &dynamodb.Projection{
-			ProjectionType:   pt,
-			NonKeyAttributes: nka,
-		},
+	if table.RangeKey != nil && !table.RangeKey.IsEmpty() {
+		(*m)[table.RangeKey.Name()] = key.RangeKey
 	}
 
-	// append lsi to *createTable
-	d.LocalSecondaryIndexes = append(d.LocalSecondaryIndexes, &dynamoLsi)
-	return d
 }
 
-func (d *createTable) WithGlobalSecondaryIndex(gsi GlobalSecondaryIndex) *createTable {
-	// handle projection types and NonKeyAttributes
-	var pt *string
-	var nka []*string
-	if gsi.ProjectionType == "" {
-		pt = aws.String(ProjectionTypeALL)
-	} else {
-		// ALL, INCLUDE, KEYS_ONLY
-		pt = aws.String(gsi.ProjectionType)
-		if gsi.ProjectionType == ProjectionTypeINCLUDE {
-			for _, key := range gsi.NonKeyAttributes {
-				newAttr := &dynamodb.AttributeDefinition{
-					AttributeName: aws.String(key.Name()),
-					AttributeType: aws.String(key.Type()),
-				}
-				d.AttributeDefinitions = append(d.AttributeDefinitions, newAttr)
-				nka = append(nka, aws.String(key.Name()))
-			}
+/*appendStoredKey is appendKeyAttribute for a logical key, stored under a suffixed partition key on sharded tables*/
+func appendStoredKey(m *map[string]*dynamodb.AttributeValue, table DynamoTable, key KeyValue) error {
+	if err := appendKeyAttribute(m, table, key); err != nil {
+		return err
+	}
+	table.shardKey(*m)
+	return nil
+}
+
+func appendKeyAttribute(m *map[string]*dynamodb.AttributeValue, table DynamoTable, key KeyValue) (err error) {
+	err = appendAttribute(m, table.PartitionKey.Name(), key.PartitionKey)
+	if err != nil {
+		return
+	} else if table.RangeKey != nil && !table.RangeKey.IsEmpty() {
+		err = appendAttribute(m, table.RangeKey.Name(), key.RangeKey)
+		if err != nil {
+			return
 		}
 	}
+	return
+}
 
-	// setup default provisioning
-	var gsir *int64
-	var gsiw *int64
+func toValues(items []map[string]*dynamodb.AttributeValue) []DynamoDBValue {
+	values := make([]DynamoDBValue, len(items))
+	for i, item := range items {
+		values[i] = item
+	}
+	return values
+}
 
-	if gsi.ReadUnits != 0 {
-		gsir = &gsi.ReadUnits
-	} else {
-		gsir = aws.Int64(DynamoBatchSize)
+func appendNames(m *map[string]*string, names map[string]*string) {
+	if len(names) <= 0 {
+		return
 	}
-	if gsi.WriteUnits != 0 {
-		gsiw = &gsi.WriteUnits
-	} else {
-		gsiw = aws.Int64(DynamoBatchSize)
+	if *m == nil {
+		*m = make(map[string]*string)
 	}
+	for k, v := range names {
+		(*m)[k] = v
+	}
+}
 
-	keySchema := []*dynamodb.KeySchemaElement{
-		{
-			AttributeName: aws.String(gsi.PartitionKey.Name()),
-			KeyType:       aws.String("HASH"),
-		},
+func appendValues(m *map[string]*dynamodb.AttributeValue, values map[string]*dynamodb.AttributeValue) {
+	if len(values) <= 0 {
+		return
 	}
+	if *m == nil {
+		*m = make(DynamoDBValue)
+	}
+	for k, v := range values {
+		(*m)[k] = v
+	}
+}
 
-	// populate missing AttributeDefinitions
-	pk := &dynamodb.AttributeDefinition{
-		AttributeName: aws.String(gsi.PartitionKey.Name()),
-		AttributeType: aws.String(gsi.PartitionKey.Type()),
+func cloneNames(m map[string]*string) map[string]*string {
+	if m == nil {
+		return nil
 	}
-	d.AttributeDefinitions = append(d.AttributeDefinitions, pk)
+	c := make(map[string]*string, len(m))
+	for k, v := range m {
+		s := *v
+		c[k] = &s
+	}
+	return c
+}
 
-	if gsi.RangeKey != nil && !gsi.RangeKey.IsEmpty() {
-		rk := &dynamodb.AttributeDefinition{
-			AttributeName: aws.String(gsi.RangeKey.Name()),
-			AttributeType: aws.String(gsi.RangeKey.Type()),
-		}
-		d.AttributeDefinitions = append(d.AttributeDefinitions, rk)
-		keySchema = append(keySchema, &dynamodb.KeySchemaElement{
-			AttributeName: aws.String(gsi.RangeKey.Name()),
-			KeyType:       aws.String("RANGE"),
-		})
+func cloneValue(m map[string]*dynamodb.AttributeValue) DynamoDBValue {
+	if m == nil {
+		return nil
+	}
+	c := make(DynamoDBValue, len(m))
+	for k, v := range m {
+		c[k] = cloneAttributeValue(v)
 	}
+	return c
+}
 
-	// create gsi obj
-	dynamoGsi := dynamodb.GlobalSecondaryIndex{
-		IndexName: &gsi.Name,
-		KeySchema: keySchema,
-		Projection: &dynamodb.Projection{
-			ProjectionType:   pt,
-			NonKeyAttributes: nka,
-		},
-		ProvisionedThroughput: &dynamodb.ProvisionedThroughput{
-			ReadCapacityUnits:  gsir,
-			WriteCapacityUnits: gsiw,
-		},
+func cloneAttributeValue(v *dynamodb.AttributeValue) *dynamodb.AttributeValue {
+	if v == nil {
+		return nil
+	}
+	c := *v
+	// Empty but set slices stay non-nil; a nil B or L would turn the attribute into {}, which dynamo rejects
+	if v.B != nil {
+		c.B = append(make([]byte, 0, len(v.B)), v.B...)
+	}
+	if v.BS != nil {
+		c.BS = make([][]byte, 0, len(v.BS))
+		for _, b := range v.BS {
+			c.BS = append(c.BS, append([]byte(nil), b...))
+		}
+	}
+	if v.NS != nil {
+		c.NS = append(make([]*string, 0, len(v.NS)), v.NS...)
+	}
+	if v.SS != nil {
+		c.SS = append(make([]*string, 0, len(v.SS)), v.SS...)
+	}
+	if v.L != nil {
+		c.L = make([]*dynamodb.AttributeValue, 0, len(v.L))
+		for _, e := range v.L {
+			c.L = append(c.L, cloneAttributeValue(e))
+		}
 	}
+	c.M = cloneValue(v.M)
+	return &c
+}
 
-	// append gsi to *createTable
-	d.GlobalSecondaryIndexes = append(d.GlobalSecondaryIndexes, &dynamoGsi)
-	return d
+/*secondaryIndex is the key schema of the index a query or scan reads*/
+type secondaryIndex struct {
+	name         string
+	partitionKey DynamoFieldIFace
+	rangeKey     DynamoFieldIFace
+	global       bool
 }
 
-func (c *createTable) Build() *dynamodb.CreateTableInput {
-	// Dedupe attribute defns
-	at := make(map[string]*dynamodb.AttributeDefinition)
-	for _, t := range c.AttributeDefinitions {
-		at[*t.AttributeName] = t
+/*GSI ... Look up one of the table's global secondary indexes by name*/
+func (table DynamoTable) GSI(name string) (GlobalSecondaryIndex, bool) {
+	for _, gsi := range table.GlobalSecondaryIndexes {
+		if gsi.Name == name {
+			return gsi, true
+		}
 	}
-	c.AttributeDefinitions = nil
+	return GlobalSecondaryIndex{}, false
+}
 
-	for _, v := range at {
-		c.AttributeDefinitions = append(c.AttributeDefinitions, v)
+/*LSI ... Look up one of the table's local secondary indexes by name*/
+func (table DynamoTable) LSI(name string) (LocalSecondaryIndex, bool) {
+	for _, lsi := range table.LocalSecondaryIndexes {
+		if lsi.Name == name {
+			return lsi, true
+		}
 	}
-	r := dynamodb.CreateTableInput(*c)
-	return &r
+	return LocalSecondaryIndex{}, false
 }
 
-func (d *createTable) ExecuteWith(ctx context.Context, dynamo DynamoDBIFace, opts ...request.Option) error {
-	defer time.Sleep(time.Duration(500) * time.Millisecond)
-	_, err := dynamo.CreateTableWithContext(ctx, d.Build(), opts...)
-	return err
+/*secondaryIndex looks up one of the table's indexes by name, nil if the table doesn't define it*/
+func (table DynamoTable) secondaryIndex(name string) *secondaryIndex {
+	if gsi, ok := table.GSI(name); ok {
+		return &secondaryIndex{name: gsi.Name, partitionKey: gsi.PartitionKey, rangeKey: gsi.RangeKey, global: true}
+	}
+	if lsi, ok := table.LSI(name); ok {
+		return &secondaryIndex{name: lsi.Name, partitionKey: lsi.PartitionKey, rangeKey: lsi.SortKey}
+	}
+	return nil
 }
 
-/**********************************************************************************************/
-/********************************************** Delete Table **********************************/
-/**********************************************************************************************/
-type deleteTable dynamodb.DeleteTableInput
+/**
+ ** validateStartKey ... Check an exclusive start key has exactly the attributes of the table's key schema,
+ ** plus the index's when one is read. An index set by name only is looked up on the table, and left
+ ** unchecked when the table doesn't define it
+ */
+func (table DynamoTable) validateStartKey(op string, indexName *string, index *secondaryIndex, key DynamoDBValue) error {
+	if key == nil {
+		return nil
+	}
+	if index == nil && indexName != nil {
+		if index = table.secondaryIndex(*indexName); index == nil {
+			return nil
+		}
+	}
+	fields := []DynamoFieldIFace{table.PartitionKey, table.RangeKey}
+	if index != nil {
+		fields = append(fields, index.partitionKey, index.rangeKey)
+	}
+	required := map[string]bool{}
+	var missing, extra []string
+	for _, f := range fields {
+		if f == nil || f.IsEmpty() || required[f.Name()] {
+			continue
+		}
+		required[f.Name()] = true
+		if _, ok := key[f.Name()]; !ok {
+			missing = append(missing, f.Name())
+		}
+	}
+	for name := range key {
+		if !required[name] {
+			extra = append(extra, name)
+		}
+	}
+	if len(missing) <= 0 && len(extra) <= 0 {
+		return nil
+	}
+	sort.Strings(extra)
 
-func (table DynamoTable) DeleteTable() *deleteTable {
-	r := deleteTable(dynamodb.DeleteTableInput{TableName: &table.Name})
-	return &r
+	schema := table.Name
+	if index != nil {
+		schema += " index " + index.name
+	}
+	var problems []string
+	if len(missing) > 0 {
+		problems = append(problems, "is missing "+strings.Join(missing, ", "))
+	}
+	if len(extra) > 0 {
+		problems = append(problems, "has extra "+strings.Join(extra, ", "))
+	}
+	return fmt.Errorf("%s %s: exclusive start key %s.", op, schema, strings.Join(problems, " and "))
 }
 
-func (d *deleteTable) Build() *dynamodb.DeleteTableInput {
-	r := dynamodb.DeleteTableInput(*d)
-	return &r
+/*keyFields are the table's key attributes, followed by the index's if one is selected, each named once*/
+func (table DynamoTable) keyFields(indexName *string) (fields []DynamoFieldIFace) {
+	candidates := []DynamoFieldIFace{table.PartitionKey, table.RangeKey}
+	if indexName != nil {
+		if gsi, ok := table.GSI(*indexName); ok {
+			candidates = append(candidates, gsi.PartitionKey, gsi.RangeKey)
+		}
+		if lsi, ok := table.LSI(*indexName); ok {
+			candidates = append(candidates, lsi.PartitionKey, lsi.SortKey)
+		}
+	}
+	seen := map[string]bool{}
+	for _, f := range candidates {
+		if f == nil || f.IsEmpty() || seen[f.Name()] {
+			continue
+		}
+		seen[f.Name()] = true
+		fields = append(fields, f)
+	}
+	return
 }
 
-func (d *deleteTable) ExecuteWith(ctx context.Context, dynamo DynamoDBIFace, opts ...request.Option) error {
-	defer time.Sleep(time.Duration(500) * time.Millisecond)
-	_, err := dynamo.DeleteTableWithContext(ctx, d.Build(), opts...)
-	return err
+/*itemKey extracts the table key, and index key if one is selected, from an item for use as an ExclusiveStartKey*/
+func itemKey(table DynamoTable, indexName *string, av DynamoDBValue) DynamoDBValue {
+	key := DynamoDBValue{}
+	for _, f := range table.keyFields(indexName) {
+		if v, ok := av[f.Name()]; ok {
+			key[f.Name()] = v
+		}
+	}
+	return key
 }
 
-/*****************************************   Helpers  ******************************************/
-func appendKeyInterface(m *map[string]interface{}, table DynamoTable, key KeyValue) {
-	if *m == nil {
-		*m = map[string]interface{}{}
+/*startKey marshals an exclusive start key from an item's table keys and, when reading an index, its index keys*/
+func (table DynamoTable) startKey(indexName *string, tableKey KeyValue, indexKey *KeyValue) (key map[string]*dynamodb.AttributeValue, err error) {
+	if err = table.validateKey("WithStartKeyValue", tableKey); err != nil {
+		return
+	}
+	if err = appendAttribute(&key, table.PartitionKey.Name(), tableKey.PartitionKey); err != nil {
+		return
 	}
-	(*m)[table.PartitionKey.Name()] = key.PartitionKey
-
 	if table.RangeKey != nil && !table.RangeKey.IsEmpty() {
-		(*m)[table.RangeKey.Name()] = key.RangeKey
+		if err = appendAttribute(&key, table.RangeKey.Name(), tableKey.RangeKey); err != nil {
+			return
+		}
 	}
 
-}
-func appendKeyAttribute(m *map[string]*dynamodb.AttributeValue, table DynamoTable, key KeyValue) (err error) {
-	err = appendAttribute(m, table.PartitionKey.Name(), key.PartitionKey)
-	if err != nil {
+	if indexName == nil {
+		if indexKey != nil {
+			err = fmt.Errorf("WithStartKeyValue %s: no index is set, but index key %v was given.", table.Name, *indexKey)
+		}
 		return
-	} else if table.RangeKey != nil && !table.RangeKey.IsEmpty() {
-		err = appendAttribute(m, table.RangeKey.Name(), key.RangeKey)
-		if err != nil {
-			return
+	}
+	var partitionKey, rangeKey DynamoFieldIFace
+	for _, gsi := range table.GlobalSecondaryIndexes {
+		if gsi.Name == *indexName {
+			partitionKey, rangeKey = gsi.PartitionKey, gsi.RangeKey
+		}
+	}
+	for _, lsi := range table.LocalSecondaryIndexes {
+		if lsi.Name == *indexName {
+			partitionKey, rangeKey = lsi.PartitionKey, lsi.SortKey
+		}
+	}
+	if partitionKey == nil {
+		return nil, fmt.Errorf("WithStartKeyValue %s: unknown index %s.", table.Name, *indexName)
+	}
+	if indexKey == nil {
+		return nil, fmt.Errorf("WithStartKeyValue %s: missing index key for %s.", table.Name, *indexName)
+	}
+
+	/*Index keys shared with the table, i.e. a local index's partition key, may be left out*/
+	components := []struct {
+		field DynamoFieldIFace
+		value interface{}
+		kind  string
+	}{
+		{partitionKey, indexKey.PartitionKey, "partition"},
+		{rangeKey, indexKey.RangeKey, "range"},
+	}
+	for _, c := range components {
+		if c.field == nil || c.field.IsEmpty() {
+			if c.value != nil {
+				return nil, fmt.Errorf("WithStartKeyValue %s: index has no %s key, but %s was given.", *indexName, c.kind, debugKey(c.value))
+			}
+			continue
+		}
+		if isMissingKey(c.value) {
+			if _, ok := key[c.field.Name()]; ok {
+				continue
+			}
+			return nil, fmt.Errorf("WithStartKeyValue %s: missing %s key %s.", *indexName, c.kind, c.field.Name())
+		}
+		if err = checkKeyType("WithStartKeyValue", *indexName, c.kind, c.field, c.value); err != nil {
+			return nil, err
+		}
+		if err = appendAttribute(&key, c.field.Name(), c.value); err != nil {
+			return nil, err
 		}
 	}
 	return
 }
 
+/*resumeKey computes where to resume paging when a page of items is abandoned at index i*/
+func resumeKey(keyOf func(DynamoDBValue) DynamoDBValue, start DynamoDBValue, items []map[string]*dynamodb.AttributeValue, i int) DynamoDBValue {
+	if i <= 0 {
+		return start
+	}
+	return keyOf(items[i-1])
+}
+
 func appendAttribute(m *map[string]*dynamodb.AttributeValue, key string, value interface{}) (err error) {
 	if *m == nil {
 		*m = make(DynamoDBValue)
 	}
 	v, err := dynamodbattribute.Marshal(value)
+	if err == nil && reflect.DeepEqual(v, &dynamodb.AttributeValue{}) {
+		// The marshaler silently skips unsupported types such as channels and funcs
+		err = fmt.Errorf("Cannot marshal %s: Go value type %T is not supported.", key, value)
+	}
 	if err == nil {
 		(*m)[key] = v
 	}
@@ -4,18 +4,31 @@ import (
 	// "fmt"
 
 	"context"
+	"crypto/rand"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"math"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+	"unicode"
 
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/credentials"
-	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -100,52 +113,319 @@ func NewUserTable() UserTable {
 	}
 }
 
+/*
+* disposableTable creates a uniquely named copy of template for the test. Defer dispose to delete it: the Go 1.10
+* toolchain rules_go 0.12 registers predates t.Cleanup. It mirrors dominotest.NewHarness, which this package's tests
+* can't import without a cycle
+ */
+func disposableTable(t *testing.T, db DynamoAdmin, template DynamoTable) DynamoTable {
+	t.Helper()
+	table := uniqueTable(t, template)
+	if _, err := table.EnsureTable(context.Background(), db); err != nil {
+		t.Fatalf("creating table %s: %v", table.Name, err)
+	}
+	return table
+}
+
+/*uniqueTable is a copy of template named after the test, with a random suffix. Like dominotest.TableName, the test name is cut to fit dynamo's 255 byte limit*/
+func uniqueTable(t *testing.T, template DynamoTable) DynamoTable {
+	t.Helper()
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		t.Fatal(err)
+	}
+	random := fmt.Sprintf("%x", suffix)
+	test := strings.Map(func(r rune) rune {
+		if r < 128 && (r == '_' || r == '.' || r == '-' || unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return r
+		}
+		return '_'
+	}, t.Name())
+	if max := 255 - len(template.Name) - len(random) - 2; len(test) > max {
+		if max < 0 {
+			max = 0
+		}
+		test = test[:max]
+	}
+	table := template
+	table.Name = template.Name + "-" + test + "-" + random
+	return table
+}
+
+/*dispose deletes a table created by disposableTable*/
+func dispose(db DynamoAdmin, table DynamoTable) {
+	table.DeleteTable().ExecuteWith(context.Background(), db)
+}
+
 func NewDB() DynamoDBIFace {
-	region := "us-west-2"
-	creds := credentials.NewStaticCredentials("123", "123", "")
-	config := aws.
-		NewConfig().
-		WithRegion(region).
-		WithCredentials(creds).
-		WithEndpoint(localDynamoHost).
-		WithHTTPClient(http.DefaultClient)
-	sess := session.New(config)
+	return NewLocalClient(localDynamoHost)
+}
+
+/*stubDB is an in-process DynamoDBIFace for tests that don't need a live dynamo. Unset handlers panic.*/
+type stubDB struct {
+	DynamoDBIFace
+	query          func(*dynamodb.QueryInput) (*dynamodb.QueryOutput, error)
+	scan           func(*dynamodb.ScanInput) (*dynamodb.ScanOutput, error)
+	batchGetItem   func(*dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error)
+	batchWriteItem func(*dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error)
+	updateItem     func(*dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error)
+	describeTable  func(*dynamodb.DescribeTableInput) (*dynamodb.DescribeTableOutput, error)
+	createTable    func(*dynamodb.CreateTableInput) (*dynamodb.CreateTableOutput, error)
+	updateTable    func(*dynamodb.UpdateTableInput) (*dynamodb.UpdateTableOutput, error)
+	getItem        func(*dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error)
+	deleteItem     func(*dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error)
+	putItem        func(*dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error)
+	transactWrite  func(*dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error)
+	transactGet    func(*dynamodb.TransactGetItemsInput) (*dynamodb.TransactGetItemsOutput, error)
+	describeLimits func(*dynamodb.DescribeLimitsInput) (*dynamodb.DescribeLimitsOutput, error)
+	opts           []request.Option //The options passed to the last call
+	mutex          sync.Mutex
+}
+
+func (s *stubDB) QueryWithContext(ctx aws.Context, in *dynamodb.QueryInput, opts ...request.Option) (*dynamodb.QueryOutput, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.opts = opts
+	return s.query(in)
+}
+
+func (s *stubDB) GetItemWithContext(ctx aws.Context, in *dynamodb.GetItemInput, opts ...request.Option) (*dynamodb.GetItemOutput, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.opts = opts
+	return s.getItem(in)
+}
+
+func (s *stubDB) ScanWithContext(ctx aws.Context, in *dynamodb.ScanInput, opts ...request.Option) (*dynamodb.ScanOutput, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.opts = opts
+	return s.scan(in)
+}
+
+func (s *stubDB) BatchGetItemWithContext(ctx aws.Context, in *dynamodb.BatchGetItemInput, opts ...request.Option) (*dynamodb.BatchGetItemOutput, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.opts = opts
+	return s.batchGetItem(in)
+}
+
+func (s *stubDB) BatchWriteItemWithContext(ctx aws.Context, in *dynamodb.BatchWriteItemInput, opts ...request.Option) (*dynamodb.BatchWriteItemOutput, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.batchWriteItem(in)
+}
+
+func (s *stubDB) PutItemWithContext(ctx aws.Context, in *dynamodb.PutItemInput, opts ...request.Option) (*dynamodb.PutItemOutput, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.putItem(in)
+}
+
+func (s *stubDB) TransactWriteItemsWithContext(ctx aws.Context, in *dynamodb.TransactWriteItemsInput, opts ...request.Option) (*dynamodb.TransactWriteItemsOutput, error) {
+	return s.transactWrite(in)
+}
+
+func (s *stubDB) TransactGetItemsWithContext(ctx aws.Context, in *dynamodb.TransactGetItemsInput, opts ...request.Option) (*dynamodb.TransactGetItemsOutput, error) {
+	return s.transactGet(in)
+}
+
+func (s *stubDB) DeleteItemWithContext(ctx aws.Context, in *dynamodb.DeleteItemInput, opts ...request.Option) (*dynamodb.DeleteItemOutput, error) {
+	return s.deleteItem(in)
+}
+
+func (s *stubDB) UpdateItemWithContext(ctx aws.Context, in *dynamodb.UpdateItemInput, opts ...request.Option) (*dynamodb.UpdateItemOutput, error) {
+	return s.updateItem(in)
+}
+
+func (s *stubDB) DescribeTableWithContext(ctx aws.Context, in *dynamodb.DescribeTableInput, opts ...request.Option) (*dynamodb.DescribeTableOutput, error) {
+	return s.describeTable(in)
+}
+
+func (s *stubDB) DescribeLimitsWithContext(ctx aws.Context, in *dynamodb.DescribeLimitsInput, opts ...request.Option) (*dynamodb.DescribeLimitsOutput, error) {
+	return s.describeLimits(in)
+}
+
+func (s *stubDB) CreateTableWithContext(ctx aws.Context, in *dynamodb.CreateTableInput, opts ...request.Option) (*dynamodb.CreateTableOutput, error) {
+	return s.createTable(in)
+}
+
+func (s *stubDB) UpdateTableWithContext(ctx aws.Context, in *dynamodb.UpdateTableInput, opts ...request.Option) (*dynamodb.UpdateTableOutput, error) {
+	return s.updateTable(in)
+}
+
+/*memoryDB is a stubDB storing the items of table in memory, serving puts, gets, deletes and batches of them*/
+func memoryDB(table DynamoTable) (*stubDB, map[string]DynamoDBValue) {
+	items := map[string]DynamoDBValue{}
+	key := func(item DynamoDBValue) string { return cacheKey(table.Name, itemKey(table, nil, item)) }
+	db := &stubDB{
+		putItem: func(in *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+			items[key(in.Item)] = in.Item
+			return &dynamodb.PutItemOutput{}, nil
+		},
+		getItem: func(in *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+			return &dynamodb.GetItemOutput{Item: items[key(in.Key)]}, nil
+		},
+		deleteItem: func(in *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
+			delete(items, key(in.Key))
+			return &dynamodb.DeleteItemOutput{}, nil
+		},
+		batchGetItem: func(in *dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error) {
+			out := &dynamodb.BatchGetItemOutput{Responses: map[string][]map[string]*dynamodb.AttributeValue{}}
+			for _, k := range in.RequestItems[table.Name].Keys {
+				if item, ok := items[key(k)]; ok {
+					out.Responses[table.Name] = append(out.Responses[table.Name], item)
+				}
+			}
+			return out, nil
+		},
+		batchWriteItem: func(in *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+			for _, w := range in.RequestItems[table.Name] {
+				if w.PutRequest != nil {
+					items[key(w.PutRequest.Item)] = w.PutRequest.Item
+				} else {
+					delete(items, key(w.DeleteRequest.Key))
+				}
+			}
+			return &dynamodb.BatchWriteItemOutput{}, nil
+		},
+	}
+	return db, items
+}
+
+/*builtPut builds a put that is expected to be valid*/
+func builtPut(t *testing.T, p *PutInput) *dynamodb.PutItemInput {
+	b, err := p.Build()
+	assert.NoError(t, err)
+	return b
+}
+
+/*describedAs describes the table as CreateTable would provision it*/
+func describedAs(c *dynamodb.CreateTableInput) *dynamodb.TableDescription {
+	d := &dynamodb.TableDescription{
+		TableName:            c.TableName,
+		KeySchema:            c.KeySchema,
+		AttributeDefinitions: c.AttributeDefinitions,
+	}
+	for _, i := range c.GlobalSecondaryIndexes {
+		d.GlobalSecondaryIndexes = append(d.GlobalSecondaryIndexes, &dynamodb.GlobalSecondaryIndexDescription{
+			IndexName: i.IndexName, KeySchema: i.KeySchema, Projection: i.Projection,
+		})
+	}
+	for _, i := range c.LocalSecondaryIndexes {
+		d.LocalSecondaryIndexes = append(d.LocalSecondaryIndexes, &dynamodb.LocalSecondaryIndexDescription{
+			IndexName: i.IndexName, KeySchema: i.KeySchema, Projection: i.Projection,
+		})
+	}
+	return d
+}
+
+/*pagedItems splits n users into pages of pageSize, keyed by the page index*/
+func pagedItems(n, pageSize int) (pages [][]map[string]*dynamodb.AttributeValue) {
+	var page []map[string]*dynamodb.AttributeValue
+	for i := 0; i < n; i++ {
+		av, _ := dynamodbattribute.MarshalMap(User{Email: "name@email.com", Password: "password" + strconv.Itoa(i)})
+		page = append(page, av)
+		if len(page) == pageSize {
+			pages = append(pages, page)
+			page = nil
+		}
+	}
+	if len(page) > 0 {
+		pages = append(pages, page)
+	}
+	return
+}
+
+/*pageKey is a users table key whose range key numbers the page it resumes*/
+func pageKey(i int) DynamoDBValue {
+	return DynamoDBValue{
+		"email":    &dynamodb.AttributeValue{S: aws.String("name@email.com")},
+		"password": &dynamodb.AttributeValue{S: aws.String(strconv.Itoa(i))},
+	}
+}
+
+func pageIndex(key DynamoDBValue) int {
+	if key == nil {
+		return 0
+	}
+	i, _ := strconv.Atoi(*key["password"].S)
+	return i
+}
+
+/*newPagedStub serves the given pages to both Query and Scan, counting the calls made. Half of each page is reported as filtered*/
+func newPagedStub(pages [][]map[string]*dynamodb.AttributeValue, calls *int) *stubDB {
+	page := func(start DynamoDBValue) (items []map[string]*dynamodb.AttributeValue, last DynamoDBValue) {
+		*calls++
+		i := pageIndex(start)
+		if i < len(pages) {
+			items = pages[i]
+		}
+		if i+1 < len(pages) {
+			last = pageKey(i + 1)
+		}
+		return
+	}
+	return &stubDB{
+		query: func(in *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+			items, last := page(in.ExclusiveStartKey)
+			n := int64(len(items))
+			return &dynamodb.QueryOutput{Items: items, LastEvaluatedKey: last, Count: &n, ScannedCount: aws.Int64(2 * n)}, nil
+		},
+		scan: func(in *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+			items, last := page(in.ExclusiveStartKey)
+			n := int64(len(items))
+			return &dynamodb.ScanOutput{Items: items, LastEvaluatedKey: last, Count: &n, ScannedCount: aws.Int64(2 * n)}, nil
+		},
+	}
+}
+
+func TestUniqueTable(t *testing.T) {
+	table := NewUserTable().DynamoTable
+	assert.Regexp(t, `^`+table.Name+`-TestUniqueTable-[0-9a-f]{8}$`, uniqueTable(t, table).Name)
 
-	return dynamodb.New(sess)
+	table.Name = strings.Repeat("t", 240)
+	assert.Equal(t, table.Name+"-TestU-", uniqueTable(t, table).Name[:247])
+	assert.Len(t, uniqueTable(t, table).Name, 255)
 }
 
 func TestCreateTable(t *testing.T) {
+	t.Parallel()
 	ctx := context.Background()
 	db := NewDB()
 	table := NewUserTable()
 
-	err := table.CreateTable().ExecuteWith(ctx, db)
+	created := uniqueTable(t, table.DynamoTable)
+	err := created.CreateTable().ExecuteWith(ctx, db)
 	assert.NoError(t, err)
 
-	err = table.DeleteTable().ExecuteWith(ctx, db)
+	err = created.DeleteTable().ExecuteWith(ctx, db)
 	assert.NoError(t, err)
 
 	// Test nil range key
 	table.RangeKey = nil
 	table.LocalSecondaryIndexes = nil // Illegal to have an lsi, and no range key
-	err = table.CreateTable().ExecuteWith(ctx, db)
+	created = uniqueTable(t, table.DynamoTable)
+	err = created.CreateTable().ExecuteWith(ctx, db)
 	assert.NoError(t, err)
 
-	err = table.DeleteTable().ExecuteWith(ctx, db)
+	err = created.DeleteTable().ExecuteWith(ctx, db)
 	assert.NoError(t, err)
 
 	// Test nil gsi range key
 	table.nameGlobalIndex.RangeKey = nil
 
-	err = table.CreateTable().ExecuteWith(ctx, db)
+	created = uniqueTable(t, table.DynamoTable)
+	err = created.CreateTable().ExecuteWith(ctx, db)
 	assert.NoError(t, err)
 
-	err = table.DeleteTable().ExecuteWith(ctx, db)
+	err = created.DeleteTable().ExecuteWith(ctx, db)
 	assert.NoError(t, err)
 
 }
 
 func TestGetItem(t *testing.T) {
+	t.Parallel()
 
 	ctx := context.Background()
 
@@ -153,13 +433,11 @@ func TestGetItem(t *testing.T) {
 
 	db := NewDB()
 
-	err := table.CreateTable().ExecuteWith(ctx, db)
-	defer table.DeleteTable().ExecuteWith(ctx, db)
-
-	assert.NoError(t, err)
+	table.DynamoTable = disposableTable(t, db, table.DynamoTable)
+	defer dispose(db, table.DynamoTable)
 
 	item := User{Email: "naveen@email.com", Password: "password"}
-	err = table.PutItem(item).ExecuteWith(ctx, db).Result(nil)
+	err := table.PutItem(item).ExecuteWith(ctx, db).Result(nil)
 	assert.Nil(t, err)
 
 	var r *User = &User{}
@@ -173,6 +451,7 @@ func TestGetItem(t *testing.T) {
 
 }
 func TestGetItemEmpty(t *testing.T) {
+	t.Parallel()
 
 	table := NewUserTable()
 
@@ -180,10 +459,8 @@ func TestGetItemEmpty(t *testing.T) {
 
 	ctx := context.Background()
 
-	err := table.CreateTable().ExecuteWith(ctx, db)
-	defer table.DeleteTable().ExecuteWith(ctx, db)
-
-	assert.NoError(t, err)
+	table.DynamoTable = disposableTable(t, db, table.DynamoTable)
+	defer dispose(db, table.DynamoTable)
 
 	out := table.GetItem(KeyValue{"naveen@email.com", "password"}).ExecuteWith(ctx, db)
 	assert.Nil(t, out.Error())
@@ -191,13 +468,12 @@ func TestGetItemEmpty(t *testing.T) {
 }
 
 func TestBatchPutItem(t *testing.T) {
+	t.Parallel()
 	table := NewUserTable()
 	db := NewDB()
 	ctx := context.Background()
-	err := table.CreateTable().ExecuteWith(ctx, db)
-	defer table.DeleteTable().ExecuteWith(ctx, db)
-
-	assert.NoError(t, err)
+	table.DynamoTable = disposableTable(t, db, table.DynamoTable)
+	defer dispose(db, table.DynamoTable)
 
 	items := []interface{}{}
 	for i := 0; i < 100; i++ {
@@ -217,7 +493,7 @@ func TestBatchPutItem(t *testing.T) {
 		unprocessed = append(unprocessed, &user)
 		return &user
 	}
-	err = q.ExecuteWith(ctx, db).Results(f)
+	err := q.ExecuteWith(ctx, db).Results(f)
 
 	assert.Empty(t, unprocessed)
 	assert.NoError(t, err)
@@ -243,13 +519,12 @@ func TestBatchPutItem(t *testing.T) {
 }
 
 func TestBatchGetItem(t *testing.T) {
+	t.Parallel()
 	table := NewUserTable()
 	db := NewDB()
 	ctx := context.Background()
-	err := table.CreateTable().ExecuteWith(ctx, db)
-	defer table.DeleteTable().ExecuteWith(ctx, db)
-
-	assert.NoError(t, err)
+	table.DynamoTable = disposableTable(t, db, table.DynamoTable)
+	defer dispose(db, table.DynamoTable)
 
 	u := &User{Email: "bob@email.com", Password: "password"}
 	items := []interface{}{u}
@@ -266,7 +541,7 @@ func TestBatchGetItem(t *testing.T) {
 		ui = append(ui, &u)
 		return &u
 	}
-	err = w.ExecuteWith(ctx, db).Results(f)
+	err := w.ExecuteWith(ctx, db).Results(f)
 
 	assert.NoError(t, err)
 	assert.Empty(t, ui)
@@ -299,18 +574,17 @@ func TestBatchGetItem(t *testing.T) {
 }
 
 func TestUpdateItem(t *testing.T) {
+	t.Parallel()
 	table := NewUserTable()
 	db := NewDB()
 	ctx := context.Background()
 
-	err := table.CreateTable().ExecuteWith(ctx, db)
-	defer table.DeleteTable().ExecuteWith(ctx, db)
-
-	assert.NoError(t, err)
+	table.DynamoTable = disposableTable(t, db, table.DynamoTable)
+	defer dispose(db, table.DynamoTable)
 
 	item := User{Email: "name@email.com", Password: "password", Degrees: []float64{1, 2}, Locales: []string{"eu"}, Preferences: map[string]string{"update_email": "test"}}
 	q := table.PutItem(item)
-	err = q.ExecuteWith(ctx, db).Result(nil)
+	err := q.ExecuteWith(ctx, db).Result(nil)
 
 	assert.NoError(t, err)
 
@@ -358,17 +632,16 @@ func TestUpdateItem(t *testing.T) {
 }
 
 func TestRemoveAttribute(t *testing.T) {
+	t.Parallel()
 	table := NewUserTable()
 	db := NewDB()
 	ctx := context.Background()
 
-	err := table.CreateTable().ExecuteWith(ctx, db)
-	defer table.DeleteTable().ExecuteWith(ctx, db)
-
-	assert.Nil(t, err)
+	table.DynamoTable = disposableTable(t, db, table.DynamoTable)
+	defer dispose(db, table.DynamoTable)
 
 	q := table.PutItem(User{Email: "brendanr@email.com", Password: "password", LoginCount: 5})
-	err = q.ExecuteWith(ctx, db).Result(nil)
+	err := q.ExecuteWith(ctx, db).Result(nil)
 	assert.Nil(t, err)
 
 	// remove
@@ -392,13 +665,13 @@ func TestRemoveAttribute(t *testing.T) {
 }
 
 func TestPutItem(t *testing.T) {
+	t.Parallel()
 	table := NewUserTable()
 	db := NewDB()
 	ctx := context.Background()
 
-	err := table.CreateTable().ExecuteWith(ctx, db)
-	defer table.DeleteTable().ExecuteWith(ctx, db)
-	assert.NoError(t, err)
+	table.DynamoTable = disposableTable(t, db, table.DynamoTable)
+	defer dispose(db, table.DynamoTable)
 
 	item := User{Email: "joe@email.com", Password: "password"}
 	q := table.PutItem(item).SetConditionExpression(
@@ -408,7 +681,7 @@ func TestPutItem(t *testing.T) {
 		),
 	)
 
-	err = q.ExecuteWith(ctx, db).Result(nil)
+	err := q.ExecuteWith(ctx, db).Result(nil)
 	assert.NoError(t, err)
 
 	v := table.
@@ -431,14 +704,13 @@ func TestPutItem(t *testing.T) {
 }
 
 func TestTransactWriteItems(t *testing.T) {
+	t.Parallel()
 	table := NewUserTable()
 	db := NewDB()
 	ctx := context.Background()
 
-	err := table.CreateTable().ExecuteWith(ctx, db)
-	defer table.DeleteTable().ExecuteWith(ctx, db)
-
-	assert.NoError(t, err)
+	table.DynamoTable = disposableTable(t, db, table.DynamoTable)
+	defer dispose(db, table.DynamoTable)
 
 	users := []User{}
 	items := make(map[string]User)
@@ -463,8 +735,7 @@ func TestTransactWriteItems(t *testing.T) {
 			ConditionCheck(conditions[ikey], table.registrationDate.Equals(123))
 	}
 
-	var out *dynamodb.TransactWriteItemsInput
-	out, err = q.Build()
+	out, err := q.Build()
 	assert.NoError(t, err)
 
 	assert.Equal(t, 2*4, len(out.TransactItems))
@@ -535,8 +806,12 @@ func TestTransactWriteItems(t *testing.T) {
 	for _, v := range conditions {
 		q = q.ConditionCheck(v, table.name.Equals("nonname2"))
 	}
-	_, err = q.ExecuteWith(ctx, db).Results()
+	failedOut := q.ExecuteWith(ctx, db)
+	_, err = failedOut.Results()
 	assert.Error(t, err)
+	failed, ok := failedOut.ConditionFailed()
+	assert.True(t, ok)
+	assert.Equal(t, CancellationReason{Index: 0, TableName: table.Name, Code: "ConditionalCheckFailed"}, failed)
 
 	// Delete
 	q = table.TransactWriteItems()
@@ -557,15 +832,168 @@ func TestTransactWriteItems(t *testing.T) {
 
 }
 
-func TestExpressions(t *testing.T) {
+func TestTransactConditionCheck(t *testing.T) {
+	ctx := context.Background()
 	table := NewUserTable()
-	db := NewDB()
+	status := StringField("status")
+	accounts := DynamoTable{Name: "accounts", PartitionKey: StringField("id"), RangeKey: EmptyField()}
+
+	q := table.TransactWriteItems().
+		Add(accounts.ConditionCheck(PK("acct1"), status.Equals("active"))).
+		PutItem(User{Email: "name@email.com", Password: "password"}).
+		Add(accounts.TransactUpdate(PK("acct1"), table.loginCount.Increment(1)), accounts.TransactDelete(PK("acct2")))
+	in, err := q.Build()
+	assert.NoError(t, err)
+	assert.Len(t, in.TransactItems, 4)
+	check := in.TransactItems[0].ConditionCheck
+	assert.Equal(t, "accounts", *check.TableName)
+	assert.Equal(t, "acct1", *check.Key["id"].S)
+	assert.Equal(t, "status = :cond_status_1", *check.ConditionExpression)
+	assert.Equal(t, "active", *check.ExpressionAttributeValues[":cond_status_1"].S)
+	assert.Equal(t, "users", *in.TransactItems[1].Put.TableName)
+	assert.Equal(t, "accounts", *in.TransactItems[2].Update.TableName)
+	assert.Equal(t, "accounts", *in.TransactItems[3].Delete.TableName)
+
+	_, err = table.TransactWriteItems().Add(accounts.ConditionCheck(PKRK("acct1", "extra"), status.Exists())).Build()
+	assert.EqualError(t, err, "TransactWriteItems accounts: table has no range key, but extra was given.")
+
+	db := &stubDB{transactWrite: func(in *dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error) {
+		return nil, awserr.New(dynamodb.ErrCodeTransactionCanceledException,
+			"Transaction cancelled, please refer cancellation reasons for specific reasons [None, None, ConditionalCheckFailed, TransactionConflict]", nil)
+	}}
+	out := table.TransactWriteItems().
+		PutItem(User{Email: "name@email.com", Password: "password"}).
+		Add(accounts.TransactDelete(PK("acct2")), accounts.ConditionCheck(PK("acct1"), status.Equals("active"))).
+		ConditionCheck(PKRK("name@email.com", "password"), table.loginCount.Exists()).
+		ExecuteWith(ctx, db)
+	assert.Error(t, out.Error())
+	assert.Equal(t, []CancellationReason{
+		{Index: 2, TableName: "accounts", Code: "ConditionalCheckFailed"},
+		{Index: 3, TableName: "users", Code: "TransactionConflict"},
+	}, out.CancellationReasons())
+	failed, ok := out.ConditionFailed()
+	assert.True(t, ok)
+	assert.Equal(t, 2, failed.Index)
+
+	db.transactWrite = func(in *dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error) {
+		return nil, awserr.New(dynamodb.ErrCodeTransactionConflictException, "conflict [x]", nil)
+	}
+	out = table.TransactWriteItems().PutItem(User{Email: "name@email.com", Password: "password"}).ExecuteWith(ctx, db)
+	assert.Nil(t, out.CancellationReasons())
+	_, ok = out.ConditionFailed()
+	assert.False(t, ok)
+}
+
+func TestTransactRetries(t *testing.T) {
+	retryBackoff = time.Millisecond
 	ctx := context.Background()
+	table := NewUserTable()
+	user := User{Email: "name@email.com", Password: "password"}
+	conflict := awserr.New(dynamodb.ErrCodeTransactionConflictException, "Transaction is ongoing for the item", nil)
+	canceled := func(codes string) error {
+		return awserr.New(dynamodb.ErrCodeTransactionCanceledException,
+			"Transaction cancelled, please refer cancellation reasons for specific reasons ["+codes+"]", nil)
+	}
+
+	var errs []error
+	var calls int
+	db := &stubDB{transactWrite: func(in *dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error) {
+		calls++
+		if len(errs) > 0 {
+			err := errs[0]
+			errs = errs[1:]
+			return nil, err
+		}
+		return &dynamodb.TransactWriteItemsOutput{}, nil
+	}}
+
+	/*Conflicts, reported either way, are retried*/
+	errs = []error{conflict, canceled("None, TransactionConflict")}
+	_, err := table.TransactWriteItems().PutItem(user).ConditionCheck(PKRK("a", "b"), table.name.Exists()).Execute(ctx, db)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+
+	calls, errs = 0, []error{conflict, conflict, conflict}
+	_, err = table.TransactWriteItems().PutItem(user).SetMaxRetries(1).Execute(ctx, db)
+	assert.EqualError(t, err, conflict.Error())
+	assert.Equal(t, 2, calls)
 
-	err := table.CreateTable().ExecuteWith(ctx, db)
-	defer table.DeleteTable().ExecuteWith(ctx, db)
+	/*Failed conditions and throttling are not*/
+	calls, errs = 0, []error{canceled("ConditionalCheckFailed, TransactionConflict")}
+	out, err := table.TransactWriteItems().PutItem(user).ConditionCheck(PKRK("a", "b"), table.name.Exists()).Execute(ctx, db)
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls)
+	reasons := out.CancellationReasons()
+	assert.Len(t, reasons, 2)
+	assert.True(t, reasons[0].Conditional())
+	assert.False(t, reasons[1].Conditional())
+
+	calls, errs = 0, []error{canceled("None, ProvisionedThroughputExceeded")}
+	out, err = table.TransactWriteItems().PutItem(user).ConditionCheck(PKRK("a", "b"), table.name.Exists()).Execute(ctx, db)
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, []CancellationReason{{Index: 1, TableName: "users", Code: "ProvisionedThroughputExceeded"}}, out.CancellationReasons())
 
+	var gets int
+	db.transactGet = func(in *dynamodb.TransactGetItemsInput) (*dynamodb.TransactGetItemsOutput, error) {
+		if gets++; gets < 3 {
+			return nil, canceled("TransactionConflict")
+		}
+		return &dynamodb.TransactGetItemsOutput{}, nil
+	}
+	_, err = table.TransactGetItems(PKRK("a", "b")).Execute(ctx, db)
 	assert.NoError(t, err)
+	assert.Equal(t, 3, gets)
+
+	gets = 0
+	_, err = table.TransactGetItems(PKRK("a", "b")).SetMaxRetries(0).Execute(ctx, db)
+	assert.Error(t, err)
+	assert.Equal(t, 1, gets)
+}
+
+func TestCancellationReasonsDecoded(t *testing.T) {
+	ctx := context.Background()
+	var request []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request, _ = ioutil.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"__type":"com.amazonaws.dynamodb.v20120810#TransactionCanceledException",` +
+			`"Message":"Transaction cancelled, please refer cancellation reasons for specific reasons [None, ConditionalCheckFailed]",` +
+			`"CancellationReasons":[{"Code":"None"},{"Code":"ConditionalCheckFailed","Message":"The conditional request failed",` +
+			`"Item":{"id":{"S":"acct1"},"status":{"S":"frozen"}}}]}`))
+	}))
+	defer server.Close()
+	db := NewLocalClient(server.URL, func(c *aws.Config) { c.MaxRetries = aws.Int(0) })
+
+	table := NewUserTable()
+	status := StringField("status")
+	accounts := DynamoTable{Name: "accounts", PartitionKey: StringField("id"), RangeKey: EmptyField()}
+	out, err := table.TransactWriteItems().
+		PutItem(User{Email: "name@email.com", Password: "password"}).
+		Add(accounts.ConditionCheck(PK("acct1"), status.Equals("active")).ReturnItemOnConditionFailure()).
+		Execute(ctx, db)
+	assert.Error(t, err)
+	assert.Contains(t, string(request), `"ReturnValuesOnConditionCheckFailure":"ALL_OLD"`)
+
+	failed, ok := out.ConditionFailed()
+	assert.True(t, ok)
+	assert.Equal(t, 1, failed.Index)
+	assert.Equal(t, "accounts", failed.TableName)
+	assert.Equal(t, "The conditional request failed", failed.Message)
+	got, _ := failed.Item.GetString("status")
+	assert.Equal(t, "frozen", got)
+	assert.Len(t, out.CancellationReasons(), 1)
+}
+
+func TestExpressions(t *testing.T) {
+	t.Parallel()
+	table := NewUserTable()
+	db := NewDB()
+	ctx := context.Background()
+
+	table.DynamoTable = disposableTable(t, db, table.DynamoTable)
+	defer dispose(db, table.DynamoTable)
 
 	expr := Or(
 		table.registrationDate.Equals(123),
@@ -591,8 +1019,10 @@ func TestExpressions(t *testing.T) {
 		SetScanForward(true).
 		SetFilterExpression(expr)
 
-	expectedFilter := "registrationDate = :filter_1 OR contains(lastName,:filter_2) OR (NOT registrationDate = :filter_3) OR (size(visits) <=:filter_4 AND size(firstName) >=:filter_5) OR registrationDate = :filter_6 OR registrationDate <= :filter_7 OR (registrationDate between :filter_8 and :filter_9) OR (registrationDate in (:filter_10,:filter_11))"
-	assert.Equal(t, expectedFilter, *q.Build().FilterExpression)
+	expectedFilter := "registrationDate = :filter_registrationDate_1 OR contains(lastName,:filter_lastName_2) OR (NOT registrationDate = :filter_registrationDate_3) OR (size(visits) <=:filter_visits_4 AND size(firstName) >=:filter_firstName_5) OR registrationDate = :filter_registrationDate_6 OR registrationDate <= :filter_registrationDate_7 OR (registrationDate between :filter_registrationDate_8 and :filter_registrationDate_9) OR (registrationDate in (:filter_registrationDate_10,:filter_registrationDate_11))"
+	built, err := q.Build()
+	assert.NoError(t, err)
+	assert.Equal(t, expectedFilter, *built.FilterExpression)
 
 	channel := make(chan *User)
 	errChan := q.ExecuteWith(ctx, db).StreamWithChannel(channel)
@@ -616,15 +1046,14 @@ func TestExpressions(t *testing.T) {
 }
 
 func TestDynamoQuery(t *testing.T) {
+	t.Parallel()
 
 	table := NewUserTable()
 	db := NewDB()
 	ctx := context.Background()
 
-	err := table.CreateTable().ExecuteWith(ctx, db)
-	defer table.DeleteTable().ExecuteWith(ctx, db)
-
-	assert.NoError(t, err)
+	table.DynamoTable = disposableTable(t, db, table.DynamoTable)
+	defer dispose(db, table.DynamoTable)
 
 	me := &User{Email: "name@email.com", Password: "password"}
 	items := []interface{}{me}
@@ -640,7 +1069,7 @@ func TestDynamoQuery(t *testing.T) {
 		ui = append(ui, &u)
 		return &u
 	}
-	err = w.ExecuteWith(ctx, db).Results(f)
+	err := w.ExecuteWith(ctx, db).Results(f)
 
 	assert.NoError(t, err)
 
@@ -685,15 +1114,14 @@ func TestDynamoQuery(t *testing.T) {
 }
 
 func TestDynamoStreamQuery(t *testing.T) {
+	t.Parallel()
 
 	table := NewUserTable()
 	db := NewDB()
 	ctx := context.Background()
 
-	err := table.CreateTable().ExecuteWith(ctx, db)
-	defer table.DeleteTable().ExecuteWith(ctx, db)
-
-	assert.NoError(t, err)
+	table.DynamoTable = disposableTable(t, db, table.DynamoTable)
+	defer dispose(db, table.DynamoTable)
 
 	me := &User{Email: "name@email.com", Password: "password"}
 	items := []interface{}{me}
@@ -709,7 +1137,7 @@ func TestDynamoStreamQuery(t *testing.T) {
 		ui = append(ui, &u)
 		return &u
 	}
-	err = w.ExecuteWith(ctx, db).Results(f)
+	err := w.ExecuteWith(ctx, db).Results(f)
 
 	assert.NoError(t, err)
 	assert.Empty(t, ui)
@@ -741,14 +1169,13 @@ func TestDynamoStreamQuery(t *testing.T) {
 }
 
 func TestDynamoQueryError(t *testing.T) {
+	t.Parallel()
 	table := NewUserTable()
 	db := NewDB()
 	ctx := context.Background()
 
-	err := table.CreateTable().ExecuteWith(ctx, db)
-	defer table.DeleteTable().ExecuteWith(ctx, db)
-
-	assert.NoError(t, err)
+	table.DynamoTable = disposableTable(t, db, table.DynamoTable)
+	defer dispose(db, table.DynamoTable)
 
 	me := &User{Email: "name@email.com", Password: "password"}
 	items := []interface{}{me}
@@ -770,6 +1197,7 @@ func TestDynamoQueryError(t *testing.T) {
 		StreamWithChannel(channel)
 
 	users := []interface{}{}
+	var err error
 
 SELECT:
 	for {
@@ -790,15 +1218,14 @@ SELECT:
 }
 
 func TestDynamoScan(t *testing.T) {
+	t.Parallel()
 
 	table := NewUserTable()
 	db := NewDB()
 	ctx := context.Background()
 
-	err := table.CreateTable().ExecuteWith(ctx, db)
-	defer table.DeleteTable().ExecuteWith(ctx, db)
-
-	assert.NoError(t, err)
+	table.DynamoTable = disposableTable(t, db, table.DynamoTable)
+	defer dispose(db, table.DynamoTable)
 
 	me := &User{Email: "name@email.com", Password: "password"}
 	items := []interface{}{me}
@@ -814,7 +1241,7 @@ func TestDynamoScan(t *testing.T) {
 		ui = append(ui, &u)
 		return &u
 	}
-	err = w.ExecuteWith(ctx, db).Results(f)
+	err := w.ExecuteWith(ctx, db).Results(f)
 
 	assert.NoError(t, err)
 
@@ -863,3 +1290,5686 @@ SELECT:
 	values = append(values, values...)
 	assert.True(t, len(values) >= limit)
 }
+
+func TestSinglePage(t *testing.T) {
+	table := NewUserTable()
+	ctx := context.Background()
+	calls := 0
+	db := newPagedStub(pagedItems(25, 10), &calls)
+
+	q := table.Query(table.emailField.Equals("name@email.com"), nil).SinglePage()
+	out := q.ExecuteWith(ctx, db)
+	var users []*User
+	err := out.Results(func() interface{} {
+		u := &User{}
+		users = append(users, u)
+		return u
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, 10, len(users))
+	assert.Equal(t, pageKey(1), out.LastEvaluatedKey())
+
+	calls = 0
+	s := table.Scan().SinglePage().WithLastEvaluatedKey(pageKey(2))
+	sout := s.ExecuteWith(ctx, db)
+	users = nil
+	err = sout.Results(func() interface{} {
+		u := &User{}
+		users = append(users, u)
+		return u
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, 5, len(users))
+	assert.Nil(t, sout.LastEvaluatedKey())
+}
+
+func TestResultsIntoMaps(t *testing.T) {
+	ctx := context.Background()
+	table := NewUserTable()
+	calls := 0
+	db := newPagedStub(pagedItems(5, 2), &calls)
+	want := func(i int) map[string]interface{} {
+		return map[string]interface{}{"email": "name@email.com", "password": "password" + strconv.Itoa(i)}
+	}
+
+	for _, workers := range []int{1, 4} {
+		channel := make(chan map[string]interface{})
+		errChan := table.Query(table.emailField.Equals("name@email.com"), nil).SetUnmarshalWorkers(workers).ExecuteWith(ctx, db).StreamWithChannel(channel)
+		var streamed []map[string]interface{}
+		for m := range channel {
+			streamed = append(streamed, m)
+		}
+		assert.NoError(t, <-errChan)
+		assert.Equal(t, []map[string]interface{}{want(0), want(1), want(2), want(3), want(4)}, streamed)
+
+		pchannel := make(chan *map[string]interface{})
+		errChan = table.Scan().SetUnmarshalWorkers(workers).ExecuteWith(ctx, db).StreamWithChannel(pchannel)
+		streamed = nil
+		for m := range pchannel {
+			streamed = append(streamed, *m)
+		}
+		assert.NoError(t, <-errChan)
+		assert.Len(t, streamed, 5)
+	}
+
+	maps, err := table.Query(table.emailField.Equals("name@email.com"), nil).ExecuteWith(ctx, db).ResultsMaps()
+	assert.NoError(t, err)
+	assert.Equal(t, []map[string]interface{}{want(0), want(1), want(2), want(3), want(4)}, maps)
+	maps, err = table.Scan().SetLimit(3).ExecuteWith(ctx, db).ResultsMaps()
+	assert.NoError(t, err)
+	assert.Equal(t, []map[string]interface{}{want(0), want(1), want(2)}, maps)
+
+	/*Maps are filled in place, without a pointer*/
+	var items []interface{}
+	err = table.Scan().ExecuteWith(ctx, db).Results(func() interface{} {
+		items = append(items, map[string]interface{}{"extra": true})
+		return items[len(items)-1]
+	})
+	assert.NoError(t, err)
+	assert.Len(t, items, 5)
+	assert.Equal(t, map[string]interface{}{"extra": true, "email": "name@email.com", "password": "password4"}, items[4])
+
+	m := map[string]interface{}{}
+	db.getItem = func(*dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+		return &dynamodb.GetItemOutput{Item: pagedItems(1, 1)[0][0]}, nil
+	}
+	assert.NoError(t, table.GetItem(PKRK("name@email.com", "password0")).ExecuteWith(ctx, db).Result(m))
+	assert.Equal(t, want(0), m)
+}
+
+func TestResultTargets(t *testing.T) {
+	ctx := context.Background()
+	table := NewUserTable()
+	calls := 0
+	db := newPagedStub(pagedItems(2, 2), &calls)
+	db.getItem = func(*dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+		return &dynamodb.GetItemOutput{Item: pagedItems(1, 1)[0][0]}, nil
+	}
+	var nilUser *User
+	var nilMap map[string]interface{}
+	user := &User{}
+
+	for _, c := range []struct {
+		name   string
+		target func() interface{}
+		err    string
+	}{
+		{"value", func() interface{} { return User{} }, "domino: target must be a non-nil pointer, got domino.User"},
+		{"nil pointer", func() interface{} { return nilUser }, "domino: target must be a non-nil pointer, got nil *domino.User"},
+		{"nil interface", func() interface{} { return nil }, "domino: target must be a non-nil pointer, got nil"},
+		{"nil map", func() interface{} { return nilMap }, "domino: target must be a non-nil pointer, got nil map[string]interface {}"},
+		{"string", func() interface{} { return "user" }, "domino: target must be a non-nil pointer, got string"},
+		{"pointer", func() interface{} { return &User{} }, ""},
+		{"double pointer", func() interface{} { return &user }, ""},
+		{"pointer to nil pointer", func() interface{} { var u *User; return &u }, ""},
+		{"map", func() interface{} { return map[string]interface{}{} }, ""},
+	} {
+		check := func(err error) {
+			if c.err == "" {
+				assert.NoError(t, err, c.name)
+			} else {
+				assert.EqualError(t, err, c.err, c.name)
+			}
+		}
+		check(table.Query(table.emailField.Equals("name@email.com"), nil).ExecuteWith(ctx, db).Results(c.target))
+		check(table.Scan().ExecuteWith(ctx, db).Results(c.target))
+		if c.name != "nil interface" {
+			/*A nil target discards a single result*/
+			check(table.GetItem(PKRK("name@email.com", "password0")).ExecuteWith(ctx, db).Result(c.target()))
+		}
+	}
+	assert.Equal(t, "password0", user.Password)
+
+	/*Targets are checked even when there's nothing to decode*/
+	empty := &stubDB{getItem: func(*dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) { return &dynamodb.GetItemOutput{}, nil }}
+	err := table.GetItem(PKRK("name@email.com", "password0")).ExecuteWith(ctx, empty).Result(User{})
+	assert.EqualError(t, err, "domino: target must be a non-nil pointer, got domino.User")
+	assert.NoError(t, table.GetItem(PKRK("name@email.com", "password0")).ExecuteWith(ctx, empty).Result(nil))
+
+	for _, channel := range []interface{}{nil, User{}, make(<-chan *User), (chan *User)(nil)} {
+		errChan := table.Scan().ExecuteWith(ctx, db).StreamWithChannel(channel)
+		assert.EqualError(t, <-errChan, fmt.Sprintf("domino: channel must be a non-nil channel items can be sent on, got %T", channel))
+		_, open := <-errChan
+		assert.False(t, open)
+		errChan = table.Query(table.emailField.Equals("name@email.com"), nil).ExecuteWith(ctx, db).StreamWithChannel(channel)
+		assert.Error(t, <-errChan)
+	}
+
+	channel := make(chan **User)
+	errChan := table.Scan().ExecuteWith(ctx, db).StreamWithChannel(channel)
+	var streamed []string
+	for u := range channel {
+		streamed = append(streamed, (*u).Password)
+	}
+	assert.NoError(t, <-errChan)
+	assert.Equal(t, []string{"password0", "password1"}, streamed)
+}
+
+func TestItemErrors(t *testing.T) {
+	ctx := context.Background()
+	table := NewUserTable()
+	pages := pagedItems(6, 2)
+	pages[1][1]["loginCount"] = &dynamodb.AttributeValue{S: aws.String("many")}
+	calls := 0
+	db := newPagedStub(pages, &calls)
+	want := `item {email: "name@email.com", password: "password3"} (page 1): `
+
+	check := func(err error, how string) {
+		assert.Error(t, err, how)
+		if err == nil {
+			return
+		}
+		assert.True(t, strings.HasPrefix(err.Error(), want), "%s: %v", how, err)
+		if itemErr, ok := err.(*ItemError); assert.True(t, ok, how) {
+			assert.Equal(t, 1, itemErr.Page, how)
+			password, _ := itemErr.Key.GetString("password")
+			assert.Equal(t, "password3", password, how)
+			assert.Len(t, itemErr.Key, 2, how)
+			assert.NotNil(t, itemErr.Unwrap(), how)
+		}
+	}
+
+	check(table.Query(table.emailField.Equals("name@email.com"), nil).ExecuteWith(ctx, db).Results(func() interface{} { return &User{} }), "query results")
+	check(table.Scan().ExecuteWith(ctx, db).Results(func() interface{} { return &User{} }), "scan results")
+	for _, workers := range []int{1, 4} {
+		channel := make(chan *User)
+		errChan := table.Query(table.emailField.Equals("name@email.com"), nil).SetUnmarshalWorkers(workers).ExecuteWith(ctx, db).StreamWithChannel(channel)
+		for range channel {
+		}
+		check(<-errChan, fmt.Sprintf("query stream, %d workers", workers))
+		channel = make(chan *User)
+		errChan = table.Scan().SetUnmarshalWorkers(workers).ExecuteWith(ctx, db).StreamWithChannel(channel)
+		for range channel {
+		}
+		check(<-errChan, fmt.Sprintf("scan stream, %d workers", workers))
+	}
+
+	it := table.Scan().ExecuteWith(ctx, db).Iterator()
+	var err error
+	for it.Next() && err == nil {
+		err = it.Item(&User{})
+	}
+	check(err, "iterator")
+
+	/*Batches are numbered like pages*/
+	db.batchGetItem = func(in *dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error) {
+		var items []map[string]*dynamodb.AttributeValue
+		for _, key := range in.RequestItems["users"].Keys {
+			item := DynamoDBValue{"email": key["email"], "password": key["password"], "loginCount": {N: aws.String("1")}}
+			if *key["password"].S == "password3" {
+				item["loginCount"] = &dynamodb.AttributeValue{S: aws.String("many")}
+			}
+			items = append(items, item)
+		}
+		return &dynamodb.BatchGetItemOutput{Responses: map[string][]map[string]*dynamodb.AttributeValue{"users": items}}, nil
+	}
+	var keys []KeyValue
+	for i := 0; i < 101; i++ {
+		if i != 3 {
+			keys = append(keys, PKRK("name@email.com", "password"+strconv.Itoa(i)))
+		}
+	}
+	keys = append(keys, PKRK("name@email.com", "password3"))
+	err = table.BatchGetItem(keys...).ExecuteWith(ctx, db).Results(func() interface{} { return &User{} })
+	assert.Error(t, err)
+	if itemErr, ok := err.(*ItemError); assert.True(t, ok) {
+		assert.Equal(t, 1, itemErr.Page)
+		assert.Equal(t, DynamoDBValue{"email": {S: aws.String("name@email.com")}, "password": {S: aws.String("password3")}}, itemErr.Key)
+	}
+
+	/*Bad targets are reported as is*/
+	err = table.Scan().ExecuteWith(ctx, db).Results(func() interface{} { return User{} })
+	_, ok := err.(*ItemError)
+	assert.False(t, ok)
+}
+
+func TestStreamLastEvaluatedKey(t *testing.T) {
+	table := NewUserTable()
+	calls := 0
+	db := newPagedStub(pagedItems(25, 10), &calls)
+	key := func(i int) DynamoDBValue {
+		av, _ := dynamodbattribute.MarshalMap(User{Email: "name@email.com", Password: "password" + strconv.Itoa(i)})
+		return av
+	}
+
+	// Stopped by the limit part way through the second page
+	out := table.Query(table.emailField.Equals("name@email.com"), nil).SetLimit(15).ExecuteWith(context.Background(), db)
+	channel := make(chan *User)
+	errChan := out.StreamWithChannel(channel)
+	users := []*User{}
+	for u := range channel {
+		users = append(users, u)
+	}
+	assert.NoError(t, <-errChan)
+	assert.Equal(t, 15, len(users))
+	assert.Equal(t, key(14), out.LastEvaluatedKey())
+
+	// Stopped by the limit on a page boundary
+	sout := table.Scan().SetLimit(10).ExecuteWith(context.Background(), db)
+	schannel := make(chan *User)
+	errChan = sout.StreamWithChannel(schannel)
+	for range schannel {
+	}
+	assert.NoError(t, <-errChan)
+	assert.Equal(t, pageKey(1), sout.LastEvaluatedKey())
+
+	// Stopped by context cancellation
+	ctx, cancel := context.WithCancel(context.Background())
+	sout = table.Scan().ExecuteWith(ctx, db)
+	schannel = make(chan *User)
+	errChan = sout.StreamWithChannel(schannel)
+	for i := 0; i < 3; i++ {
+		<-schannel
+	}
+	cancel()
+	for range errChan {
+	}
+	assert.Equal(t, key(2), sout.LastEvaluatedKey())
+}
+
+func TestOnPage(t *testing.T) {
+	table := NewUserTable()
+	calls := 0
+	db := newPagedStub(pagedItems(25, 10), &calls)
+
+	var pages []int
+	var sizes []int
+	var keys []DynamoDBValue
+	handler := func(pageIndex int, items []DynamoDBValue, lastKey DynamoDBValue) {
+		pages = append(pages, pageIndex)
+		sizes = append(sizes, len(items))
+		keys = append(keys, lastKey)
+	}
+
+	count := 0
+	err := table.Scan().OnPage(handler).ExecuteWith(context.Background(), db).Results(func() interface{} {
+		count++
+		return &User{}
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 25, count)
+	assert.Equal(t, []int{0, 1, 2}, pages)
+	assert.Equal(t, []int{10, 10, 5}, sizes)
+	assert.Equal(t, []DynamoDBValue{pageKey(1), pageKey(2), nil}, keys)
+
+	pages = nil
+	channel := make(chan User)
+	table.Query(table.emailField.Equals("name@email.com"), nil).OnPage(handler).ExecuteWith(context.Background(), db).StreamWithChannel(channel)
+	for range channel {
+	}
+	assert.Equal(t, []int{0, 1, 2}, pages)
+}
+
+func TestCounts(t *testing.T) {
+	table := NewUserTable()
+	calls := 0
+	db := newPagedStub(pagedItems(25, 10), &calls)
+
+	out := table.Scan().ExecuteWith(context.Background(), db)
+	err := out.Results(func() interface{} { return &User{} })
+	assert.NoError(t, err)
+	assert.Equal(t, int64(25), out.Count())
+	assert.Equal(t, int64(50), out.ScannedCount())
+
+	// Counts reflect whole pages fetched, not items consumed
+	qout := table.Query(table.emailField.Equals("name@email.com"), nil).SetLimit(5).ExecuteWith(context.Background(), db)
+	err = qout.Results(func() interface{} { return &User{} })
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), qout.Count())
+	assert.Equal(t, int64(20), qout.ScannedCount())
+}
+
+/*newLimitStub serves items in order, honoring the request Limit, and records each Limit received*/
+func newLimitStub(items []map[string]*dynamodb.AttributeValue, limits *[]int64) *stubDB {
+	page := func(start DynamoDBValue, limit *int64) (page []map[string]*dynamodb.AttributeValue, last DynamoDBValue) {
+		*limits = append(*limits, aws.Int64Value(limit))
+		i := pageIndex(start)
+		end := len(items)
+		if limit != nil && i+int(*limit) < end {
+			end = i + int(*limit)
+			last = pageKey(end)
+		}
+		return items[i:end], last
+	}
+	return &stubDB{
+		query: func(in *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+			items, last := page(in.ExclusiveStartKey, in.Limit)
+			return &dynamodb.QueryOutput{Items: items, LastEvaluatedKey: last}, nil
+		},
+		scan: func(in *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+			items, last := page(in.ExclusiveStartKey, in.Limit)
+			return &dynamodb.ScanOutput{Items: items, LastEvaluatedKey: last}, nil
+		},
+	}
+}
+
+func TestLimitConstrainsPageSize(t *testing.T) {
+	table := NewUserTable()
+	items := pagedItems(100, 100)[0]
+
+	var limits []int64
+	db := newLimitStub(items, &limits)
+	count := 0
+	next := func() interface{} {
+		count++
+		return &User{}
+	}
+
+	err := table.Query(table.emailField.Equals("name@email.com"), nil).
+		SetLimit(25).
+		SetPageSize(10).
+		ExecuteWith(context.Background(), db).
+		Results(next)
+	assert.NoError(t, err)
+	assert.Equal(t, 25, count)
+	assert.Equal(t, []int64{10, 10, 5}, limits)
+
+	limits, count = nil, 0
+	err = table.Scan().SetPageSize(100).SetLimit(10).ExecuteWith(context.Background(), db).Results(next)
+	assert.NoError(t, err)
+	assert.Equal(t, 10, count)
+	assert.Equal(t, []int64{10}, limits)
+
+	limits = nil
+	channel := make(chan *User)
+	table.Scan().SetLimit(7).SetPageSize(3).ExecuteWith(context.Background(), db).StreamWithChannel(channel)
+	users := []*User{}
+	for u := range channel {
+		users = append(users, u)
+	}
+	assert.Equal(t, 7, len(users))
+	assert.Equal(t, []int64{3, 3, 1}, limits)
+}
+
+func TestClone(t *testing.T) {
+	table := NewUserTable()
+	pages := pagedItems(30, 10)
+	page := func(start DynamoDBValue) (items []map[string]*dynamodb.AttributeValue, last DynamoDBValue) {
+		i := pageIndex(start)
+		if i+1 < len(pages) {
+			last = pageKey(i + 1)
+		}
+		return pages[i], last
+	}
+	db := &stubDB{
+		query: func(in *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+			items, last := page(in.ExclusiveStartKey)
+			return &dynamodb.QueryOutput{Items: items, LastEvaluatedKey: last}, nil
+		},
+		scan: func(in *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+			items, last := page(in.ExclusiveStartKey)
+			return &dynamodb.ScanOutput{Items: items, LastEvaluatedKey: last}, nil
+		},
+	}
+
+	query := table.Query(table.emailField.Equals("name@email.com"), nil).SetFilterExpression(table.loginCount.Equals(0))
+	scan := table.Scan().SetFilterExpression(table.loginCount.Equals(0))
+	update := table.UpdateItem(KeyValue{"name@email.com", "password"}).SetUpdateExpression(table.loginCount.Increment(1))
+	write := table.BatchWriteItem().PutItems(User{Email: "name@email.com", Password: "password"})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			count := 0
+			err := query.Clone().
+				WithLastEvaluatedKey(pageKey(i)).
+				SetFilterExpression(table.registrationDate.Equals(i)).
+				ExecuteWith(context.Background(), db).
+				Results(func() interface{} {
+					count++
+					return &User{}
+				})
+			assert.NoError(t, err)
+			assert.Equal(t, 10*(3-i), count)
+
+			count = 0
+			err = scan.Clone().SinglePage().WithLastEvaluatedKey(pageKey(i)).ExecuteWith(context.Background(), db).Results(func() interface{} {
+				count++
+				return &User{}
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, 10, count)
+
+			u, err := update.Clone().SetConditionExpression(table.registrationDate.Equals(i)).Build()
+			assert.NoError(t, err)
+			assert.NotNil(t, u.ConditionExpression)
+
+			b, err := write.Clone().PutItems(User{Email: "name@email.com", Password: strconv.Itoa(i)}).Build()
+			assert.NoError(t, err)
+			assert.Equal(t, 2, len(b))
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Nil(t, query.ExclusiveStartKey)
+	qb, err := query.Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "loginCount = :filter_loginCount_1", *qb.FilterExpression)
+	u, err := update.Build()
+	assert.NoError(t, err)
+	assert.Nil(t, u.ConditionExpression)
+	b, err := write.Build()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(b))
+}
+
+func TestCloneKeepsEmptyValues(t *testing.T) {
+	v := DynamoDBValue{
+		"list":   {L: []*dynamodb.AttributeValue{}},
+		"binary": {B: []byte{}},
+		"map":    {M: map[string]*dynamodb.AttributeValue{}},
+	}
+	c := cloneValue(v)
+	assert.Equal(t, v, c)
+	assert.NotNil(t, c["list"].L)
+	assert.NotNil(t, c["binary"].B)
+	assert.NotNil(t, c["map"].M)
+
+	var sent *dynamodb.UpdateItemInput
+	db := &stubDB{
+		updateItem: func(in *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+			sent = in
+			return &dynamodb.UpdateItemOutput{}, nil
+		},
+	}
+	table := NewUserTable().DynamoTable.BeforeUpdate(func(*dynamodb.UpdateItemInput) error { return nil })
+	err := table.UpdateItem(KeyValue{"name@email.com", "password"}).
+		SetUpdateExpression(ListField("tags").OrEmpty().AppendAll("x")).
+		ExecuteWith(context.Background(), db).
+		Result(nil)
+	assert.NoError(t, err)
+	for name, av := range sent.ExpressionAttributeValues {
+		assert.NotEqual(t, dynamodb.AttributeValue{}, *av, name)
+	}
+}
+
+func TestBatchBuildIdempotent(t *testing.T) {
+	table := NewUserTable()
+	gets, writes := 0, 0
+	db := &stubDB{
+		batchGetItem: func(in *dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error) {
+			gets++
+			return &dynamodb.BatchGetItemOutput{}, nil
+		},
+		batchWriteItem: func(in *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+			writes++
+			return &dynamodb.BatchWriteItemOutput{}, nil
+		},
+	}
+
+	keys := []KeyValue{}
+	items := []interface{}{}
+	for i := 0; i < 150; i++ {
+		keys = append(keys, KeyValue{"name@email.com", strconv.Itoa(i)})
+		items = append(items, User{Email: "name@email.com", Password: strconv.Itoa(i)})
+	}
+
+	g := table.BatchGetItem(keys...)
+	b, err := g.Build()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(b))
+	b, err = g.Build()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(b))
+
+	assert.NoError(t, g.ExecuteWith(context.Background(), db).Error())
+	assert.NoError(t, g.ExecuteWith(context.Background(), db).Error())
+	assert.Equal(t, 4, gets)
+
+	w := table.BatchWriteItem().PutItems(items...).DeleteItems(keys[0])
+	wb, err := w.Build()
+	assert.NoError(t, err)
+	assert.Equal(t, 7, len(wb))
+	wb, err = w.Build()
+	assert.NoError(t, err)
+	assert.Equal(t, 7, len(wb))
+
+	assert.NoError(t, w.ExecuteWith(context.Background(), db).Error())
+	assert.NoError(t, w.ExecuteWith(context.Background(), db).Error())
+	assert.Equal(t, 14, writes)
+}
+
+func TestUpdateExpressionOrder(t *testing.T) {
+	table := NewUserTable()
+
+	for i := 0; i < 10; i++ {
+		u, err := table.
+			UpdateItem(KeyValue{"name@email.com", "password"}).
+			SetUpdateExpression(
+				table.loginCount.Increment(1),
+				table.lastLoginDate.SetField(1, false),
+				table.preferences.Remove("update_email"),
+				table.preferences.Set("test", "value"),
+				table.locales.AddString("us"),
+				table.degrees.DeleteFloat(1),
+			).
+			Build()
+		assert.NoError(t, err)
+		assert.Equal(t, "SET lastLoginDate = :update_lastLoginDate_101, preferences.test = :update_preferences_test_103 REMOVE preferences.update_email ADD loginCount :update_loginCount_100, locales :update_locales_104 DELETE degrees :update_degrees_105", *u.UpdateExpression)
+	}
+}
+
+func TestAddUpdateExpression(t *testing.T) {
+	table := NewUserTable()
+
+	u := table.
+		UpdateItem(KeyValue{"name@email.com", "password"}).
+		SetUpdateExpression(table.lastLoginDate.SetField(1, false))
+	u.AddUpdateExpression(table.loginCount.Increment(1))
+	u.SetUpdateExpression(table.registrationDate.SetField(2, true))
+
+	b, err := u.Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "SET lastLoginDate = :update_lastLoginDate_100, registrationDate = if_not_exists(registrationDate,:update_registrationDate_102) ADD loginCount :update_loginCount_101", *b.UpdateExpression)
+	assert.Equal(t, DynamoDBValue{
+		":update_lastLoginDate_100":    &dynamodb.AttributeValue{N: aws.String("1")},
+		":update_loginCount_101":       &dynamodb.AttributeValue{N: aws.String("1")},
+		":update_registrationDate_102": &dynamodb.AttributeValue{N: aws.String("2")},
+	}, DynamoDBValue(b.ExpressionAttributeValues))
+}
+
+func TestCombinedFilterExpressions(t *testing.T) {
+	table := NewUserTable()
+
+	q := table.
+		Query(table.emailField.Equals("name@email.com"), nil).
+		SetFilterExpression(table.loginCount.GreaterThan(5)).
+		SetFilterExpression(Or(table.lastName.Equals("smith"), table.lastName.Equals("jones")))
+	b, err := q.Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "loginCount > :filter_loginCount_1 AND (lastName = :filter_lastName_2 OR lastName = :filter_lastName_3)", *b.FilterExpression)
+	assert.Equal(t, "email = :cond_email_0", *b.KeyConditionExpression)
+	assert.Equal(t, DynamoDBValue{
+		":cond_email_0":        &dynamodb.AttributeValue{S: aws.String("name@email.com")},
+		":filter_loginCount_1": &dynamodb.AttributeValue{N: aws.String("5")},
+		":filter_lastName_2":   &dynamodb.AttributeValue{S: aws.String("smith")},
+		":filter_lastName_3":   &dynamodb.AttributeValue{S: aws.String("jones")},
+	}, DynamoDBValue(b.ExpressionAttributeValues))
+
+	s, err := table.Scan().
+		SetFilterExpression(Not(table.loginCount.Equals(1))).
+		SetFilterExpression(table.registrationDate.Exists()).
+		Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "(NOT loginCount = :filter_loginCount_1) AND attribute_exists(registrationDate)", *s.FilterExpression)
+	assert.Equal(t, DynamoDBValue{
+		":filter_loginCount_1": &dynamodb.AttributeValue{N: aws.String("1")},
+	}, DynamoDBValue(s.ExpressionAttributeValues))
+}
+
+/*namedEquals is a condition on an attribute referenced through a name placeholder*/
+type namedEquals struct {
+	name  string
+	value interface{}
+}
+
+func (e namedEquals) construct(prefix string, counter uint, topLevel bool) (string, map[string]*string, map[string]interface{}, uint) {
+	n := generateNamePlaceholder(prefix, counter)
+	v := generatePlaceholder(prefix, "", counter)
+	return n + " = " + v, map[string]*string{n: aws.String(e.name)}, map[string]interface{}{v: e.value}, counter + 1
+}
+
+func (e namedEquals) ToSDKBuilder() (expression.ConditionBuilder, error) {
+	return expression.Name(e.name).Equal(expression.Value(e.value)), nil
+}
+
+func TestPlaceholderNames(t *testing.T) {
+	table := NewUserTable()
+
+	/*The same field in the key condition and the filter*/
+	q, err := table.Query(table.emailField.Equals("name@email.com"), nil).
+		SetFilterExpression(And(table.emailField.NotEquals("other@email.com"), table.emailField.In("a", "b"))).
+		Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "email = :cond_email_0", *q.KeyConditionExpression)
+	assert.Equal(t, "email <> :filter_email_1 AND (email in (:filter_email_2,:filter_email_3))", *q.FilterExpression)
+	assert.Len(t, q.ExpressionAttributeValues, 4)
+
+	/*The same field in the update and the condition*/
+	u, err := table.UpdateItem(KeyValue{"name@email.com", "password"}).
+		SetUpdateExpression(table.loginCount.SetField(2, false)).
+		SetConditionExpression(table.loginCount.Equals(1)).
+		Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "SET loginCount = :update_loginCount_100", *u.UpdateExpression)
+	assert.Equal(t, "loginCount = :cond_loginCount_1", *u.ConditionExpression)
+	assert.Len(t, u.ExpressionAttributeValues, 2)
+
+	/*Field names are sanitized and truncated, the counter stays last*/
+	long := NumericField(strings.Repeat("x", 100) + "-count")
+	assert.Equal(t, ":cond_"+strings.Repeat("x", 64)+"_0", generatePlaceholder("cond", long.name, 0))
+	registered := StringField("registration-date")
+	assert.Equal(t, "registration-date = :cond_registration_date_0", registered.Equals("a").String())
+}
+
+func TestExpiry(t *testing.T) {
+	table := NewUserTable()
+	expiryClock = func() time.Time { return time.Unix(1500000000, 999) }
+	defer func() { expiryClock = time.Now }()
+	expires := NumericField("expiresAt")
+
+	p, err := table.PutItem(User{Email: "name@email.com", Password: "password"}).
+		SetExpiry(expires, time.Hour).
+		Build()
+	assert.NoError(t, err)
+	assert.Equal(t, &dynamodb.AttributeValue{N: aws.String("1500003600")}, p.Item["expiresAt"])
+
+	u, err := table.UpdateItem(KeyValue{"name@email.com", "password"}).
+		SetUpdateExpression(expires.ExpireIn(90 * time.Second)).
+		Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "SET #update_100 = :update_expiresAt_101", *u.UpdateExpression)
+	assert.Equal(t, "expiresAt", *u.ExpressionAttributeNames["#update_100"])
+	assert.Equal(t, &dynamodb.AttributeValue{N: aws.String("1500000090")}, u.ExpressionAttributeValues[":update_expiresAt_101"])
+
+	q, err := table.Query(table.emailField.Equals("name@email.com"), nil).
+		SetFilterExpression(NotExpired(expires, time.Unix(1600000000, 0))).
+		Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "(attribute_not_exists(#filter_1) OR #filter_1 > :filter_expiresAt_2)", *q.FilterExpression)
+	assert.Equal(t, "expiresAt", *q.ExpressionAttributeNames["#filter_1"])
+	assert.Equal(t, &dynamodb.AttributeValue{N: aws.String("1600000000")}, q.ExpressionAttributeValues[":filter_expiresAt_2"])
+
+	/*ttl is a reserved word, so it's only ever referenced through a name placeholder*/
+	ttl := NumericField("ttl")
+	u, err = table.UpdateItem(KeyValue{"name@email.com", "password"}).
+		SetUpdateExpression(ttl.ExpireIn(time.Minute)).
+		Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "SET #update_100 = :update_ttl_101", *u.UpdateExpression)
+	assert.Equal(t, "ttl", *u.ExpressionAttributeNames["#update_100"])
+	q, err = table.Query(table.emailField.Equals("name@email.com"), nil).
+		SetFilterExpression(table.loginCount.Equals(1)).
+		SetFilterExpression(NotExpired(ttl, time.Unix(1600000000, 0))).
+		Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "loginCount = :filter_loginCount_1 AND (attribute_not_exists(#filter_2) OR #filter_2 > :filter_ttl_3)", *q.FilterExpression)
+	assert.Equal(t, "ttl", *q.ExpressionAttributeNames["#filter_2"])
+}
+
+func TestExecute(t *testing.T) {
+	ctx := context.Background()
+	table := NewUserTable()
+	key := KeyValue{"name@email.com", "password"}
+	conditionFailed := awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "failed", nil)
+	failure := errors.New("failed")
+	db := &stubDB{
+		getItem: func(*dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+			return &dynamodb.GetItemOutput{}, nil
+		},
+		putItem: func(*dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+			return nil, conditionFailed
+		},
+		updateItem: func(*dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+			return nil, conditionFailed
+		},
+		deleteItem: func(*dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
+			return &dynamodb.DeleteItemOutput{}, nil
+		},
+		batchGetItem: func(*dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error) {
+			return nil, failure
+		},
+		batchWriteItem: func(*dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+			return nil, failure
+		},
+		transactWrite: func(*dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error) {
+			return nil, failure
+		},
+	}
+
+	/*Errors are returned, and still recorded on the output*/
+	get, err := table.GetItem(key).Execute(ctx, db)
+	assert.NoError(t, err)
+	assert.False(t, get.Found())
+
+	put, err := table.PutItem(User{Email: "name@email.com", Password: "password"}).Execute(ctx, db)
+	assert.Equal(t, conditionFailed, err)
+	assert.True(t, put.ConditionalCheckFailed())
+
+	update, err := table.UpdateItem(key).SetUpdateExpression(table.loginCount.Increment(1)).Execute(ctx, db)
+	assert.Equal(t, conditionFailed, err)
+	assert.True(t, update.ConditionalCheckFailed())
+
+	_, err = table.DeleteItem(key).Execute(ctx, db)
+	assert.NoError(t, err)
+	_, err = table.DeleteItem(KeyValue{PartitionKey: "name@email.com"}).Execute(ctx, db)
+	assert.EqualError(t, err, "DeleteItem users: missing range key password.")
+
+	batchGet, err := table.BatchGetItem(key).Execute(ctx, db)
+	assert.Equal(t, failure, err)
+	assert.Equal(t, failure, batchGet.Error())
+
+	_, err = table.BatchWriteItem().PutItems(User{Email: "name@email.com", Password: "password"}).Execute(ctx, db)
+	assert.Equal(t, failure, err)
+
+	_, err = table.TransactWriteItems().PutItem(User{Email: "name@email.com", Password: "password"}).Execute(ctx, db)
+	assert.Equal(t, failure, err)
+
+	_, err = table.TransactGetItems(KeyValue{PartitionKey: "name@email.com"}).Execute(ctx, db)
+	assert.Error(t, err)
+
+	/*Queries and scans fetch lazily, so only build errors are returned up front*/
+	badStart := func(q *QueryInput) { q.WithStartKeyValue(KeyValue{PartitionKey: "name@email.com"}, nil) }
+	query := table.Query(table.emailField.Equals("name@email.com"), nil)
+	badStart(query)
+	_, err = query.Execute(ctx, db)
+	assert.Error(t, err)
+	_, err = table.QuerySharded("name@email.com", nil, ShardedKey(table.emailField, 2)).Configure(badStart).Execute(ctx, db)
+	assert.Error(t, err)
+	_, err = table.ScanIndex(GlobalSecondaryIndex{Name: "missing-index"}).Execute(ctx, db)
+	assert.EqualError(t, err, "ScanIndex users: table has no index missing-index.")
+}
+
+func TestLocalClient(t *testing.T) {
+	ctx := context.Background()
+	var target string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		target = r.Header.Get("X-Amz-Target")
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		w.Write([]byte(`{"TableNames":[]}`))
+	}))
+	defer server.Close()
+
+	db := NewLocalClient(server.URL, func(c *aws.Config) { c.MaxRetries = aws.Int(0) })
+	client, ok := db.(*dynamodb.DynamoDB)
+	assert.True(t, ok)
+	assert.Equal(t, server.URL, client.Endpoint)
+	assert.Equal(t, "us-west-2", aws.StringValue(client.Config.Region))
+	assert.NoError(t, HealthCheck(ctx, db))
+	assert.Equal(t, "DynamoDB_20120810.ListTables", target)
+
+	server.Close()
+	assert.Error(t, HealthCheck(ctx, db))
+
+	assert.EqualError(t, HealthCheck(ctx, &stubDB{}), "HealthCheck: *domino.stubDB can't list tables.")
+}
+
+func TestMergedConditionExpressions(t *testing.T) {
+	table := NewUserTable()
+
+	p, err := table.PutItem(User{Email: "name@email.com", Password: "password"}).
+		SetConditionExpression(namedEquals{"name", "bob"}).
+		SetConditionExpression(table.loginCount.Equals(1)).
+		Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "#cond_1 = :cond_1 AND loginCount = :cond_loginCount_2", *p.ConditionExpression)
+	assert.Equal(t, map[string]*string{"#cond_1": aws.String("name")}, p.ExpressionAttributeNames)
+	assert.Equal(t, DynamoDBValue{
+		":cond_1":            &dynamodb.AttributeValue{S: aws.String("bob")},
+		":cond_loginCount_2": &dynamodb.AttributeValue{N: aws.String("1")},
+	}, DynamoDBValue(p.ExpressionAttributeValues))
+
+	d, err := table.DeleteItem(KeyValue{"name@email.com", "password"}).
+		SetConditionExpression(table.loginCount.Equals(1)).
+		SetConditionExpression(namedEquals{"name", "bob"}).
+		Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "loginCount = :cond_loginCount_1 AND #cond_2 = :cond_2", *d.ConditionExpression)
+	assert.Equal(t, map[string]*string{"#cond_2": aws.String("name")}, d.ExpressionAttributeNames)
+
+	u, err := table.UpdateItem(KeyValue{"name@email.com", "password"}).
+		SetConditionExpression(namedEquals{"name", "bob"}).
+		SetUpdateExpression(table.loginCount.Increment(1)).
+		SetConditionExpression(table.registrationDate.Exists()).
+		Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "#cond_1 = :cond_1 AND attribute_exists(registrationDate)", *u.ConditionExpression)
+	assert.Equal(t, "ADD loginCount :update_loginCount_100", *u.UpdateExpression)
+	assert.Equal(t, map[string]*string{"#cond_1": aws.String("name")}, u.ExpressionAttributeNames)
+	assert.Equal(t, DynamoDBValue{
+		":cond_1":                &dynamodb.AttributeValue{S: aws.String("bob")},
+		":update_loginCount_100": &dynamodb.AttributeValue{N: aws.String("1")},
+	}, DynamoDBValue(u.ExpressionAttributeValues))
+}
+
+func TestMapPathExpression(t *testing.T) {
+	table := NewUserTable()
+
+	u, err := table.UpdateItem(KeyValue{"name@email.com", "password"}).
+		SetUpdateExpression(
+			table.preferences.Path("a.b", "size").Set(1),
+			table.preferences.Path("a.b", "color").Remove(),
+		).
+		Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "SET #update_100.#update_101.#update_102 = :update_preferences_a_b_size_103 REMOVE #update_104.#update_105.#update_106", *u.UpdateExpression)
+	assert.Equal(t, map[string]*string{
+		"#update_100": aws.String("preferences"),
+		"#update_101": aws.String("a.b"),
+		"#update_102": aws.String("size"),
+		"#update_104": aws.String("preferences"),
+		"#update_105": aws.String("a.b"),
+		"#update_106": aws.String("color"),
+	}, u.ExpressionAttributeNames)
+	assert.Equal(t, DynamoDBValue{
+		":update_preferences_a_b_size_103": &dynamodb.AttributeValue{N: aws.String("1")},
+	}, DynamoDBValue(u.ExpressionAttributeValues))
+}
+
+func TestMapPathUpdate(t *testing.T) {
+	t.Parallel()
+	table := NewUserTable()
+	db := NewDB()
+	ctx := context.Background()
+
+	table.DynamoTable = disposableTable(t, db, table.DynamoTable)
+	defer dispose(db, table.DynamoTable)
+
+	item := map[string]interface{}{
+		"email":    "name@email.com",
+		"password": "password",
+		"preferences": map[string]interface{}{
+			"notifications": map[string]interface{}{
+				"email": "daily",
+				"push":  "never",
+			},
+		},
+	}
+	err := table.PutItem(item).ExecuteWith(ctx, db).Result(nil)
+	assert.NoError(t, err)
+
+	err = table.UpdateItem(KeyValue{"name@email.com", "password"}).
+		SetUpdateExpression(
+			table.preferences.Path("notifications", "email").Set("weekly"),
+			table.preferences.Path("notifications", "push").Remove(),
+		).
+		ExecuteWith(ctx, db).
+		Result(nil)
+	assert.NoError(t, err)
+
+	out := map[string]interface{}{}
+	err = table.GetItem(KeyValue{"name@email.com", "password"}).ExecuteWith(ctx, db).Result(&out)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"notifications": map[string]interface{}{
+			"email": "weekly",
+		},
+	}, out["preferences"])
+}
+
+func TestEmptyUpdateExpression(t *testing.T) {
+	table := NewUserTable()
+	key := KeyValue{"name@email.com", "password"}
+
+	_, err := table.UpdateItem(key).SetUpdateExpression().Build()
+	assert.EqualError(t, err, "UpdateItem users: no update expressions were given.")
+
+	_, err = table.UpdateItem(key).SetUpdateExpression(nil, nil).Build()
+	assert.EqualError(t, err, "UpdateItem users: no update expressions were given.")
+
+	/*Expressions rendering nothing count as none*/
+	_, err = table.UpdateItem(key).SetUpdateExpression(table.locales.AddStrings(nil)).Build()
+	assert.EqualError(t, err, "UpdateItem users: no update expressions were given.")
+
+	var rename *UpdateExpression
+	u, err := table.UpdateItem(key).
+		SetUpdateExpression(nil, table.loginCount.Increment(1), rename).
+		AddUpdateExpression(nil, table.lastLoginDate.SetField(10, false)).
+		Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "SET lastLoginDate = :update_lastLoginDate_101 ADD loginCount :update_loginCount_100", *u.UpdateExpression)
+	assert.Len(t, u.ExpressionAttributeValues, 2)
+}
+
+func TestMapSetAll(t *testing.T) {
+	table := NewUserTable()
+
+	u, err := table.UpdateItem(KeyValue{"name@email.com", "password"}).
+		SetUpdateExpression(
+			table.loginCount.Increment(1),
+			table.preferences.SetAll(map[string]interface{}{"theme": "dark", "lang": "en"}),
+		).
+		Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "SET #update_101.#update_102 = :update_preferences_lang_103, #update_101.#update_104 = :update_preferences_theme_105 ADD loginCount :update_loginCount_100", *u.UpdateExpression)
+	assert.Equal(t, map[string]*string{
+		"#update_101": aws.String("preferences"),
+		"#update_102": aws.String("lang"),
+		"#update_104": aws.String("theme"),
+	}, u.ExpressionAttributeNames)
+	assert.Equal(t, DynamoDBValue{
+		":update_loginCount_100":        &dynamodb.AttributeValue{N: aws.String("1")},
+		":update_preferences_lang_103":  &dynamodb.AttributeValue{S: aws.String("en")},
+		":update_preferences_theme_105": &dynamodb.AttributeValue{S: aws.String("dark")},
+	}, DynamoDBValue(u.ExpressionAttributeValues))
+
+	u, err = table.UpdateItem(KeyValue{"name@email.com", "password"}).
+		SetUpdateExpression(
+			table.preferences.SetAll(nil),
+			table.loginCount.Increment(1),
+		).
+		Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "ADD loginCount :update_loginCount_100", *u.UpdateExpression)
+	assert.Nil(t, u.ExpressionAttributeNames)
+}
+
+func TestListAppendAll(t *testing.T) {
+	table := NewUserTable()
+	history := ListField("history")
+
+	u, err := table.UpdateItem(KeyValue{"name@email.com", "password"}).
+		SetUpdateExpression(
+			history.AppendAll("a", "b"),
+			history.OrEmpty().PrependAll("z"),
+			history.AppendAll(),
+		).
+		Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "SET history = list_append(history,:update_history_100), history = list_append(:update_history_101,if_not_exists(history,:update_history_102))", *u.UpdateExpression)
+	assert.Equal(t, DynamoDBValue{
+		":update_history_100": &dynamodb.AttributeValue{L: []*dynamodb.AttributeValue{{S: aws.String("a")}, {S: aws.String("b")}}},
+		":update_history_101": &dynamodb.AttributeValue{L: []*dynamodb.AttributeValue{{S: aws.String("z")}}},
+		":update_history_102": &dynamodb.AttributeValue{L: []*dynamodb.AttributeValue{}},
+	}, DynamoDBValue(u.ExpressionAttributeValues))
+}
+
+func TestListAppendAllMissingAttribute(t *testing.T) {
+	t.Parallel()
+	table := NewUserTable()
+	db := NewDB()
+	ctx := context.Background()
+	history := ListField("history")
+
+	table.DynamoTable = disposableTable(t, db, table.DynamoTable)
+	defer dispose(db, table.DynamoTable)
+
+	err := table.PutItem(User{Email: "name@email.com", Password: "password"}).ExecuteWith(ctx, db).Result(nil)
+	assert.NoError(t, err)
+
+	key := KeyValue{"name@email.com", "password"}
+	err = table.UpdateItem(key).SetUpdateExpression(history.AppendAll("a")).ExecuteWith(ctx, db).Result(nil)
+	assert.Error(t, err)
+
+	err = table.UpdateItem(key).SetUpdateExpression(history.OrEmpty().AppendAll("a", "b")).ExecuteWith(ctx, db).Result(nil)
+	assert.NoError(t, err)
+	err = table.UpdateItem(key).SetUpdateExpression(history.OrEmpty().PrependAll("y", "z")).ExecuteWith(ctx, db).Result(nil)
+	assert.NoError(t, err)
+
+	out := map[string]interface{}{}
+	err = table.GetItem(key).ExecuteWith(ctx, db).Result(&out)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{"y", "z", "a", "b"}, out["history"])
+}
+
+func TestListSet(t *testing.T) {
+	table := NewUserTable()
+	history := ListField("history")
+
+	u, err := table.UpdateItem(KeyValue{"name@email.com", "password"}).
+		SetUpdateExpression(history.Set(2, "x")).
+		Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "SET history[2] = :update_history_100", *u.UpdateExpression)
+	assert.Equal(t, DynamoDBValue{
+		":update_history_100": &dynamodb.AttributeValue{S: aws.String("x")},
+	}, DynamoDBValue(u.ExpressionAttributeValues))
+}
+
+func TestListSetElement(t *testing.T) {
+	t.Parallel()
+	table := NewUserTable()
+	db := NewDB()
+	ctx := context.Background()
+	history := ListField("history")
+
+	table.DynamoTable = disposableTable(t, db, table.DynamoTable)
+	defer dispose(db, table.DynamoTable)
+
+	item := map[string]interface{}{"email": "name@email.com", "password": "password", "history": []string{"a", "b", "c"}}
+	err := table.PutItem(item).ExecuteWith(ctx, db).Result(nil)
+	assert.NoError(t, err)
+
+	key := KeyValue{"name@email.com", "password"}
+	err = table.UpdateItem(key).SetUpdateExpression(history.Set(1, "x")).ExecuteWith(ctx, db).Result(nil)
+	assert.NoError(t, err)
+
+	out := map[string]interface{}{}
+	err = table.GetItem(key).ExecuteWith(ctx, db).Result(&out)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{"a", "x", "c"}, out["history"])
+}
+
+func TestBulkSetUpdates(t *testing.T) {
+	table := NewUserTable()
+
+	u, err := table.UpdateItem(KeyValue{"name@email.com", "password"}).
+		SetUpdateExpression(
+			table.locales.AddStrings([]string{"us", "eu"}),
+			table.visits.AddInts([]int64{1, 2, 3}),
+			table.degrees.DeleteFloats([]float64{1.5}),
+			table.locales.DeleteStrings(nil),
+			table.visits.AddInts([]int64{}),
+		).
+		Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "ADD locales :update_locales_100, visits :update_visits_101 DELETE degrees :update_degrees_102", *u.UpdateExpression)
+	assert.Equal(t, DynamoDBValue{
+		":update_locales_100": &dynamodb.AttributeValue{SS: aws.StringSlice([]string{"us", "eu"})},
+		":update_visits_101":  &dynamodb.AttributeValue{NS: aws.StringSlice([]string{"1", "2", "3"})},
+		":update_degrees_102": &dynamodb.AttributeValue{NS: aws.StringSlice([]string{"1.5"})},
+	}, DynamoDBValue(u.ExpressionAttributeValues))
+}
+
+func TestBinarySetUpdate(t *testing.T) {
+	t.Parallel()
+	table := NewUserTable()
+	db := NewDB()
+	ctx := context.Background()
+	tokens := BinarySetField("tokens")
+
+	table.DynamoTable = disposableTable(t, db, table.DynamoTable)
+	defer dispose(db, table.DynamoTable)
+
+	key := KeyValue{"name@email.com", "password"}
+	err := table.PutItem(User{Email: "name@email.com", Password: "password"}).ExecuteWith(ctx, db).Result(nil)
+	assert.NoError(t, err)
+
+	err = table.UpdateItem(key).
+		SetUpdateExpression(tokens.AddBinaries([][]byte{[]byte("a"), []byte("b"), []byte("c")})).
+		ExecuteWith(ctx, db).Result(nil)
+	assert.NoError(t, err)
+
+	err = table.UpdateItem(key).
+		SetUpdateExpression(tokens.DeleteBinary([]byte("b")), tokens.AddBinary([]byte("d"))).
+		ExecuteWith(ctx, db).Result(nil)
+	assert.NoError(t, err)
+
+	out := table.GetItem(key).ExecuteWith(ctx, db)
+	assert.NoError(t, out.Error())
+	assert.ElementsMatch(t, [][]byte{[]byte("a"), []byte("c"), []byte("d")}, out.Item["tokens"].BS)
+
+	err = table.UpdateItem(key).
+		SetUpdateExpression(tokens.DeleteBinaries([][]byte{[]byte("a"), []byte("c"), []byte("d")})).
+		ExecuteWith(ctx, db).Result(nil)
+	assert.NoError(t, err)
+
+	out = table.GetItem(key).ExecuteWith(ctx, db)
+	assert.NoError(t, out.Error())
+	assert.Nil(t, out.Item["tokens"])
+}
+
+func TestDeleteFloatMatchesMarshaledSet(t *testing.T) {
+	t.Parallel()
+	table := NewUserTable()
+	db := NewDB()
+	ctx := context.Background()
+
+	table.DynamoTable = disposableTable(t, db, table.DynamoTable)
+	defer dispose(db, table.DynamoTable)
+
+	key := KeyValue{"name@email.com", "password"}
+	item := User{Email: "name@email.com", Password: "password", Degrees: []float64{1, 2, 0.25}}
+	err := table.PutItem(item).ExecuteWith(ctx, db).Result(nil)
+	assert.NoError(t, err)
+
+	err = table.UpdateItem(key).
+		SetUpdateExpression(table.degrees.DeleteFloat(1), table.degrees.DeleteFloats([]float64{0.25})).
+		ExecuteWith(ctx, db).Result(nil)
+	assert.NoError(t, err)
+
+	item = User{}
+	err = table.GetItem(key).ExecuteWith(ctx, db).Result(&item)
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{2}, item.Degrees)
+}
+
+func TestIncrementPreservesPrecision(t *testing.T) {
+	t.Parallel()
+	table := NewUserTable()
+	db := NewDB()
+	ctx := context.Background()
+
+	table.DynamoTable = disposableTable(t, db, table.DynamoTable)
+	defer dispose(db, table.DynamoTable)
+
+	key := KeyValue{"name@email.com", "password"}
+	err := table.PutItem(User{Email: "name@email.com", Password: "password"}).ExecuteWith(ctx, db).Result(nil)
+	assert.NoError(t, err)
+
+	var big int64 = 1<<53 + 1
+	err = table.UpdateItem(key).
+		SetUpdateExpression(table.lastLoginDate.AddInt(big), table.loginCount.Increment(3)).
+		ExecuteWith(ctx, db).Result(nil)
+	assert.NoError(t, err)
+
+	err = table.UpdateItem(key).
+		SetUpdateExpression(table.lastLoginDate.Increment(2), table.loginCount.Decrement(1)).
+		ExecuteWith(ctx, db).Result(nil)
+	assert.NoError(t, err)
+
+	item := User{}
+	err = table.GetItem(key).ExecuteWith(ctx, db).Result(&item)
+	assert.NoError(t, err)
+	assert.Equal(t, big+2, item.LoginDate)
+	assert.Equal(t, 2, item.LoginCount)
+}
+
+func TestResetItem(t *testing.T) {
+	table := NewUserTable()
+	ctx := context.Background()
+	key := KeyValue{"name@email.com", "password"}
+
+	var get *dynamodb.GetItemInput
+	var update *dynamodb.UpdateItemInput
+	db := &stubDB{
+		getItem: func(in *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+			get = in
+			return &dynamodb.GetItemOutput{Item: DynamoDBValue{
+				"email":      &dynamodb.AttributeValue{S: aws.String("name@email.com")},
+				"password":   &dynamodb.AttributeValue{S: aws.String("password")},
+				"firstName":  &dynamodb.AttributeValue{S: aws.String("Jane")},
+				"loginCount": &dynamodb.AttributeValue{N: aws.String("3")},
+				"name":       &dynamodb.AttributeValue{S: aws.String("jane")},
+				"size":       &dynamodb.AttributeValue{N: aws.String("1")},
+			}}, nil
+		},
+		updateItem: func(in *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+			update = in
+			return &dynamodb.UpdateItemOutput{}, nil
+		},
+	}
+
+	removed, err := table.ResetItem(ctx, db, key, &table.loginCount)
+	assert.NoError(t, err)
+	assert.True(t, *get.ConsistentRead)
+	assert.Equal(t, []string{"firstName", "name", "size"}, removed)
+	/*Reserved words, i.e. name and size, go through name placeholders*/
+	assert.Equal(t, "REMOVE #update_100, #update_101, #update_102", *update.UpdateExpression)
+	assert.Equal(t, map[string]*string{
+		"#update_100": aws.String("firstName"),
+		"#update_101": aws.String("name"),
+		"#update_102": aws.String("size"),
+	}, update.ExpressionAttributeNames)
+	assert.Equal(t, "attribute_exists(email) AND attribute_exists(password)", *update.ConditionExpression)
+
+	/*Nothing left to remove, no update is sent*/
+	update = nil
+	removed, err = table.ResetItemAttributes(ctx, db, key, []string{"email", "password", "loginCount"}, &table.loginCount)
+	assert.NoError(t, err)
+	assert.Nil(t, removed)
+	assert.Nil(t, update)
+
+	/*Timestamps are stamped again rather than removed*/
+	stamped := table.WithTimestamps(NumericField("createdAt"), NumericField("updatedAt"), func() time.Time { return time.Unix(5, 0) })
+	removed, err = stamped.ResetItemAttributes(ctx, db, key, []string{"createdAt", "updatedAt", "degrees"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"degrees"}, removed)
+	assert.Equal(t, "SET updatedAt = :update_updatedAt_101, createdAt = if_not_exists(createdAt,:update_createdAt_102) REMOVE #update_100", *update.UpdateExpression)
+
+	/*A missing item has nothing to remove*/
+	db.getItem = func(in *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+		return &dynamodb.GetItemOutput{}, nil
+	}
+	update = nil
+	removed, err = table.ResetItem(ctx, db, key)
+	assert.NoError(t, err)
+	assert.Nil(t, removed)
+	assert.Nil(t, update)
+}
+
+func TestIncrementField(t *testing.T) {
+	table := NewUserTable()
+	ctx := context.Background()
+
+	var input *dynamodb.UpdateItemInput
+	db := &stubDB{updateItem: func(in *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+		input = in
+		return &dynamodb.UpdateItemOutput{Attributes: DynamoDBValue{
+			"loginCount": &dynamodb.AttributeValue{N: aws.String("9007199254740993")},
+		}}, nil
+	}}
+
+	v, err := table.IncrementField(ctx, db, KeyValue{"name@email.com", "password"}, table.loginCount, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(9007199254740993), v)
+	assert.Equal(t, "UPDATED_NEW", *input.ReturnValues)
+	assert.Equal(t, "ADD loginCount :update_loginCount_100", *input.UpdateExpression)
+	assert.Equal(t, "2", *input.ExpressionAttributeValues[":update_loginCount_100"].N)
+	assert.Nil(t, input.ConditionExpression)
+
+	db.updateItem = func(in *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+		input = in
+		return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "bound exceeded", nil)
+	}
+	_, err = table.IncrementField(ctx, db, KeyValue{"name@email.com", "password"}, table.loginCount, 1, table.loginCount.LessThan(10))
+	assert.Equal(t, "loginCount < :cond_loginCount_1", *input.ConditionExpression)
+	assert.Equal(t, dynamodb.ErrCodeConditionalCheckFailedException, err.(awserr.Error).Code())
+
+	db.updateItem = func(in *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+		return &dynamodb.UpdateItemOutput{}, nil
+	}
+	_, err = table.IncrementField(ctx, db, KeyValue{"name@email.com", "password"}, table.loginCount, 1)
+	assert.Equal(t, MissingCounterError, err)
+}
+
+func TestRequireExistsInputs(t *testing.T) {
+	table := NewUserTable()
+	key := KeyValue{"name@email.com", "password"}
+
+	u, err := table.UpdateItem(key).
+		SetUpdateExpression(table.loginCount.Increment(1)).
+		RequireExists().
+		Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "attribute_exists(email) AND attribute_exists(password)", *u.ConditionExpression)
+
+	u, err = table.UpdateItem(key).
+		SetUpdateExpression(table.loginCount.Increment(1)).
+		SetConditionExpression(table.loginCount.LessThan(5)).
+		RequireNotExists().
+		Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "loginCount < :cond_loginCount_1 AND (attribute_not_exists(email) AND attribute_not_exists(password))", *u.ConditionExpression)
+
+	events := DynamoTable{Name: "events", PartitionKey: NumericField("id"), RangeKey: EmptyField()}
+	visits := NumericField("visits")
+	u, err = events.UpdateItem(PK(1)).
+		SetUpdateExpression(visits.Increment(1)).
+		RequireExists().
+		Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "attribute_exists(id)", *u.ConditionExpression)
+
+	/*Clones keep the table's key conditions*/
+	u, err = events.UpdateItem(PK(1)).
+		SetUpdateExpression(visits.Increment(1)).
+		Clone().
+		RequireNotExists().
+		Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "attribute_not_exists(id)", *u.ConditionExpression)
+}
+
+func TestRequireExists(t *testing.T) {
+	t.Parallel()
+	table := NewUserTable()
+	db := NewDB()
+	ctx := context.Background()
+
+	table.DynamoTable = disposableTable(t, db, table.DynamoTable)
+	defer dispose(db, table.DynamoTable)
+
+	key := KeyValue{"name@email.com", "password"}
+	out := table.UpdateItem(key).
+		SetUpdateExpression(table.loginCount.Increment(1)).
+		RequireExists().
+		ExecuteWith(ctx, db)
+	assert.True(t, out.ConditionalCheckFailed())
+
+	/*No skeleton item was created*/
+	found, err := table.GetItem(key).SetConsistentRead(true).ExecuteWith(ctx, db).ResultOK(&User{})
+	assert.NoError(t, err)
+	assert.False(t, found)
+
+	err = table.UpdateItem(key).
+		SetUpdateExpression(table.loginCount.Increment(1)).
+		RequireNotExists().
+		ExecuteWith(ctx, db).
+		Error()
+	assert.NoError(t, err)
+
+	err = table.UpdateItem(key).
+		SetUpdateExpression(table.loginCount.Increment(1)).
+		RequireExists().
+		ExecuteWith(ctx, db).
+		Error()
+	assert.NoError(t, err)
+
+	out = table.UpdateItem(key).
+		SetUpdateExpression(table.loginCount.Increment(1)).
+		RequireNotExists().
+		ExecuteWith(ctx, db)
+	assert.True(t, out.ConditionalCheckFailed())
+}
+
+func TestOptimisticLockInputs(t *testing.T) {
+	table := NewUserTable()
+
+	p, err := table.PutItem(User{Email: "name@email.com", Password: "password", LoginCount: 3}).
+		WithOptimisticLock(table.loginCount).
+		Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "loginCount = :cond_loginCount_1", *p.ConditionExpression)
+	assert.Equal(t, "3", *p.ExpressionAttributeValues[":cond_loginCount_1"].N)
+	assert.Equal(t, "4", *p.Item["loginCount"].N)
+
+	p, err = table.PutItem(User{Email: "name@email.com", Password: "password"}).
+		WithOptimisticLock(table.loginCount).
+		Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "attribute_not_exists(loginCount)", *p.ConditionExpression)
+	assert.Equal(t, "1", *p.Item["loginCount"].N)
+
+	u, err := table.UpdateItem(KeyValue{"name@email.com", "password"}).
+		SetUpdateExpression(table.lastLoginDate.SetField(10, false)).
+		WithOptimisticLock(table.loginCount, 3).
+		Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "loginCount = :cond_loginCount_1", *u.ConditionExpression)
+	assert.Equal(t, "SET lastLoginDate = :update_lastLoginDate_100, loginCount = :update_loginCount_101", *u.UpdateExpression)
+	assert.Equal(t, "4", *u.ExpressionAttributeValues[":update_loginCount_101"].N)
+}
+
+func TestOptimisticLock(t *testing.T) {
+	t.Parallel()
+	table := NewUserTable()
+	db := NewDB()
+	ctx := context.Background()
+
+	table.DynamoTable = disposableTable(t, db, table.DynamoTable)
+	defer dispose(db, table.DynamoTable)
+
+	key := KeyValue{"name@email.com", "password"}
+	item := User{Email: "name@email.com", Password: "password"}
+	err := table.PutItem(item).WithOptimisticLock(table.loginCount).ExecuteWith(ctx, db).Result(nil)
+	assert.NoError(t, err)
+
+	out := table.PutItem(item).WithOptimisticLock(table.loginCount).ExecuteWith(ctx, db)
+	assert.Equal(t, ErrVersionConflict, out.Error())
+	assert.True(t, out.ConditionalCheckFailed())
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = table.UpdateItem(key).
+				SetUpdateExpression(table.lastLoginDate.SetField(i, false)).
+				WithOptimisticLock(table.loginCount, 1).
+				ExecuteWith(ctx, db).
+				Error()
+		}(i)
+	}
+	wg.Wait()
+
+	won := 0
+	for _, err := range errs {
+		if err == nil {
+			won++
+		} else {
+			assert.Equal(t, ErrVersionConflict, err)
+		}
+	}
+	assert.Equal(t, 1, won)
+
+	item = User{}
+	err = table.GetItem(key).ExecuteWith(ctx, db).Result(&item)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, item.LoginCount)
+}
+
+func TestPutIfNotExists(t *testing.T) {
+	table := NewUserTable()
+
+	p, err := table.PutIfNotExists(User{Email: "name@email.com", Password: "password"}).Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "attribute_not_exists(email) AND attribute_not_exists(password)", *p.ConditionExpression)
+}
+
+func TestGetOrCreate(t *testing.T) {
+	t.Parallel()
+	table := NewUserTable()
+	db := NewDB()
+	ctx := context.Background()
+
+	table.DynamoTable = disposableTable(t, db, table.DynamoTable)
+	defer dispose(db, table.DynamoTable)
+
+	key := KeyValue{"name@email.com", "password"}
+	var wg sync.WaitGroup
+	created := make([]bool, 4)
+	users := make([]User, 4)
+	for i := range created {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var err error
+			created[i], err = table.GetOrCreate(ctx, db, key, func() interface{} {
+				return User{Email: "name@email.com", Password: "password", LoginCount: i + 1}
+			}, &users[i])
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+
+	winner := -1
+	for i, c := range created {
+		if c {
+			assert.Equal(t, -1, winner)
+			winner = i
+		}
+	}
+	assert.NotEqual(t, -1, winner)
+	for _, u := range users {
+		assert.Equal(t, winner+1, u.LoginCount)
+	}
+}
+
+func TestGetOrCreateStub(t *testing.T) {
+	ctx := context.Background()
+	table := NewUserTable().DynamoTable.WithTimestamps(NumericField("createdAt"), NumericField("updatedAt"), func() time.Time { return time.Unix(5, 0) })
+	key := KeyValue{"name@email.com", "password"}
+	create := func() interface{} { return User{Email: "name@email.com", Password: "password"} }
+
+	/*The item read back is the one written, timestamps included*/
+	puts := 0
+	db := &stubDB{
+		putItem: func(in *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+			puts++
+			return &dynamodb.PutItemOutput{}, nil
+		},
+	}
+	out := map[string]interface{}{}
+	created, err := table.GetOrCreate(ctx, db, key, create, &out)
+	assert.NoError(t, err)
+	assert.True(t, created)
+	assert.Equal(t, float64(5), out["createdAt"])
+	assert.Equal(t, float64(5), out["updatedAt"])
+
+	/*An item deleted between losing the race and reading it back is an error, not a silent miss*/
+	db.putItem = func(in *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+		return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "exists", nil)
+	}
+	db.getItem = func(in *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+		return &dynamodb.GetItemOutput{}, nil
+	}
+	created, err = table.GetOrCreate(ctx, db, key, create, &User{})
+	assert.Equal(t, ErrItemDeleted, err)
+	assert.False(t, created)
+
+	/*create must carry key*/
+	db.putItem = func(in *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+		puts++
+		return &dynamodb.PutItemOutput{}, nil
+	}
+	puts = 0
+	created, err = table.GetOrCreate(ctx, db, KeyValue{"other@email.com", "password"}, create, &User{})
+	assert.EqualError(t, err, `GetOrCreate users: the item created doesn't carry the key's email "other@email.com".`)
+	assert.False(t, created)
+	assert.Equal(t, 0, puts)
+}
+
+func TestTimeField(t *testing.T) {
+	at := time.Date(2018, 3, 4, 5, 6, 7, 891011121, time.FixedZone("PST", -8*3600))
+
+	for enc, want := range map[TimeEncoding]*dynamodb.AttributeValue{
+		EpochSeconds: {N: aws.String("1520168767")},
+		EpochMillis:  {N: aws.String("1520168767891")},
+		EpochNanos:   {N: aws.String("1520168767891011121")},
+		RFC3339:      {S: aws.String("2018-03-04T13:06:07.891011121Z")},
+	} {
+		f := TimeField("at", enc)
+		u, err := NewUserTable().UpdateItem(KeyValue{"name@email.com", "password"}).
+			SetUpdateExpression(f.SetField(at, true)).
+			Build()
+		assert.NoError(t, err)
+		assert.Equal(t, "SET at = if_not_exists(at,:update_at_100)", *u.UpdateExpression)
+		assert.Equal(t, want, u.ExpressionAttributeValues[":update_at_100"])
+
+		d, err := f.Decode(want)
+		assert.NoError(t, err)
+		assert.True(t, at.Truncate(map[TimeEncoding]time.Duration{EpochSeconds: time.Second, EpochMillis: time.Millisecond}[enc]).Equal(d), "%v", enc)
+	}
+
+	assert.Equal(t, dN, TimeField("at", EpochMillis).Type())
+	assert.Equal(t, dS, TimeField("at", RFC3339).Type())
+}
+
+func TestTimeFieldRoundTrip(t *testing.T) {
+	t.Parallel()
+	db := NewDB()
+	ctx := context.Background()
+	at := time.Now().Truncate(time.Millisecond)
+
+	for _, enc := range []TimeEncoding{EpochMillis, RFC3339} {
+		id := StringField("id")
+		ts := TimeField("at", enc)
+		table := DynamoTable{Name: "events", PartitionKey: id, RangeKey: ts}
+
+		table = disposableTable(t, db, table)
+		defer dispose(db, table)
+
+		err := table.PutItem(map[string]interface{}{"id": "a", "at": ts.Value(at)}).ExecuteWith(ctx, db).Result(nil)
+		assert.NoError(t, err)
+
+		kc := ts.Between(at.Add(-time.Second), at.Add(time.Second))
+		items, _, err := table.Query(id.Equals("a"), &kc).ExecuteWith(ctx, db).ResultsList()
+		assert.NoError(t, err)
+		assert.Len(t, items, 1)
+
+		out := table.GetItem(KeyValue{"a", ts.Value(at)}).ExecuteWith(ctx, db)
+		assert.NoError(t, out.Error())
+		got, err := ts.Decode(out.Item["at"])
+		assert.NoError(t, err)
+		assert.True(t, at.Equal(got))
+	}
+}
+
+func TestNumericTimeRange(t *testing.T) {
+	a := time.Date(2018, 3, 4, 5, 6, 7, 891011121, time.UTC)
+	b := a.Add(24 * time.Hour)
+	table := NewUserTable()
+
+	for unit, want := range map[TimeEncoding][2]string{
+		EpochSeconds: {"1520139967", "1520226367"},
+		EpochMillis:  {"1520139967891", "1520226367891"},
+		EpochNanos:   {"1520139967891011121", "1520226367891011121"},
+	} {
+		reg := table.registrationDate.WithEpochUnit(unit)
+
+		since, until, between := reg.Since(a), reg.Until(b), reg.BetweenTimes(a, b)
+		q, err := table.Query(table.emailField.Equals("name@email.com"), &between).
+			SetLocalIndex(table.registrationDateIndex).
+			SetFilterExpression(And(since, until)).
+			Build()
+		assert.NoError(t, err)
+		assert.Equal(t, "email = :cond_email_0 AND (registrationDate between :cond_registrationDate_1 and :cond_registrationDate_2)", *q.KeyConditionExpression)
+		assert.Equal(t, want[0], *q.ExpressionAttributeValues[":cond_registrationDate_1"].N, "%v", unit)
+		assert.Equal(t, want[1], *q.ExpressionAttributeValues[":cond_registrationDate_2"].N, "%v", unit)
+		assert.Equal(t, "registrationDate >= :filter_registrationDate_1 AND registrationDate <= :filter_registrationDate_2", *q.FilterExpression)
+		assert.Equal(t, want[0], *q.ExpressionAttributeValues[":filter_registrationDate_1"].N, "%v", unit)
+		assert.Equal(t, want[1], *q.ExpressionAttributeValues[":filter_registrationDate_2"].N, "%v", unit)
+	}
+}
+
+func TestNumericTimeRangeQuery(t *testing.T) {
+	t.Parallel()
+	db := NewDB()
+	ctx := context.Background()
+	table := NewUserTable()
+	table.DynamoTable = disposableTable(t, db, table.DynamoTable)
+	defer dispose(db, table.DynamoTable)
+
+	reg := table.registrationDate.WithEpochUnit(EpochMillis)
+	now := time.Now()
+	for i, age := range []time.Duration{time.Hour, 12 * time.Hour, 36 * time.Hour} {
+		u := User{Email: "name@email.com", Password: fmt.Sprintf("password%d", i), RegDate: now.Add(-age).UnixNano() / int64(time.Millisecond)}
+		err := table.PutItem(u).ExecuteWith(ctx, db).Result(nil)
+		assert.NoError(t, err)
+	}
+
+	since := reg.Since(now.Add(-24 * time.Hour))
+	items, _, err := table.Query(table.emailField.Equals("name@email.com"), &since).
+		SetLocalIndex(table.registrationDateIndex).
+		ExecuteWith(ctx, db).
+		ResultsList()
+	assert.NoError(t, err)
+	assert.Len(t, items, 2)
+
+	between := reg.BetweenTimes(now.Add(-48*time.Hour), now.Add(-24*time.Hour))
+	items, _, err = table.Query(table.emailField.Equals("name@email.com"), &between).
+		SetLocalIndex(table.registrationDateIndex).
+		ExecuteWith(ctx, db).
+		ResultsList()
+	assert.NoError(t, err)
+	assert.Len(t, items, 1)
+
+	until := reg.Until(now.Add(-2 * time.Hour))
+	items, _, err = table.Scan().SetFilterExpression(until).ExecuteWith(ctx, db).ResultsList()
+	assert.NoError(t, err)
+	assert.Len(t, items, 2)
+}
+
+func TestTableEncoderDecoder(t *testing.T) {
+	type note struct {
+		ID   string `dynamodbav:"email"`
+		Body string `dynamodbav:"body"`
+	}
+	table := NewUserTable()
+
+	p, err := table.PutItem(note{ID: "name@email.com"}).Build()
+	assert.NoError(t, err)
+	assert.Equal(t, true, *p.Item["body"].NULL)
+
+	table.Encoder = dynamodbattribute.NewEncoder(func(e *dynamodbattribute.Encoder) {
+		e.NullEmptyString = false
+	})
+	p, err = table.PutItem(note{ID: "name@email.com"}).Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "", *p.Item["body"].S)
+
+	table.Decoder = dynamodbattribute.NewDecoder(func(d *dynamodbattribute.Decoder) {
+		d.UseNumber = true
+	})
+	db := &stubDB{query: func(in *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+		return &dynamodb.QueryOutput{Items: []map[string]*dynamodb.AttributeValue{
+			{"visits": &dynamodb.AttributeValue{N: aws.String("9007199254740993")}},
+		}}, nil
+	}}
+	var item map[string]interface{}
+	err = table.Query(table.emailField.Equals("name@email.com"), nil).
+		ExecuteWith(context.Background(), db).
+		Results(func() interface{} {
+			item = map[string]interface{}{}
+			return &item
+		})
+	assert.NoError(t, err)
+	assert.Equal(t, dynamodbattribute.Number("9007199254740993"), item["visits"])
+}
+
+func TestSanitizeWrites(t *testing.T) {
+	type doc struct {
+		Email    string                 `dynamodbav:"email"`
+		Password string                 `dynamodbav:"password"`
+		Name     string                 `dynamodbav:"name"`
+		Blob     []byte                 `dynamodbav:"blob"`
+		Ptr      *int                   `dynamodbav:"ptr"`
+		Count    int                    `dynamodbav:"count"`
+		Nested   map[string]interface{} `dynamodbav:"nested"`
+		Tags     []string               `dynamodbav:"tags"`
+	}
+	item := doc{
+		Password: "password",
+		Nested:   map[string]interface{}{"a": "", "b": "x", "c": map[string]interface{}{"d": nil}},
+		Tags:     []string{"", "t"},
+	}
+	table := NewUserTable()
+
+	before := builtPut(t, table.PutItem(item)).Item
+	assert.Equal(t, true, *before["name"].NULL)
+	assert.Equal(t, true, *before["blob"].NULL)
+	assert.Equal(t, true, *before["ptr"].NULL)
+
+	want := DynamoDBValue{
+		"email":    &dynamodb.AttributeValue{NULL: aws.Bool(true)},
+		"password": &dynamodb.AttributeValue{S: aws.String("password")},
+		"count":    &dynamodb.AttributeValue{N: aws.String("0")},
+		"nested": &dynamodb.AttributeValue{M: DynamoDBValue{
+			"b": &dynamodb.AttributeValue{S: aws.String("x")},
+			"c": &dynamodb.AttributeValue{M: DynamoDBValue{}},
+		}},
+		"tags": &dynamodb.AttributeValue{L: []*dynamodb.AttributeValue{{S: aws.String("t")}}},
+	}
+	assert.Equal(t, want, DynamoDBValue(builtPut(t, table.PutItem(item).SanitizeWrites()).Item))
+	assert.Equal(t, want, DynamoDBValue(builtPut(t, table.SanitizeWrites().PutItem(item)).Item))
+
+	batches, err := table.BatchWriteItem().SanitizeWrites().PutItems(item).Build()
+	assert.NoError(t, err)
+	assert.Equal(t, want, DynamoDBValue(batches[0].RequestItems["users"][0].PutRequest.Item))
+}
+
+type taggedUser struct {
+	Email     string `dynamodbav:"email" domino:"pk"`
+	Password  string `dynamodbav:"password" domino:"rk"`
+	FirstName string `dynamodbav:"firstName" domino:"gsi:name-index,pk"`
+	LastName  string `dynamodbav:"lastName" domino:"gsi:name-index,rk"`
+	RegDate   int64  `dynamodbav:"registrationDate" domino:"lsi:registrationDate-index"`
+	Visits    []int64
+}
+
+func TestTableFromStruct(t *testing.T) {
+	table, err := TableFromStruct("users", &taggedUser{})
+	assert.NoError(t, err)
+
+	user := NewUserTable()
+	user.GlobalSecondaryIndexes[0].ReadUnits = 0
+	user.GlobalSecondaryIndexes[0].WriteUnits = 0
+	user.LocalSecondaryIndexes[0].ProjectionType = ProjectionTypeALL
+	assert.Equal(t, user.DynamoTable, table)
+
+	for _, v := range []interface{}{
+		struct{ A string }{},
+		struct {
+			A string `domino:"pk"`
+			B string `domino:"pk"`
+		}{},
+		struct {
+			A string `domino:"pk"`
+			B bool   `domino:"rk"`
+		}{},
+		struct {
+			A string `domino:"pk"`
+			B string `domino:"rk;gsi:idx,pk"`
+			C string `domino:"lsi:idx"`
+		}{},
+		struct {
+			A string `domino:"pk"`
+			B string `domino:"gsi:idx,rk"`
+		}{},
+		struct {
+			A string `domino:"pk;sk"`
+		}{},
+		"not a struct",
+	} {
+		_, err := TableFromStruct("t", v)
+		assert.Error(t, err, "%#v", v)
+	}
+}
+
+func TestTableFromStructQuery(t *testing.T) {
+	t.Parallel()
+	db := NewDB()
+	ctx := context.Background()
+	table, err := TableFromStruct("users", taggedUser{})
+	assert.NoError(t, err)
+
+	table = disposableTable(t, db, table)
+	defer dispose(db, table)
+
+	err = table.PutItem(taggedUser{Email: "a@email.com", Password: "p", FirstName: "naveen", LastName: "gattu", RegDate: 1}).ExecuteWith(ctx, db).Result(nil)
+	assert.NoError(t, err)
+
+	gsi := table.GlobalSecondaryIndexes[0]
+	p := gsi.PartitionKey.(String)
+	items, _, err := table.Query(p.Equals("naveen"), nil).SetGlobalIndex(gsi).ExecuteWith(ctx, db).ResultsList()
+	assert.NoError(t, err)
+	assert.Len(t, items, 1)
+}
+
+func TestValidateSchema(t *testing.T) {
+	table := NewUserTable()
+	ctx := context.Background()
+
+	var live *dynamodb.TableDescription
+	db := &stubDB{describeTable: func(in *dynamodb.DescribeTableInput) (*dynamodb.DescribeTableOutput, error) {
+		assert.Equal(t, "users", *in.TableName)
+		return &dynamodb.DescribeTableOutput{Table: live}, nil
+	}}
+
+	live = describedAs(table.CreateTable().Build())
+	diffs, err := table.ValidateSchema(ctx, db)
+	assert.NoError(t, err)
+	assert.Empty(t, diffs)
+
+	drifted := NewUserTable()
+	drifted.RangeKey = NumericField("password")
+	drifted.GlobalSecondaryIndexes = nil
+	drifted.LocalSecondaryIndexes[0].ProjectionType = ProjectionTypeKEYS_ONLY
+	drifted.GlobalSecondaryIndexes = append(drifted.GlobalSecondaryIndexes, GlobalSecondaryIndex{
+		Name: "visits-index", PartitionKey: NumericField("visits"), RangeKey: EmptyField(),
+	})
+	live = describedAs(drifted.CreateTable().Build())
+
+	diffs, err = table.ValidateSchema(ctx, db)
+	assert.NoError(t, err)
+	var s []string
+	for _, d := range diffs {
+		s = append(s, d.String())
+	}
+	assert.Equal(t, []string{
+		"range key type mismatch: declared S, actual N",
+		"GSI name-index missing",
+		"GSI visits-index not declared",
+		"LSI registrationDate-index projection mismatch: declared ALL, actual KEYS_ONLY",
+	}, s)
+}
+
+func TestEnsureTable(t *testing.T) {
+	ensureTablePollInterval = time.Millisecond
+	table := NewUserTable()
+	ctx := context.Background()
+
+	// A missing table is created and waited on
+	var live *dynamodb.TableDescription
+	polls := 0
+	db := &stubDB{
+		describeTable: func(in *dynamodb.DescribeTableInput) (*dynamodb.DescribeTableOutput, error) {
+			if live == nil {
+				return nil, awserr.New(dynamodb.ErrCodeResourceNotFoundException, "not found", nil)
+			}
+			polls++
+			status := dynamodb.TableStatusCreating
+			if polls > 2 {
+				status = dynamodb.TableStatusActive
+			}
+			live.TableStatus = aws.String(status)
+			for _, gsi := range live.GlobalSecondaryIndexes {
+				gsi.IndexStatus = aws.String(status)
+			}
+			return &dynamodb.DescribeTableOutput{Table: live}, nil
+		},
+		createTable: func(in *dynamodb.CreateTableInput) (*dynamodb.CreateTableOutput, error) {
+			live = describedAs(in)
+			return &dynamodb.CreateTableOutput{}, nil
+		},
+	}
+	actions, err := table.EnsureTable(ctx, db)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"created table users"}, actions)
+	assert.Equal(t, 3, polls)
+
+	// An existing table is left alone
+	actions, err = table.EnsureTable(ctx, db)
+	assert.NoError(t, err)
+	assert.Empty(t, actions)
+
+	// A missing global index is added and waited on
+	var update *dynamodb.UpdateTableInput
+	bare := NewUserTable()
+	bare.GlobalSecondaryIndexes = nil
+	live, polls = describedAs(bare.CreateTable().Build()), 0
+	db.updateTable = func(in *dynamodb.UpdateTableInput) (*dynamodb.UpdateTableOutput, error) {
+		update, polls = in, 0
+		c := in.GlobalSecondaryIndexUpdates[0].Create
+		live.GlobalSecondaryIndexes = append(live.GlobalSecondaryIndexes, &dynamodb.GlobalSecondaryIndexDescription{
+			IndexName: c.IndexName, KeySchema: c.KeySchema, Projection: c.Projection,
+		})
+		live.AttributeDefinitions = append(live.AttributeDefinitions, in.AttributeDefinitions...)
+		return &dynamodb.UpdateTableOutput{}, nil
+	}
+	actions, err = table.EnsureTable(ctx, db)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"added GSI name-index"}, actions)
+	assert.Equal(t, "name-index", *update.GlobalSecondaryIndexUpdates[0].Create.IndexName)
+	assert.NotNil(t, update.GlobalSecondaryIndexUpdates[0].Create.ProvisionedThroughput)
+	assert.Len(t, update.AttributeDefinitions, 2)
+	assert.Equal(t, 3, polls)
+
+	// An on demand table's new index is given no throughput
+	live, polls = describedAs(bare.CreateTable().Build()), 0
+	live.BillingModeSummary = &dynamodb.BillingModeSummary{BillingMode: aws.String(dynamodb.BillingModePayPerRequest)}
+	actions, err = table.EnsureTable(ctx, db)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"added GSI name-index"}, actions)
+	assert.Nil(t, update.GlobalSecondaryIndexUpdates[0].Create.ProvisionedThroughput)
+
+	// Key mismatches are not reconciled
+	drifted := NewUserTable()
+	drifted.RangeKey = NumericField("password")
+	live, update = describedAs(drifted.CreateTable().Build()), nil
+	actions, err = table.EnsureTable(ctx, db)
+	assert.EqualError(t, err, "EnsureTable users: cannot reconcile range key type mismatch: declared S, actual N.")
+	assert.Nil(t, actions)
+	assert.Nil(t, update)
+}
+
+func TestWaitForIndexActive(t *testing.T) {
+	table := NewUserTable()
+	ctx := context.Background()
+
+	// The index backfills, then goes ACTIVE
+	states := []*dynamodb.GlobalSecondaryIndexDescription{
+		{IndexStatus: aws.String(dynamodb.IndexStatusCreating), Backfilling: aws.Bool(true)},
+		{IndexStatus: aws.String(dynamodb.IndexStatusCreating), Backfilling: aws.Bool(true), ItemCount: aws.Int64(40)},
+		{IndexStatus: aws.String(dynamodb.IndexStatusActive), ItemCount: aws.Int64(100)},
+	}
+	polls := 0
+	db := &stubDB{
+		describeTable: func(in *dynamodb.DescribeTableInput) (*dynamodb.DescribeTableOutput, error) {
+			gsi := states[polls]
+			gsi.IndexName = aws.String("name-index")
+			polls++
+			return &dynamodb.DescribeTableOutput{Table: &dynamodb.TableDescription{
+				TableStatus:            aws.String(dynamodb.TableStatusActive),
+				ItemCount:              aws.Int64(100),
+				GlobalSecondaryIndexes: []*dynamodb.GlobalSecondaryIndexDescription{gsi},
+			}}, nil
+		},
+	}
+	var progress []IndexProgress
+	err := table.WaitForIndexActive(ctx, db, "name-index",
+		WaitInterval(time.Millisecond),
+		WaitProgress(func(p IndexProgress) { progress = append(progress, p) }),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, []IndexProgress{
+		{IndexName: "name-index", Status: "CREATING", Backfilling: true, TableItems: 100},
+		{IndexName: "name-index", Status: "CREATING", Backfilling: true, ItemCount: 40, TableItems: 100},
+		{IndexName: "name-index", Status: "ACTIVE", ItemCount: 100, TableItems: 100},
+	}, progress)
+
+	// Missing and deleted indexes fail fast
+	polls = 0
+	err = table.WaitForIndexActive(ctx, db, "other-index")
+	assert.EqualError(t, err, "WaitForIndexActive users: table has no index other-index.")
+	polls, states[0].IndexStatus = 0, aws.String(dynamodb.IndexStatusDeleting)
+	err = table.WaitForIndexActive(ctx, db, "name-index")
+	assert.EqualError(t, err, "WaitForIndexActive users: index name-index is being deleted.")
+
+	// The context deadline bounds the wait
+	polls, states[0].IndexStatus = 0, aws.String(dynamodb.IndexStatusCreating)
+	states = []*dynamodb.GlobalSecondaryIndexDescription{states[0], states[0], states[0]}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Millisecond)
+	defer cancel()
+	err = table.WaitForIndexActive(ctx, db, "name-index", WaitInterval(time.Hour))
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Equal(t, 1, polls)
+}
+
+func TestTableDefaults(t *testing.T) {
+	table := NewUserTable()
+	ctx := context.Background()
+	key := KeyValue{"name@email.com", "password"}
+
+	g := table.GetItem(key).Build()
+	assert.Nil(t, g.ConsistentRead)
+	assert.Equal(t, "INDEXES", *g.ReturnConsumedCapacity)
+
+	var applied []string
+	option := func(name string) request.Option {
+		return func(*request.Request) { applied = append(applied, name) }
+	}
+	table.Defaults = TableDefaults{
+		ConsistentRead:         true,
+		ReturnConsumedCapacity: "TOTAL",
+		Options:                []request.Option{option("default")},
+	}
+
+	g = table.GetItem(key).Build()
+	assert.True(t, *g.ConsistentRead)
+	assert.Equal(t, "TOTAL", *g.ReturnConsumedCapacity)
+	assert.False(t, *table.GetItem(key).SetConsistentRead(false).Build().ConsistentRead)
+
+	q, err := table.Query(table.emailField.Equals("name@email.com"), nil).Build()
+	assert.NoError(t, err)
+	assert.True(t, *q.ConsistentRead)
+	assert.Equal(t, "TOTAL", *q.ReturnConsumedCapacity)
+	q, err = table.Query(table.emailField.Equals("name@email.com"), nil).SetConsistentRead(false).Build()
+	assert.NoError(t, err)
+	assert.False(t, *q.ConsistentRead)
+	s, err := table.Scan().Build()
+	assert.NoError(t, err)
+	assert.True(t, *s.ConsistentRead)
+	s, err = table.Scan().SetConsistentRead(false).Build()
+	assert.NoError(t, err)
+	assert.False(t, *s.ConsistentRead)
+
+	b, err := table.BatchGetItem(key).Build()
+	assert.NoError(t, err)
+	assert.True(t, *b[0].RequestItems["users"].ConsistentRead)
+	assert.Equal(t, "TOTAL", *b[0].ReturnConsumedCapacity)
+	b, err = table.BatchGetItem(key).SetConsistentRead(false).Build()
+	assert.NoError(t, err)
+	assert.False(t, *b[0].RequestItems["users"].ConsistentRead)
+
+	db := &stubDB{
+		getItem: func(*dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) { return &dynamodb.GetItemOutput{}, nil },
+		query:   func(*dynamodb.QueryInput) (*dynamodb.QueryOutput, error) { return &dynamodb.QueryOutput{}, nil },
+	}
+	apply := func() []string {
+		applied = nil
+		for _, o := range db.opts {
+			o(&request.Request{})
+		}
+		return applied
+	}
+	table.GetItem(key).ExecuteWith(ctx, db, option("call"))
+	assert.Equal(t, []string{"default", "call"}, apply())
+	table.Query(table.emailField.Equals("name@email.com"), nil).ExecuteWith(ctx, db).ResultsList()
+	assert.Equal(t, []string{"default"}, apply())
+}
+
+func TestKeyOf(t *testing.T) {
+	table := NewUserTable()
+
+	key, err := table.KeyOf(User{Email: "name@email.com", Password: "password", LoginCount: 3})
+	assert.NoError(t, err)
+	assert.Equal(t, KeyValue{"name@email.com", "password"}, key)
+
+	g, err := table.GetItemOf(&User{Email: "name@email.com", Password: "password"})
+	assert.NoError(t, err)
+	assert.Equal(t, table.GetItem(KeyValue{"name@email.com", "password"}).Build(), g.Build())
+
+	d, err := table.DeleteItemOf(User{Email: "name@email.com", Password: "password"})
+	assert.NoError(t, err)
+	want, err := table.DeleteItem(KeyValue{"name@email.com", "password"}).Build()
+	assert.NoError(t, err)
+	got, err := d.Build()
+	assert.NoError(t, err)
+	assert.Equal(t, want, got)
+
+	_, err = table.KeyOf(User{Email: "name@email.com"})
+	assert.EqualError(t, err, "KeyOf users: item is missing key attribute password.")
+
+	events := DynamoTable{Name: "events", PartitionKey: NumericField("id"), RangeKey: EmptyField()}
+	key, err = events.KeyOf(map[string]interface{}{"id": int64(1<<53 + 1)})
+	assert.NoError(t, err)
+	assert.Equal(t, "9007199254740993", *events.GetItem(key).Build().Key["id"].N)
+	assert.Nil(t, key.RangeKey)
+}
+
+func TestCompositeKeys(t *testing.T) {
+	sk := StringField("sk")
+
+	assert.Equal(t, "ORDER#1520168767#42", sk.Composite("ORDER", 1520168767, 42))
+	assert.Equal(t, []string{"ORDER", "1520168767", "42"}, sk.SplitComposite("ORDER#1520168767#42"))
+	assert.Equal(t, []string{"USER"}, sk.SplitComposite("USER"))
+
+	for _, segments := range [][]interface{}{
+		{"USER", "a#b", `c\d`, `e\#f`, "", "#"},
+		{"x", `\`},
+	} {
+		var want []string
+		for _, s := range segments {
+			want = append(want, fmt.Sprint(s))
+		}
+		assert.Equal(t, want, sk.SplitComposite(sk.Composite(segments...)))
+	}
+
+	piped := sk.WithSeparator("||")
+	assert.Equal(t, "USER||a#b", piped.Composite("USER", "a#b"))
+	assert.Equal(t, []string{"USER", "a||b"}, piped.SplitComposite(piped.Composite("USER", "a||b")))
+
+	table := NewUserTable()
+	kc := sk.BeginsWithComposite("ORDER", 1520168767)
+	q, err := table.Query(table.emailField.Equals("USER#1"), &kc).Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "email = :cond_email_0 AND begins_with(sk,:cond_sk_1)", *q.KeyConditionExpression)
+	assert.Equal(t, "ORDER#1520168767#", *q.ExpressionAttributeValues[":cond_sk_1"].S)
+}
+
+func TestCopyTable(t *testing.T) {
+	retryBackoff = time.Millisecond
+	src := NewUserTable()
+	dst := NewUserTable()
+	dst.Name = "dynamo-test-copy"
+
+	pages := pagedItems(60, 20)
+	written := map[string]int{}
+	retried := false
+	db := &stubDB{
+		scan: func(in *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+			assert.Equal(t, src.Name, *in.TableName)
+			assert.Equal(t, int64(2), *in.TotalSegments)
+			if *in.Segment == 1 {
+				return &dynamodb.ScanOutput{}, nil
+			}
+			page := 0
+			if in.ExclusiveStartKey != nil {
+				page, _ = strconv.Atoi(*in.ExclusiveStartKey["page"].N)
+			}
+			out := &dynamodb.ScanOutput{Items: pages[page]}
+			if page < len(pages)-1 {
+				out.LastEvaluatedKey = map[string]*dynamodb.AttributeValue{"page": {N: aws.String(strconv.Itoa(page + 1))}}
+			}
+			return out, nil
+		},
+		batchWriteItem: func(in *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+			requests := in.RequestItems[dst.Name]
+			assert.True(t, len(requests) <= 25)
+			out := &dynamodb.BatchWriteItemOutput{}
+			if !retried {
+				retried = true
+				out.UnprocessedItems = map[string][]*dynamodb.WriteRequest{dst.Name: requests[1:]}
+				requests = requests[:1]
+			}
+			for _, r := range requests {
+				written[*r.PutRequest.Item["password"].S]++
+			}
+			return out, nil
+		},
+	}
+
+	var last CopyProgress
+	progress, err := CopyTable(context.Background(), db, src.DynamoTable, dst.DynamoTable,
+		CopySegments(2),
+		CopyTransform(func(item DynamoDBValue) (DynamoDBValue, bool) {
+			return item, *item["password"].S != "password0"
+		}),
+		CopyProgressHandler(func(p CopyProgress) { last = p }),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, CopyProgress{Scanned: 60, Written: 59, Skipped: 1}, progress)
+	assert.Equal(t, progress, last)
+	assert.Len(t, written, 59)
+	for _, n := range written {
+		assert.Equal(t, 1, n)
+	}
+
+	db.batchWriteItem = func(in *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+		return &dynamodb.BatchWriteItemOutput{UnprocessedItems: in.RequestItems}, nil
+	}
+	_, err = CopyTable(context.Background(), db, src.DynamoTable, dst.DynamoTable, CopySegments(2), CopyMaxRetries(2))
+	assert.Error(t, err)
+
+	/*Copied items go through dst's hooks*/
+	var tagged int
+	db.batchWriteItem = func(in *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+		for _, r := range in.RequestItems[dst.Name] {
+			if *r.PutRequest.Item["copied"].BOOL {
+				tagged++
+			}
+		}
+		return &dynamodb.BatchWriteItemOutput{}, nil
+	}
+	var after int
+	hooked := dst.DynamoTable.
+		BeforePut(func(item DynamoDBValue) error {
+			item["copied"] = &dynamodb.AttributeValue{BOOL: aws.Bool(true)}
+			return nil
+		}).
+		AfterWrite(func(op string, key DynamoDBValue, err error) {
+			assert.Equal(t, "PutItem", op)
+			assert.NoError(t, err)
+			after++
+		})
+	progress, err = CopyTable(context.Background(), db, src.DynamoTable, hooked, CopySegments(2))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(60), progress.Written)
+	assert.Equal(t, 60, tagged)
+	assert.Equal(t, 60, after)
+	assert.Nil(t, pages[0][0]["copied"])
+}
+
+func TestDeleteByQuery(t *testing.T) {
+	retryBackoff = time.Millisecond
+	table := NewUserTable()
+	pages := pagedItems(60, 30)
+	db := &stubDB{
+		query: func(in *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+			assert.Equal(t, "#key0, #key1", *in.ProjectionExpression)
+			assert.Equal(t, "email", *in.ExpressionAttributeNames["#key0"])
+			assert.Equal(t, "password", *in.ExpressionAttributeNames["#key1"])
+			assert.NotNil(t, in.FilterExpression)
+			page := 0
+			if in.ExclusiveStartKey != nil {
+				page = 1
+			}
+			out := &dynamodb.QueryOutput{Items: pages[page]}
+			if page == 0 {
+				out.LastEvaluatedKey = map[string]*dynamodb.AttributeValue{"page": {N: aws.String("1")}}
+			}
+			return out, nil
+		},
+	}
+
+	var deletes []string
+	db.batchWriteItem = func(in *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+		for _, r := range in.RequestItems[table.Name] {
+			assert.Len(t, r.DeleteRequest.Key, 2)
+			deletes = append(deletes, *r.DeleteRequest.Key["password"].S)
+		}
+		return &dynamodb.BatchWriteItemOutput{}, nil
+	}
+	filter := BulkFilter(table.loginCount.Equals(0))
+
+	deleted, err := table.DeleteByQuery(context.Background(), db, table.emailField.Equals("name@email.com"), nil, filter, BulkDryRun())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(60), deleted)
+	assert.Empty(t, deletes)
+
+	deleted, err = table.DeleteByQuery(context.Background(), db, table.emailField.Equals("name@email.com"), nil, filter)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(60), deleted)
+	assert.Len(t, deletes, 60)
+
+	batches := 0
+	db.batchWriteItem = func(in *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+		if batches++; batches > 1 {
+			return &dynamodb.BatchWriteItemOutput{UnprocessedItems: in.RequestItems}, nil
+		}
+		return &dynamodb.BatchWriteItemOutput{}, nil
+	}
+
+	/*After write hooks see the deletes sent, and the ones left unprocessed as failed*/
+	succeeded, failed := 0, 0
+	hooked := table.DynamoTable.AfterWrite(func(op string, key DynamoDBValue, err error) {
+		assert.Equal(t, "DeleteItem", op)
+		assert.Len(t, key, 2)
+		if err != nil {
+			failed++
+		} else {
+			succeeded++
+		}
+	})
+	deleted, err = hooked.DeleteByQuery(context.Background(), db, table.emailField.Equals("name@email.com"), nil, filter, BulkMaxRetries(1))
+	assert.Error(t, err)
+	assert.Equal(t, int64(25), deleted)
+	assert.Equal(t, 25, succeeded)
+	assert.Equal(t, 5, failed)
+}
+
+func TestQueryKeys(t *testing.T) {
+	ctx := context.Background()
+	table := NewUserTable()
+
+	/*The stub only returns the projected attributes*/
+	var projected []string
+	user := func(email, password, lastName string) map[string]interface{} {
+		return map[string]interface{}{"email": email, "password": password, "firstName": "bob", "lastName": lastName, "registrationDate": 1, "loginCount": 3}
+	}
+	pages := [][]map[string]interface{}{
+		{user("name@email.com", "a", "smith"), user("name@email.com", "b", "jones")},
+		{user("other@email.com", "c", "brown")},
+	}
+	db := &stubDB{
+		query: func(in *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+			assert.Nil(t, in.AttributesToGet)
+			projected = nil
+			for _, p := range strings.Split(*in.ProjectionExpression, ", ") {
+				projected = append(projected, *in.ExpressionAttributeNames[p])
+			}
+			page := 0
+			if in.ExclusiveStartKey != nil {
+				page = 1
+			}
+			out := &dynamodb.QueryOutput{}
+			for _, u := range pages[page] {
+				av, _ := dynamodbattribute.MarshalMap(u)
+				item := DynamoDBValue{}
+				for _, name := range projected {
+					item[name] = av[name]
+				}
+				out.Items = append(out.Items, item)
+			}
+			if page == 0 {
+				out.LastEvaluatedKey = DynamoDBValue{"email": {S: aws.String("name@email.com")}, "password": {S: aws.String("b")}}
+			}
+			return out, nil
+		},
+	}
+
+	q := table.Query(table.emailField.Equals("name@email.com"), nil).SetAttributesToGet([]DynamoField{table.lastName.DynamoField})
+	keys, lastKey, err := q.QueryKeys(ctx, db)
+	assert.NoError(t, err)
+	assert.Nil(t, lastKey)
+	assert.Equal(t, []string{"email", "password"}, projected)
+	assert.Equal(t, []KeyValue{{"name@email.com", "a"}, {"name@email.com", "b"}, {"other@email.com", "c"}}, keys)
+	assert.Nil(t, q.ProjectionExpression)
+	assert.Len(t, q.AttributesToGet, 1)
+
+	keys, lastKey, err = table.Query(table.emailField.Equals("name@email.com"), nil).SetLimit(1).QueryKeys(ctx, db)
+	assert.NoError(t, err)
+	assert.Equal(t, []KeyValue{{"name@email.com", "a"}}, keys)
+	assert.Equal(t, DynamoDBValue{"email": {S: aws.String("name@email.com")}, "password": {S: aws.String("a")}}, lastKey)
+
+	/*Reading an index projects its keys too, so the last key resumes the index query*/
+	keys, lastKey, err = table.QueryIndex(table.nameGlobalIndex, table.name.Equals("bob"), nil).SetLimit(1).QueryKeys(ctx, db)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"email", "password", "firstName", "lastName"}, projected)
+	assert.Equal(t, []KeyValue{{"name@email.com", "a"}}, keys)
+	assert.Equal(t, DynamoDBValue{
+		"email":     {S: aws.String("name@email.com")},
+		"password":  {S: aws.String("a")},
+		"firstName": {S: aws.String("bob")},
+		"lastName":  {S: aws.String("smith")},
+	}, lastKey)
+
+	/*Keys shared with the table are projected once*/
+	_, _, err = table.QueryLocalIndex(table.registrationDateIndex, table.emailField.Equals("name@email.com"), nil).QueryKeys(ctx, db)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"email", "password", "registrationDate"}, projected)
+
+	_, _, err = table.Query(table.emailField.Equals("name@email.com"), nil).SetIndexName("missing-index").QueryKeys(ctx, db)
+	assert.EqualError(t, err, "Query users: table has no index missing-index.")
+}
+
+func TestUpdateByQuery(t *testing.T) {
+	retryBackoff = time.Millisecond
+	table := NewUserTable()
+	pages := pagedItems(40, 20)
+	db := &stubDB{
+		query: func(in *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+			assert.Equal(t, "#key0, #key1", *in.ProjectionExpression)
+			page := 0
+			if in.ExclusiveStartKey != nil {
+				page = 1
+			}
+			out := &dynamodb.QueryOutput{Items: pages[page]}
+			if page == 0 {
+				out.LastEvaluatedKey = map[string]*dynamodb.AttributeValue{"page": {N: aws.String("1")}}
+			}
+			return out, nil
+		},
+	}
+
+	var mutex sync.Mutex
+	attempts := map[string]int{}
+	db.updateItem = func(in *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		assert.Equal(t, "SET loginCount = :update_loginCount_100", *in.UpdateExpression)
+		assert.Equal(t, "(attribute_exists(email) AND attribute_exists(password)) AND registrationDate < :cond_registrationDate_1", *in.ConditionExpression)
+		assert.Equal(t, dynamodb.ReturnConsumedCapacityTotal, *in.ReturnConsumedCapacity)
+
+		password := *in.Key["password"].S
+		attempts[password]++
+		switch {
+		case password == "password1" && attempts[password] == 1:
+			return nil, awserr.New(dynamodb.ErrCodeProvisionedThroughputExceededException, "slow down", nil)
+		case password == "password2":
+			return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "failed", nil)
+		}
+		return &dynamodb.UpdateItemOutput{}, nil
+	}
+
+	/*Every update goes through the table's hooks*/
+	after := map[string]error{}
+	hooked := table.DynamoTable.
+		BeforeUpdate(func(in *dynamodb.UpdateItemInput) error {
+			if *in.Key["password"].S == "password3" {
+				return errors.New("refused")
+			}
+			in.ReturnConsumedCapacity = aws.String(dynamodb.ReturnConsumedCapacityTotal)
+			return nil
+		}).
+		AfterWrite(func(op string, key DynamoDBValue, err error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			assert.Equal(t, "UpdateItem", op)
+			after[*key["password"].S] = err
+		})
+
+	result, err := hooked.UpdateByQuery(table.emailField.Equals("name@email.com"), nil, table.loginCount.Equals(0), table.loginCount.SetField(1, false)).
+		SetConditionExpression(table.registrationDate.LessThan(3)).
+		SetConcurrency(4).
+		ExecuteWith(context.Background(), db)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(40), result.Matched)
+	assert.Equal(t, int64(38), result.Updated)
+	assert.Equal(t, int64(2), result.Failed)
+	assert.Equal(t, 2, attempts["password1"])
+	assert.Equal(t, 0, attempts["password3"])
+	assert.Len(t, after, 39)
+	assert.NoError(t, after["password1"])
+	assert.Error(t, after["password2"])
+
+	var failed []string
+	for _, key := range result.FailedKeys {
+		failed = append(failed, *key["password"].S)
+	}
+	sort.Strings(failed)
+	assert.Equal(t, []string{"password2", "password3"}, failed)
+}
+
+func TestExists(t *testing.T) {
+	table := NewUserTable()
+	db := &stubDB{}
+	db.getItem = func(in *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+		if in.ProjectionExpression != nil {
+			assert.Equal(t, "#key0", *in.ProjectionExpression)
+			assert.Equal(t, "email", *in.ExpressionAttributeNames["#key0"])
+		}
+		if *in.Key["password"].S == "missing" {
+			return &dynamodb.GetItemOutput{}, nil
+		}
+		return &dynamodb.GetItemOutput{Item: map[string]*dynamodb.AttributeValue{"email": in.Key["email"]}}, nil
+	}
+
+	exists, err := table.Exists(context.Background(), db, KeyValue{"name@email.com", "password"})
+	assert.NoError(t, err)
+	assert.True(t, exists)
+
+	exists, err = table.Exists(context.Background(), db, KeyValue{"name@email.com", "missing"})
+	assert.NoError(t, err)
+	assert.False(t, exists)
+	assert.False(t, table.GetItem(KeyValue{"name@email.com", "missing"}).ExecuteWith(context.Background(), db).Found())
+
+	db.getItem = func(in *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+		return nil, awserr.New(dynamodb.ErrCodeResourceNotFoundException, "no table", nil)
+	}
+	exists, err = table.Exists(context.Background(), db, KeyValue{"name@email.com", "password"})
+	assert.Error(t, err)
+	assert.False(t, exists)
+}
+
+func TestFieldTypes(t *testing.T) {
+	fields := map[string]DynamoFieldIFace{
+		"NULL": EmptyField(),
+		"N":    NumericField("n"),
+		"NS":   NumericSetField("ns"),
+		"S":    StringField("s"),
+		"BOOL": BoolField("bool"),
+		"B":    BinaryField("b"),
+		"BS":   BinarySetField("bs"),
+		"SS":   StringSetField("ss"),
+		"L":    ListField("l"),
+		"M":    MapField("m"),
+	}
+	for expected, f := range fields {
+		assert.Equal(t, expected, f.Type(), "%T", f)
+	}
+	assert.Equal(t, "N", TimeField("t", EpochSeconds).Type())
+	assert.Equal(t, "N", TimeField("t", EpochMillis).Type())
+	assert.Equal(t, "N", TimeField("t", EpochNanos).Type())
+	assert.Equal(t, "S", TimeField("t", RFC3339).Type())
+}
+
+func TestHashOnlyGlobalIndex(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	db := NewDB()
+	table := NewUserTable()
+	loginIndex := GlobalSecondaryIndex{
+		Name:         "loginCount-index",
+		PartitionKey: table.loginCount,
+		RangeKey:     EmptyField(),
+	}
+	table.GlobalSecondaryIndexes = append(table.GlobalSecondaryIndexes, loginIndex)
+
+	input := table.CreateTable().Build()
+	for _, gsi := range input.GlobalSecondaryIndexes {
+		if *gsi.IndexName == loginIndex.Name {
+			assert.Len(t, gsi.KeySchema, 1)
+		}
+	}
+
+	table.DynamoTable = disposableTable(t, db, table.DynamoTable)
+	defer dispose(db, table.DynamoTable)
+
+	err := table.PutItem(User{Email: "naveen@email.com", Password: "password", LoginCount: 7}).ExecuteWith(ctx, db).Result(nil)
+	assert.NoError(t, err)
+
+	var users []*User
+	err = table.Query(table.loginCount.Equals(7), nil).
+		SetGlobalIndex(loginIndex).
+		ExecuteWith(ctx, db).
+		Results(func() interface{} {
+			u := &User{}
+			users = append(users, u)
+			return u
+		})
+	assert.NoError(t, err)
+	assert.Equal(t, []*User{{Email: "naveen@email.com", Password: "password", LoginCount: 7}}, users)
+}
+
+func TestIncludeProjectionAttributeDefinitions(t *testing.T) {
+	table := NewUserTable()
+	table.LocalSecondaryIndexes[0].ProjectionType = ProjectionTypeINCLUDE
+	table.LocalSecondaryIndexes[0].NonKeyAttributes = []DynamoFieldIFace{table.loginCount}
+	table.GlobalSecondaryIndexes[0].ProjectionType = ProjectionTypeINCLUDE
+	table.GlobalSecondaryIndexes[0].NonKeyAttributes = []DynamoFieldIFace{table.visits, table.preferences}
+
+	input := table.CreateTable().Build()
+	var defined []string
+	for _, a := range input.AttributeDefinitions {
+		defined = append(defined, *a.AttributeName)
+	}
+	assert.ElementsMatch(t, []string{"email", "password", "registrationDate", "firstName", "lastName"}, defined)
+	assert.Equal(t, []*string{aws.String("loginCount")}, input.LocalSecondaryIndexes[0].Projection.NonKeyAttributes)
+	assert.Equal(t, []*string{aws.String("visits"), aws.String("preferences")}, input.GlobalSecondaryIndexes[0].Projection.NonKeyAttributes)
+}
+
+func TestKeyMarshalingErrors(t *testing.T) {
+	table := NewUserTable()
+	ctx := context.Background()
+	db := &stubDB{} // Any request reaching dynamo would panic on the missing handler
+	key := KeyValue{"name@email.com", make(chan int)}
+
+	err := table.GetItem(key).ExecuteWith(ctx, db).Result(&User{})
+	assertMarshalError(t, err)
+
+	err = table.DeleteItem(key).ExecuteWith(ctx, db).Result(nil)
+	assertMarshalError(t, err)
+
+	_, err = table.UpdateItem(key).SetUpdateExpression(table.loginCount.Increment(1)).Build()
+	assertMarshalError(t, err)
+	err = table.UpdateItem(key).SetUpdateExpression(table.loginCount.Increment(1)).ExecuteWith(ctx, db).Result(nil)
+	assertMarshalError(t, err)
+
+	_, err = table.TransactGetItems(key).Build()
+	assertMarshalError(t, err)
+
+	_, err = table.TransactWriteItems().DeleteItem(key).Build()
+	assertMarshalError(t, err)
+}
+
+func assertMarshalError(t *testing.T, err error) {
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Cannot marshal password: Go value type chan int is not supported.")
+	}
+}
+
+func TestKeyValidation(t *testing.T) {
+	table := NewUserTable()
+	ctx := context.Background()
+	db := &stubDB{} // Any request reaching dynamo would panic on the missing handler
+
+	err := table.GetItem(KeyValue{"name@email.com", nil}).ExecuteWith(ctx, db).Result(nil)
+	assert.EqualError(t, err, "GetItem users: missing range key password.")
+
+	err = table.DeleteItem(KeyValue{"", "password"}).ExecuteWith(ctx, db).Result(nil)
+	assert.EqualError(t, err, "DeleteItem users: missing partition key email.")
+
+	_, err = table.UpdateItem(KeyValue{"name@email.com", aws.String("")}).Build()
+	assert.EqualError(t, err, "UpdateItem users: missing range key password.")
+
+	_, err = table.BatchGetItem(KeyValue{"name@email.com", "password"}, KeyValue{PartitionKey: "name@email.com"}).Build()
+	assert.EqualError(t, err, "BatchGetItem users: missing range key password.")
+
+	_, err = table.BatchWriteItem().DeleteItems(KeyValue{PartitionKey: "name@email.com"}).Build()
+	assert.EqualError(t, err, "DeleteItems users: missing range key password.")
+
+	events := DynamoTable{Name: "events", PartitionKey: NumericField("id"), RangeKey: EmptyField()}
+	err = events.GetItem(KeyValue{1, "extra"}).ExecuteWith(ctx, db).Result(nil)
+	assert.EqualError(t, err, "GetItem events: table has no range key, but extra was given.")
+
+	g := events.GetItem(KeyValue{PartitionKey: 0}).Build()
+	assert.Equal(t, "0", *g.Key["id"].N)
+
+	/*The value given in the range key slot of a range less table*/
+	err = events.GetItem(KeyValue{RangeKey: 7}).ExecuteWith(ctx, db).Result(nil)
+	assert.EqualError(t, err, "GetItem events: table has no range key, but 7 was given in its place. Use PK(7).")
+
+	g = events.GetItem(PK(7)).Build()
+	assert.Equal(t, "7", *g.Key["id"].N)
+	assert.Len(t, g.Key, 1)
+
+	_, err = table.UpdateItem(PK("name@email.com")).Build()
+	assert.EqualError(t, err, "UpdateItem users: missing range key password.")
+
+	_, err = table.UpdateItem(PKRK("name@email.com", "password")).SetUpdateExpression(table.loginCount.Increment(1)).Build()
+	assert.NoError(t, err)
+
+	_, err = table.TransactGetItems(PKRK("name@email.com", "password"), PK("name@email.com")).Build()
+	assert.EqualError(t, err, "TransactGetItems users: missing range key password.")
+
+	_, err = table.TransactWriteItems().
+		UpdateItem(PK("name@email.com"), table.loginCount.Increment(1)).
+		Build()
+	assert.EqualError(t, err, "TransactWriteItems users: missing range key password.")
+
+	_, err = events.TransactWriteItems().
+		DeleteItem(PKRK(1, "extra")).
+		Build()
+	assert.EqualError(t, err, "TransactWriteItems events: table has no range key, but extra was given.")
+}
+
+func TestBinaryKeyValidation(t *testing.T) {
+	hash, chunk := BinaryField("hash"), BinaryField("chunk")
+	blobs := DynamoTable{Name: "blobs", PartitionKey: hash, RangeKey: chunk}
+	sum := [4]byte{0xde, 0xad, 0xbe, 0xef}
+
+	g := blobs.GetItem(KeyValue{sum, []byte{1}}).Build()
+	assert.Equal(t, sum[:], g.Key["hash"].B)
+	assert.Equal(t, []byte{1}, g.Key["chunk"].B)
+
+	err := blobs.GetItem(KeyValue{string(sum[:]), []byte{1}}).ExecuteWith(context.Background(), &stubDB{}).Result(nil)
+	assert.EqualError(t, err, "GetItem blobs: partition key hash has type B, but \xde\xad\xbe\xef was given.")
+	_, err = blobs.BatchGetItem(KeyValue{sum[:], "1"}).Build()
+	assert.EqualError(t, err, "BatchGetItem blobs: range key chunk has type B, but 1 was given.")
+	_, err = blobs.BatchGetItem(KeyValue{sum[:], []byte{}}).Build()
+	assert.EqualError(t, err, "BatchGetItem blobs: missing range key chunk.")
+
+	/*Repeated keys, binary ones included, are requested once*/
+	batches, err := blobs.BatchGetItem(PKRK(sum, []byte{1}), PKRK(sum[:], []byte{1}), PKRK(sum[:], []byte{2})).Build()
+	assert.NoError(t, err)
+	assert.Len(t, batches[0].RequestItems["blobs"].Keys, 2)
+
+	events := DynamoTable{Name: "events", PartitionKey: StringField("id"), RangeKey: EmptyField()}
+	_, err = events.BatchWriteItem().DeleteItems(PK(sum[:])).Build()
+	assert.EqualError(t, err, "DeleteItems events: partition key id has type S, but 0xdeadbeef was given.")
+	_, err = events.BatchWriteItem().DeleteItems(KeyValue{RangeKey: sum[:]}).Build()
+	assert.EqualError(t, err, "DeleteItems events: table has no range key, but 0xdeadbeef was given in its place. Use PK(0xdeadbeef).")
+
+	b, err := blobs.BatchWriteItem().DeleteItems(PKRK(sum, []byte{1}), PKRK(sum[:], []byte{2})).Build()
+	assert.NoError(t, err)
+	writes := b[0].RequestItems["blobs"]
+	assert.Len(t, writes, 2)
+	assert.Equal(t, sum[:], writes[0].DeleteRequest.Key["hash"].B)
+	assert.Equal(t, []byte{2}, writes[1].DeleteRequest.Key["chunk"].B)
+
+	/*Binary prefixes of binary range keys*/
+	prefix := chunk.BeginsWith([]byte{0})
+	q, err := blobs.Query(hash.Equals(sum[:]), &prefix).WithStartKeyValue(PKRK(sum[:], []byte{0, 1}), nil).Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "hash = :cond_hash_0 AND begins_with(chunk,:cond_chunk_1)", *q.KeyConditionExpression)
+	assert.Equal(t, []byte{0}, q.ExpressionAttributeValues[":cond_chunk_1"].B)
+	assert.Equal(t, []byte{0, 1}, q.ExclusiveStartKey["chunk"].B)
+
+	_, err = blobs.Query(hash.Equals(sum[:]), nil).WithStartKeyValue(PKRK(sum[:], 1), nil).Build()
+	assert.EqualError(t, err, "WithStartKeyValue blobs: range key chunk has type B, but 1 was given.")
+
+	key, err := blobs.KeyOf(map[string]interface{}{"hash": sum, "chunk": []byte{1}, "body": "x"})
+	assert.NoError(t, err)
+	assert.Equal(t, KeyValue{sum[:], []byte{1}}, key)
+}
+
+func TestBinaryKeyTable(t *testing.T) {
+	t.Parallel()
+	db := NewDB()
+	ctx := context.Background()
+	hash, chunk := BinaryField("hash"), BinaryField("chunk")
+	blobs := disposableTable(t, db, DynamoTable{Name: "blobs", PartitionKey: hash, RangeKey: chunk})
+	defer dispose(db, blobs)
+
+	sum := []byte{0xde, 0xad, 0xbe, 0xef, 0x00}
+	var items []interface{}
+	for i := byte(0); i < 5; i++ {
+		items = append(items, map[string]interface{}{"hash": sum, "chunk": []byte{0, i}, "body": fmt.Sprintf("chunk %d", i)})
+	}
+	items = append(items, map[string]interface{}{"hash": sum, "chunk": []byte{1, 0}, "body": "trailer"})
+	assert.NoError(t, blobs.BatchWriteItem().PutItems(items...).ExecuteWith(ctx, db).Results(nil))
+
+	out := blobs.GetItem(PKRK(sum, []byte{0, 3})).ExecuteWith(ctx, db)
+	assert.NoError(t, out.Error())
+	assert.True(t, out.Found())
+	body, _ := DynamoDBValue(out.Item).GetString("body")
+	assert.Equal(t, "chunk 3", body)
+	assert.Equal(t, PKRK(sum, []byte{0, 3}), DynamoDBValue(out.Item).Key(blobs))
+
+	/*Duplicate binary keys are requested once*/
+	var got []DynamoDBValue
+	err := blobs.BatchGetItem(PKRK(sum, []byte{0, 1}), PKRK(sum, []byte{1, 0}), PKRK(append([]byte(nil), sum...), []byte{0, 1})).
+		ExecuteWith(ctx, db).
+		Results(func() interface{} {
+			got = append(got, DynamoDBValue{})
+			return &got[len(got)-1]
+		})
+	assert.NoError(t, err)
+	assert.Len(t, got, 2)
+
+	/*Page through the prefixed chunks, resuming from each page's binary start key*/
+	prefix := chunk.BeginsWith([]byte{0})
+	var bodies []string
+	var start DynamoDBValue
+	for {
+		q := blobs.Query(hash.Equals(sum), &prefix).SetLimit(2).SinglePage()
+		if start != nil {
+			q.WithStartKeyValue(start.Key(blobs), nil)
+		}
+		page, last, err := q.ExecuteWith(ctx, db).ResultsList()
+		assert.NoError(t, err)
+		for _, item := range page {
+			b, _ := item.GetString("body")
+			bodies = append(bodies, b)
+		}
+		if last == nil {
+			break
+		}
+		start = last
+	}
+	assert.Equal(t, []string{"chunk 0", "chunk 1", "chunk 2", "chunk 3", "chunk 4"}, bodies)
+
+	err = blobs.BatchWriteItem().DeleteItems(PKRK(sum, []byte{0, 0}), PKRK(sum, []byte{1, 0})).ExecuteWith(ctx, db).Results(nil)
+	assert.NoError(t, err)
+	remaining, _, err := blobs.Query(hash.Equals(sum), nil).ExecuteWith(ctx, db).ResultsList()
+	assert.NoError(t, err)
+	assert.Len(t, remaining, 4)
+	assert.Equal(t, []byte{0, 1}, remaining[0]["chunk"].B)
+}
+
+func TestQueryIndex(t *testing.T) {
+	table := NewUserTable()
+
+	smith := table.lastName.Equals("smith")
+	q, err := table.QueryIndex(table.nameGlobalIndex, table.name.Equals("bob"), &smith).Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "name-index", *q.IndexName)
+	assert.Equal(t, "firstName = :cond_firstName_0 AND lastName = :cond_lastName_1", *q.KeyConditionExpression)
+
+	_, err = table.QueryIndex(table.nameGlobalIndex, table.emailField.Equals("name@email.com"), nil).Build()
+	assert.EqualError(t, err, "QueryIndex users index name-index: partition key condition is on email, expected firstName.")
+
+	password := table.passwordField.Equals("password")
+	_, err = table.QueryIndex(table.nameGlobalIndex, table.name.Equals("bob"), &password).Build()
+	assert.EqualError(t, err, "QueryIndex users index name-index: range key condition is on password, expected lastName.")
+
+	since := table.registrationDate.GreaterThan(10)
+	q, err = table.QueryLocalIndex(table.registrationDateIndex, table.emailField.Equals("name@email.com"), &since).Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "registrationDate-index", *q.IndexName)
+
+	_, err = table.QueryLocalIndex(table.registrationDateIndex, table.emailField.Equals("name@email.com"), &password).Build()
+	assert.EqualError(t, err, "QueryLocalIndex users index registrationDate-index: range key condition is on password, expected registrationDate.")
+
+	byName := GlobalSecondaryIndex{Name: "firstName-index", PartitionKey: table.name, RangeKey: EmptyField()}
+	_, err = table.QueryIndex(byName, table.name.Equals("bob"), nil).Build()
+	assert.EqualError(t, err, "QueryIndex users: table has no index firstName-index.")
+
+	withIndex := table
+	withIndex.GlobalSecondaryIndexes = append([]GlobalSecondaryIndex{byName}, table.GlobalSecondaryIndexes...)
+	_, err = withIndex.QueryIndex(byName, table.name.Equals("bob"), &smith).Build()
+	assert.EqualError(t, err, "QueryIndex users index firstName-index: index has no range key, but a range key condition on lastName was given.")
+
+	/*Global indexes ignore the table's consistent read default*/
+	withIndex.Defaults.ConsistentRead = true
+	q, err = withIndex.QueryIndex(byName, table.name.Equals("bob"), nil).Build()
+	assert.NoError(t, err)
+	assert.Nil(t, q.ConsistentRead)
+
+	s, err := withIndex.ScanIndex(table.nameGlobalIndex).Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "name-index", *s.IndexName)
+	assert.Nil(t, s.ConsistentRead)
+
+	s, err = withIndex.ScanLocalIndex(table.registrationDateIndex).Build()
+	assert.NoError(t, err)
+	assert.True(t, *s.ConsistentRead)
+
+	_, err = table.ScanIndex(byName).Build()
+	assert.EqualError(t, err, "ScanIndex users: table has no index firstName-index.")
+}
+
+func TestIndexByName(t *testing.T) {
+	table := NewUserTable()
+
+	gsi, ok := table.GSI("name-index")
+	assert.True(t, ok)
+	assert.Equal(t, table.nameGlobalIndex, gsi)
+	_, ok = table.GSI("registrationDate-index")
+	assert.False(t, ok)
+
+	lsi, ok := table.LSI("registrationDate-index")
+	assert.True(t, ok)
+	assert.Equal(t, table.registrationDateIndex, lsi)
+	_, ok = table.LSI("missing-index")
+	assert.False(t, ok)
+
+	/*The index kind comes from the table definition*/
+	q := table.Query(table.name.Equals("bob"), nil).SetIndexName("name-index")
+	assert.Equal(t, &secondaryIndex{name: "name-index", partitionKey: table.name, rangeKey: table.lastName, global: true}, q.index)
+	built, err := q.Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "name-index", *built.IndexName)
+
+	_, err = q.SetConsistentRead(true).Build()
+	assert.EqualError(t, err, "Cannot query global secondary index name-index with consistent reads, only local indexes support them.")
+
+	s, err := table.Scan().SetIndexName("registrationDate-index").SetConsistentRead(true).Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "registrationDate-index", *s.IndexName)
+
+	_, err = table.Scan().SetIndexName("name-index").SetConsistentRead(true).Build()
+	assert.EqualError(t, err, "Cannot scan global secondary index name-index with consistent reads, only local indexes support them.")
+
+	_, err = table.Query(table.name.Equals("bob"), nil).SetIndexName("missing-index").Build()
+	assert.EqualError(t, err, "Query users: table has no index missing-index.")
+
+	_, err = table.Scan().SetIndexName("missing-index").Build()
+	assert.EqualError(t, err, "Scan users: table has no index missing-index.")
+
+	/*Start keys are checked against the named index*/
+	_, err = table.Query(table.emailField.Equals("name@email.com"), nil).
+		SetIndexName("registrationDate-index").
+		WithLastEvaluatedKey(DynamoDBValue{"email": {S: aws.String("name@email.com")}, "password": {S: aws.String("password")}}).
+		Build()
+	assert.EqualError(t, err, "Query users index registrationDate-index: exclusive start key is missing registrationDate.")
+}
+
+func TestStartKeyValidation(t *testing.T) {
+	table := NewUserTable()
+	email := &dynamodb.AttributeValue{S: aws.String("name@email.com")}
+	password := &dynamodb.AttributeValue{S: aws.String("password")}
+	query := func() *QueryInput {
+		return table.Query(table.emailField.Equals("name@email.com"), nil)
+	}
+
+	_, err := query().WithLastEvaluatedKey(DynamoDBValue{"email": email, "password": password}).Build()
+	assert.NoError(t, err)
+
+	_, err = query().WithLastEvaluatedKey(DynamoDBValue{"email": email}).Build()
+	assert.EqualError(t, err, "Query users: exclusive start key is missing password.")
+
+	_, err = table.Scan().WithLastEvaluatedKey(DynamoDBValue{"email": email, "password": password, "page": password}).Build()
+	assert.EqualError(t, err, "Scan users: exclusive start key has extra page.")
+
+	/*Resuming an index read needs the index's keys too. Keys shared with the table count once*/
+	_, err = query().
+		SetLocalIndex(table.registrationDateIndex).
+		WithLastEvaluatedKey(DynamoDBValue{"email": email, "password": password}).
+		Build()
+	assert.EqualError(t, err, "Query users index registrationDate-index: exclusive start key is missing registrationDate.")
+
+	_, err = query().
+		SetLocalIndex(table.registrationDateIndex).
+		WithLastEvaluatedKey(DynamoDBValue{"email": email, "password": password, "registrationDate": {N: aws.String("1")}}).
+		Build()
+	assert.NoError(t, err)
+
+	_, err = table.Scan().
+		SetGlobalIndex(table.nameGlobalIndex).
+		WithLastEvaluatedKey(DynamoDBValue{"password": password, "firstName": email, "size": password}).
+		Build()
+	assert.EqualError(t, err, "Scan users index name-index: exclusive start key is missing email, lastName and has extra size.")
+
+	/*An index set through the sdk is looked up by name, and unknown ones aren't checked*/
+	q := query().WithLastEvaluatedKey(DynamoDBValue{"email": email, "password": password})
+	q.QueryInput.SetIndexName("name-index")
+	_, err = q.Build()
+	assert.EqualError(t, err, "Query users index name-index: exclusive start key is missing firstName, lastName.")
+
+	q.QueryInput.SetIndexName("unknown-index")
+	_, err = q.Build()
+	assert.NoError(t, err)
+}
+
+func TestExpressionLimits(t *testing.T) {
+	table := NewUserTable()
+
+	names := make([]interface{}, 101)
+	for i := range names {
+		names[i] = "name" + strconv.Itoa(i)
+	}
+	_, err := table.Query(table.emailField.Equals("name@email.com"), nil).
+		SetFilterExpression(table.name.In(names...)).
+		Build()
+	assert.EqualError(t, err, "Query filter expression exceeds the IN operands limit: 101 > 100.")
+
+	_, err = table.Query(table.emailField.Equals("name@email.com"), nil).
+		SetFilterExpression(table.name.In(names[:100]...)).
+		Build()
+	assert.NoError(t, err)
+
+	/*A filter built from many clauses blows the 4KB expression limit*/
+	var clauses []Expression
+	for i := 0; i < 300; i++ {
+		clauses = append(clauses, table.registrationDate.Equals(i))
+	}
+	_, err = table.Scan().SetFilterExpression(Or(clauses...)).Build()
+	limitErr, ok := err.(*ExpressionLimitError)
+	assert.True(t, ok)
+	assert.Equal(t, "Scan", limitErr.Op)
+	assert.Equal(t, "filter", limitErr.Expression)
+	assert.Equal(t, "length", limitErr.Limit)
+	assert.Equal(t, 4096, limitErr.Max)
+
+	_, err = table.PutItem(User{Email: "name@email.com", Password: "password"}).
+		SetConditionExpression(Or(clauses...)).
+		Build()
+	limitErr, ok = err.(*ExpressionLimitError)
+	assert.True(t, ok)
+	assert.Equal(t, "PutItem condition", limitErr.Op+" "+limitErr.Expression)
+
+	_, err = table.DeleteItem(KeyValue{"name@email.com", "password"}).
+		SetConditionExpression(Or(clauses...)).
+		Build()
+	limitErr, ok = err.(*ExpressionLimitError)
+	assert.True(t, ok)
+	assert.Equal(t, "DeleteItem condition", limitErr.Op+" "+limitErr.Expression)
+
+	var updates []*UpdateExpression
+	for i := 0; i < 300; i++ {
+		updates = append(updates, table.lastLoginDate.SetField(i, false))
+	}
+	_, err = table.UpdateItem(KeyValue{"name@email.com", "password"}).
+		SetUpdateExpression(updates...).
+		Build()
+	limitErr, ok = err.(*ExpressionLimitError)
+	assert.True(t, ok)
+	assert.Equal(t, "UpdateItem update", limitErr.Op+" "+limitErr.Expression)
+
+	/*Limits surface through transactions too*/
+	_, err = table.TransactWriteItems().
+		ConditionCheck(KeyValue{"name@email.com", "password"}, table.name.In(names...)).
+		Build()
+	assert.EqualError(t, err, "ConditionCheck condition expression exceeds the IN operands limit: 101 > 100.")
+
+	/*Deeply nested document paths*/
+	path := "a"
+	for i := 0; i < 32; i++ {
+		path += ".b"
+	}
+	assert.EqualError(t, validateExpression("Query", "projection", path), "Query projection expression exceeds the document path depth limit: 33 > 32.")
+	assert.NoError(t, validateExpression("Query", "projection", path[2:]))
+}
+
+func TestResultOK(t *testing.T) {
+	table := NewUserTable()
+	ctx := context.Background()
+	key := KeyValue{"name@email.com", "password"}
+	stored := map[string]*dynamodb.AttributeValue{
+		"email":    {S: aws.String("name@email.com")},
+		"password": {S: aws.String("password")},
+	}
+	var item map[string]*dynamodb.AttributeValue
+	db := &stubDB{
+		getItem: func(*dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+			return &dynamodb.GetItemOutput{Item: item}, nil
+		},
+		deleteItem: func(*dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
+			return &dynamodb.DeleteItemOutput{Attributes: item}, nil
+		},
+		updateItem: func(*dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+			return &dynamodb.UpdateItemOutput{Attributes: item}, nil
+		},
+	}
+
+	user := &User{}
+	found, err := table.GetItem(key).ExecuteWith(ctx, db).ResultOK(user)
+	assert.NoError(t, err)
+	assert.False(t, found)
+	found, err = table.DeleteItem(key).ReturnNone().ExecuteWith(ctx, db).ResultOK(user)
+	assert.NoError(t, err)
+	assert.False(t, found)
+	found, err = table.UpdateItem(key).SetUpdateExpression(table.loginCount.Increment(1)).ReturnNone().ExecuteWith(ctx, db).ResultOK(user)
+	assert.NoError(t, err)
+	assert.False(t, found)
+	assert.Equal(t, &User{}, user)
+
+	item = stored
+	found, err = table.GetItem(key).ExecuteWith(ctx, db).ResultOK(user)
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, &User{Email: "name@email.com", Password: "password"}, user)
+	found, err = table.DeleteItem(key).ReturnAllOld().ExecuteWith(ctx, db).ResultOK(&User{})
+	assert.True(t, found)
+	found, err = table.UpdateItem(key).SetUpdateExpression(table.loginCount.Increment(1)).ReturnAllNew().ExecuteWith(ctx, db).ResultOK(&User{})
+	assert.True(t, found)
+
+	db.getItem = func(*dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+		return nil, awserr.New(dynamodb.ErrCodeResourceNotFoundException, "no table", nil)
+	}
+	found, err = table.GetItem(key).ExecuteWith(ctx, db).ResultOK(user)
+	assert.Error(t, err)
+	assert.False(t, found)
+}
+
+func TestResultDeserializationErrors(t *testing.T) {
+	table := NewUserTable()
+	ctx := context.Background()
+	key := KeyValue{"name@email.com", "password"}
+	// loginCount is stored as a string, which an int field can't hold
+	stored := map[string]*dynamodb.AttributeValue{"loginCount": {S: aws.String("many")}}
+	db := &stubDB{
+		getItem: func(*dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+			return &dynamodb.GetItemOutput{Item: stored}, nil
+		},
+		putItem: func(*dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+			return &dynamodb.PutItemOutput{Attributes: stored}, nil
+		},
+		deleteItem: func(*dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
+			return &dynamodb.DeleteItemOutput{Attributes: stored}, nil
+		},
+		updateItem: func(*dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+			return &dynamodb.UpdateItemOutput{Attributes: stored}, nil
+		},
+	}
+
+	outputs := map[string]interface {
+		Result(interface{}) error
+		Error() error
+	}{
+		"get":    table.GetItem(key).ExecuteWith(ctx, db),
+		"put":    table.PutItem(User{Email: "name@email.com", Password: "password"}).ReturnAllOld().ExecuteWith(ctx, db),
+		"delete": table.DeleteItem(key).ReturnAllOld().ExecuteWith(ctx, db),
+		"update": table.UpdateItem(key).SetUpdateExpression(table.loginCount.Increment(1)).ReturnAllNew().ExecuteWith(ctx, db),
+	}
+	for name, out := range outputs {
+		err := out.Result(&User{})
+		assert.Error(t, err, name)
+		assert.Equal(t, err, out.Error(), name)
+	}
+}
+
+func TestOutputInterface(t *testing.T) {
+	outputs := []Output{
+		&GetOutput{}, &BatchGetOutput{}, &TransactGetOutput{}, &PutOutput{}, &TransactWriteItemsOutput{},
+		&BatchWriteOutput{}, &DeleteItemOutput{}, &UpdateOutput{}, &QueryOutput{}, &ScanOutput{},
+	}
+	assert.Len(t, outputs, 10)
+
+	table := NewUserTable()
+	ctx := context.Background()
+	units := func(u float64) *dynamodb.ConsumedCapacity {
+		return &dynamodb.ConsumedCapacity{CapacityUnits: aws.Float64(u)}
+	}
+	pages := pagedItems(30, 10)
+	db := &stubDB{
+		getItem: func(*dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+			return &dynamodb.GetItemOutput{ConsumedCapacity: units(0.5)}, nil
+		},
+		query: func(in *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+			i := pageIndex(in.ExclusiveStartKey)
+			out := &dynamodb.QueryOutput{Items: pages[i], ConsumedCapacity: units(1.5)}
+			if i+1 < len(pages) {
+				out.LastEvaluatedKey = pageKey(i + 1)
+			}
+			return out, nil
+		},
+		updateItem: func(*dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+			return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "failed", nil)
+		},
+	}
+	metrics := func(o Output) (float64, int, bool) {
+		return o.ConsumedCapacityUnits(), o.Attempts(), o.ConditionalCheckFailed()
+	}
+
+	units1, attempts, failed := metrics(table.GetItem(KeyValue{"name@email.com", "password"}).ExecuteWith(ctx, db))
+	assert.Equal(t, 0.5, units1)
+	assert.Equal(t, 1, attempts)
+	assert.False(t, failed)
+
+	q := table.Query(table.emailField.Equals("name@email.com"), nil).ExecuteWith(ctx, db)
+	assert.NoError(t, q.Results(func() interface{} { return &User{} }))
+	units1, attempts, failed = metrics(q)
+	assert.Equal(t, 4.5, units1)
+	assert.Equal(t, 3, attempts)
+
+	units1, attempts, failed = metrics(table.UpdateItem(KeyValue{"name@email.com", "password"}).
+		SetUpdateExpression(table.loginCount.Increment(1)).
+		ExecuteWith(ctx, db))
+	assert.Equal(t, 0.0, units1)
+	assert.Equal(t, 1, attempts)
+	assert.True(t, failed)
+}
+
+func TestExportedBuilders(t *testing.T) {
+	table := NewUserTable()
+	// Builders can be passed to and returned from helpers
+	audited := func(q *PutInput) *PutInput {
+		return q.SetConditionExpression(table.emailField.Exists())
+	}
+	var pending struct {
+		put *PutInput
+		get *GetInput
+	}
+	pending.put = audited(table.PutItem(User{Email: "name@email.com", Password: "password"}))
+	pending.get = table.GetItem(KeyValue{"name@email.com", "password"})
+
+	assert.Equal(t, "attribute_exists(email)", *builtPut(t, pending.put).ConditionExpression)
+	assert.Equal(t, "name@email.com", *pending.get.Build().Key["email"].S)
+
+	var _ *BatchGetInput = table.BatchGetItem()
+	var _ *BatchWriteInput = table.BatchWriteItem()
+	var _ *TransactGetInput = table.TransactGetItems()
+	var _ *TransactWriteItemsInput = table.TransactWriteItems()
+	var _ *DeleteItemInput = table.DeleteItem(KeyValue{"name@email.com", "password"})
+	var _ *CreateTableInput = table.CreateTable()
+	var _ *DeleteTableInput = table.DeleteTable()
+}
+
+func benchmarkScan(b *testing.B, consume func(*ScanOutput) error) {
+	table := NewUserTable()
+	calls := 0
+	db := newPagedStub(pagedItems(1000, 100), &calls)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := consume(table.Scan().ExecuteWith(context.Background(), db)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkScanResults(b *testing.B) {
+	benchmarkScan(b, func(out *ScanOutput) error {
+		return out.Results(func() interface{} { return &User{} })
+	})
+}
+
+func BenchmarkStreamWithChannel(b *testing.B) {
+	benchmarkScan(b, func(out *ScanOutput) error {
+		channel := make(chan *User)
+		errChan := out.StreamWithChannel(channel)
+		for range channel {
+		}
+		return <-errChan
+	})
+}
+
+func BenchmarkScanResultsFunc(b *testing.B) {
+	benchmarkScan(b, func(out *ScanOutput) error {
+		return out.ResultsFunc(func(av DynamoDBValue) error { return nil })
+	})
+}
+
+func TestResultsFunc(t *testing.T) {
+	table := NewUserTable()
+	calls := 0
+	db := newPagedStub(pagedItems(30, 10), &calls)
+
+	var passwords []string
+	err := table.Scan().SetLimit(25).ExecuteWith(context.Background(), db).ResultsFunc(func(av DynamoDBValue) error {
+		passwords = append(passwords, *av["password"].S)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Len(t, passwords, 25)
+	assert.Equal(t, "password24", passwords[24])
+
+	stop := fmt.Errorf("stop")
+	count := 0
+	out := table.Query(table.emailField.Equals("name@email.com"), nil).ExecuteWith(context.Background(), db)
+	err = out.ResultsFunc(func(av DynamoDBValue) error {
+		if count++; count == 15 {
+			return stop
+		}
+		return nil
+	})
+	assert.Equal(t, stop, err)
+	assert.Equal(t, "password13", *out.LastEvaluatedKey()["password"].S)
+}
+
+func TestCachedStructDecoding(t *testing.T) {
+	type base struct {
+		ID string `dynamodbav:"id"`
+	}
+	type tagged struct {
+		Name     string `json:"name"`
+		Renamed  int    `dynamodbav:"count,omitempty"`
+		Skipped  string `dynamodbav:"-"`
+		Untagged bool
+		hidden   string
+	}
+	type embedded struct {
+		base
+		Name string `dynamodbav:"name"`
+	}
+	av := DynamoDBValue{
+		"id":       {S: aws.String("1")},
+		"name":     {S: aws.String("n")},
+		"count":    {N: aws.String("3")},
+		"Skipped":  {S: aws.String("s")},
+		"-":        {S: aws.String("s")},
+		"untagged": {BOOL: aws.Bool(true)},
+		"hidden":   {S: aws.String("h")},
+	}
+
+	for _, decoder := range []*dynamodbattribute.Decoder{nil, dynamodbattribute.NewDecoder()} {
+		var cached, sdk tagged
+		assert.NoError(t, deserializeTo(decoder, av, &cached))
+		assert.NoError(t, dynamodbattribute.UnmarshalMap(av, &sdk))
+		assert.Equal(t, tagged{Name: "n", Renamed: 3, Untagged: true}, cached)
+		assert.Equal(t, sdk, cached)
+
+		var e embedded
+		assert.NoError(t, deserializeTo(decoder, av, &e))
+		assert.Equal(t, embedded{base{"1"}, "n"}, e)
+	}
+	assert.Nil(t, decodableFields(reflect.TypeOf(&embedded{}), dynamodbattribute.NewDecoder().MarshalOptions))
+
+	var mismatched tagged
+	assert.Error(t, deserializeTo(nil, DynamoDBValue{"count": {S: aws.String("many")}}, &mismatched))
+}
+
+func TestDebugString(t *testing.T) {
+	table := NewUserTable()
+
+	c := And(
+		table.emailField.Equals("name@email.com"),
+		Or(table.registrationDate.Between(1, 10), table.loginCount.In(1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11)),
+	)
+	assert.Equal(t, `email = "name@email.com" AND ((registrationDate between 1 and 10) OR (loginCount in (1,2,3,4,5,6,7,8,9,10,11)))`, c.DebugString())
+
+	assert.Equal(t, "loginCount = :cond_loginCount_0", table.loginCount.Equals(3).String())
+	assert.Equal(t, "loginCount = 3", table.loginCount.Equals(3).DebugString())
+	assert.Equal(t, "NOT attribute_exists(email)", Not(table.emailField.Exists()).DebugString())
+
+	u := table.preferences.Set("theme", map[string]interface{}{"dark": true, "font": []int{12, 14}})
+	assert.Equal(t, "SET preferences.theme = :update_preferences_theme_100", u.String())
+	assert.Equal(t, "SET preferences.theme = {dark: true, font: [12, 14]}", u.DebugString())
+	assert.Equal(t, `ADD locales {"en", "fr"}`, table.locales.AddStrings([]string{"en", "fr"}).DebugString())
+	assert.Equal(t, "ADD degrees {0x000102030405060708090a0b0c0d0e0f...(20 bytes)}",
+		table.degrees.AddBinary(make20Bytes()).DebugString())
+
+	q := table.Query(table.emailField.Equals("name@email.com"), nil).SetFilterExpression(table.loginCount.GreaterThan(2))
+	assert.Equal(t, `KEY email = "name@email.com" FILTER loginCount > 2`, q.DebugString())
+	s := table.Scan().SetFilterExpression(table.lastName.BeginsWith("Sm"))
+	assert.Equal(t, `FILTER begins_with(lastName,"Sm")`, s.DebugString())
+
+	update := table.UpdateItem(KeyValue{"name@email.com", "password"}).
+		SetUpdateExpression(table.loginCount.SetField(1, false)).
+		SetConditionExpression(table.loginCount.Equals(0))
+	assert.Equal(t, `KEY {email: "name@email.com", password: "password"} SET loginCount = 1 CONDITION loginCount = 0`, update.DebugString())
+}
+
+func make20Bytes() []byte {
+	b := make([]byte, 20)
+	for i := range b {
+		b[i] = byte(i)
+	}
+	return b
+}
+
+func TestFromSDKExpression(t *testing.T) {
+	table := NewUserTable()
+
+	sdk, err := expression.NewBuilder().
+		WithCondition(expression.Name("name").Equal(expression.Value("bob")).
+			And(expression.Name("visits").GreaterThan(expression.Value(3)))).
+		Build()
+	assert.NoError(t, err)
+
+	p, err := table.PutItem(User{Email: "name@email.com", Password: "password"}).
+		SetConditionExpression(table.loginCount.Equals(1)).
+		SetConditionExpression(FromSDKExpression(sdk)).
+		SetConditionExpression(table.registrationDate.Exists()).
+		Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "loginCount = :cond_loginCount_1 AND ((#cond_2 = :cond_3) AND (#cond_4 > :cond_5)) AND attribute_exists(registrationDate)", *p.ConditionExpression)
+	assert.Equal(t, map[string]*string{
+		"#cond_2": aws.String("name"),
+		"#cond_4": aws.String("visits"),
+	}, p.ExpressionAttributeNames)
+	assert.Equal(t, DynamoDBValue{
+		":cond_loginCount_1": &dynamodb.AttributeValue{N: aws.String("1")},
+		":cond_3":            &dynamodb.AttributeValue{S: aws.String("bob")},
+		":cond_5":            &dynamodb.AttributeValue{N: aws.String("3")},
+	}, DynamoDBValue(p.ExpressionAttributeValues))
+
+	filter, err := expression.NewBuilder().
+		WithFilter(expression.Name("name").BeginsWith("b")).
+		Build()
+	assert.NoError(t, err)
+	s, err := table.Scan().SetFilterExpression(FromSDKExpression(filter)).Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "begins_with (#filter_1, :filter_2)", *s.FilterExpression)
+
+	_, err = FromSDKExpression(sdk).ToSDKBuilder()
+	assert.Error(t, err)
+}
+
+func TestToSDKBuilder(t *testing.T) {
+	table := NewUserTable()
+
+	c, err := Or(
+		table.loginCount.Between(1, 5),
+		And(table.locales.Contains("en-US"), Not(table.registrationDate.Exists())),
+		table.locales.Size(">", 2),
+		table.visits.In(1, 2),
+	).ToSDKBuilder()
+	assert.NoError(t, err)
+
+	e, err := expression.NewBuilder().WithCondition(c).Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "(#0 BETWEEN :0 AND :1) OR ((contains (#1, :2)) AND (NOT (attribute_exists (#2)))) OR (size (#1) > :3) OR (#3 IN (:4, :5))", *e.Condition())
+	assert.Equal(t, map[string]*string{
+		"#0": aws.String("loginCount"),
+		"#1": aws.String("locales"),
+		"#2": aws.String("registrationDate"),
+		"#3": aws.String("visits"),
+	}, e.Names())
+
+	/*A lone expression in a group converts to itself*/
+	c, err = And(table.loginCount.Equals(1)).ToSDKBuilder()
+	assert.NoError(t, err)
+	e, err = expression.NewBuilder().WithCondition(c).Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "#0 = :0", *e.Condition())
+
+	_, err = table.locales.Contains(1).ToSDKBuilder()
+	assert.Error(t, err)
+	_, err = And().ToSDKBuilder()
+	assert.Error(t, err)
+}
+
+func TestConsistentReadOnGlobalIndex(t *testing.T) {
+	table := NewUserTable()
+	ctx := context.Background()
+
+	_, err := table.Query(table.name.Equals("bob"), nil).
+		SetGlobalIndex(table.nameGlobalIndex).
+		SetConsistentRead(true).
+		Build()
+	assert.EqualError(t, err, "Cannot query global secondary index name-index with consistent reads, only local indexes support them.")
+
+	_, err = table.Scan().SetGlobalIndex(table.nameGlobalIndex).SetConsistentRead(true).Build()
+	assert.Error(t, err)
+
+	out := table.Query(table.name.Equals("bob"), nil).
+		SetGlobalIndex(table.nameGlobalIndex).
+		SetConsistentRead(true).
+		ExecuteWith(ctx, &stubDB{})
+	assert.Error(t, out.Error())
+	_, _, err = out.ResultsList()
+	assert.Error(t, err)
+
+	q, err := table.Query(table.name.Equals("bob"), nil).SetGlobalIndex(table.nameGlobalIndex).Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "name-index", *q.IndexName)
+
+	/*Local indexes support consistent reads*/
+	kc := table.registrationDate.GreaterThan(0)
+	q, err = table.Query(table.emailField.Equals("name@email.com"), &kc).
+		SetLocalIndex(table.registrationDateIndex).
+		SetConsistentRead(true).
+		Build()
+	assert.NoError(t, err)
+	assert.True(t, *q.ConsistentRead)
+}
+
+func TestWithStartKeyValue(t *testing.T) {
+	table := NewUserTable()
+
+	q, err := table.Query(table.emailField.Equals("name@email.com"), nil).
+		WithStartKeyValue(KeyValue{"name@email.com", "password"}, nil).
+		Build()
+	assert.NoError(t, err)
+	assert.Equal(t, DynamoDBValue{
+		"email":    &dynamodb.AttributeValue{S: aws.String("name@email.com")},
+		"password": &dynamodb.AttributeValue{S: aws.String("password")},
+	}, DynamoDBValue(q.ExclusiveStartKey))
+
+	/*Global index start keys need both the table and index keys*/
+	s, err := table.Scan().
+		SetGlobalIndex(table.nameGlobalIndex).
+		WithStartKeyValue(KeyValue{"name@email.com", "password"}, &KeyValue{"Bob", "Smith"}).
+		Build()
+	assert.NoError(t, err)
+	assert.Equal(t, DynamoDBValue{
+		"email":     &dynamodb.AttributeValue{S: aws.String("name@email.com")},
+		"password":  &dynamodb.AttributeValue{S: aws.String("password")},
+		"firstName": &dynamodb.AttributeValue{S: aws.String("Bob")},
+		"lastName":  &dynamodb.AttributeValue{S: aws.String("Smith")},
+	}, DynamoDBValue(s.ExclusiveStartKey))
+
+	/*A local index shares the table's partition key, so it may be left out*/
+	kc := table.registrationDate.GreaterThan(0)
+	q, err = table.Query(table.emailField.Equals("name@email.com"), &kc).
+		SetLocalIndex(table.registrationDateIndex).
+		WithStartKeyValue(KeyValue{"name@email.com", "password"}, &KeyValue{RangeKey: 1520168767}).
+		Build()
+	assert.NoError(t, err)
+	assert.Equal(t, DynamoDBValue{
+		"email":            &dynamodb.AttributeValue{S: aws.String("name@email.com")},
+		"password":         &dynamodb.AttributeValue{S: aws.String("password")},
+		"registrationDate": &dynamodb.AttributeValue{N: aws.String("1520168767")},
+	}, DynamoDBValue(q.ExclusiveStartKey))
+
+	_, err = table.Query(table.emailField.Equals("name@email.com"), nil).
+		WithStartKeyValue(KeyValue{PartitionKey: "name@email.com"}, nil).
+		Build()
+	assert.EqualError(t, err, "WithStartKeyValue users: missing range key password.")
+
+	_, err = table.Scan().
+		SetGlobalIndex(table.nameGlobalIndex).
+		WithStartKeyValue(KeyValue{"name@email.com", "password"}, nil).
+		Build()
+	assert.EqualError(t, err, "WithStartKeyValue users: missing index key for name-index.")
+
+	_, err = table.Scan().
+		SetGlobalIndex(table.nameGlobalIndex).
+		WithStartKeyValue(KeyValue{"name@email.com", "password"}, &KeyValue{PartitionKey: "Bob"}).
+		Build()
+	assert.EqualError(t, err, "WithStartKeyValue name-index: missing range key lastName.")
+
+	_, err = table.Scan().
+		WithStartKeyValue(KeyValue{"name@email.com", "password"}, &KeyValue{"Bob", "Smith"}).
+		Build()
+	assert.Error(t, err)
+
+	out := table.Scan().
+		WithStartKeyValue(KeyValue{PartitionKey: "name@email.com"}, nil).
+		ExecuteWith(context.Background(), &stubDB{})
+	assert.Error(t, out.Error())
+
+	/*A valid start key doesn't clear an earlier error*/
+	_, err = table.QueryIndex(table.nameGlobalIndex, table.emailField.Equals("name@email.com"), nil).
+		WithStartKeyValue(KeyValue{"name@email.com", "password"}, &KeyValue{"Bob", "Smith"}).
+		Build()
+	assert.EqualError(t, err, "QueryIndex users index name-index: partition key condition is on email, expected firstName.")
+
+	_, err = table.ScanIndex(GlobalSecondaryIndex{Name: "missing-index"}).
+		WithStartKeyValue(KeyValue{"name@email.com", "password"}, nil).
+		Build()
+	assert.EqualError(t, err, "ScanIndex users: table has no index missing-index.")
+}
+
+func TestParallelScanCheckpoints(t *testing.T) {
+	table := NewUserTable()
+
+	/*Each of the 3 segments has 4 pages of 5 items*/
+	segments := [][][]map[string]*dynamodb.AttributeValue{}
+	for i := 0; i < 3; i++ {
+		var pages [][]map[string]*dynamodb.AttributeValue
+		for _, page := range pagedItems(20, 5) {
+			var items []map[string]*dynamodb.AttributeValue
+			for _, item := range page {
+				items = append(items, map[string]*dynamodb.AttributeValue{
+					"email":    {S: aws.String(strconv.Itoa(i))},
+					"password": item["password"],
+				})
+			}
+			pages = append(pages, items)
+		}
+		segments = append(segments, pages)
+	}
+	db := &stubDB{
+		scan: func(in *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+			assert.Equal(t, int64(3), *in.TotalSegments)
+			pages := segments[*in.Segment]
+			page := 0
+			if in.ExclusiveStartKey != nil {
+				page, _ = strconv.Atoi(*in.ExclusiveStartKey["page"].N)
+			}
+			out := &dynamodb.ScanOutput{Items: pages[page]}
+			if page < len(pages)-1 {
+				out.LastEvaluatedKey = map[string]*dynamodb.AttributeValue{"page": {N: aws.String(strconv.Itoa(page + 1))}}
+			}
+			return out, nil
+		},
+	}
+
+	dir, err := ioutil.TempDir("", "domino")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "checkpoints.json")
+
+	var mutex sync.Mutex
+	seen := map[string]int{}
+	handle := func(segment int, items []DynamoDBValue) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		for _, item := range items {
+			seen[*item["email"].S+"/"+*item["password"].S]++
+		}
+	}
+
+	/*Kill the scan while segment 1 handles its third page*/
+	killed := errors.New("killed")
+	handled := 0
+	err = table.Scan().ParallelScan(3).WithCheckpointer(NewFileCheckpointer(path)).
+		ExecuteWith(context.Background(), db, func(segment int, items []DynamoDBValue) error {
+			handle(segment, items)
+			if segment == 1 {
+				if handled++; handled == 3 {
+					return killed
+				}
+			}
+			return nil
+		})
+	assert.Equal(t, killed, err)
+
+	/*A fresh checkpointer resumes from the file*/
+	err = table.Scan().ParallelScan(3).WithCheckpointer(NewFileCheckpointer(path)).
+		ExecuteWith(context.Background(), db, func(segment int, items []DynamoDBValue) error {
+			handle(segment, items)
+			return nil
+		})
+	assert.NoError(t, err)
+
+	assert.Len(t, seen, 60)
+	duplicates := 0
+	for _, n := range seen {
+		duplicates += n - 1
+	}
+	/*At most the page in flight in each segment is handled twice*/
+	assert.True(t, duplicates >= 5 && duplicates <= 15, "%d duplicates", duplicates)
+
+	/*Every segment is done, so a further resume scans nothing*/
+	err = table.Scan().ParallelScan(3).WithCheckpointer(NewFileCheckpointer(path)).
+		ExecuteWith(context.Background(), db, func(segment int, items []DynamoDBValue) error {
+			t.Errorf("segment %d scanned after finishing", segment)
+			return nil
+		})
+	assert.NoError(t, err)
+
+	/*In memory checkpoints resume the same way*/
+	cp := NewMemoryCheckpointer()
+	assert.NoError(t, cp.Save(2, DynamoDBValue{"page": {N: aws.String("3")}}, false))
+	assert.NoError(t, cp.Save(0, nil, true))
+	pages := map[int]int{}
+	err = table.Scan().ParallelScan(3).WithCheckpointer(cp).
+		ExecuteWith(context.Background(), db, func(segment int, items []DynamoDBValue) error {
+			mutex.Lock()
+			defer mutex.Unlock()
+			pages[segment]++
+			return nil
+		})
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]int{1: 4, 2: 1}, pages)
+}
+
+func TestExportJSON(t *testing.T) {
+	table := NewUserTable()
+	ctx := context.Background()
+
+	items := []map[string]*dynamodb.AttributeValue{
+		{
+			"email":    {S: aws.String("name@email.com")},
+			"password": {S: aws.String("password")},
+			"balance":  {N: aws.String("12345678901234567890.123456789")},
+			"avatar":   {B: []byte{0, 1, 2, 255}},
+			"locales":  {SS: aws.StringSlice([]string{"en-US", "fr-FR"})},
+			"degrees":  {NS: aws.StringSlice([]string{"1", "2.5"})},
+			"keys":     {BS: [][]byte{{1}, {2}}},
+			"tags":     {L: []*dynamodb.AttributeValue{{S: aws.String("a")}, {N: aws.String("1")}}},
+			"meta":     {M: map[string]*dynamodb.AttributeValue{"verified": {BOOL: aws.Bool(true)}, "deleted": {NULL: aws.Bool(true)}}},
+		},
+		{
+			"email":    {S: aws.String("other@email.com")},
+			"password": {S: aws.String("password")},
+		},
+	}
+	db := &stubDB{
+		scan: func(in *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+			if in.ExclusiveStartKey == nil {
+				return &dynamodb.ScanOutput{
+					Items:            items[:1],
+					LastEvaluatedKey: map[string]*dynamodb.AttributeValue{"page": {N: aws.String("1")}},
+				}, nil
+			}
+			return &dynamodb.ScanOutput{Items: items[1:]}, nil
+		},
+	}
+
+	/*Raw json round trips every type*/
+	var raw strings.Builder
+	assert.NoError(t, table.Scan().ExecuteWith(ctx, db).ExportJSON(ctx, &raw, JSONRaw()))
+	assert.Equal(t, 2, strings.Count(raw.String(), "\n"))
+	assert.Contains(t, raw.String(), `"avatar":{"B":"AAEC/w=="}`)
+
+	var imported []map[string]*dynamodb.AttributeValue
+	err := ImportJSON(strings.NewReader(raw.String()), func(av DynamoDBValue) error {
+		imported = append(imported, av)
+		return nil
+	}, JSONRaw())
+	assert.NoError(t, err)
+	assert.Equal(t, items, imported)
+
+	/*Plain json keeps number precision, writing binaries as base64*/
+	var plain strings.Builder
+	assert.NoError(t, table.Scan().ExecuteWith(ctx, db).ExportJSON(ctx, &plain))
+	lines := strings.Split(strings.TrimSpace(plain.String()), "\n")
+	assert.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `"balance":12345678901234567890.123456789`)
+	assert.Contains(t, lines[0], `"avatar":"AAEC/w=="`)
+	assert.Contains(t, lines[0], `"degrees":[1,2.5]`)
+	assert.Equal(t, `{"email":"other@email.com","password":"password"}`, lines[1])
+
+	imported = nil
+	err = ImportJSON(strings.NewReader(lines[0]), func(av DynamoDBValue) error {
+		imported = append(imported, av)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, items[0]["balance"], imported[0]["balance"])
+	assert.Equal(t, items[0]["tags"], imported[0]["tags"])
+	assert.Equal(t, items[0]["meta"], imported[0]["meta"])
+	assert.Equal(t, &dynamodb.AttributeValue{S: aws.String("AAEC/w==")}, imported[0]["avatar"])
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.Equal(t, context.Canceled, table.Scan().ExecuteWith(ctx, db).ExportJSON(cancelled, &plain))
+}
+
+func TestTableImportJSON(t *testing.T) {
+	retryBackoff = time.Millisecond
+	table := NewUserTable()
+	ctx := context.Background()
+
+	var lines []string
+	for i := 0; i < 60; i++ {
+		lines = append(lines, fmt.Sprintf(`{"email":{"S":"name@email.com"},"password":{"S":"password%d"},"loginCount":{"N":"%d"}}`, i, i))
+	}
+	lines[10] = `{"email":{"S":"name@email.com"`
+	lines[20] = `{"email":{"S":"name@email.com"}}`
+	lines = append(lines, "")
+	input := strings.Join(lines, "\n")
+
+	written := map[string]int{}
+	db := &stubDB{
+		batchWriteItem: func(in *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+			requests := in.RequestItems[table.Name]
+			assert.True(t, len(requests) <= 25)
+			for _, r := range requests {
+				written[*r.PutRequest.Item["password"].S]++
+				assert.NotNil(t, r.PutRequest.Item["loginCount"].N)
+			}
+			return &dynamodb.BatchWriteItemOutput{}, nil
+		},
+	}
+
+	result, err := table.ImportJSON(ctx, db, strings.NewReader(input), ImportRaw(), ImportConcurrency(3))
+	assert.NoError(t, err)
+	assert.Equal(t, 60, result.Lines)
+	assert.Equal(t, int64(58), result.Imported)
+	assert.Len(t, written, 58)
+	lineErrors := map[int]bool{}
+	for _, e := range result.Errors {
+		lineErrors[e.Line] = true
+	}
+	assert.Equal(t, map[int]bool{11: true, 21: true}, lineErrors)
+
+	/*A dry run only validates*/
+	written = map[string]int{}
+	result, err = table.ImportJSON(ctx, db, strings.NewReader(input), ImportRaw(), ImportDryRun())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(58), result.Imported)
+	assert.Len(t, result.Errors, 2)
+	assert.Empty(t, written)
+
+	/*Existing keys are skipped*/
+	db.putItem = func(in *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+		assert.Equal(t, "attribute_not_exists(#cond_0)", *in.ConditionExpression)
+		assert.Equal(t, "email", *in.ExpressionAttributeNames["#cond_0"])
+		if *in.Item["password"].S == "password1" {
+			return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "exists", nil)
+		}
+		return &dynamodb.PutItemOutput{}, nil
+	}
+	plain := `{"email":"name@email.com","password":"password0","loginCount":1}` + "\n" + `{"email":"name@email.com","password":"password1"}`
+	result, err = table.ImportJSON(ctx, db, strings.NewReader(plain), ImportSkipExisting())
+	assert.NoError(t, err)
+	assert.Equal(t, ImportResult{Lines: 2, Imported: 1, Skipped: 1}, result)
+
+	/*Failed writes are reported against each line of the batch*/
+	db.batchWriteItem = func(in *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+		return nil, errors.New("unavailable")
+	}
+	result, err = table.ImportJSON(ctx, db, strings.NewReader(plain))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), result.Imported)
+	assert.Len(t, result.Errors, 2)
+	assert.EqualError(t, result.Errors[0], "line 1: unavailable")
+
+	/*Imported items are stamped and go through the table's hooks, as puts do*/
+	var events []string
+	hooked := table.DynamoTable.
+		WithTimestamps(NumericField("createdAt"), NumericField("updatedAt"), func() time.Time { return time.Unix(5, 0) }).
+		BeforePut(func(item DynamoDBValue) error {
+			if item["loginCount"] == nil {
+				return errors.New("missing loginCount")
+			}
+			return nil
+		}).
+		AfterWrite(func(op string, key DynamoDBValue, err error) {
+			events = append(events, fmt.Sprintf("%s %s %v", op, *key["password"].S, err))
+		})
+	var stamped []DynamoDBValue
+	db.batchWriteItem = func(in *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+		for _, r := range in.RequestItems[table.Name] {
+			stamped = append(stamped, r.PutRequest.Item)
+		}
+		return &dynamodb.BatchWriteItemOutput{}, nil
+	}
+	result, err = hooked.ImportJSON(ctx, db, strings.NewReader(plain))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), result.Imported)
+	assert.EqualError(t, result.Errors[0], "line 2: missing loginCount")
+	assert.Len(t, stamped, 1)
+	assert.Equal(t, "5", *stamped[0]["createdAt"].N)
+	assert.Equal(t, "5", *stamped[0]["updatedAt"].N)
+	assert.Equal(t, []string{"PutItem password0 <nil>"}, events)
+
+	events = nil
+	result, err = hooked.ImportJSON(ctx, db, strings.NewReader(plain), ImportSkipExisting())
+	assert.NoError(t, err)
+	assert.Equal(t, ImportResult{Lines: 2, Imported: 1, Errors: []ImportLineError{{2, errors.New("missing loginCount")}}}, result)
+	assert.Equal(t, []string{"PutItem password0 <nil>"}, events)
+}
+
+func TestCachedClient(t *testing.T) {
+	table := NewUserTable()
+	ctx := context.Background()
+
+	gets, batchGets := 0, 0
+	stored := map[string]map[string]*dynamodb.AttributeValue{}
+	stub := &stubDB{
+		getItem: func(in *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+			gets++
+			return &dynamodb.GetItemOutput{Item: stored[*in.Key["password"].S]}, nil
+		},
+		batchGetItem: func(in *dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error) {
+			batchGets++
+			out := &dynamodb.BatchGetItemOutput{Responses: map[string][]map[string]*dynamodb.AttributeValue{}}
+			for _, key := range in.RequestItems[table.Name].Keys {
+				if item, ok := stored[*key["password"].S]; ok {
+					out.Responses[table.Name] = append(out.Responses[table.Name], item)
+				}
+			}
+			return out, nil
+		},
+		putItem: func(in *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+			stored[*in.Item["password"].S] = in.Item
+			return &dynamodb.PutItemOutput{}, nil
+		},
+		updateItem: func(in *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+			return &dynamodb.UpdateItemOutput{}, nil
+		},
+		query: func(in *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+			return &dynamodb.QueryOutput{}, nil
+		},
+	}
+	db := NewCachedClient(stub, time.Minute, 100)
+	user := func(password string, count int) User {
+		return User{Email: "name@email.com", Password: password, LoginCount: count}
+	}
+	get := func(password string) *User {
+		u := &User{}
+		found, err := table.GetItem(KeyValue{"name@email.com", password}).SetConsistentRead(false).ExecuteWith(ctx, db).ResultOK(u)
+		assert.NoError(t, err)
+		if !found {
+			return nil
+		}
+		return u
+	}
+
+	assert.NoError(t, table.PutItem(user("a", 1)).ExecuteWith(ctx, db).Error())
+	assert.Equal(t, 1, get("a").LoginCount)
+	assert.Equal(t, 1, get("a").LoginCount)
+	assert.Equal(t, 1, gets)
+	assert.Equal(t, int64(1), db.Hits())
+	assert.Equal(t, int64(1), db.Misses())
+
+	/*Writes invalidate*/
+	assert.NoError(t, table.PutItem(user("a", 2)).ExecuteWith(ctx, db).Error())
+	assert.Equal(t, 2, get("a").LoginCount)
+	assert.Equal(t, 2, gets)
+	table.UpdateItem(KeyValue{"name@email.com", "a"}).SetUpdateExpression(table.loginCount.Increment(1)).ExecuteWith(ctx, db)
+	get("a")
+	assert.Equal(t, 3, gets)
+
+	/*Missing items are cached too*/
+	assert.Nil(t, get("b"))
+	assert.Nil(t, get("b"))
+	assert.Equal(t, 4, gets)
+
+	/*Consistent reads bypass the cache*/
+	table.GetItem(KeyValue{"name@email.com", "a"}).SetConsistentRead(true).ExecuteWith(ctx, db)
+	assert.Equal(t, 5, gets)
+
+	/*Batch gets only fetch the uncached keys*/
+	assert.NoError(t, table.PutItem(user("c", 3)).ExecuteWith(ctx, db).Error())
+	var users []*User
+	err := table.BatchGetItem(KeyValue{"name@email.com", "a"}, KeyValue{"name@email.com", "b"}, KeyValue{"name@email.com", "c"}).
+		SetConsistentRead(false).
+		ExecuteWith(ctx, db).
+		Results(func() interface{} {
+			u := &User{}
+			users = append(users, u)
+			return u
+		})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, batchGets)
+	assert.Equal(t, int64(2), db.Hits()-2)
+	assert.Len(t, users, 2)
+	table.BatchGetItem(KeyValue{"name@email.com", "c"}).SetConsistentRead(false).ExecuteWith(ctx, db).Results(func() interface{} { return &User{} })
+	assert.Equal(t, 1, batchGets)
+
+	/*An item read while a write invalidates its key isn't cached, as it may predate the write*/
+	racing := true
+	stores := func(u User) {
+		stored[u.Password], _ = dynamodbattribute.MarshalMap(u)
+	}
+	getItem, batchGetItem := stub.getItem, stub.batchGetItem
+	stub.getItem = func(in *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+		out, err := getItem(in)
+		if racing {
+			stores(user("d", 5))
+			db.invalidateKey(in.TableName, in.Key)
+		}
+		return out, err
+	}
+	stub.batchGetItem = func(in *dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error) {
+		out, err := batchGetItem(in)
+		if racing {
+			stores(user("e", 6))
+			db.invalidateKey(aws.String(table.Name), in.RequestItems[table.Name].Keys[0])
+		}
+		return out, err
+	}
+	assert.Nil(t, get("d"))
+	racing = false
+	assert.Equal(t, 5, get("d").LoginCount)
+	assert.Equal(t, 5, get("d").LoginCount)
+
+	racing = true
+	assert.NoError(t, table.BatchGetItem(KeyValue{"name@email.com", "e"}).SetConsistentRead(false).ExecuteWith(ctx, db).Error())
+	racing = false
+	users = nil
+	assert.NoError(t, table.BatchGetItem(KeyValue{"name@email.com", "e"}).SetConsistentRead(false).ExecuteWith(ctx, db).
+		Results(func() interface{} {
+			u := &User{}
+			users = append(users, u)
+			return u
+		}))
+	assert.Len(t, users, 1)
+	assert.Equal(t, 3, batchGets)
+	assert.Empty(t, db.fetching)
+
+	/*Everything else passes through*/
+	assert.NoError(t, table.Query(table.emailField.Equals("name@email.com"), nil).ExecuteWith(ctx, db).Error())
+
+	cache := NewMemoryCache(time.Millisecond, 2)
+	cache.Set("a", DynamoDBValue{})
+	cache.Set("b", DynamoDBValue{})
+	cache.Get("a")
+	cache.Set("c", DynamoDBValue{})
+	_, ok := cache.Get("b")
+	assert.False(t, ok, "least recently used entry is evicted")
+	_, ok = cache.Get("a")
+	assert.True(t, ok)
+	time.Sleep(2 * time.Millisecond)
+	_, ok = cache.Get("a")
+	assert.False(t, ok, "entries expire")
+}
+
+/*classicStub implements only the plain calls, blocking queries until release is closed*/
+type classicStub struct {
+	ClassicDynamoIFace
+	release chan struct{}
+}
+
+func (c classicStub) GetItem(in *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	return &dynamodb.GetItemOutput{Item: in.Key}, nil
+}
+
+func (c classicStub) Query(in *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+	<-c.release
+	return &dynamodb.QueryOutput{}, nil
+}
+
+func TestThroughputInfo(t *testing.T) {
+	ctx := context.Background()
+	table := NewUserTable()
+	provisioned := func(r, w int64) *dynamodb.ProvisionedThroughputDescription {
+		return &dynamodb.ProvisionedThroughputDescription{ReadCapacityUnits: aws.Int64(r), WriteCapacityUnits: aws.Int64(w)}
+	}
+	db := &stubDB{
+		describeTable: func(in *dynamodb.DescribeTableInput) (*dynamodb.DescribeTableOutput, error) {
+			assert.Equal(t, "users", *in.TableName)
+			return &dynamodb.DescribeTableOutput{Table: &dynamodb.TableDescription{
+				ProvisionedThroughput: provisioned(10, 5),
+				GlobalSecondaryIndexes: []*dynamodb.GlobalSecondaryIndexDescription{
+					{IndexName: aws.String("nameGlobalIndex"), ProvisionedThroughput: provisioned(4, 2)},
+					{IndexName: aws.String("other"), ProvisionedThroughput: provisioned(1, 1)},
+				},
+			}}, nil
+		},
+		describeLimits: func(*dynamodb.DescribeLimitsInput) (*dynamodb.DescribeLimitsOutput, error) {
+			return &dynamodb.DescribeLimitsOutput{
+				AccountMaxReadCapacityUnits:  aws.Int64(80000),
+				AccountMaxWriteCapacityUnits: aws.Int64(80000),
+				TableMaxReadCapacityUnits:    aws.Int64(40000),
+				TableMaxWriteCapacityUnits:   aws.Int64(40000),
+			}, nil
+		},
+	}
+
+	info, err := table.ThroughputInfo(ctx, db)
+	assert.NoError(t, err)
+	assert.Equal(t, TableThroughput{
+		ReadCapacityUnits:  10,
+		WriteCapacityUnits: 5,
+		Indexes: []IndexThroughput{
+			{IndexName: "nameGlobalIndex", ReadCapacityUnits: 4, WriteCapacityUnits: 2},
+			{IndexName: "other", ReadCapacityUnits: 1, WriteCapacityUnits: 1},
+		},
+		AccountMaxReadCapacityUnits:  80000,
+		AccountMaxWriteCapacityUnits: 80000,
+		TableMaxReadCapacityUnits:    40000,
+		TableMaxWriteCapacityUnits:   40000,
+	}, info)
+	assert.Equal(t, int64(15), info.ReadCapacityUnitsTotal())
+	assert.Equal(t, int64(8), info.WriteCapacityUnitsTotal())
+
+	db.describeTable = func(*dynamodb.DescribeTableInput) (*dynamodb.DescribeTableOutput, error) {
+		return &dynamodb.DescribeTableOutput{Table: &dynamodb.TableDescription{
+			BillingModeSummary:    &dynamodb.BillingModeSummary{BillingMode: aws.String(dynamodb.BillingModePayPerRequest)},
+			ProvisionedThroughput: provisioned(0, 0),
+		}}, nil
+	}
+	info, err = table.ThroughputInfo(ctx, db)
+	assert.NoError(t, err)
+	assert.True(t, info.OnDemand)
+	assert.Equal(t, int64(0), info.ReadCapacityUnitsTotal())
+
+	db.describeLimits = func(*dynamodb.DescribeLimitsInput) (*dynamodb.DescribeLimitsOutput, error) {
+		return nil, awserr.New("AccessDeniedException", "denied", nil)
+	}
+	_, err = table.ThroughputInfo(ctx, db)
+	assert.EqualError(t, err, "AccessDeniedException: denied")
+}
+
+func TestWrapClassic(t *testing.T) {
+	var _ ClassicDynamoIFace = (*dynamodb.DynamoDB)(nil)
+	table := NewUserTable()
+	stub := classicStub{release: make(chan struct{})}
+	defer close(stub.release)
+	db := WrapClassic(stub)
+
+	u := &User{}
+	err := table.GetItem(KeyValue{"name@email.com", "password"}).ExecuteWith(context.Background(), db).Result(u)
+	assert.NoError(t, err)
+	assert.Equal(t, "name@email.com", u.Email)
+
+	/*A blocked call returns once ctx is done*/
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = table.Query(table.emailField.Equals("name@email.com"), nil).ExecuteWith(ctx, db).Results(func() interface{} { return &User{} })
+	assert.Error(t, err)
+	assert.Equal(t, request.CanceledErrorCode, err.(awserr.Error).Code())
+
+	_, err = db.GetItemWithContext(ctx, &dynamodb.GetItemInput{})
+	assert.Error(t, err)
+}
+
+func TestNarrowInterfaces(t *testing.T) {
+	var _ DynamoDBIFace = (*dynamodb.DynamoDB)(nil)
+	table := NewUserTable()
+	ctx := context.Background()
+	stub := &stubDB{
+		getItem: func(in *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+			return &dynamodb.GetItemOutput{Item: in.Key}, nil
+		},
+		putItem: func(in *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+			return &dynamodb.PutItemOutput{}, nil
+		},
+	}
+
+	/*Read only and write only doubles only need the calls they make*/
+	var reader DynamoReader = struct{ DynamoReader }{stub}
+	var writer DynamoWriter = struct{ DynamoWriter }{stub}
+
+	u := &User{}
+	assert.NoError(t, table.GetItem(KeyValue{"name@email.com", "password"}).ExecuteWith(ctx, reader).Result(u))
+	assert.Equal(t, "name@email.com", u.Email)
+	assert.NoError(t, table.PutItem(User{Email: "name@email.com", Password: "password"}).ExecuteWith(ctx, writer).Error())
+}
+
+func TestTransactWriteIdempotency(t *testing.T) {
+	table := NewUserTable()
+	ctx := context.Background()
+	transaction := func(password string) *TransactWriteItemsInput {
+		return table.TransactWriteItems().
+			PutItem(User{Email: "name@email.com", Password: password}).
+			DeleteItem(KeyValue{"other@email.com", password})
+	}
+
+	a, err := transaction("password").SetAutoClientRequestToken().Build()
+	assert.NoError(t, err)
+	b, err := transaction("password").SetAutoClientRequestToken().Build()
+	assert.NoError(t, err)
+	c, err := transaction("other").SetAutoClientRequestToken().Build()
+	assert.NoError(t, err)
+	assert.Len(t, *a.ClientRequestToken, 36)
+	assert.Equal(t, *a.ClientRequestToken, *b.ClientRequestToken)
+	assert.NotEqual(t, *a.ClientRequestToken, *c.ClientRequestToken)
+	assert.Equal(t, dynamodb.ReturnConsumedCapacityIndexes, *a.ReturnConsumedCapacity)
+
+	explicit, err := transaction("password").SetClientRequestToken("token").SetAutoClientRequestToken().Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "token", *explicit.ClientRequestToken)
+
+	/*Dynamo replays a repeated token by consuming only read capacity*/
+	tokens := map[string]bool{}
+	db := &stubDB{
+		transactWrite: func(in *dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error) {
+			capacity := &dynamodb.ConsumedCapacity{Table: &dynamodb.Capacity{WriteCapacityUnits: aws.Float64(4)}}
+			if tokens[*in.ClientRequestToken] {
+				capacity = &dynamodb.ConsumedCapacity{Table: &dynamodb.Capacity{ReadCapacityUnits: aws.Float64(2)}}
+			}
+			tokens[*in.ClientRequestToken] = true
+			return &dynamodb.TransactWriteItemsOutput{ConsumedCapacity: []*dynamodb.ConsumedCapacity{capacity}}, nil
+		},
+	}
+	out := transaction("password").SetAutoClientRequestToken().ExecuteWith(ctx, db)
+	assert.NoError(t, out.Error())
+	assert.False(t, out.IdempotentReplay())
+	out = transaction("password").SetAutoClientRequestToken().ExecuteWith(ctx, db)
+	assert.NoError(t, out.Error())
+	assert.True(t, out.IdempotentReplay())
+
+	/*Timestamped puts and updates are stamped, but a redrive in a later second still derives the same token*/
+	now := int64(1520168767)
+	stamped := table.DynamoTable.WithTimestamps(NumericField("createdAt"), NumericField("updatedAt"), func() time.Time {
+		now++
+		return time.Unix(now, 0)
+	})
+	redrive := func() *dynamodb.TransactWriteItemsInput {
+		in, err := stamped.TransactWriteItems().
+			PutItem(User{Email: "name@email.com", Password: "password"}).
+			UpdateItem(KeyValue{"other@email.com", "password"}, table.loginCount.Increment(1)).
+			SetAutoClientRequestToken().
+			Build()
+		assert.NoError(t, err)
+		return in
+	}
+	first, second := redrive(), redrive()
+	assert.NotNil(t, first.TransactItems[0].Put.Item["createdAt"])
+	assert.NotEqual(t, first.TransactItems[0].Put.Item["updatedAt"], second.TransactItems[0].Put.Item["updatedAt"])
+	assert.Contains(t, *first.TransactItems[1].Update.UpdateExpression, "updatedAt")
+	assert.Equal(t, *first.ClientRequestToken, *second.ClientRequestToken)
+}
+
+func TestTimestamps(t *testing.T) {
+	created, updated := NumericField("createdAt"), NumericField("updatedAt")
+	now := time.Unix(1520168767, 0)
+	table := NewUserTable()
+	stamped := table.DynamoTable.WithTimestamps(created, updated, func() time.Time { return now })
+	stamp := &dynamodb.AttributeValue{N: aws.String("1520168767")}
+
+	p := builtPut(t, stamped.PutItem(User{Email: "name@email.com", Password: "password"}))
+	assert.Equal(t, stamp, p.Item["createdAt"])
+	assert.Equal(t, stamp, p.Item["updatedAt"])
+	assert.Nil(t, builtPut(t, table.PutItem(User{Email: "name@email.com", Password: "password"})).Item["createdAt"])
+
+	/*Values the item carries win*/
+	p = builtPut(t, stamped.PutItem(map[string]interface{}{"email": "name@email.com", "password": "password", "createdAt": 1}))
+	assert.Equal(t, "1", *p.Item["createdAt"].N)
+	assert.Equal(t, stamp, p.Item["updatedAt"])
+
+	batches, err := stamped.BatchWriteItem().PutItems(User{Email: "name@email.com", Password: "password"}).Build()
+	assert.NoError(t, err)
+	assert.Equal(t, stamp, batches[0].RequestItems[table.Name][0].PutRequest.Item["updatedAt"])
+
+	u, err := stamped.UpdateItem(KeyValue{"name@email.com", "password"}).
+		SetUpdateExpression(table.loginCount.Increment(1)).
+		Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "SET updatedAt = :update_updatedAt_101, createdAt = if_not_exists(createdAt,:update_createdAt_102) ADD loginCount :update_loginCount_100", *u.UpdateExpression)
+	assert.Equal(t, stamp, u.ExpressionAttributeValues[":update_updatedAt_101"])
+	assert.Equal(t, stamp, u.ExpressionAttributeValues[":update_createdAt_102"])
+
+	/*An update that sets a timestamp itself keeps it*/
+	u, err = stamped.UpdateItem(KeyValue{"name@email.com", "password"}).
+		SetUpdateExpression(updated.SetField(5, false)).
+		Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "SET updatedAt = :update_updatedAt_100, createdAt = if_not_exists(createdAt,:update_createdAt_101)", *u.UpdateExpression)
+	assert.Equal(t, "5", *u.ExpressionAttributeValues[":update_updatedAt_100"].N)
+
+	/*As does one that sets it through a name placeholder, i.e. a diff*/
+	u, err = stamped.UpdateItem(KeyValue{"name@email.com", "password"}).
+		SetUpdateExpression(MapPath{name: "updatedAt"}.Set(5)).
+		Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "SET #update_100 = :update_updatedAt_101, createdAt = if_not_exists(createdAt,:update_createdAt_102)", *u.UpdateExpression)
+}
+
+func TestWriteHooks(t *testing.T) {
+	ctx := context.Background()
+	type event struct {
+		op  string
+		key string
+		err error
+	}
+	var events []event
+	errNoTenant := errors.New("missing tenantId")
+	table := NewUserTable().DynamoTable.
+		BeforePut(func(item DynamoDBValue) error {
+			if item["tenantId"] == nil {
+				return errNoTenant
+			}
+			return nil
+		}).
+		BeforeUpdate(func(in *dynamodb.UpdateItemInput) error {
+			if in.ConditionExpression == nil {
+				return errors.New("unconditional update")
+			}
+			return nil
+		}).
+		AfterWrite(func(op string, key DynamoDBValue, err error) {
+			events = append(events, event{op, *key["password"].S, err})
+		})
+	untouched := NewUserTable()
+
+	puts := 0
+	db := &stubDB{
+		putItem: func(in *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+			puts++
+			return &dynamodb.PutItemOutput{}, nil
+		},
+		updateItem: func(in *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+			return &dynamodb.UpdateItemOutput{}, nil
+		},
+		deleteItem: func(in *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
+			return nil, errors.New("unavailable")
+		},
+		batchWriteItem: func(in *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+			/*The second put of a batch is left unprocessed*/
+			requests := in.RequestItems[table.Name]
+			if len(requests) < 2 {
+				return &dynamodb.BatchWriteItemOutput{}, nil
+			}
+			return &dynamodb.BatchWriteItemOutput{UnprocessedItems: map[string][]*dynamodb.WriteRequest{
+				table.Name: {{PutRequest: &dynamodb.PutRequest{Item: cloneValue(requests[1].PutRequest.Item)}}},
+			}}, nil
+		},
+	}
+	tenant := func(password string) map[string]interface{} {
+		return map[string]interface{}{"email": "name@email.com", "password": password, "tenantId": "t1"}
+	}
+
+	/*Before hooks abort the call before it's sent*/
+	out := table.PutItem(User{Email: "name@email.com", Password: "a"}).ExecuteWith(ctx, db)
+	assert.Equal(t, errNoTenant, out.Error())
+	assert.Equal(t, 0, puts)
+	assert.Equal(t, errNoTenant, table.BatchWriteItem().PutItems(tenant("a"), User{Email: "name@email.com", Password: "b"}).ExecuteWith(ctx, db).Error())
+	assert.Error(t, table.UpdateItem(KeyValue{"name@email.com", "a"}).SetUpdateExpression(untouched.loginCount.Increment(1)).ExecuteWith(ctx, db).Error())
+	assert.Empty(t, events)
+	assert.NoError(t, untouched.PutItem(User{Email: "name@email.com", Password: "a"}).ExecuteWith(ctx, db).Error())
+	assert.Equal(t, 1, puts)
+
+	/*After hooks audit what was sent*/
+	assert.NoError(t, table.PutItem(tenant("a")).ExecuteWith(ctx, db).Error())
+	assert.NoError(t, table.UpdateItem(KeyValue{"name@email.com", "b"}).
+		SetUpdateExpression(untouched.loginCount.Increment(1)).
+		SetConditionExpression(untouched.loginCount.Exists()).
+		ExecuteWith(ctx, db).Error())
+	assert.Error(t, table.DeleteItem(KeyValue{"name@email.com", "c"}).ExecuteWith(ctx, db).Error())
+	assert.NoError(t, table.BatchWriteItem().PutItems(tenant("d"), tenant("e")).DeleteItems(KeyValue{"name@email.com", "f"}).ExecuteWith(ctx, db).Error())
+	assert.Equal(t, []event{
+		{"PutItem", "a", nil},
+		{"UpdateItem", "b", nil},
+		{"DeleteItem", "c", errors.New("unavailable")},
+		{"PutItem", "d", nil},
+		{"DeleteItem", "f", nil},
+	}, events)
+}
+
+func TestDiffUpdate(t *testing.T) {
+	table := NewUserTable()
+	old := User{
+		Email:       "a@email.com",
+		Password:    "password",
+		LoginCount:  3,
+		LoginDate:   100,
+		Locales:     []string{"en", "fr"},
+		Visits:      []int64{1, 2},
+		Preferences: map[string]string{"color": "red", "size": "large", "font": "mono"},
+	}
+
+	exprs, err := table.DiffUpdate(old, old)
+	assert.NoError(t, err)
+	assert.Empty(t, exprs)
+
+	new := old
+	new.Password = "other"
+	new.Locales = []string{"fr", "en"}
+	exprs, err = table.DiffUpdate(old, new)
+	assert.NoError(t, err)
+	assert.Empty(t, exprs)
+
+	new.LoginCount = 4
+	new.LoginDate = 0
+	new.RegDate = 50
+	new.Visits = []int64{1, 2, 3}
+	exprs, err = table.DiffUpdate(old, new)
+	assert.NoError(t, err)
+	q, err := table.UpdateItem(KeyValue{"a@email.com", "password"}).SetUpdateExpression(exprs...).Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "SET #update_101 = :update_loginCount_102, #update_103 = :update_registrationDate_104, #update_105 = :update_visits_106 REMOVE #update_100", *q.UpdateExpression)
+	assert.Equal(t, "lastLoginDate", *q.ExpressionAttributeNames["#update_100"])
+	assert.Equal(t, "loginCount", *q.ExpressionAttributeNames["#update_101"])
+	assert.Equal(t, "4", *q.ExpressionAttributeValues[":update_loginCount_102"].N)
+	assert.Equal(t, "50", *q.ExpressionAttributeValues[":update_registrationDate_104"].N)
+	assert.Len(t, q.ExpressionAttributeValues[":update_visits_106"].NS, 3)
+
+	new = old
+	new.Preferences = map[string]string{"color": "blue", "size": "large", "shape": "round"}
+	exprs, err = table.DiffUpdate(old, new)
+	assert.NoError(t, err)
+	q, err = table.UpdateItem(KeyValue{"a@email.com", "password"}).SetUpdateExpression(exprs...).Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "SET #update_100 = :update_preferences_101", *q.UpdateExpression)
+	assert.Equal(t, "preferences", *q.ExpressionAttributeNames["#update_100"])
+
+	exprs, err = table.DiffUpdate(old, new, DiffNested())
+	assert.NoError(t, err)
+	q, err = table.UpdateItem(KeyValue{"a@email.com", "password"}).SetUpdateExpression(exprs...).Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "SET #update_100.#update_101 = :update_preferences_color_102, #update_105.#update_106 = :update_preferences_shape_107 REMOVE #update_103.#update_104", *q.UpdateExpression)
+	assert.Equal(t, "color", *q.ExpressionAttributeNames["#update_101"])
+	assert.Equal(t, "blue", *q.ExpressionAttributeValues[":update_preferences_color_102"].S)
+	assert.Equal(t, "font", *q.ExpressionAttributeNames["#update_104"])
+	assert.Equal(t, "shape", *q.ExpressionAttributeNames["#update_106"])
+
+	/*Reserved words, and names dynamo can't parse, are referenced through name placeholders*/
+	type profile struct {
+		ID     string `dynamodbav:"id"`
+		Name   string `dynamodbav:"name"`
+		Status string `dynamodbav:"status,omitempty"`
+		Dashed string `dynamodbav:"first-name"`
+	}
+	profiles := DynamoTable{Name: "profiles", PartitionKey: StringField("id")}
+	exprs, err = profiles.DiffUpdate(profile{ID: "1", Name: "a", Status: "active"}, profile{ID: "1", Name: "b", Dashed: "c"})
+	assert.NoError(t, err)
+	q, err = profiles.UpdateItem(KeyValue{PartitionKey: "1"}).SetUpdateExpression(exprs...).Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "SET #update_100 = :update_first_name_101, #update_102 = :update_name_103 REMOVE #update_104", *q.UpdateExpression)
+	assert.Equal(t, "first-name", *q.ExpressionAttributeNames["#update_100"])
+	assert.Equal(t, "name", *q.ExpressionAttributeNames["#update_102"])
+	assert.Equal(t, "status", *q.ExpressionAttributeNames["#update_104"])
+}
+
+/*ctxQueryDB serves queries with a handler that sees the request context, and may run concurrently*/
+type ctxQueryDB struct {
+	DynamoDBIFace
+	query func(aws.Context, *dynamodb.QueryInput) (*dynamodb.QueryOutput, error)
+}
+
+func (d ctxQueryDB) QueryWithContext(ctx aws.Context, in *dynamodb.QueryInput, opts ...request.Option) (*dynamodb.QueryOutput, error) {
+	return d.query(ctx, in)
+}
+
+/*ctxScanDB is ctxQueryDB for scans*/
+type ctxScanDB struct {
+	DynamoDBIFace
+	scan func(aws.Context, *dynamodb.ScanInput) (*dynamodb.ScanOutput, error)
+}
+
+func (d ctxScanDB) ScanWithContext(ctx aws.Context, in *dynamodb.ScanInput, opts ...request.Option) (*dynamodb.ScanOutput, error) {
+	return d.scan(ctx, in)
+}
+
+func TestShardedKeyWrites(t *testing.T) {
+	ctx := context.Background()
+	var puts []DynamoDBValue
+	var updates []DynamoDBValue
+	db := &stubDB{
+		putItem: func(in *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+			puts = append(puts, in.Item)
+			return &dynamodb.PutItemOutput{}, nil
+		},
+		updateItem: func(in *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+			updates = append(updates, in.Key)
+			return &dynamodb.UpdateItemOutput{}, nil
+		},
+	}
+	table := NewUserTable()
+	sharded := ShardedKey(table.emailField, 8)
+	st := table.WithShardedKey(sharded)
+
+	for i := 0; i < 2; i++ {
+		err := st.PutItem(User{Email: "name@email.com", Password: "password"}).ExecuteWith(ctx, db).Result(nil)
+		assert.NoError(t, err)
+		err = st.UpdateItem(KeyValue{"name@email.com", "password"}).
+			SetUpdateExpression(table.loginCount.Increment(1)).
+			ExecuteWith(ctx, db).Result(nil)
+		assert.NoError(t, err)
+	}
+	pk := *puts[0]["email"].S
+	assert.True(t, strings.HasPrefix(pk, "name@email.com#"), pk)
+	assert.Equal(t, pk, *puts[1]["email"].S)
+	assert.Equal(t, pk, *updates[0]["email"].S)
+	assert.Equal(t, pk, *updates[1]["email"].S)
+	assert.Equal(t, "password", *puts[0]["password"].S)
+
+	shards := map[string]bool{}
+	for i := 0; i < 32; i++ {
+		err := st.PutItem(User{Email: "name@email.com", Password: fmt.Sprintf("password%d", i)}).ExecuteWith(ctx, db).Result(nil)
+		assert.NoError(t, err)
+		shards[*puts[len(puts)-1]["email"].S] = true
+	}
+	assert.True(t, len(shards) > 1)
+	for s := range shards {
+		assert.Contains(t, []string{sharded.Key("name@email.com", 0), sharded.Key("name@email.com", 1), sharded.Key("name@email.com", 2), sharded.Key("name@email.com", 3),
+			sharded.Key("name@email.com", 4), sharded.Key("name@email.com", 5), sharded.Key("name@email.com", 6), sharded.Key("name@email.com", 7)}, s)
+	}
+
+	/*Random shards leave updates alone*/
+	updates = nil
+	st = table.WithShardedKey(ShardedKey(table.emailField, 8).Random())
+	err := st.UpdateItem(KeyValue{"name@email.com#3", "password"}).
+		SetUpdateExpression(table.loginCount.Increment(1)).
+		ExecuteWith(ctx, db).Result(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "name@email.com#3", *updates[0]["email"].S)
+
+	/*Executing a builder again assigns the shard afresh, rather than suffixing the assigned one*/
+	puts, updates = nil, nil
+	st = table.WithShardedKey(sharded)
+	put := st.PutItem(User{Email: "name@email.com", Password: "password"})
+	update := st.UpdateItem(KeyValue{"name@email.com", "password"}).SetUpdateExpression(table.loginCount.Increment(1))
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, put.ExecuteWith(ctx, db).Result(nil))
+		assert.NoError(t, update.ExecuteWith(ctx, db).Result(nil))
+	}
+	assert.Equal(t, pk, *puts[0]["email"].S)
+	assert.Equal(t, pk, *puts[1]["email"].S)
+	assert.Equal(t, pk, *updates[0]["email"].S)
+	assert.Equal(t, pk, *updates[1]["email"].S)
+	assert.Equal(t, "name@email.com", *put.Item["email"].S)
+
+	var batched []string
+	db.batchWriteItem = func(in *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+		for _, w := range in.RequestItems["users"] {
+			batched = append(batched, *w.PutRequest.Item["email"].S)
+		}
+		return &dynamodb.BatchWriteItemOutput{}, nil
+	}
+	batch := st.BatchWriteItem().PutItems(User{Email: "name@email.com", Password: "password"})
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, batch.ExecuteWith(ctx, db).Error())
+	}
+	assert.Equal(t, []string{pk, pk}, batched)
+
+	/*Logical values shaped like a suffixed one are suffixed all the same*/
+	puts = nil
+	assert.NoError(t, st.PutItem(User{Email: "TENANT#2", Password: "password"}).ExecuteWith(ctx, db).Result(nil))
+	stored := *puts[0]["email"].S
+	assert.NotEqual(t, "TENANT#2", stored)
+	assert.Equal(t, []string{"TENANT#2", stored[len(stored)-1:]}, table.emailField.SplitComposite(stored))
+
+	/*Items read back through QuerySharded are unsharded before they're written back, landing on the same item*/
+	logical, ok := sharded.Unshard(stored)
+	assert.True(t, ok)
+	assert.Equal(t, "TENANT#2", logical)
+	assert.NoError(t, st.PutItem(User{Email: logical, Password: "password"}).ExecuteWith(ctx, db).Result(nil))
+	assert.Equal(t, stored, *puts[1]["email"].S)
+	_, ok = sharded.Unshard("TENANT")
+	assert.False(t, ok)
+}
+
+func TestShardedKeyRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	table := NewUserTable()
+	sharded := ShardedKey(table.emailField, 8)
+	st := table.WithShardedKey(sharded)
+	db, items := memoryDB(st)
+	key := KeyValue{"name@email.com", "password"}
+	stored := KeyValue{sharded.KeyFor("name@email.com", "password"), "password"}
+	assert.NotEqual(t, "name@email.com", stored.PartitionKey)
+
+	/*Every builder addresses the item by its logical key*/
+	assert.NoError(t, st.PutItem(User{Email: "name@email.com", Password: "password", LoginCount: 1}).ExecuteWith(ctx, db).Error())
+	u := &User{}
+	assert.NoError(t, st.GetItem(key).ExecuteWith(ctx, db).Result(u))
+	assert.Equal(t, stored.PartitionKey, u.Email)
+	assert.Equal(t, 1, u.LoginCount)
+
+	var users []*User
+	err := st.BatchGetItem(key).ExecuteWith(ctx, db).Results(func() interface{} {
+		u := &User{}
+		users = append(users, u)
+		return u
+	})
+	assert.NoError(t, err)
+	assert.Len(t, users, 1)
+
+	assert.NoError(t, st.DeleteItem(key).ExecuteWith(ctx, db).Error())
+	assert.Empty(t, items)
+
+	assert.NoError(t, st.PutItem(User{Email: "name@email.com", Password: "password"}).ExecuteWith(ctx, db).Error())
+	deleted, unprocessed, err := st.BatchDelete(ctx, db, key)
+	assert.NoError(t, err)
+	assert.Empty(t, unprocessed)
+	assert.Equal(t, 1, deleted)
+	assert.Empty(t, items)
+
+	assert.NoError(t, st.PutItem(User{Email: "name@email.com", Password: "password"}).ExecuteWith(ctx, db).Error())
+	assert.NoError(t, st.BatchWriteItem().DeleteItems(key).ExecuteWith(ctx, db).Error())
+	assert.Empty(t, items)
+
+	/*The stored key is only found unsharded, or through the plain table*/
+	assert.NoError(t, st.PutItem(User{Email: "name@email.com", Password: "password"}).ExecuteWith(ctx, db).Error())
+	found, err := table.GetItem(stored).ExecuteWith(ctx, db).ResultOK(&User{})
+	assert.NoError(t, err)
+	assert.True(t, found)
+}
+
+func TestQuerySharded(t *testing.T) {
+	ctx := context.Background()
+	table := NewUserTable()
+	sharded := ShardedKey(table.emailField, 3)
+	db := ctxQueryDB{query: func(ctx aws.Context, in *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+		pk := *in.ExpressionAttributeValues[":cond_email_0"].S
+		var items []map[string]*dynamodb.AttributeValue
+		for shard := 0; shard < 3; shard++ {
+			if pk != sharded.Key("name@email.com", shard) {
+				continue
+			}
+			for i := shard; i < 12; i += 3 {
+				items = append(items, map[string]*dynamodb.AttributeValue{
+					"email":            {S: aws.String(pk)},
+					"password":         {S: aws.String(fmt.Sprintf("password%d", i))},
+					"registrationDate": {N: aws.String(strconv.Itoa(i * 10))},
+				})
+			}
+		}
+		return &dynamodb.QueryOutput{Items: items, Count: aws.Int64(int64(len(items)))}, nil
+	}}
+
+	dates := func(q *ShardedQueryInput) (d []int64) {
+		var users []*User
+		err := q.ExecuteWith(ctx, db).Results(func() interface{} {
+			u := &User{}
+			users = append(users, u)
+			return u
+		})
+		assert.NoError(t, err)
+		for _, u := range users {
+			d = append(d, u.RegDate)
+		}
+		return
+	}
+
+	unsorted := table.QuerySharded("name@email.com", nil, sharded)
+	assert.Equal(t, []int64{0, 30, 60, 90, 10, 40, 70, 100, 20, 50, 80, 110}, dates(unsorted))
+
+	sorted := table.QuerySharded("name@email.com", nil, sharded).
+		SortByRangeKey().
+		Configure(func(q *QueryInput) { q.SetLocalIndex(table.registrationDateIndex) })
+	assert.Equal(t, []int64{0, 10, 20, 30, 40, 50, 60, 70, 80, 90, 100, 110}, dates(sorted))
+
+	sorted.Configure(func(q *QueryInput) { q.SetScanForward(false).SetLimit(4) })
+	assert.Equal(t, []int64{110, 100, 90, 80}, dates(sorted))
+
+	channel := make(chan User)
+	errChan := table.QuerySharded("name@email.com", nil, sharded).
+		SortByRangeKey().
+		Configure(func(q *QueryInput) { q.SetLocalIndex(table.registrationDateIndex) }).
+		ExecuteWith(ctx, db).
+		StreamWithChannel(channel)
+	var streamed []int64
+	for u := range channel {
+		streamed = append(streamed, u.RegDate)
+	}
+	assert.NoError(t, <-errChan)
+	assert.Equal(t, []int64{0, 10, 20, 30, 40, 50, 60, 70, 80, 90, 100, 110}, streamed)
+
+	/*Each shard renders its own key condition, alongside the range key condition and filter*/
+	var mu sync.Mutex
+	keys := map[string]bool{}
+	capture := ctxQueryDB{query: func(ctx aws.Context, in *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, "email = :cond_email_0 AND begins_with(password,:cond_password_1)", *in.KeyConditionExpression)
+		assert.Equal(t, "loginCount = :filter_loginCount_1", *in.FilterExpression)
+		keys[*in.ExpressionAttributeValues[":cond_email_0"].S] = true
+		return &dynamodb.QueryOutput{}, nil
+	}}
+	prefix := table.passwordField.BeginsWith("pass")
+	err := table.QuerySharded("name@email.com", &prefix, sharded).
+		Configure(func(q *QueryInput) { q.SetFilterExpression(table.loginCount.Equals(0)) }).
+		ExecuteWith(ctx, capture).
+		Results(func() interface{} { return &User{} })
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]bool{sharded.Key("name@email.com", 0): true, sharded.Key("name@email.com", 1): true, sharded.Key("name@email.com", 2): true}, keys)
+}
+
+func TestQueryShardedCancellation(t *testing.T) {
+	table := NewUserTable()
+	sharded := ShardedKey(table.emailField, 4)
+	failure := errors.New("shard failed")
+	var canceled int32
+	db := ctxQueryDB{query: func(ctx aws.Context, in *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+		if *in.ExpressionAttributeValues[":cond_email_0"].S == sharded.Key("name@email.com", 2) {
+			return nil, failure
+		}
+		<-ctx.Done()
+		atomic.AddInt32(&canceled, 1)
+		return nil, awserr.New(request.CanceledErrorCode, "request context canceled", ctx.Err())
+	}}
+
+	/*A failing shard cancels the others, and its error is reported*/
+	out := table.QuerySharded("name@email.com", nil, sharded).ExecuteWith(context.Background(), db)
+	err := out.Results(func() interface{} { return &User{} })
+	assert.Equal(t, failure, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&canceled))
+	assert.Equal(t, failure, out.Error())
+
+	/*Canceling the caller's context stops every shard*/
+	atomic.StoreInt32(&canceled, 0)
+	blocked := ctxQueryDB{query: func(ctx aws.Context, in *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+		<-ctx.Done()
+		atomic.AddInt32(&canceled, 1)
+		return nil, awserr.New(request.CanceledErrorCode, "request context canceled", ctx.Err())
+	}}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	err = table.QuerySharded("name@email.com", nil, sharded).ExecuteWith(ctx, blocked).Results(func() interface{} { return &User{} })
+	assert.Error(t, err)
+	assert.Equal(t, request.CanceledErrorCode, err.(awserr.Error).Code())
+	assert.Equal(t, int32(4), atomic.LoadInt32(&canceled))
+}
+
+func TestQueryFirst(t *testing.T) {
+	ctx := context.Background()
+	table := NewUserTable()
+	var limits []int64
+	pages := []*dynamodb.QueryOutput{
+		{LastEvaluatedKey: map[string]*dynamodb.AttributeValue{"email": {S: aws.String("name@email.com")}, "password": {S: aws.String("a")}}},
+		{LastEvaluatedKey: map[string]*dynamodb.AttributeValue{"email": {S: aws.String("name@email.com")}, "password": {S: aws.String("b")}}},
+		{
+			Items: []map[string]*dynamodb.AttributeValue{
+				{"email": {S: aws.String("name@email.com")}, "password": {S: aws.String("c")}, "loginCount": {N: aws.String("3")}},
+			},
+			LastEvaluatedKey: map[string]*dynamodb.AttributeValue{"email": {S: aws.String("name@email.com")}, "password": {S: aws.String("c")}},
+		},
+	}
+	db := &stubDB{query: func(in *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+		limits = append(limits, aws.Int64Value(in.Limit))
+		page := pages[0]
+		pages = pages[1:]
+		return page, nil
+	}}
+
+	/*Pages filtered down to nothing are skipped, and nothing is fetched past the first match*/
+	var user User
+	found, err := table.Query(table.emailField.Equals("name@email.com"), nil).
+		SetScanForward(false).
+		SetFilterExpression(table.loginCount.GreaterThan(2)).
+		ExecuteWith(ctx, db).
+		First(&user)
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "c", user.Password)
+	assert.Equal(t, 3, user.LoginCount)
+	assert.Equal(t, []int64{1, 1, 1}, limits)
+	assert.Empty(t, pages)
+
+	db.query = func(in *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+		return &dynamodb.QueryOutput{}, nil
+	}
+	found, err = table.Query(table.emailField.Equals("none@email.com"), nil).ExecuteWith(ctx, db).First(&user)
+	assert.NoError(t, err)
+	assert.False(t, found)
+
+	failure := errors.New("query failed")
+	db.query = func(in *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+		return nil, failure
+	}
+	out := table.Query(table.emailField.Equals("name@email.com"), nil).ExecuteWith(ctx, db)
+	found, err = out.First(&user)
+	assert.Equal(t, failure, err)
+	assert.False(t, found)
+	assert.Equal(t, failure, out.Error())
+}
+
+func TestResultIterator(t *testing.T) {
+	ctx := context.Background()
+	table := NewUserTable()
+	user := func(password string) map[string]*dynamodb.AttributeValue {
+		return map[string]*dynamodb.AttributeValue{"email": {S: aws.String("name@email.com")}, "password": {S: aws.String(password)}}
+	}
+	var requests int
+	db := &stubDB{query: func(in *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+		requests++
+		if in.ExclusiveStartKey == nil {
+			return &dynamodb.QueryOutput{Items: []map[string]*dynamodb.AttributeValue{user("a"), user("b")}, LastEvaluatedKey: user("b")}, nil
+		}
+		switch *in.ExclusiveStartKey["password"].S {
+		case "b":
+			/*Everything on this page was filtered out*/
+			return &dynamodb.QueryOutput{LastEvaluatedKey: user("c")}, nil
+		default:
+			return &dynamodb.QueryOutput{Items: []map[string]*dynamodb.AttributeValue{user("d"), user("e")}}, nil
+		}
+	}}
+
+	it := table.Query(table.emailField.Equals("name@email.com"), nil).ExecuteWith(ctx, db).Iterator()
+	assert.Error(t, it.Item(&User{}))
+	var passwords []string
+	for it.Next() {
+		var u User
+		assert.NoError(t, it.Item(&u))
+		assert.Equal(t, u.Password, *it.Raw()["password"].S)
+		passwords = append(passwords, u.Password)
+		/*Pages are only fetched as they're needed*/
+		if u.Password == "a" {
+			assert.Equal(t, 1, requests)
+		}
+	}
+	assert.NoError(t, it.Err())
+	assert.Equal(t, []string{"a", "b", "d", "e"}, passwords)
+	assert.Equal(t, 3, requests)
+	assert.False(t, it.Next())
+	assert.Nil(t, it.Raw())
+
+	/*The limit stops iteration, leaving the resume key at the last item returned*/
+	out := table.Query(table.emailField.Equals("name@email.com"), nil).SetLimit(1).ExecuteWith(ctx, db)
+	it = out.Iterator()
+	assert.True(t, it.Next())
+	assert.False(t, it.Next())
+	assert.NoError(t, it.Err())
+	assert.Equal(t, "a", *out.LastEvaluatedKey()["password"].S)
+
+	/*Canceling the context ends iteration with its error*/
+	cctx, cancel := context.WithCancel(ctx)
+	out = table.Query(table.emailField.Equals("name@email.com"), nil).ExecuteWith(cctx, db)
+	it = out.Iterator()
+	assert.True(t, it.Next())
+	cancel()
+	assert.False(t, it.Next())
+	assert.Equal(t, context.Canceled, it.Err())
+	assert.Equal(t, "a", *out.LastEvaluatedKey()["password"].S)
+
+	failure := errors.New("scan failed")
+	sdb := &stubDB{scan: func(in *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+		if in.ExclusiveStartKey == nil {
+			return &dynamodb.ScanOutput{Items: []map[string]*dynamodb.AttributeValue{user("a")}, LastEvaluatedKey: user("a")}, nil
+		}
+		return nil, failure
+	}}
+	it = table.Scan().ExecuteWith(ctx, sdb).Iterator()
+	assert.True(t, it.Next())
+	assert.False(t, it.Next())
+	assert.Equal(t, failure, it.Err())
+}
+
+func TestDynamoDBValueAccessors(t *testing.T) {
+	v := DynamoDBValue{
+		"s":     {S: aws.String("str")},
+		"n":     {N: aws.String("42")},
+		"neg":   {N: aws.String("-7")},
+		"whole": {N: aws.String("1.50e2")},
+		"frac":  {N: aws.String("1.5")},
+		"big":   {N: aws.String("9223372036854775808")},
+		"max":   {N: aws.String("9223372036854775807")},
+		"huge":  {N: aws.String("1e500")},
+		"bad":   {N: aws.String("abc")},
+		"b":     {BOOL: aws.Bool(true)},
+		"bin":   {B: []byte("bytes")},
+		"ss":    {SS: []*string{aws.String("a"), aws.String("b")}},
+		"m":     {M: map[string]*dynamodb.AttributeValue{"inner": {S: aws.String("x")}}},
+		"null":  {NULL: aws.Bool(true)},
+		"nil":   nil,
+	}
+
+	s, ok := v.GetString("s")
+	assert.True(t, ok)
+	assert.Equal(t, "str", s)
+	for _, f := range []string{"n", "missing", "null", "nil"} {
+		_, ok = v.GetString(f)
+		assert.False(t, ok, f)
+	}
+
+	for f, want := range map[string]int64{"n": 42, "neg": -7, "whole": 150, "max": math.MaxInt64} {
+		i, ok := v.GetInt(f)
+		assert.True(t, ok, f)
+		assert.Equal(t, want, i, f)
+	}
+	for _, f := range []string{"frac", "big", "huge", "bad", "s", "missing", "nil"} {
+		_, ok = v.GetInt(f)
+		assert.False(t, ok, f)
+	}
+
+	for f, want := range map[string]float64{"n": 42, "whole": 150, "frac": 1.5} {
+		fl, ok := v.GetFloat(f)
+		assert.True(t, ok, f)
+		assert.Equal(t, want, fl, f)
+	}
+	for _, f := range []string{"huge", "bad", "s", "missing"} {
+		_, ok = v.GetFloat(f)
+		assert.False(t, ok, f)
+	}
+
+	b, ok := v.GetBool("b")
+	assert.True(t, ok)
+	assert.True(t, b)
+	_, ok = v.GetBool("s")
+	assert.False(t, ok)
+
+	bin, ok := v.GetBytes("bin")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("bytes"), bin)
+	_, ok = v.GetBytes("s")
+	assert.False(t, ok)
+
+	ss, ok := v.GetStringSet("ss")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"a", "b"}, ss)
+	_, ok = v.GetStringSet("s")
+	assert.False(t, ok)
+
+	m, ok := v.GetMap("m")
+	assert.True(t, ok)
+	inner, ok := m.GetString("inner")
+	assert.True(t, ok)
+	assert.Equal(t, "x", inner)
+	_, ok = v.GetMap("missing")
+	assert.False(t, ok)
+
+	var empty DynamoDBValue
+	_, ok = empty.GetString("s")
+	assert.False(t, ok)
+}
+
+func TestDynamoDBValueKey(t *testing.T) {
+	table := NewUserTable()
+	item := DynamoDBValue{"email": {S: aws.String("name@email.com")}, "password": {S: aws.String("password")}, "loginCount": {N: aws.String("3")}}
+	assert.Equal(t, KeyValue{"name@email.com", "password"}, item.Key(table.DynamoTable))
+
+	id := NumericField("id")
+	numbered := DynamoTable{Name: "numbered", PartitionKey: id}
+	key := DynamoDBValue{"id": {N: aws.String("12345678901234567890")}}.Key(numbered)
+	assert.Equal(t, KeyValue{PartitionKey: dynamodbattribute.Number("12345678901234567890")}, key)
+
+	g := numbered.GetItem(key).Build()
+	assert.Equal(t, "12345678901234567890", *g.Key["id"].N)
+}
+
+func TestBatchGetUnprocessedKeys(t *testing.T) {
+	retryBackoff = time.Millisecond
+	ctx := context.Background()
+	table := NewUserTable()
+	var calls int
+	db := &stubDB{batchGetItem: func(in *dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error) {
+		calls++
+		out := &dynamodb.BatchGetItemOutput{Responses: map[string][]map[string]*dynamodb.AttributeValue{}}
+		var unprocessed []map[string]*dynamodb.AttributeValue
+		for _, key := range in.RequestItems[table.Name].Keys {
+			/*Throttled forever*/
+			if strings.HasPrefix(*key["password"].S, "hot") {
+				unprocessed = append(unprocessed, key)
+				continue
+			}
+			out.Responses[table.Name] = append(out.Responses[table.Name], key)
+		}
+		if len(unprocessed) > 0 {
+			out.UnprocessedKeys = map[string]*dynamodb.KeysAndAttributes{table.Name: {Keys: unprocessed}}
+		}
+		return out, nil
+	}}
+
+	out := table.BatchGetItem(PKRK("name@email.com", "a"), PKRK("name@email.com", "hot1"), PKRK("name@email.com", "b"), PKRK("name@email.com", "hot2")).
+		SetMaxRetries(3).
+		ExecuteWith(ctx, db)
+	assert.NoError(t, out.Error())
+	assert.Equal(t, 4, calls)
+	assert.Equal(t, []KeyValue{PKRK("name@email.com", "hot1"), PKRK("name@email.com", "hot2")}, out.UnprocessedKeys())
+
+	var users []*User
+	err := out.Results(func() interface{} {
+		u := &User{}
+		users = append(users, u)
+		return u
+	})
+	assert.NoError(t, err)
+	assert.Len(t, users, 2)
+
+	/*A failed call leaves every key not yet fetched unprocessed*/
+	failure := errors.New("batch get failed")
+	db.batchGetItem = func(in *dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error) {
+		return nil, failure
+	}
+	out = table.BatchGetItem(PKRK("name@email.com", "a"), PKRK("name@email.com", "b")).ExecuteWith(ctx, db)
+	assert.Equal(t, failure, out.Error())
+	assert.Equal(t, []KeyValue{PKRK("name@email.com", "a"), PKRK("name@email.com", "b")}, out.UnprocessedKeys())
+
+	db.batchGetItem = func(in *dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error) {
+		return &dynamodb.BatchGetItemOutput{}, nil
+	}
+	out = table.BatchGetItem(PKRK("name@email.com", "a")).ExecuteWith(ctx, db)
+	assert.NoError(t, out.Error())
+	assert.Empty(t, out.UnprocessedKeys())
+}
+
+func TestBatchReader(t *testing.T) {
+	retryBackoff = time.Millisecond
+	ctx := context.Background()
+	table := NewUserTable()
+
+	/*Items come back in reverse, one with extra attributes, and "missing" doesn't exist*/
+	var sizes []int
+	db := &stubDB{batchGetItem: func(in *dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error) {
+		keys := in.RequestItems[table.Name].Keys
+		sizes = append(sizes, len(keys))
+		out := &dynamodb.BatchGetItemOutput{Responses: map[string][]map[string]*dynamodb.AttributeValue{}}
+		for i := len(keys) - 1; i >= 0; i-- {
+			if *keys[i]["password"].S == "missing" {
+				continue
+			}
+			item := cloneValue(keys[i])
+			item["loginCount"] = &dynamodb.AttributeValue{N: aws.String(strconv.Itoa(i))}
+			out.Responses[table.Name] = append(out.Responses[table.Name], item)
+		}
+		return out, nil
+	}}
+
+	r := table.BatchReader(ctx, db, ReaderLinger(time.Hour))
+	var futures []*ReadFuture
+	var shared *ReadFuture
+	for i := 0; i < 250; i++ {
+		futures = append(futures, r.Request(PKRK("name@email.com", strconv.Itoa(i))))
+		if i == 3 {
+			shared = r.Request(PKRK("name@email.com", "3"))
+		}
+	}
+	missing := r.Request(PKRK("name@email.com", "missing"))
+	invalid := r.Request(KeyValue{PartitionKey: "name@email.com"})
+	r.Close()
+	assert.ElementsMatch(t, []int{100, 100, 51}, sizes)
+
+	for i, f := range futures {
+		u := &User{}
+		found, err := f.ResultOK(u)
+		assert.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, strconv.Itoa(i), u.Password)
+	}
+	assert.True(t, shared == futures[3])
+	item, err := missing.Item()
+	assert.NoError(t, err)
+	assert.Nil(t, item)
+	_, err = invalid.Item()
+	assert.EqualError(t, err, "BatchReader users: missing range key password.")
+	_, err = r.Get(PKRK("name@email.com", "1"))
+	assert.EqualError(t, err, "BatchReader users: closed.")
+
+	/*Keys requested from many goroutines within the linger window share a call*/
+	sizes = nil
+	r = table.BatchReader(ctx, db, ReaderLinger(50*time.Millisecond))
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			item, err := r.Get(PKRK("name@email.com", strconv.Itoa(i)))
+			assert.NoError(t, err)
+			assert.Equal(t, strconv.Itoa(i), *item["password"].S)
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, []int{10}, sizes)
+	r.Close()
+}
+
+func TestBatchReaderThrottling(t *testing.T) {
+	retryBackoff = time.Millisecond
+	ctx := context.Background()
+	table := NewUserTable()
+
+	/*"hot" keys are throttled forever, "warm" keys for the first two attempts*/
+	attempts := map[string]int{}
+	db := &stubDB{batchGetItem: func(in *dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error) {
+		out := &dynamodb.BatchGetItemOutput{Responses: map[string][]map[string]*dynamodb.AttributeValue{}}
+		var unprocessed []map[string]*dynamodb.AttributeValue
+		for _, key := range in.RequestItems[table.Name].Keys {
+			password := *key["password"].S
+			attempts[password]++
+			if password == "hot" || (password == "warm" && attempts[password] <= 2) {
+				unprocessed = append(unprocessed, key)
+				continue
+			}
+			out.Responses[table.Name] = append(out.Responses[table.Name], key)
+		}
+		if len(unprocessed) > 0 {
+			out.UnprocessedKeys = map[string]*dynamodb.KeysAndAttributes{table.Name: {Keys: unprocessed}}
+		}
+		return out, nil
+	}}
+
+	r := table.BatchReader(ctx, db, ReaderMaxRetries(3))
+	hot, warm, cold := r.Request(PKRK("name@email.com", "hot")), r.Request(PKRK("name@email.com", "warm")), r.Request(PKRK("name@email.com", "cold"))
+	r.Close()
+	_, err := hot.Item()
+	assert.EqualError(t, err, "BatchReader users: key still unprocessed after 3 retries.")
+	item, err := warm.Item()
+	assert.NoError(t, err)
+	assert.NotNil(t, item)
+	item, err = cold.Item()
+	assert.NoError(t, err)
+	assert.NotNil(t, item)
+	assert.Equal(t, map[string]int{"hot": 4, "warm": 3, "cold": 1}, attempts)
+
+	/*A failed call fails every read in the batch*/
+	db.batchGetItem = func(in *dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error) {
+		return nil, errors.New("batch get failed")
+	}
+	r = table.BatchReader(ctx, db)
+	_, err = r.Get(PKRK("name@email.com", "a"))
+	assert.EqualError(t, err, "batch get failed")
+	r.Close()
+}
+
+/*concurrentWriteDB serves batch writes with a handler that may run concurrently*/
+type concurrentWriteDB struct {
+	DynamoDBIFace
+	batchWriteItem func(*dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error)
+}
+
+func (d *concurrentWriteDB) BatchWriteItemWithContext(ctx aws.Context, in *dynamodb.BatchWriteItemInput, opts ...request.Option) (*dynamodb.BatchWriteItemOutput, error) {
+	return d.batchWriteItem(in)
+}
+
+func TestBatchDelete(t *testing.T) {
+	retryBackoff = time.Millisecond
+	ctx := context.Background()
+	table := NewUserTable()
+
+	var keys []KeyValue
+	for i := 0; i < 60; i++ {
+		keys = append(keys, KeyValue{"name@email.com", strconv.Itoa(i)})
+	}
+	keys = append(keys, keys[0])
+
+	var mu sync.Mutex
+	var calls, inFlight, maxInFlight int
+	deleted := map[string]int{}
+	db := &concurrentWriteDB{batchWriteItem: func(in *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+		mu.Lock()
+		calls++
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+
+		mu.Lock()
+		defer mu.Unlock()
+		inFlight--
+		requests := in.RequestItems[table.Name]
+		assert.True(t, len(requests) <= 25)
+		out := &dynamodb.BatchWriteItemOutput{}
+		for _, r := range requests {
+			/*One key is never processed*/
+			if password := *r.DeleteRequest.Key["password"].S; password == "42" {
+				out.UnprocessedItems = map[string][]*dynamodb.WriteRequest{table.Name: {r}}
+			} else {
+				deleted[password]++
+			}
+		}
+		return out, nil
+	}}
+
+	n, unprocessed, err := table.WithBatchConcurrency(3).BatchDelete(ctx, db, keys...)
+	assert.NoError(t, err)
+	assert.Equal(t, 59, n)
+	assert.Equal(t, []KeyValue{{"name@email.com", "42"}}, unprocessed)
+	assert.Len(t, deleted, 59)
+	for _, count := range deleted {
+		assert.Equal(t, 1, count)
+	}
+	assert.Equal(t, 3, maxInFlight)
+	assert.Equal(t, 3+10, calls)
+
+	/*Keys are validated before anything is sent*/
+	calls = 0
+	_, _, err = table.BatchDelete(ctx, db, keys[1], KeyValue{PartitionKey: "name@email.com"})
+	assert.EqualError(t, err, "BatchDelete users: missing range key password.")
+	assert.Equal(t, 0, calls)
+
+	/*An error stops the deletes, and unsent keys come back as unprocessed*/
+	db.batchWriteItem = func(in *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+		return nil, errors.New("throttled")
+	}
+	n, unprocessed, err = table.BatchDelete(ctx, db, keys[:60]...)
+	assert.EqualError(t, err, "throttled")
+	assert.Equal(t, 0, n)
+	assert.Equal(t, keys[:60], unprocessed)
+
+	n, unprocessed, err = table.BatchDelete(ctx, db)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+	assert.Empty(t, unprocessed)
+
+	/*After write hooks see every delete sent, and unprocessed ones only on an error*/
+	var events []string
+	hooked := table.DynamoTable.AfterWrite(func(op string, key DynamoDBValue, err error) {
+		events = append(events, fmt.Sprintf("%s %s %v", op, *key["password"].S, err))
+	})
+	db.batchWriteItem = func(in *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+		out := &dynamodb.BatchWriteItemOutput{}
+		for _, r := range in.RequestItems[table.Name] {
+			if *r.DeleteRequest.Key["password"].S == "1" {
+				out.UnprocessedItems = map[string][]*dynamodb.WriteRequest{table.Name: {r}}
+			}
+		}
+		return out, nil
+	}
+	_, unprocessed, err = hooked.BatchDelete(ctx, db, keys[0], keys[1])
+	assert.NoError(t, err)
+	assert.Equal(t, []KeyValue{keys[1]}, unprocessed)
+	assert.Equal(t, []string{"DeleteItem 0 <nil>"}, events)
+
+	events = nil
+	db.batchWriteItem = func(in *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+		return nil, errors.New("throttled")
+	}
+	_, _, err = hooked.BatchDelete(ctx, db, keys[0])
+	assert.EqualError(t, err, "throttled")
+	assert.Equal(t, []string{"DeleteItem 0 throttled"}, events)
+}
+
+func TestBatchWriter(t *testing.T) {
+	retryBackoff = time.Millisecond
+	ctx := context.Background()
+	table := NewUserTable()
+
+	var mu sync.Mutex
+	var batches []int
+	var inFlight, maxInFlight int
+	db := &concurrentWriteDB{batchWriteItem: func(in *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+		mu.Lock()
+		batches = append(batches, len(in.RequestItems[table.Name]))
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		return &dynamodb.BatchWriteItemOutput{}, nil
+	}}
+
+	/*Full batches are sent as they fill, the rest on Close*/
+	w := table.WithBatchConcurrency(2).BatchWriter(ctx, db, WriterFlushInterval(time.Hour))
+	for i := 0; i < 60; i++ {
+		assert.NoError(t, w.Put(User{Email: "name@email.com", Password: strconv.Itoa(i)}))
+	}
+	assert.NoError(t, w.Delete(KeyValue{"other@email.com", "password"}))
+	assert.EqualError(t, w.Delete(KeyValue{PartitionKey: "name@email.com"}), "BatchWriter users: missing range key password.")
+	written, unprocessed, err := w.Close()
+	assert.NoError(t, err)
+	assert.Equal(t, 61, written)
+	assert.Empty(t, unprocessed)
+	assert.Equal(t, []int{25, 25, 11}, batches)
+	assert.Equal(t, 2, maxInFlight)
+	assert.EqualError(t, w.Put(User{Email: "name@email.com", Password: "password"}), "BatchWriter users: closed.")
+
+	/*A repeated key starts a new batch, and partial batches go out on the flush interval*/
+	batches = nil
+	w = table.BatchWriter(ctx, db, WriterFlushInterval(time.Millisecond))
+	assert.NoError(t, w.Put(User{Email: "name@email.com", Password: "password"}))
+	assert.NoError(t, w.Delete(KeyValue{"name@email.com", "password"}))
+	for start := time.Now(); time.Since(start) < time.Second; time.Sleep(time.Millisecond) {
+		mu.Lock()
+		n := len(batches)
+		mu.Unlock()
+		if n == 2 {
+			break
+		}
+	}
+	mu.Lock()
+	assert.Equal(t, []int{1, 1}, batches)
+	mu.Unlock()
+	written, _, err = w.Close()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, written)
+
+	/*Throttled writes are retried with backoff, and reported once retries run out*/
+	attempts := map[string]int{}
+	db.batchWriteItem = func(in *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+		out := &dynamodb.BatchWriteItemOutput{UnprocessedItems: map[string][]*dynamodb.WriteRequest{}}
+		for _, r := range in.RequestItems[table.Name] {
+			password := *r.PutRequest.Item["password"].S
+			attempts[password]++
+			if password == "never" || attempts[password] <= 2 {
+				out.UnprocessedItems[table.Name] = append(out.UnprocessedItems[table.Name], r)
+			}
+		}
+		return out, nil
+	}
+	w = table.BatchWriter(ctx, db, WriterMaxRetries(3))
+	assert.NoError(t, w.Put(User{Email: "name@email.com", Password: "eventually"}))
+	assert.NoError(t, w.Put(User{Email: "name@email.com", Password: "never"}))
+	written, unprocessed, err = w.Close()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, written)
+	assert.Equal(t, map[string]int{"eventually": 3, "never": 4}, attempts)
+	assert.Len(t, unprocessed, 1)
+	assert.Equal(t, "never", *unprocessed[0].PutRequest.Item["password"].S)
+
+	/*An error stops the writer*/
+	db.batchWriteItem = func(in *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+		return nil, errors.New("failed")
+	}
+	w = table.BatchWriter(ctx, db)
+	for i := 0; i < 25; i++ {
+		assert.NoError(t, w.Put(User{Email: "name@email.com", Password: strconv.Itoa(i)}))
+	}
+	written, unprocessed, err = w.Close()
+	assert.EqualError(t, err, "failed")
+	assert.Equal(t, 0, written)
+	assert.Len(t, unprocessed, 25)
+}
+
+func TestBatchWriterCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	table := NewUserTable()
+
+	/*The only worker blocks on the first batch, so the second batch and then Put have to wait*/
+	release := make(chan struct{})
+	db := &concurrentWriteDB{batchWriteItem: func(in *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+		<-release
+		return &dynamodb.BatchWriteItemOutput{}, nil
+	}}
+	w := table.BatchWriter(ctx, db, WriterFlushInterval(time.Hour))
+	for i := 0; i < 50; i++ {
+		assert.NoError(t, w.Put(User{Email: "name@email.com", Password: strconv.Itoa(i)}))
+	}
+	blocked := make(chan error)
+	go func() { blocked <- w.Put(User{Email: "name@email.com", Password: "50"}) }()
+	select {
+	case <-blocked:
+		t.Fatal("Put returned while the writer was full")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	/*Cancelling unblocks Put, and the batch that was never sent comes back as unprocessed*/
+	cancel()
+	assert.Equal(t, context.Canceled, <-blocked)
+	assert.Equal(t, context.Canceled, w.Put(User{Email: "name@email.com", Password: "51"}))
+	close(release)
+	written, unprocessed, err := w.Close()
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 25, written)
+	assert.Len(t, unprocessed, 25)
+}
+
+func TestBatchWriteUnprocessed(t *testing.T) {
+	ctx := context.Background()
+	table := NewUserTable()
+	db := &stubDB{batchWriteItem: func(in *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+		var unprocessed []*dynamodb.WriteRequest
+		for _, write := range in.RequestItems[table.Name] {
+			var password string
+			if write.PutRequest != nil {
+				password = *write.PutRequest.Item["password"].S
+			} else {
+				password = *write.DeleteRequest.Key["password"].S
+			}
+			if strings.HasPrefix(password, "hot") {
+				unprocessed = append(unprocessed, write)
+			}
+		}
+		return &dynamodb.BatchWriteItemOutput{UnprocessedItems: map[string][]*dynamodb.WriteRequest{table.Name: unprocessed}}, nil
+	}}
+
+	out := table.BatchWriteItem().
+		PutItems(User{Email: "name@email.com", Password: "a"}, User{Email: "name@email.com", Password: "hot1", LoginCount: 2}).
+		DeleteItems(PKRK("name@email.com", "b"), PKRK("name@email.com", "hot2")).
+		ExecuteWith(ctx, db)
+	assert.NoError(t, out.Error())
+
+	var puts []*User
+	next := func() interface{} {
+		u := &User{}
+		puts = append(puts, u)
+		return u
+	}
+	assert.NoError(t, out.UnprocessedPuts(next))
+	assert.Equal(t, []*User{{Email: "name@email.com", Password: "hot1", LoginCount: 2}}, puts)
+
+	keys, err := out.UnprocessedDeleteKeys()
+	assert.NoError(t, err)
+	assert.Equal(t, []KeyValue{PKRK("name@email.com", "hot2")}, keys)
+
+	/*Results no longer trips over unprocessed deletes*/
+	puts = nil
+	assert.NoError(t, out.Results(next))
+	assert.Len(t, puts, 1)
+
+	failure := errors.New("batch write failed")
+	db.batchWriteItem = func(in *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+		return nil, failure
+	}
+	out = table.BatchWriteItem().DeleteItems(PKRK("name@email.com", "b")).ExecuteWith(ctx, db)
+	_, err = out.UnprocessedDeleteKeys()
+	assert.Equal(t, failure, err)
+	assert.Equal(t, failure, out.UnprocessedPuts(next))
+}
+
+/*slowUser decodes after a delay that shrinks along the page, so concurrent decodes finish out of order*/
+type slowUser struct {
+	User
+}
+
+func (u *slowUser) LoadDynamoDBValue(av DynamoDBValue) error {
+	i, _ := strconv.Atoi(strings.TrimPrefix(*av["password"].S, "password"))
+	if i == 13 && av["loginCount"] != nil {
+		return errors.New("bad item")
+	}
+	time.Sleep(time.Duration(10-i%10) * 100 * time.Microsecond)
+	return dynamodbattribute.UnmarshalMap(av, &u.User)
+}
+
+func TestStreamUnmarshalWorkers(t *testing.T) {
+	table := NewUserTable()
+	ctx := context.Background()
+	calls := 0
+	db := newPagedStub(pagedItems(30, 10), &calls)
+
+	stream := func(out *ScanOutput) (passwords []string, err error) {
+		channel := make(chan *slowUser)
+		errChan := out.StreamWithChannel(channel)
+		for u := range channel {
+			passwords = append(passwords, u.Password)
+		}
+		return passwords, <-errChan
+	}
+	var want []string
+	for i := 0; i < 30; i++ {
+		want = append(want, "password"+strconv.Itoa(i))
+	}
+
+	for _, workers := range []int{0, 1, 4, 16} {
+		passwords, err := stream(table.Scan().SetUnmarshalWorkers(workers).ExecuteWith(ctx, db))
+		assert.NoError(t, err)
+		assert.Equal(t, want, passwords, "%d workers", workers)
+
+		out := table.Scan().SetUnmarshalWorkers(workers).SetLimit(15).ExecuteWith(ctx, db)
+		passwords, err = stream(out)
+		assert.NoError(t, err)
+		assert.Equal(t, want[:15], passwords, "%d workers", workers)
+		assert.Equal(t, "password14", *out.LastEvaluatedKey()["password"].S)
+	}
+
+	channel := make(chan User)
+	errChan := table.Query(table.emailField.Equals("name@email.com"), nil).SetUnmarshalWorkers(4).ExecuteWith(ctx, db).StreamWithChannel(channel)
+	var passwords []string
+	for u := range channel {
+		passwords = append(passwords, u.Password)
+	}
+	assert.NoError(t, <-errChan)
+	assert.Equal(t, want, passwords)
+
+	/*Items ahead of a bad one are still sent, in order*/
+	pages := pagedItems(30, 10)
+	pages[1][3]["loginCount"] = &dynamodb.AttributeValue{N: aws.String("1")}
+	passwords, err := stream(table.Scan().SetUnmarshalWorkers(4).ExecuteWith(ctx, newPagedStub(pages, &calls)))
+	assert.EqualError(t, err, `item {email: "name@email.com", password: "password13"} (page 1): bad item`)
+	assert.Equal(t, want[:13], passwords)
+}
+
+func BenchmarkStreamUnmarshalWorkers(b *testing.B) {
+	table := NewUserTable()
+	calls := 0
+	var pages [][]map[string]*dynamodb.AttributeValue
+	for p := 0; p < 5; p++ {
+		var page []map[string]*dynamodb.AttributeValue
+		for i := 0; i < 100; i++ {
+			item := map[string]*dynamodb.AttributeValue{}
+			for a := 0; a < 100; a++ {
+				item[fmt.Sprintf("attribute%d", a)] = &dynamodb.AttributeValue{S: aws.String(strings.Repeat("v", 20))}
+			}
+			page = append(page, item)
+		}
+		pages = append(pages, page)
+	}
+	db := newPagedStub(pages, &calls)
+
+	for _, workers := range []int{1, 2, 4} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				channel := make(chan map[string]interface{})
+				errChan := table.Scan().SetUnmarshalWorkers(workers).ExecuteWith(context.Background(), db).StreamWithChannel(channel)
+				for range channel {
+				}
+				if err := <-errChan; err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestResultsPage(t *testing.T) {
+	ctx := context.Background()
+	table := NewUserTable()
+
+	item := DynamoDBValue{
+		"email":    {S: aws.String("name@email.com")},
+		"password": {S: aws.String("b")},
+	}
+	last := DynamoDBValue{
+		"email":    {S: aws.String("name@email.com")},
+		"password": {S: aws.String("c")},
+	}
+	capacity := &dynamodb.ConsumedCapacity{TableName: aws.String("users"), CapacityUnits: aws.Float64(0.5)}
+	db := &stubDB{
+		query: func(in *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+			return &dynamodb.QueryOutput{Items: []map[string]*dynamodb.AttributeValue{item}, Count: aws.Int64(1), ScannedCount: aws.Int64(3), LastEvaluatedKey: last, ConsumedCapacity: capacity}, nil
+		},
+		scan: func(in *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+			return &dynamodb.ScanOutput{Items: []map[string]*dynamodb.AttributeValue{item}, Count: aws.Int64(1), ScannedCount: aws.Int64(3), ConsumedCapacity: capacity}, nil
+		},
+	}
+
+	page, err := table.Query(table.emailField.Equals("name@email.com"), nil).
+		SetFilterExpression(table.passwordField.Equals("b")).
+		ExecuteWith(ctx, db).
+		ResultsPage()
+	assert.NoError(t, err)
+	assert.Equal(t, Page{
+		Items:            []DynamoDBValue{item},
+		LastEvaluatedKey: last,
+		Count:            1,
+		ScannedCount:     3,
+		ConsumedCapacity: capacity,
+	}, page)
+
+	page, err = table.Scan().
+		SetFilterExpression(table.passwordField.Equals("b")).
+		ExecuteWith(ctx, db).
+		ResultsPage()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), page.Count)
+	assert.Equal(t, int64(3), page.ScannedCount)
+	assert.Nil(t, page.LastEvaluatedKey)
+
+	/*ResultsList is the page without its metadata*/
+	values, lastKey, err := table.Query(table.emailField.Equals("name@email.com"), nil).ExecuteWith(ctx, db).ResultsList()
+	assert.NoError(t, err)
+	assert.Equal(t, []DynamoDBValue{item}, values)
+	assert.Equal(t, last, lastKey)
+}
+
+func TestResultsPageFiltered(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	table := NewUserTable()
+	db := NewDB()
+
+	table.DynamoTable = disposableTable(t, db, table.DynamoTable)
+	defer dispose(db, table.DynamoTable)
+
+	var items []interface{}
+	for i := 0; i < 10; i++ {
+		items = append(items, &User{Email: "name@email.com", Password: "password" + strconv.Itoa(i), LoginCount: i})
+	}
+	err := table.BatchWriteItem().PutItems(items...).ExecuteWith(ctx, db).Results(nil)
+	assert.NoError(t, err)
+
+	q := table.Query(table.emailField.Equals("name@email.com"), nil).
+		SetFilterExpression(table.loginCount.GreaterThanOrEq(7))
+	q.QueryInput.SetReturnConsumedCapacity(dynamodb.ReturnConsumedCapacityTotal)
+	page, err := q.ExecuteWith(ctx, db).ResultsPage()
+	assert.NoError(t, err)
+	assert.Len(t, page.Items, 3)
+	assert.Equal(t, int64(3), page.Count)
+	assert.Equal(t, int64(10), page.ScannedCount)
+	assert.Nil(t, page.LastEvaluatedKey)
+	assert.NotNil(t, page.ConsumedCapacity)
+}
+
+func TestSample(t *testing.T) {
+	ctx := context.Background()
+	table := NewUserTable()
+
+	/*1000 items, stored and spread over segments by a hash of their key, each page costing half a unit*/
+	hash := func(i int) int64 { return int64(i * 7919 % 1009) }
+	var stored []int
+	for i := 0; i < 1000; i++ {
+		stored = append(stored, i)
+	}
+	sort.Slice(stored, func(a, b int) bool { return hash(stored[a]) < hash(stored[b]) })
+	var calls, inFlight, maxInFlight int32
+	db := ctxScanDB{scan: func(ctx aws.Context, in *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+		atomic.AddInt32(&calls, 1)
+		if n := atomic.AddInt32(&inFlight, 1); n > atomic.LoadInt32(&maxInFlight) {
+			atomic.StoreInt32(&maxInFlight, n)
+		}
+		time.Sleep(time.Millisecond)
+		defer atomic.AddInt32(&inFlight, -1)
+		out := &dynamodb.ScanOutput{ConsumedCapacity: &dynamodb.ConsumedCapacity{CapacityUnits: aws.Float64(0.5)}}
+		for _, i := range stored {
+			if int64(len(out.Items)) >= *in.Limit {
+				break
+			}
+			if hash(i)%*in.TotalSegments == *in.Segment {
+				out.Items = append(out.Items, map[string]*dynamodb.AttributeValue{
+					"email":    {S: aws.String("name@email.com")},
+					"password": {S: aws.String(strconv.Itoa(i))},
+				})
+			}
+		}
+		return out, nil
+	}}
+
+	items, err := table.Sample(ctx, db, 100, SampleSegments(200), SampleSeed(1), SampleConcurrency(1))
+	assert.NoError(t, err)
+	assert.Len(t, items, 100)
+	seen := map[string]bool{}
+	sum := 0
+	for _, item := range items {
+		password := *item["password"].S
+		assert.False(t, seen[password], password)
+		seen[password] = true
+		i, _ := strconv.Atoi(password)
+		sum += i
+	}
+	assert.InDelta(t, 500, sum/len(items), 150)
+	assert.Equal(t, int32(100), calls)
+	assert.Equal(t, int32(1), maxInFlight)
+
+	/*Segments are read concurrently, reading at most a page per worker more than needed. The sample still repeats*/
+	calls, maxInFlight = 0, 0
+	again, err := table.Sample(ctx, db, 100, SampleSegments(200), SampleSeed(1), SampleConcurrency(8))
+	assert.NoError(t, err)
+	assert.Equal(t, items, again)
+	assert.True(t, calls >= 100 && calls < 108, "%d calls", calls)
+	assert.True(t, maxInFlight > 1 && maxInFlight <= 8, "%d in flight", maxInFlight)
+
+	/*The capacity budget is shared by the workers, and stops sampling early*/
+	calls = 0
+	items, err = table.Sample(ctx, db, 100, SampleSegments(200), SampleMaxCapacity(5), SampleConcurrency(1))
+	assert.NoError(t, err)
+	assert.Len(t, items, 10)
+	assert.Equal(t, int32(10), calls)
+
+	calls = 0
+	items, err = table.Sample(ctx, db, 100, SampleSegments(200), SampleMaxCapacity(5), SampleConcurrency(4))
+	assert.NoError(t, err)
+	assert.True(t, len(items) >= 10 && len(items) < 14, "%d items", len(items))
+	assert.Equal(t, int32(len(items)), calls)
+
+	/*Fewer segments than items take several items from each*/
+	calls = 0
+	items, err = table.Sample(ctx, db, 100, SampleSegments(10))
+	assert.NoError(t, err)
+	assert.Len(t, items, 100)
+	assert.Equal(t, int32(10), calls)
+
+	/*Nothing to sample sends no scans*/
+	calls = 0
+	for _, n := range []int{0, -1} {
+		items, err = table.Sample(ctx, db, n, SampleSegments(10))
+		assert.NoError(t, err)
+		assert.Empty(t, items)
+	}
+	assert.Equal(t, int32(0), calls)
+}
+
+func TestSampleSeeded(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	table := NewUserTable()
+	db := NewDB()
+	table.DynamoTable = disposableTable(t, db, table.DynamoTable)
+	defer dispose(db, table.DynamoTable)
+
+	var users []interface{}
+	for i := 0; i < 300; i++ {
+		users = append(users, &User{Email: fmt.Sprintf("%d@email.com", i), Password: strconv.Itoa(i)})
+	}
+	err := table.BatchWriteItem().PutItems(users...).ExecuteWith(ctx, db).Results(nil)
+	assert.NoError(t, err)
+
+	items, err := table.Sample(ctx, db, 50, SampleSegments(100))
+	assert.NoError(t, err)
+	assert.Len(t, items, 50)
+	low := 0
+	seen := map[string]bool{}
+	for _, item := range items {
+		password := *item["password"].S
+		assert.False(t, seen[password], password)
+		seen[password] = true
+		if i, _ := strconv.Atoi(password); i < 150 {
+			low++
+		}
+	}
+	assert.True(t, low >= 10 && low <= 40, "%d of 50 items from the lower half", low)
+}
+
+func TestCapacityBudget(t *testing.T) {
+	ctx := context.Background()
+	table := NewUserTable()
+
+	/*Each page of 10 items reports 2.5 units*/
+	calls := 0
+	paged := newPagedStub(pagedItems(50, 10), &calls)
+	var requested []string
+	capacity := &dynamodb.ConsumedCapacity{TableName: aws.String("users"), CapacityUnits: aws.Float64(2.5)}
+	db := &stubDB{
+		query: func(in *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+			requested = append(requested, aws.StringValue(in.ReturnConsumedCapacity))
+			out, err := paged.query(in)
+			out.ConsumedCapacity = capacity
+			return out, err
+		},
+		scan: func(in *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+			requested = append(requested, aws.StringValue(in.ReturnConsumedCapacity))
+			out, err := paged.scan(in)
+			out.ConsumedCapacity = capacity
+			return out, err
+		},
+	}
+
+	/*Pages are read until the budget is spent, overshooting by the last page*/
+	var handled float64
+	query := table.Query(table.emailField.Equals("name@email.com"), nil).
+		SetCapacityBudget(6).
+		WithConsumedCapacityHandler(func(c *dynamodb.ConsumedCapacity) { handled += *c.CapacityUnits })
+	out := query.ExecuteWith(ctx, db)
+	var users []User
+	err := out.Results(func() interface{} {
+		users = append(users, User{})
+		return &users[len(users)-1]
+	})
+	assert.Equal(t, ErrCapacityBudgetExceeded, err)
+	assert.Len(t, users, 30)
+	assert.Equal(t, pageKey(3), out.LastEvaluatedKey())
+	assert.Equal(t, 7.5, handled)
+	assert.Equal(t, []string{"INDEXES", "INDEXES", "INDEXES"}, requested)
+
+	/*Resuming from the key picks up where the budget ran out*/
+	requested = nil
+	sout := table.Scan().WithLastEvaluatedKey(pageKey(3)).SetCapacityBudget(6).ExecuteWith(ctx, db)
+	channel := make(chan *User)
+	errChan := sout.StreamWithChannel(channel)
+	var passwords []string
+	for u := range channel {
+		passwords = append(passwords, u.Password)
+	}
+	assert.NoError(t, <-errChan)
+	assert.Len(t, passwords, 20)
+	assert.Equal(t, "password30", passwords[0])
+	assert.Nil(t, sout.LastEvaluatedKey())
+	assert.Equal(t, []string{"TOTAL", "TOTAL"}, requested)
+
+	/*A budget spent by the first page still returns that page*/
+	requested = nil
+	out = table.Query(table.emailField.Equals("name@email.com"), nil).SetCapacityBudget(2).ExecuteWith(ctx, db)
+	_, _, err = out.ResultsList()
+	assert.NoError(t, err)
+	_, _, err = out.ResultsList()
+	assert.Equal(t, ErrCapacityBudgetExceeded, err)
+	assert.Equal(t, pageKey(1), out.LastEvaluatedKey())
+	assert.Equal(t, []string{"TOTAL"}, requested)
+}
+
+func TestStopBeforeDeadline(t *testing.T) {
+	table := NewUserTable()
+
+	/*Each page takes 20ms and holds the next item, for as many pages as there are*/
+	pages := 0
+	page := func(start map[string]*dynamodb.AttributeValue) (items []map[string]*dynamodb.AttributeValue, last map[string]*dynamodb.AttributeValue) {
+		time.Sleep(20 * time.Millisecond)
+		offset := 0
+		if start != nil {
+			offset, _ = strconv.Atoi(*start["password"].S)
+		}
+		item := map[string]*dynamodb.AttributeValue{
+			"email":    {S: aws.String("name@email.com")},
+			"password": {S: aws.String(strconv.Itoa(offset + 1))},
+		}
+		if offset+1 >= pages {
+			return []map[string]*dynamodb.AttributeValue{item}, nil
+		}
+		return []map[string]*dynamodb.AttributeValue{item}, item
+	}
+	db := &stubDB{
+		query: func(in *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+			items, last := page(in.ExclusiveStartKey)
+			return &dynamodb.QueryOutput{Items: items, LastEvaluatedKey: last}, nil
+		},
+		scan: func(in *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+			items, last := page(in.ExclusiveStartKey)
+			return &dynamodb.ScanOutput{Items: items, LastEvaluatedKey: last}, nil
+		},
+	}
+
+	/*Paging stops cleanly while there's still time left, with a key to resume from*/
+	pages = 1000
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	var items []DynamoDBValue
+	collect := func(av DynamoDBValue) error {
+		items = append(items, av)
+		return nil
+	}
+	q := table.Query(table.emailField.Equals("name@email.com"), nil).
+		StopBeforeDeadline(100*time.Millisecond).
+		ExecuteWith(ctx, db)
+	assert.NoError(t, q.ResultsFunc(collect))
+	assert.NoError(t, ctx.Err())
+	assert.True(t, q.Partial())
+	assert.NotEmpty(t, items)
+	assert.True(t, len(items) < 10)
+	assert.Equal(t, strconv.Itoa(len(items)), *q.LastEvaluatedKey()["password"].S)
+
+	items = nil
+	s := table.Scan().StopBeforeDeadline(100*time.Millisecond).ExecuteWith(ctx, db)
+	assert.NoError(t, s.ResultsFunc(collect))
+	assert.True(t, s.Partial())
+	assert.Empty(t, items)
+	assert.Nil(t, s.LastEvaluatedKey())
+
+	/*Without a deadline, or with time to spare, every page is read*/
+	pages, items = 3, nil
+	q = table.Query(table.emailField.Equals("name@email.com"), nil).
+		StopBeforeDeadline(100*time.Millisecond).
+		ExecuteWith(context.Background(), db)
+	assert.NoError(t, q.ResultsFunc(collect))
+	assert.False(t, q.Partial())
+	assert.Len(t, items, 3)
+	assert.Nil(t, q.LastEvaluatedKey())
+}
+
+func TestMaxScannedCount(t *testing.T) {
+	ctx := context.Background()
+	table := NewUserTable()
+
+	/*A table of size items, none of which match the filter, evaluated at most 40 per page*/
+	var evaluated int64
+	var limits []int64
+	page := func(start map[string]*dynamodb.AttributeValue, limit *int64, size int64) (scanned int64, last map[string]*dynamodb.AttributeValue) {
+		offset := int64(0)
+		if start != nil {
+			offset, _ = strconv.ParseInt(*start["password"].S, 10, 64)
+		}
+		scanned = 40
+		if limit != nil && *limit < scanned {
+			scanned = *limit
+		}
+		if offset+scanned >= size {
+			return size - offset, nil
+		}
+		limits = append(limits, aws.Int64Value(limit))
+		evaluated += scanned
+		return scanned, map[string]*dynamodb.AttributeValue{
+			"email":    {S: aws.String("name@email.com")},
+			"password": {S: aws.String(strconv.FormatInt(offset+scanned, 10))},
+		}
+	}
+	db := &stubDB{
+		scan: func(in *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+			scanned, last := page(in.ExclusiveStartKey, in.Limit, 1000)
+			return &dynamodb.ScanOutput{Count: aws.Int64(0), ScannedCount: &scanned, LastEvaluatedKey: last}, nil
+		},
+		query: func(in *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+			scanned, last := page(in.ExclusiveStartKey, in.Limit, 1000)
+			return &dynamodb.QueryOutput{Count: aws.Int64(0), ScannedCount: &scanned, LastEvaluatedKey: last}, nil
+		},
+	}
+
+	out := table.Scan().
+		SetFilterExpression(table.loginCount.GreaterThan(1000)).
+		SetMaxScannedCount(100).
+		ExecuteWith(ctx, db)
+	var users []User
+	err := out.Results(func() interface{} {
+		users = append(users, User{})
+		return &users[len(users)-1]
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, users)
+	assert.Equal(t, int64(100), out.ScannedCount())
+	assert.Equal(t, int64(100), evaluated)
+	assert.Equal(t, []int64{100, 60, 20}, limits)
+	assert.True(t, out.MaxScannedCountReached())
+	assert.Equal(t, "100", *out.LastEvaluatedKey()["password"].S)
+
+	/*Resuming from the key continues where the bound stopped*/
+	evaluated, limits = 0, nil
+	qout := table.Query(table.emailField.Equals("name@email.com"), nil).
+		SetFilterExpression(table.loginCount.GreaterThan(1000)).
+		SetPageSize(30).
+		SetMaxScannedCount(50).
+		WithLastEvaluatedKey(out.LastEvaluatedKey()).
+		ExecuteWith(ctx, db)
+	err = qout.ResultsFunc(func(DynamoDBValue) error { return nil })
+	assert.NoError(t, err)
+	assert.Equal(t, int64(50), qout.ScannedCount())
+	assert.Equal(t, []int64{30, 20}, limits)
+	assert.True(t, qout.MaxScannedCountReached())
+	assert.Equal(t, "150", *qout.LastEvaluatedKey()["password"].S)
+
+	/*Exhausting the table first isn't reported as reaching the bound*/
+	db.scan = func(in *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+		scanned, last := page(in.ExclusiveStartKey, in.Limit, 70)
+		return &dynamodb.ScanOutput{Count: aws.Int64(0), ScannedCount: &scanned, LastEvaluatedKey: last}, nil
+	}
+	out = table.Scan().SetMaxScannedCount(100).ExecuteWith(ctx, db)
+	assert.NoError(t, out.ResultsFunc(func(DynamoDBValue) error { return nil }))
+	assert.Equal(t, int64(70), out.ScannedCount())
+	assert.False(t, out.MaxScannedCountReached())
+	assert.Nil(t, out.LastEvaluatedKey())
+}
@@ -0,0 +1,123 @@
+package dominotest
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/stretchr/testify/assert"
+	"github.com/vsco/domino"
+)
+
+/**
+ ** AssertItemsEqual ... Compare two items by what they hold rather than how it is encoded
+ ** Numbers are compared by value, so 1, 1.0 and 1E+00 are equal, and string, number and binary sets ignore order.
+ ** Mismatches are reported as a diff of the normalized items
+ */
+func AssertItemsEqual(t testing.TB, expected, actual domino.DynamoDBValue) bool {
+	t.Helper()
+	return assert.Equal(t, normalizeItem(expected), normalizeItem(actual))
+}
+
+/**
+ ** AssertContainsItem ... Check that items holds want, compared as by AssertItemsEqual
+ ** want can be a DynamoDBValue, or anything dynamodbattribute can marshal into one
+ */
+func AssertContainsItem(t testing.TB, items []domino.DynamoDBValue, want interface{}) bool {
+	t.Helper()
+	item, ok := want.(domino.DynamoDBValue)
+	if !ok {
+		av, err := dynamodbattribute.MarshalMap(want)
+		if err != nil {
+			t.Errorf("dominotest: marshaling %#v: %v", want, err)
+			return false
+		}
+		item = av
+	}
+
+	normalized := normalizeItem(item)
+	var all []string
+	for _, i := range items {
+		n := normalizeItem(i)
+		if assert.ObjectsAreEqual(normalized, n) {
+			return true
+		}
+		all = append(all, fmt.Sprintf("\t%v", n))
+	}
+	return assert.Fail(t, fmt.Sprintf("Item not found\nwant:\n\t%v\nin %d items:\n%s", normalized, len(items), strings.Join(all, "\n")))
+}
+
+/*normalizeItem maps each attribute to a {type: value} form that compares equal for equivalent encodings*/
+func normalizeItem(item domino.DynamoDBValue) map[string]interface{} {
+	if item == nil {
+		return nil
+	}
+	n := make(map[string]interface{}, len(item))
+	for k, v := range item {
+		n[k] = normalizeValue(v)
+	}
+	return n
+}
+
+func normalizeValue(v *dynamodb.AttributeValue) interface{} {
+	switch {
+	case v == nil:
+		return nil
+	case v.S != nil:
+		return map[string]interface{}{"S": *v.S}
+	case v.N != nil:
+		return map[string]interface{}{"N": normalizeNumber(*v.N)}
+	case v.B != nil:
+		return map[string]interface{}{"B": base64.StdEncoding.EncodeToString(v.B)}
+	case v.BOOL != nil:
+		return map[string]interface{}{"BOOL": *v.BOOL}
+	case v.NULL != nil:
+		return map[string]interface{}{"NULL": *v.NULL}
+	case v.SS != nil:
+		var set []string
+		for _, s := range v.SS {
+			set = append(set, *s)
+		}
+		return map[string]interface{}{"SS": sorted(set)}
+	case v.NS != nil:
+		var set []string
+		for _, s := range v.NS {
+			set = append(set, normalizeNumber(*s))
+		}
+		return map[string]interface{}{"NS": sorted(set)}
+	case v.BS != nil:
+		var set []string
+		for _, b := range v.BS {
+			set = append(set, base64.StdEncoding.EncodeToString(b))
+		}
+		return map[string]interface{}{"BS": sorted(set)}
+	case v.L != nil:
+		list := make([]interface{}, len(v.L))
+		for i, e := range v.L {
+			list[i] = normalizeValue(e)
+		}
+		return map[string]interface{}{"L": list}
+	case v.M != nil:
+		return map[string]interface{}{"M": normalizeItem(v.M)}
+	}
+	return map[string]interface{}{}
+}
+
+/*normalizeNumber formats a dynamo number canonically. Dynamo numbers have up to 38 digits, well within 256 bits*/
+func normalizeNumber(n string) string {
+	f, _, err := big.ParseFloat(n, 10, 256, big.ToNearestEven)
+	if err != nil {
+		return n
+	}
+	return f.Text('g', -1)
+}
+
+func sorted(s []string) []string {
+	sort.Strings(s)
+	return s
+}
@@ -0,0 +1,86 @@
+package dominotest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/stretchr/testify/assert"
+	"github.com/vsco/domino"
+)
+
+/*recorder is a testing.TB that records failures instead of failing the test*/
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Name() string { return "recorder" }
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestTableName(t *testing.T) {
+	name := TableName("users", "TestQuery/sub test")
+	assert.Regexp(t, `^users-TestQuery_sub_test-[0-9a-f]{8}$`, name)
+	assert.NotEqual(t, name, TableName("users", "TestQuery/sub test"))
+	assert.Len(t, TableName("users", string(make([]byte, 500))), maxTableNameBytes)
+}
+
+func TestAssertItemsEqual(t *testing.T) {
+	expected := domino.DynamoDBValue{
+		"count":  {N: aws.String("1")},
+		"score":  {N: aws.String("0.5")},
+		"tags":   {SS: aws.StringSlice([]string{"a", "b"})},
+		"visits": {NS: aws.StringSlice([]string{"1", "20"})},
+		"tokens": {BS: [][]byte{[]byte("x"), []byte("y")}},
+		"nested": {M: map[string]*dynamodb.AttributeValue{
+			"n": {N: aws.String("100")},
+			"l": {L: []*dynamodb.AttributeValue{{N: aws.String("2")}, {S: aws.String("s")}}},
+		}},
+	}
+	actual := domino.DynamoDBValue{
+		"count":  {N: aws.String("1.0")},
+		"score":  {N: aws.String("5E-1")},
+		"tags":   {SS: aws.StringSlice([]string{"b", "a"})},
+		"visits": {NS: aws.StringSlice([]string{"2E+1", "1.00"})},
+		"tokens": {BS: [][]byte{[]byte("y"), []byte("x")}},
+		"nested": {M: map[string]*dynamodb.AttributeValue{
+			"n": {N: aws.String("1E+02")},
+			"l": {L: []*dynamodb.AttributeValue{{N: aws.String("2.0")}, {S: aws.String("s")}}},
+		}},
+	}
+	r := &recorder{}
+	assert.True(t, AssertItemsEqual(r, expected, actual))
+	assert.Empty(t, r.errors)
+
+	// Lists keep their order, and types must match
+	actual["nested"].M["l"].L = []*dynamodb.AttributeValue{{S: aws.String("s")}, {N: aws.String("2")}}
+	actual["count"] = &dynamodb.AttributeValue{S: aws.String("1")}
+	assert.False(t, AssertItemsEqual(r, expected, actual))
+	assert.Len(t, r.errors, 1)
+	assert.Contains(t, r.errors[0], `+  (string) (len=1) "S": (string) (len=1) "1"`)
+}
+
+func TestAssertContainsItem(t *testing.T) {
+	type user struct {
+		Email  string  `dynamodbav:"email"`
+		Visits []int64 `dynamodbav:"visits,numberset"`
+	}
+	items := []domino.DynamoDBValue{
+		{"email": {S: aws.String("a@email.com")}, "visits": {NS: aws.StringSlice([]string{"3.0", "1"})}},
+		{"email": {S: aws.String("b@email.com")}},
+	}
+	r := &recorder{}
+	assert.True(t, AssertContainsItem(r, items, user{Email: "a@email.com", Visits: []int64{1, 3}}))
+	assert.True(t, AssertContainsItem(r, items, domino.DynamoDBValue{"email": {S: aws.String("b@email.com")}}))
+	assert.Empty(t, r.errors)
+
+	assert.False(t, AssertContainsItem(r, items, user{Email: "a@email.com", Visits: []int64{1}}))
+	assert.Len(t, r.errors, 1)
+	assert.Contains(t, r.errors[0], "in 2 items:")
+}
@@ -0,0 +1,97 @@
+/*Package dominotest provides disposable dynamo tables for integration tests*/
+package dominotest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"regexp"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/vsco/domino"
+)
+
+/*maxTableNameBytes is dynamo's limit on table names*/
+const maxTableNameBytes = 255
+
+var invalidTableNameChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]`)
+
+/**
+ ** Harness ... A uniquely named copy of a table, created for a single test
+ ** Tests sharing a local dynamo no longer clobber each other's tables, so packages can run in parallel.
+ ** Defer Close to delete the table when the test finishes. Close isn't registered with t.Cleanup, as the Go 1.10
+ ** toolchain rules_go 0.12 registers predates it.
+ */
+type Harness struct {
+	Table domino.DynamoTable
+	t     testing.TB
+	db    domino.DynamoDBIFace
+}
+
+/**
+ ** NewHarness ... Create a copy of template named after the test, and wait for it to become ACTIVE
+ **
+ **	h := dominotest.NewHarness(t, db, table)
+ **	defer h.Close()
+ */
+func NewHarness(t testing.TB, db domino.DynamoDBIFace, template domino.DynamoTable) *Harness {
+	t.Helper()
+	table := template
+	table.Name = TableName(template.Name, t.Name())
+
+	ctx := context.Background()
+	if _, err := table.EnsureTable(ctx, db); err != nil {
+		t.Fatalf("dominotest: creating table %s: %v", table.Name, err)
+	}
+	return &Harness{Table: table, t: t, db: db}
+}
+
+/*Close ... Delete the table. A table the test already deleted is fine*/
+func (h *Harness) Close() {
+	h.t.Helper()
+	err := h.Table.DeleteTable().ExecuteWith(context.Background(), h.db)
+	if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == dynamodb.ErrCodeResourceNotFoundException {
+		return
+	}
+	if err != nil {
+		h.t.Errorf("dominotest: deleting table %s: %v", h.Table.Name, err)
+	}
+}
+
+/*TableName ... A unique table name of the form <base>-<test>-<random>, restricted to the characters dynamo allows*/
+func TableName(base, test string) string {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		panic(err)
+	}
+	random := hex.EncodeToString(suffix)
+	test = invalidTableNameChars.ReplaceAllString(test, "_")
+	if max := maxTableNameBytes - len(base) - len(random) - 2; len(test) > max {
+		if max < 0 {
+			max = 0
+		}
+		test = test[:max]
+	}
+	return base + "-" + test + "-" + random
+}
+
+/*SeedItems ... Batch write items to the table, failing the test if any are left unprocessed*/
+func (h *Harness) SeedItems(items ...interface{}) {
+	h.t.Helper()
+	unprocessed := 0
+	err := h.Table.BatchWriteItem().
+		PutItems(items...).
+		ExecuteWith(context.Background(), h.db).
+		UnprocessedPuts(func() interface{} {
+			unprocessed++
+			return &map[string]interface{}{}
+		})
+	if err != nil {
+		h.t.Fatalf("dominotest: seeding table %s: %v", h.Table.Name, err)
+	}
+	if unprocessed > 0 {
+		h.t.Fatalf("dominotest: seeding table %s: %d items were left unprocessed", h.Table.Name, unprocessed)
+	}
+}
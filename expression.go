@@ -3,15 +3,21 @@ package domino
 import (
 	"fmt"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
 )
 
 /*Expression represents a dynamo Condition expression, i.e. And(if_empty(...), size(path) >0) */
 type Expression interface {
 	construct(prefix string, counter uint, b bool) (string, map[string]*string, map[string]interface{}, uint)
+	ToSDKBuilder() (expression.ConditionBuilder, error)
 }
 type ExpressionGroup struct {
 	expressions []Expression
@@ -21,6 +27,9 @@ type ExpressionGroup struct {
 type Condition struct {
 	exprF func([]string) string
 	args  []interface{}
+	field string
+	names []string // attributes referenced by name placeholders, passed to exprF ahead of the value placeholders
+	sdk   func() (expression.ConditionBuilder, error)
 }
 
 type KeyCondition struct {
@@ -42,8 +51,21 @@ const (
 
 var nonalpha *regexp.Regexp = regexp.MustCompile("[^a-zA-Z_0-9]")
 
-func generatePlaceholder(a string, counter uint) string {
+/*placeholderFieldBytes caps how much of a field name goes into a value placeholder*/
+const placeholderFieldBytes = 64
+
+/*
+* generatePlaceholder names a value placeholder after its clause and field, i.e. :cond_registrationDate_3.
+* The counter, always last, keeps placeholders unique within a clause and the clause prefix across clauses
+ */
+func generatePlaceholder(a string, field string, counter uint) string {
 	r := fmt.Sprintf("%s_%d", a, counter)
+	if field != "" {
+		if len(field) > placeholderFieldBytes {
+			field = field[:placeholderFieldBytes]
+		}
+		r = fmt.Sprintf("%s_%s_%d", a, field, counter)
+	}
 	return ":" + nonalpha.ReplaceAllString(r, "_")
 }
 
@@ -107,6 +129,14 @@ func And(c ...Expression) ExpressionGroup {
 	}
 }
 
+/*conjunction ands expressions together, leaving a lone expression as is*/
+func conjunction(c []Expression) Expression {
+	if len(c) == 1 {
+		return c[0]
+	}
+	return And(c...)
+}
+
 /*String stringifies expressions for easy debugging*/
 func (c ExpressionGroup) String() string {
 	s, _, _, _ := c.construct("expr", 0, true)
@@ -143,18 +173,29 @@ func Not(c Expression) negation {
 /*******Conditions that only apply to keys*********/
 
 func (c Condition) construct(prefix string, counter uint, topLevel bool) (string, map[string]*string, map[string]interface{}, uint) {
+	var n map[string]*string
+	var placeholders []string
+	for _, name := range c.names {
+		ph := generateNamePlaceholder(prefix, counter)
+		if n == nil {
+			n = map[string]*string{}
+		}
+		n[ph] = aws.String(name)
+		placeholders = append(placeholders, ph)
+		counter++
+	}
 	a := make([]string, len(c.args))
 	var m map[string]interface{}
 	for i, b := range c.args {
-		a[i] = generatePlaceholder(prefix, counter)
+		a[i] = generatePlaceholder(prefix, c.field, counter)
 		if m == nil {
 			m = map[string]interface{}{}
 		}
 		m[a[i]] = b
 		counter++
 	}
-	s := c.exprF(a)
-	return s, nil, m, counter
+	s := c.exprF(append(placeholders, a...))
+	return s, n, m, counter
 }
 
 func (c Condition) String() string {
@@ -168,7 +209,18 @@ func (p *DynamoField) In(elems ...interface{}) Condition {
 		exprF: func(placeholders []string) string {
 			return fmt.Sprintf("(%s in (%s))", p.name, strings.Join(placeholders, ","))
 		},
-		args: elems,
+		args:  elems,
+		field: p.name,
+		sdk: func() (c expression.ConditionBuilder, err error) {
+			if len(elems) == 0 {
+				return c, fmt.Errorf("Cannot convert %s in () to an sdk condition: no values given.", p.name)
+			}
+			values := make([]expression.OperandBuilder, len(elems))
+			for i, e := range elems {
+				values[i] = expression.Value(e)
+			}
+			return expression.In(expression.Name(p.name), values[0], values[1:]...), nil
+		},
 	}
 
 }
@@ -179,6 +231,9 @@ func (p *DynamoField) Exists() Condition {
 		exprF: func(placeholders []string) string {
 			return "attribute_exists(" + p.name + ")"
 		},
+		sdk: func() (expression.ConditionBuilder, error) {
+			return expression.AttributeExists(expression.Name(p.name)), nil
+		},
 	}
 }
 
@@ -188,6 +243,9 @@ func (p *DynamoField) NotExists() Condition {
 		exprF: func(placeholders []string) string {
 			return "attribute_not_exists(" + p.name + ")"
 		},
+		sdk: func() (expression.ConditionBuilder, error) {
+			return expression.AttributeNotExists(expression.Name(p.name)), nil
+		},
 	}
 }
 
@@ -197,7 +255,16 @@ func (p *dynamoCollectionField) Contains(a interface{}) Condition {
 		exprF: func(placeholders []string) string {
 			return fmt.Sprintf("contains("+p.name+",%s)", placeholders[0])
 		},
-		args: []interface{}{a},
+		args:  []interface{}{a},
+		field: p.name,
+		sdk: func() (c expression.ConditionBuilder, err error) {
+			/*The sdk's contains only takes strings*/
+			e, ok := a.(string)
+			if !ok {
+				return c, fmt.Errorf("Cannot convert contains(%s) to an sdk condition: %T is not a string.", p.name, a)
+			}
+			return expression.Contains(expression.Name(p.name), e), nil
+		},
 	}
 }
 
@@ -207,7 +274,11 @@ func (p *String) Contains(a string) Condition {
 		exprF: func(placeholders []string) string {
 			return fmt.Sprintf("contains("+p.name+",%s)", placeholders[0])
 		},
-		args: []interface{}{a},
+		args:  []interface{}{a},
+		field: p.name,
+		sdk: func() (expression.ConditionBuilder, error) {
+			return expression.Contains(expression.Name(p.name), a), nil
+		},
 	}
 }
 
@@ -220,7 +291,11 @@ func (p *dynamoCollectionField) Size(op string, a int) Condition {
 		exprF: func(placeholders []string) string {
 			return fmt.Sprintf("size("+p.name+") "+op+"%s", placeholders[0])
 		},
-		args: []interface{}{a},
+		args:  []interface{}{a},
+		field: p.name,
+		sdk: func() (expression.ConditionBuilder, error) {
+			return sdkComparison(op, expression.Name(p.name).Size(), a)
+		},
 	}
 }
 
@@ -233,7 +308,11 @@ func (p *String) Size(op string, a int) Condition {
 		exprF: func(placeholders []string) string {
 			return fmt.Sprintf("size("+p.name+") "+op+"%s", placeholders[0])
 		},
-		args: []interface{}{a},
+		args:  []interface{}{a},
+		field: p.name,
+		sdk: func() (expression.ConditionBuilder, error) {
+			return sdkComparison(op, expression.Name(p.name).Size(), a)
+		},
 	}
 }
 
@@ -247,7 +326,11 @@ func (p *DynamoField) operation(op string, a interface{}) KeyCondition {
 			exprF: func(placeholders []string) string {
 				return fmt.Sprintf("%s %s %s", p.name, op, placeholders[0])
 			},
-			args: []interface{}{a},
+			args:  []interface{}{a},
+			field: p.name,
+			sdk: func() (expression.ConditionBuilder, error) {
+				return sdkComparison(op, expression.Name(p.name), a)
+			},
 		},
 	}
 }
@@ -272,23 +355,223 @@ func (p *DynamoField) GreaterThanOrEq(a interface{}) KeyCondition {
 }
 
 func (p *String) BeginsWith(a interface{}) KeyCondition {
+	return p.beginsWith(a)
+}
+
+/*BeginsWith ... A range key condition on a binary prefix, i.e. the leading bytes of a hash*/
+func (p *Binary) BeginsWith(a []byte) KeyCondition {
+	return p.beginsWith(a)
+}
+
+func (p *DynamoField) beginsWith(a interface{}) KeyCondition {
 	return KeyCondition{
 		Condition{
 			exprF: func(placeholders []string) string {
 				return fmt.Sprintf("begins_with("+p.name+",%s)", placeholders[0])
 			},
-			args: []interface{}{a},
+			args:  []interface{}{a},
+			field: p.name,
+			sdk: func() (c expression.ConditionBuilder, err error) {
+				/*The sdk's begins_with only takes string prefixes*/
+				e, ok := a.(string)
+				if !ok {
+					return c, fmt.Errorf("Cannot convert begins_with(%s) to an sdk condition: %T is not a string.", p.name, a)
+				}
+				return expression.BeginsWith(expression.Name(p.name), e), nil
+			},
 		},
 	}
 }
 
+/*DefaultCompositeSeparator joins the segments of composite keys, i.e. USER#1234*/
+const DefaultCompositeSeparator = "#"
+
+/*compositeEscape precedes separators, and itself, inside composite key segments*/
+const compositeEscape = "\\"
+
+/*WithSeparator returns a copy of the field that joins composite key segments with sep*/
+func (p *String) WithSeparator(sep string) String {
+	r := *p
+	r.separator = sep
+	return r
+}
+
+func (p *String) compositeSeparator() string {
+	if p.separator == "" {
+		return DefaultCompositeSeparator
+	}
+	return p.separator
+}
+
+/*Composite joins segments into a key value, i.e. ORDER#<ts>#<id>. Separators inside a segment are escaped*/
+func (p *String) Composite(segments ...interface{}) string {
+	sep := p.compositeSeparator()
+	parts := make([]string, len(segments))
+	for i, segment := range segments {
+		s := strings.Replace(fmt.Sprint(segment), compositeEscape, compositeEscape+compositeEscape, -1)
+		parts[i] = strings.Replace(s, sep, compositeEscape+sep, -1)
+	}
+	return strings.Join(parts, sep)
+}
+
+/*BeginsWithComposite matches keys starting with these whole segments, i.e. ORDER matches ORDER#1234 but not ORDERS#1234*/
+func (p *String) BeginsWithComposite(segments ...interface{}) KeyCondition {
+	return p.BeginsWith(p.Composite(segments...) + p.compositeSeparator())
+}
+
+/*SplitComposite splits a composite key value built with Composite back into its segments*/
+func (p *String) SplitComposite(value string) (segments []string) {
+	sep := p.compositeSeparator()
+	var segment []byte
+	for i := 0; i < len(value); {
+		switch {
+		case strings.HasPrefix(value[i:], compositeEscape) && i+len(compositeEscape) < len(value):
+			i += len(compositeEscape)
+			n := len(compositeEscape)
+			if strings.HasPrefix(value[i:], sep) {
+				n = len(sep)
+			}
+			segment = append(segment, value[i:i+n]...)
+			i += n
+		case strings.HasPrefix(value[i:], sep):
+			segments = append(segments, string(segment))
+			segment = nil
+			i += len(sep)
+		default:
+			segment = append(segment, value[i])
+			i++
+		}
+	}
+	return append(segments, string(segment))
+}
+
 func (p *DynamoField) Between(a interface{}, b interface{}) KeyCondition {
 	return KeyCondition{
 		Condition{
 			exprF: func(placeholders []string) string {
 				return fmt.Sprintf("("+p.name+" between %s and %s)", placeholders[0], placeholders[1])
 			},
-			args: []interface{}{a, b},
+			args:  []interface{}{a, b},
+			field: p.name,
+			sdk: func() (expression.ConditionBuilder, error) {
+				return expression.Between(expression.Name(p.name), expression.Value(a), expression.Value(b)), nil
+			},
+		},
+	}
+}
+
+const rfc3339Fixed = "2006-01-02T15:04:05.000000000Z07:00"
+
+/*Value encodes t the way this field stores it*/
+func (p *Time) Value(t time.Time) interface{} {
+	if p.encoding == RFC3339 {
+		return t.UTC().Format(rfc3339Fixed)
+	}
+	return epochValue(p.encoding, t)
+}
+
+/*epochValue encodes t as a number in the given unit, seconds unless millis or nanos*/
+func epochValue(unit TimeEncoding, t time.Time) int64 {
+	switch unit {
+	case EpochMillis:
+		return t.UnixNano() / int64(time.Millisecond)
+	case EpochNanos:
+		return t.UnixNano()
+	default:
+		return t.Unix()
+	}
+}
+
+/*Decode parses a stored attribute value of this field back into a time*/
+func (p *Time) Decode(av *dynamodb.AttributeValue) (t time.Time, err error) {
+	if av == nil {
+		return t, fmt.Errorf("Time attribute %s is missing.", p.name)
+	}
+	if p.encoding == RFC3339 {
+		if av.S == nil {
+			return t, fmt.Errorf("Time attribute %s is not a string.", p.name)
+		}
+		return time.Parse(time.RFC3339Nano, *av.S)
+	}
+	if av.N == nil {
+		return t, fmt.Errorf("Time attribute %s is not a number.", p.name)
+	}
+	n, err := strconv.ParseInt(*av.N, 10, 64)
+	if err != nil {
+		return
+	}
+	switch p.encoding {
+	case EpochMillis:
+		t = time.Unix(0, n*int64(time.Millisecond))
+	case EpochNanos:
+		t = time.Unix(0, n)
+	default:
+		t = time.Unix(n, 0)
+	}
+	return
+}
+
+func (p *Time) Equals(t time.Time) KeyCondition {
+	return p.operation(eq, p.Value(t))
+}
+func (p *Time) Before(t time.Time) KeyCondition {
+	return p.operation(lt, p.Value(t))
+}
+func (p *Time) After(t time.Time) KeyCondition {
+	return p.operation(gt, p.Value(t))
+}
+func (p *Time) Between(a time.Time, b time.Time) KeyCondition {
+	return p.DynamoField.Between(p.Value(a), p.Value(b))
+}
+
+/*WithEpochUnit returns a copy of the field whose time range conditions encode times in unit. RFC3339 is not numeric and encodes as seconds*/
+func (p *Numeric) WithEpochUnit(unit TimeEncoding) Numeric {
+	r := *p
+	r.epoch = unit
+	return r
+}
+
+/*
+* Since matches timestamps at or after t, in the field's epoch unit. Usable as a key condition or a filter
+* table.createdAt.Since(time.Now().Add(-24 * time.Hour))
+ */
+func (p *Numeric) Since(t time.Time) KeyCondition {
+	return p.operation(gte, epochValue(p.epoch, t))
+}
+
+/*Until matches timestamps at or before t, in the field's epoch unit*/
+func (p *Numeric) Until(t time.Time) KeyCondition {
+	return p.operation(lte, epochValue(p.epoch, t))
+}
+
+/*BetweenTimes matches timestamps from a to b inclusive, in the field's epoch unit*/
+func (p *Numeric) BetweenTimes(a time.Time, b time.Time) KeyCondition {
+	return p.DynamoField.Between(epochValue(p.epoch, a), epochValue(p.epoch, b))
+}
+
+/*expiryClock is the time TTL expiries are computed from*/
+var expiryClock = time.Now
+
+/*expiresAt is the TTL attribute value for an expiry d from now. Dynamo's TTL only understands epoch seconds*/
+func expiresAt(d time.Duration) int64 {
+	return expiryClock().Add(d).Unix()
+}
+
+/*
+* NotExpired filters out items whose TTL field has passed, as dynamo deletes expired items lazily.
+* Items without the field never expire and are kept. The field is referenced by a name placeholder, as ttl is reserved
+ */
+func NotExpired(field Numeric, now time.Time) Condition {
+	return Condition{
+		exprF: func(placeholders []string) string {
+			return fmt.Sprintf("(attribute_not_exists(%s) OR %s > %s)", placeholders[0], placeholders[0], placeholders[1])
+		},
+		args:  []interface{}{now.Unix()},
+		field: field.name,
+		names: []string{field.name},
+		sdk: func() (expression.ConditionBuilder, error) {
+			name := expression.Name(field.name)
+			return expression.Or(expression.AttributeNotExists(name), name.GreaterThan(expression.Value(now.Unix()))), nil
 		},
 	}
 }
@@ -296,6 +579,9 @@ func (p *DynamoField) Between(a interface{}, b interface{}) KeyCondition {
 /*********************************************************************************/
 /******************************** Update Expressions *****************************/
 /*********************************************************************************/
+/*updateOps is the order in which update clauses are rendered*/
+var updateOps = []string{"SET", "REMOVE", "ADD", "DELETE"}
+
 type UpdateExpression struct {
 	op string
 	f  func(counter uint) (expression string, exprAttributeNames map[string]*string, exprAttributeValues map[string]interface{}, c uint)
@@ -304,7 +590,7 @@ type UpdateExpression struct {
 /*SetField sets a dynamo Field. Set onlyIfEmpty to true if you want to prevent overwrites*/
 func (Field *DynamoField) SetField(a interface{}, onlyIfEmpty bool) *UpdateExpression {
 	f := func(c uint) (string, map[string]*string, map[string]interface{}, uint) {
-		ph := generatePlaceholder("update", c)
+		ph := generatePlaceholder("update", Field.name, c)
 		r := ph
 		if onlyIfEmpty {
 			r = fmt.Sprintf("if_not_exists(%s,%s)", Field.name, ph)
@@ -319,6 +605,16 @@ func (Field *DynamoField) SetField(a interface{}, onlyIfEmpty bool) *UpdateExpre
 	return &UpdateExpression{op: "SET", f: f}
 }
 
+/*SetField sets a time Field. Set onlyIfEmpty to true if you want to prevent overwrites*/
+func (Field *Time) SetField(t time.Time, onlyIfEmpty bool) *UpdateExpression {
+	return Field.DynamoField.SetField(Field.Value(t), onlyIfEmpty)
+}
+
+/*ExpireIn sets a TTL Field to expire d from now, in epoch seconds. The field is referenced by a name placeholder, as ttl is reserved*/
+func (Field *Numeric) ExpireIn(d time.Duration) *UpdateExpression {
+	return MapPath{name: Field.name}.Set(expiresAt(d))
+}
+
 /*RemoveField removes a dynamo Field.*/
 func (Field *DynamoField) RemoveField() *UpdateExpression {
 	f := func(c uint) (string, map[string]*string, map[string]interface{}, uint) {
@@ -331,7 +627,7 @@ func (Field *DynamoField) RemoveField() *UpdateExpression {
 /*Add adds an amount to dynamo numeric Field*/
 func (Field *Numeric) Add(amount float64) *UpdateExpression {
 	f := func(c uint) (string, map[string]*string, map[string]interface{}, uint) {
-		ph := generatePlaceholder("update", c)
+		ph := generatePlaceholder("update", Field.name, c)
 		s := Field.name + " " + ph
 		m := map[string]interface{}{ph: amount}
 		c++
@@ -340,10 +636,22 @@ func (Field *Numeric) Add(amount float64) *UpdateExpression {
 	return &UpdateExpression{op: "ADD", f: f}
 }
 
-/*Append appends an element to a list Field*/
+/*AddInt adds an integer amount to a numeric Field without a float64 round trip*/
+func (Field *Numeric) AddInt(amount int64) *UpdateExpression {
+	f := func(c uint) (string, map[string]*string, map[string]interface{}, uint) {
+		ph := generatePlaceholder("update", Field.name, c)
+		s := Field.name + " " + ph
+		m := map[string]interface{}{ph: &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(amount, 10))}}
+		c++
+		return s, nil, m, c
+	}
+	return &UpdateExpression{op: "ADD", f: f}
+}
+
+/*Append adds an element to the front of a list Field. See AppendAll to add elements to the end*/
 func (Field *dynamoListField) Append(a interface{}) *UpdateExpression {
 	f := func(c uint) (string, map[string]*string, map[string]interface{}, uint) {
-		ph := generatePlaceholder("update", c)
+		ph := generatePlaceholder("update", Field.name, c)
 		s := fmt.Sprintf(Field.name+" = list_append(%s,"+Field.name+")", ph)
 		m := map[string]interface{}{ph: []interface{}{a}}
 		c++
@@ -352,11 +660,54 @@ func (Field *dynamoListField) Append(a interface{}) *UpdateExpression {
 	return &UpdateExpression{op: "SET", f: f}
 }
 
+/*OrEmpty returns a copy of the list Field whose AppendAll and PrependAll create the list if the attribute is missing*/
+func (Field dynamoListField) OrEmpty() *dynamoListField {
+	Field.orEmpty = true
+	return &Field
+}
+
+/*AppendAll adds elements, in order, to the end of a list Field*/
+func (Field *dynamoListField) AppendAll(items ...interface{}) *UpdateExpression {
+	return Field.concat(items, false)
+}
+
+/*PrependAll adds elements, in order, to the front of a list Field*/
+func (Field *dynamoListField) PrependAll(items ...interface{}) *UpdateExpression {
+	return Field.concat(items, true)
+}
+
+func (Field *dynamoListField) concat(items []interface{}, prepend bool) *UpdateExpression {
+	f := func(c uint) (string, map[string]*string, map[string]interface{}, uint) {
+		if len(items) <= 0 {
+			return "", nil, nil, c
+		}
+		ph := generatePlaceholder("update", Field.name, c)
+		c++
+		m := map[string]interface{}{ph: items}
+
+		operand := Field.name
+		if Field.orEmpty {
+			eph := generatePlaceholder("update", Field.name, c)
+			c++
+			m[eph] = &dynamodb.AttributeValue{L: []*dynamodb.AttributeValue{}}
+			operand = fmt.Sprintf("if_not_exists(%s,%s)", Field.name, eph)
+		}
+
+		s := fmt.Sprintf("%s = list_append(%s,%s)", Field.name, operand, ph)
+		if prepend {
+			s = fmt.Sprintf("%s = list_append(%s,%s)", Field.name, ph, operand)
+		}
+		return s, nil, m, c
+	}
+	return &UpdateExpression{op: "SET", f: f}
+}
+
+/*Set sets the element at index of a list Field*/
 func (Field *dynamoListField) Set(index int, a interface{}) *UpdateExpression {
 	f := func(c uint) (string, map[string]*string, map[string]interface{}, uint) {
-		ph := generatePlaceholder("update", c)
+		ph := generatePlaceholder("update", Field.name, c)
 		s := fmt.Sprintf(Field.name+"[%d] = %s", index, ph)
-		m := map[string]interface{}{ph: []interface{}{a}}
+		m := map[string]interface{}{ph: a}
 		c++
 		return s, nil, m, c
 	}
@@ -373,7 +724,7 @@ func (Field *dynamoListField) Remove(index int) *UpdateExpression {
 
 func (Field *dynamoMapField) Set(key string, a interface{}) *UpdateExpression {
 	f := func(c uint) (string, map[string]*string, map[string]interface{}, uint) {
-		ph := generatePlaceholder("update", c)
+		ph := generatePlaceholder("update", Field.name+"."+key, c)
 		s := fmt.Sprintf("%s.%s = %s", Field.name, key, ph)
 		m := map[string]interface{}{
 			ph: a,
@@ -394,9 +745,107 @@ func (Field *dynamoMapField) Remove(key string) *UpdateExpression {
 	return &UpdateExpression{op: "REMOVE", f: f}
 }
 
+/*SetAll sets several keys of a map Field at once, producing one SET clause per key. Keys are set in sorted order*/
+func (Field *dynamoMapField) SetAll(values map[string]interface{}) *UpdateExpression {
+	f := func(c uint) (string, map[string]*string, map[string]interface{}, uint) {
+		if len(values) <= 0 {
+			return "", nil, nil, c
+		}
+		keys := make([]string, 0, len(values))
+		for k := range values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		field := generateNamePlaceholder("update", c)
+		c++
+		names := map[string]*string{field: aws.String(Field.name)}
+		m := map[string]interface{}{}
+		clauses := make([]string, len(keys))
+		for i, k := range keys {
+			kph := generateNamePlaceholder("update", c)
+			c++
+			ph := generatePlaceholder("update", Field.name+"."+k, c)
+			c++
+			names[kph] = aws.String(k)
+			m[ph] = values[k]
+			clauses[i] = fmt.Sprintf("%s.%s = %s", field, kph, ph)
+		}
+		return strings.Join(clauses, ", "), names, m, c
+	}
+	return &UpdateExpression{op: "SET", f: f}
+}
+
+/*MapPath is a path to a nested element of a map Field, i.e. prefs.a.b*/
+type MapPath struct {
+	name string
+	keys []string
+}
+
+/*Path addresses a nested element of a map Field. Every segment is referenced by a name placeholder*/
+func (Field *dynamoMapField) Path(keys ...string) MapPath {
+	return MapPath{name: Field.name, keys: keys}
+}
+
+/*path renders the path with a name placeholder per segment*/
+func (p MapPath) path(c uint) (string, map[string]*string, uint) {
+	segments := append([]string{p.name}, p.keys...)
+	names := make(map[string]*string, len(segments))
+	placeholders := make([]string, len(segments))
+	for i, segment := range segments {
+		ph := generateNamePlaceholder("update", c)
+		names[ph] = aws.String(segment)
+		placeholders[i] = ph
+		c++
+	}
+	return strings.Join(placeholders, "."), names, c
+}
+
+/*Set sets the element at the path*/
+func (p MapPath) Set(a interface{}) *UpdateExpression {
+	f := func(c uint) (string, map[string]*string, map[string]interface{}, uint) {
+		path, names, c := p.path(c)
+		ph := generatePlaceholder("update", strings.Join(append([]string{p.name}, p.keys...), "."), c)
+		m := map[string]interface{}{
+			ph: a,
+		}
+		c++
+		return path + " = " + ph, names, m, c
+	}
+	return &UpdateExpression{op: "SET", f: f}
+}
+
+/*Remove removes the element at the path*/
+func (p MapPath) Remove() *UpdateExpression {
+	f := func(c uint) (string, map[string]*string, map[string]interface{}, uint) {
+		path, names, c := p.path(c)
+		return path, names, nil, c
+	}
+	return &UpdateExpression{op: "REMOVE", f: f}
+}
+
+/*removeAttributes removes top level attributes by name, each referenced by a name placeholder so reserved words are safe*/
+func removeAttributes(attributes []string) *UpdateExpression {
+	f := func(c uint) (string, map[string]*string, map[string]interface{}, uint) {
+		if len(attributes) <= 0 {
+			return "", nil, nil, c
+		}
+		names := make(map[string]*string, len(attributes))
+		clauses := make([]string, len(attributes))
+		for i, attribute := range attributes {
+			ph := generateNamePlaceholder("update", c)
+			names[ph] = aws.String(attribute)
+			clauses[i] = ph
+			c++
+		}
+		return strings.Join(clauses, ", "), names, nil, c
+	}
+	return &UpdateExpression{op: "REMOVE", f: f}
+}
+
 func (Field *dynamoSetField) Add(a *dynamodb.AttributeValue) *UpdateExpression {
 	f := func(c uint) (string, map[string]*string, map[string]interface{}, uint) {
-		ph := generatePlaceholder("update", c)
+		ph := generatePlaceholder("update", Field.name, c)
 		s := fmt.Sprintf(Field.name+" %s", ph)
 		m := map[string]interface{}{ph: a}
 
@@ -407,7 +856,7 @@ func (Field *dynamoSetField) Add(a *dynamodb.AttributeValue) *UpdateExpression {
 }
 
 func (Field *dynamoSetField) AddFloat(a float64) *UpdateExpression {
-	v := strconv.FormatFloat(a, 'E', -1, 64)
+	v := formatNumber(a)
 	attr := &dynamodb.AttributeValue{
 		NS: []*string{&v},
 	}
@@ -428,9 +877,46 @@ func (Field *dynamoSetField) AddString(a string) *UpdateExpression {
 	return Field.Add(attr)
 }
 
+/*AddBinary adds an element to a binary set Field*/
+func (Field *dynamoSetField) AddBinary(a []byte) *UpdateExpression {
+	return Field.Add(&dynamodb.AttributeValue{BS: [][]byte{a}})
+}
+
+/*AddBinaries adds several elements to a binary set Field in a single clause*/
+func (Field *dynamoSetField) AddBinaries(a [][]byte) *UpdateExpression {
+	if len(a) <= 0 {
+		return noopUpdate("ADD")
+	}
+	return Field.Add(&dynamodb.AttributeValue{BS: a})
+}
+
+/*AddStrings adds several elements to a string set Field in a single clause*/
+func (Field *dynamoSetField) AddStrings(a []string) *UpdateExpression {
+	if len(a) <= 0 {
+		return noopUpdate("ADD")
+	}
+	return Field.Add(stringSet(a))
+}
+
+/*AddInts adds several elements to a numeric set Field in a single clause*/
+func (Field *dynamoSetField) AddInts(a []int64) *UpdateExpression {
+	if len(a) <= 0 {
+		return noopUpdate("ADD")
+	}
+	return Field.Add(intSet(a))
+}
+
+/*AddFloats adds several elements to a numeric set Field in a single clause*/
+func (Field *dynamoSetField) AddFloats(a []float64) *UpdateExpression {
+	if len(a) <= 0 {
+		return noopUpdate("ADD")
+	}
+	return Field.Add(floatSet(a))
+}
+
 func (Field *dynamoSetField) Delete(a *dynamodb.AttributeValue) *UpdateExpression {
 	f := func(c uint) (string, map[string]*string, map[string]interface{}, uint) {
-		ph := generatePlaceholder("update", c)
+		ph := generatePlaceholder("update", Field.name, c)
 		s := fmt.Sprintf(Field.name+" %s", ph)
 		m := map[string]interface{}{ph: a}
 		c++
@@ -440,7 +926,7 @@ func (Field *dynamoSetField) Delete(a *dynamodb.AttributeValue) *UpdateExpressio
 }
 
 func (Field *dynamoSetField) DeleteFloat(a float64) *UpdateExpression {
-	v := strconv.FormatFloat(a, 'E', -1, 64)
+	v := formatNumber(a)
 	attr := &dynamodb.AttributeValue{
 		NS: []*string{&v},
 	}
@@ -461,12 +947,514 @@ func (Field *dynamoSetField) DeleteString(a string) *UpdateExpression {
 	return Field.Delete(attr)
 }
 
+/*DeleteBinary removes an element from a binary set Field*/
+func (Field *dynamoSetField) DeleteBinary(a []byte) *UpdateExpression {
+	return Field.Delete(&dynamodb.AttributeValue{BS: [][]byte{a}})
+}
+
+/*DeleteBinaries removes several elements from a binary set Field in a single clause*/
+func (Field *dynamoSetField) DeleteBinaries(a [][]byte) *UpdateExpression {
+	if len(a) <= 0 {
+		return noopUpdate("DELETE")
+	}
+	return Field.Delete(&dynamodb.AttributeValue{BS: a})
+}
+
+/*DeleteStrings removes several elements from a string set Field in a single clause*/
+func (Field *dynamoSetField) DeleteStrings(a []string) *UpdateExpression {
+	if len(a) <= 0 {
+		return noopUpdate("DELETE")
+	}
+	return Field.Delete(stringSet(a))
+}
+
+/*DeleteInts removes several elements from a numeric set Field in a single clause*/
+func (Field *dynamoSetField) DeleteInts(a []int64) *UpdateExpression {
+	if len(a) <= 0 {
+		return noopUpdate("DELETE")
+	}
+	return Field.Delete(intSet(a))
+}
+
+/*DeleteFloats removes several elements from a numeric set Field in a single clause*/
+func (Field *dynamoSetField) DeleteFloats(a []float64) *UpdateExpression {
+	if len(a) <= 0 {
+		return noopUpdate("DELETE")
+	}
+	return Field.Delete(floatSet(a))
+}
+
+func stringSet(a []string) *dynamodb.AttributeValue {
+	ss := make([]*string, len(a))
+	for i := range a {
+		ss[i] = &a[i]
+	}
+	return &dynamodb.AttributeValue{SS: ss}
+}
+
+func intSet(a []int64) *dynamodb.AttributeValue {
+	ns := make([]*string, len(a))
+	for i, v := range a {
+		ns[i] = aws.String(strconv.FormatInt(v, 10))
+	}
+	return &dynamodb.AttributeValue{NS: ns}
+}
+
+func floatSet(a []float64) *dynamodb.AttributeValue {
+	ns := make([]*string, len(a))
+	for i, v := range a {
+		ns[i] = aws.String(formatNumber(v))
+	}
+	return &dynamodb.AttributeValue{NS: ns}
+}
+
+/*formatNumber renders a float the way dynamodbattribute marshals it*/
+func formatNumber(a float64) string {
+	return strconv.FormatFloat(a, 'f', -1, 64)
+}
+
+/*noopUpdate is an update expression that renders nothing, for empty inputs*/
+func noopUpdate(op string) *UpdateExpression {
+	f := func(c uint) (string, map[string]*string, map[string]interface{}, uint) {
+		return "", nil, nil, c
+	}
+	return &UpdateExpression{op: op, f: f}
+}
+
 /*Increment a numeric counter Field*/
 func (Field *Numeric) Increment(by uint) *UpdateExpression {
-	return Field.Add(float64(by))
+	return Field.AddInt(int64(by))
 }
 
 /*Decrement a numeric counter Field*/
 func (Field *Numeric) Decrement(by uint) *UpdateExpression {
-	return Field.Add(-float64(by))
+	return Field.AddInt(-int64(by))
+}
+
+/*********************************************************************************/
+/******************************** SDK Interop ************************************/
+/*********************************************************************************/
+
+/*ToSDKBuilder converts the group to an aws-sdk expression.ConditionBuilder*/
+func (c ExpressionGroup) ToSDKBuilder() (b expression.ConditionBuilder, err error) {
+	if len(c.expressions) == 0 {
+		return b, fmt.Errorf("Cannot convert an empty %s group to an sdk condition.", c.op)
+	}
+	builders := make([]expression.ConditionBuilder, len(c.expressions))
+	for i, e := range c.expressions {
+		if builders[i], err = e.ToSDKBuilder(); err != nil {
+			return
+		}
+	}
+	/*The sdk's And and Or need at least two operands*/
+	if len(builders) == 1 {
+		return builders[0], nil
+	}
+	if c.op == "OR" {
+		return expression.Or(builders[0], builders[1], builders[2:]...), nil
+	}
+	return expression.And(builders[0], builders[1], builders[2:]...), nil
+}
+
+/*ToSDKBuilder converts the negation to an aws-sdk expression.ConditionBuilder*/
+func (c negation) ToSDKBuilder() (b expression.ConditionBuilder, err error) {
+	if b, err = c.expression.ToSDKBuilder(); err != nil {
+		return
+	}
+	return expression.Not(b), nil
+}
+
+/*ToSDKBuilder converts the condition to an aws-sdk expression.ConditionBuilder*/
+func (c Condition) ToSDKBuilder() (b expression.ConditionBuilder, err error) {
+	if c.sdk == nil {
+		return b, fmt.Errorf("Cannot convert %s to an sdk condition.", c.String())
+	}
+	return c.sdk()
+}
+
+/*sdkComparison builds the sdk condition for one of the comparison operators, i.e. <, <=, =*/
+func sdkComparison(op string, left expression.OperandBuilder, a interface{}) (b expression.ConditionBuilder, err error) {
+	right := expression.Value(a)
+	switch op {
+	case eq:
+		return expression.Equal(left, right), nil
+	case neq:
+		return expression.NotEqual(left, right), nil
+	case lt:
+		return expression.LessThan(left, right), nil
+	case lte:
+		return expression.LessThanEqual(left, right), nil
+	case gt:
+		return expression.GreaterThan(left, right), nil
+	case gte:
+		return expression.GreaterThanEqual(left, right), nil
+	}
+	return b, fmt.Errorf("Cannot convert operator %s to an sdk condition.", op)
+}
+
+/*sdkExpression is a condition built with the aws-sdk's expression package*/
+type sdkExpression struct {
+	expr   string
+	names  map[string]*string
+	values map[string]*dynamodb.AttributeValue
+}
+
+var sdkPlaceholder *regexp.Regexp = regexp.MustCompile("[#:][0-9]+")
+
+/*
+* FromSDKExpression adapts a condition built with the aws-sdk's expression package,
+* so it can be used with SetConditionExpression and SetFilterExpression.
+* The filter is used when the expression has no condition.
+ */
+func FromSDKExpression(e expression.Expression) Expression {
+	expr := e.Condition()
+	if expr == nil {
+		expr = e.Filter()
+	}
+	return sdkExpression{
+		expr:   aws.StringValue(expr),
+		names:  e.Names(),
+		values: e.Values(),
+	}
+}
+
+/*construct renames the sdk's placeholders, i.e. #0 and :0, into this expression's namespace*/
+func (e sdkExpression) construct(prefix string, counter uint, topLevel bool) (string, map[string]*string, map[string]interface{}, uint) {
+	var names map[string]*string
+	var values map[string]interface{}
+	renamed := map[string]string{}
+	s := sdkPlaceholder.ReplaceAllStringFunc(e.expr, func(p string) string {
+		if r, ok := renamed[p]; ok {
+			return r
+		}
+		if n, ok := e.names[p]; ok {
+			if names == nil {
+				names = map[string]*string{}
+			}
+			renamed[p] = generateNamePlaceholder(prefix, counter)
+			names[renamed[p]] = n
+		} else if v, ok := e.values[p]; ok {
+			if values == nil {
+				values = map[string]interface{}{}
+			}
+			renamed[p] = generatePlaceholder(prefix, "", counter)
+			values[renamed[p]] = v
+		} else {
+			return p
+		}
+		counter++
+		return renamed[p]
+	})
+	if !topLevel {
+		s = "(" + s + ")"
+	}
+	return s, names, values, counter
+}
+
+/*ToSDKBuilder always fails, the sdk can't convert a built expression back into a builder*/
+func (e sdkExpression) ToSDKBuilder() (b expression.ConditionBuilder, err error) {
+	return b, fmt.Errorf("Cannot convert %s back to an sdk condition.", e.expr)
+}
+
+/*********************************************************************************/
+/******************************** Debugging **************************************/
+/*********************************************************************************/
+
+/*debugBinaryBytes is how many bytes of a binary value are rendered before truncating*/
+const debugBinaryBytes = 16
+
+/*String renders a key condition with placeholders, as sent to dynamo*/
+func (c KeyCondition) String() string {
+	return c.Condition.String()
+}
+
+/*String renders an update expression with placeholders, i.e. SET loginCount = :update_100*/
+func (u *UpdateExpression) String() string {
+	s, _, _, _ := u.f(100)
+	if s == "" {
+		return ""
+	}
+	return u.op + " " + s
+}
+
+/*DebugString renders the expression with its values inlined. For debugging only, never send it to dynamo*/
+func (c ExpressionGroup) DebugString() string {
+	return debugExpression(c)
+}
+
+/*DebugString renders the expression with its values inlined. For debugging only, never send it to dynamo*/
+func (c negation) DebugString() string {
+	return debugExpression(c)
+}
+
+/*DebugString renders the condition with its values inlined. For debugging only, never send it to dynamo*/
+func (c Condition) DebugString() string {
+	return debugExpression(c)
+}
+
+/*DebugString renders the key condition with its values inlined. For debugging only, never send it to dynamo*/
+func (c KeyCondition) DebugString() string {
+	return debugExpression(c)
+}
+
+/*DebugString renders the update with its values inlined. For debugging only, never send it to dynamo*/
+func (u *UpdateExpression) DebugString() string {
+	s, names, values, _ := u.f(100)
+	if s == "" {
+		return ""
+	}
+	return u.op + " " + debugSubstitute(s, names, debugValues(values))
+}
+
+func debugExpression(e Expression) string {
+	s, names, values, _ := e.construct("debug", 0, true)
+	return debugSubstitute(s, names, debugValues(values))
+}
+
+/*debugValues marshals expression values, rendering any that can't be marshaled as an error marker*/
+func debugValues(m map[string]interface{}) map[string]*dynamodb.AttributeValue {
+	o := map[string]*dynamodb.AttributeValue{}
+	for k, v := range m {
+		if av, ok := v.(*dynamodb.AttributeValue); ok {
+			o[k] = av
+		} else if av, err := dynamodbattribute.Marshal(v); err == nil {
+			o[k] = av
+		} else {
+			o[k] = &dynamodb.AttributeValue{S: aws.String(fmt.Sprintf("<%v>", err))}
+		}
+	}
+	return o
+}
+
+/*debugSubstitute replaces name and value placeholders in s, longest first so :a_10 isn't clobbered by :a_1*/
+func debugSubstitute(s string, names map[string]*string, values map[string]*dynamodb.AttributeValue) string {
+	var pairs []string
+	placeholders := make([]string, 0, len(names)+len(values))
+	for k := range names {
+		placeholders = append(placeholders, k)
+	}
+	for k := range values {
+		placeholders = append(placeholders, k)
+	}
+	sort.Slice(placeholders, func(i, j int) bool {
+		return len(placeholders[i]) > len(placeholders[j])
+	})
+	for _, k := range placeholders {
+		if n, ok := names[k]; ok {
+			pairs = append(pairs, k, aws.StringValue(n))
+		} else {
+			pairs = append(pairs, k, debugValue(values[k]))
+		}
+	}
+	return strings.NewReplacer(pairs...).Replace(s)
+}
+
+/*debugValue renders an attribute value readably: strings quoted, sets in braces, lists in brackets and binaries as truncated hex*/
+func debugValue(av *dynamodb.AttributeValue) string {
+	join := func(open string, items []string, close string) string {
+		return open + strings.Join(items, ", ") + close
+	}
+	switch {
+	case av == nil:
+		return "<nil>"
+	case av.S != nil:
+		return strconv.Quote(*av.S)
+	case av.N != nil:
+		return *av.N
+	case av.B != nil:
+		return debugBinary(av.B)
+	case av.BOOL != nil:
+		return strconv.FormatBool(*av.BOOL)
+	case av.NULL != nil:
+		return "NULL"
+	case av.SS != nil:
+		items := make([]string, len(av.SS))
+		for i, s := range av.SS {
+			items[i] = strconv.Quote(aws.StringValue(s))
+		}
+		return join("{", items, "}")
+	case av.NS != nil:
+		return join("{", aws.StringValueSlice(av.NS), "}")
+	case av.BS != nil:
+		items := make([]string, len(av.BS))
+		for i, b := range av.BS {
+			items[i] = debugBinary(b)
+		}
+		return join("{", items, "}")
+	case av.L != nil:
+		items := make([]string, len(av.L))
+		for i, v := range av.L {
+			items[i] = debugValue(v)
+		}
+		return join("[", items, "]")
+	case av.M != nil:
+		keys := make([]string, 0, len(av.M))
+		for k := range av.M {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		items := make([]string, len(keys))
+		for i, k := range keys {
+			items[i] = k + ": " + debugValue(av.M[k])
+		}
+		return join("{", items, "}")
+	}
+	return "<empty>"
+}
+
+func debugBinary(b []byte) string {
+	if len(b) > debugBinaryBytes {
+		return fmt.Sprintf("0x%x...(%d bytes)", b[:debugBinaryBytes], len(b))
+	}
+	return fmt.Sprintf("0x%x", b)
+}
+
+/***************************************************************************************/
+/********************************** Expression Limits **********************************/
+/***************************************************************************************/
+
+/*Documented DynamoDB expression limits, checked before a request is sent*/
+const (
+	maxExpressionBytes   = 4096
+	maxPlaceholderBytes  = 255
+	maxSubstitutionBytes = 2 * 1024 * 1024
+	maxInOperands        = 100
+	maxUpdateOperators   = 300
+	maxDocumentPathDepth = 32
+)
+
+var (
+	inOperandsRegex   = regexp.MustCompile(`(?i)\bIN\s*\(([^()]*)\)`)
+	updateClauseRegex = regexp.MustCompile(`\b(SET|REMOVE|ADD|DELETE)\s`)
+	updateFuncRegex   = regexp.MustCompile(`\b(if_not_exists|list_append)\(|\s[+-]\s`)
+	documentPathRegex = regexp.MustCompile(`[#\w]+(\.[#\w]+|\[\d+\])+`)
+)
+
+/*
+ExpressionLimitError ... Returned by Build when an expression exceeds one of dynamo's documented limits,
+naming the operation, the expression (key condition, filter, condition, update...) and the limit exceeded
+*/
+type ExpressionLimitError struct {
+	Op         string
+	Expression string
+	Limit      string
+	Value      int
+	Max        int
+}
+
+func (e *ExpressionLimitError) Error() string {
+	return fmt.Sprintf("%s %s expression exceeds the %s limit: %d > %d.", e.Op, e.Expression, e.Limit, e.Value, e.Max)
+}
+
+type namedExpression struct {
+	name       string
+	expression *string
+}
+
+/*validateExpressions checks each expression and the shared placeholder maps against dynamo's limits*/
+func validateExpressions(op string, names map[string]*string, values map[string]*dynamodb.AttributeValue, exprs ...namedExpression) error {
+	for _, e := range exprs {
+		if e.expression == nil {
+			continue
+		}
+		if err := validateExpression(op, e.name, *e.expression); err != nil {
+			return err
+		}
+	}
+
+	limit := func(l string, v, max int) error {
+		if v > max {
+			return &ExpressionLimitError{Op: op, Expression: "attribute", Limit: l, Value: v, Max: max}
+		}
+		return nil
+	}
+	total := 0
+	for k, n := range names {
+		if err := limit("placeholder length", len(k), maxPlaceholderBytes); err != nil {
+			return err
+		}
+		if err := limit("attribute name length", len(aws.StringValue(n)), maxPlaceholderBytes); err != nil {
+			return err
+		}
+		total += len(k) + len(aws.StringValue(n))
+	}
+	for k, v := range values {
+		if err := limit("placeholder length", len(k), maxPlaceholderBytes); err != nil {
+			return err
+		}
+		total += len(k) + attributeSize(v)
+	}
+	return limit("substitution size", total, maxSubstitutionBytes)
+}
+
+func validateExpression(op, name, s string) error {
+	limit := func(l string, v, max int) error {
+		if v > max {
+			return &ExpressionLimitError{Op: op, Expression: name, Limit: l, Value: v, Max: max}
+		}
+		return nil
+	}
+	if err := limit("length", len(s), maxExpressionBytes); err != nil {
+		return err
+	}
+	for _, m := range inOperandsRegex.FindAllStringSubmatch(s, -1) {
+		if err := limit("IN operands", strings.Count(m[1], ",")+1, maxInOperands); err != nil {
+			return err
+		}
+	}
+	for _, p := range documentPathRegex.FindAllString(s, -1) {
+		if err := limit("document path depth", strings.Count(p, ".")+strings.Count(p, "[")+1, maxDocumentPathDepth); err != nil {
+			return err
+		}
+	}
+	if name == "update" {
+		return limit("operators", updateOperators(s), maxUpdateOperators)
+	}
+	return nil
+}
+
+/*updateOperators counts the actions, functions and arithmetic in an update expression*/
+func updateOperators(s string) int {
+	n := len(updateClauseRegex.FindAllString(s, -1)) + len(updateFuncRegex.FindAllString(s, -1))
+	depth := 0
+	for _, c := range s {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+/*attributeSize approximates the encoded size of an attribute value*/
+func attributeSize(av *dynamodb.AttributeValue) (n int) {
+	if av == nil {
+		return 0
+	}
+	n = len(aws.StringValue(av.S)) + len(aws.StringValue(av.N)) + len(av.B)
+	if av.BOOL != nil || av.NULL != nil {
+		n++
+	}
+	for _, s := range av.SS {
+		n += len(aws.StringValue(s))
+	}
+	for _, s := range av.NS {
+		n += len(aws.StringValue(s))
+	}
+	for _, b := range av.BS {
+		n += len(b)
+	}
+	for _, v := range av.L {
+		n += attributeSize(v)
+	}
+	for k, v := range av.M {
+		n += len(k) + attributeSize(v)
+	}
+	return
 }
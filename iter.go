@@ -0,0 +1,25 @@
+//go:build go1.23
+// +build go1.23
+
+package domino
+
+import "iter"
+
+/**
+ ** All ... Adapt the iterator for range over func. Iteration ends with a single nil item and the error, if any.
+ **	for av, err := range out.Iterator().All() {
+ **	}
+ */
+func (it *ResultIterator) All() iter.Seq2[DynamoDBValue, error] {
+	return func(yield func(DynamoDBValue, error) bool) {
+		for it.Next() {
+			if !yield(it.Raw(), nil) {
+				it.stop()
+				return
+			}
+		}
+		if err := it.Err(); err != nil {
+			yield(nil, err)
+		}
+	}
+}